@@ -234,7 +234,6 @@ func newFakeChain(params *chaincfg.Params) *BlockChain {
 	return &BlockChain{
 		chainParams:         params,
 		timeSource:          NewMedianTime(),
-		minRetargetTimespan: targetTimespan / adjustmentFactor,
 		maxRetargetTimespan: targetTimespan * adjustmentFactor,
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               index,
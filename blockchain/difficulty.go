@@ -168,9 +168,11 @@ func (b *BlockChain) calcEasiestDifficulty(bits uint32, duration time.Duration)
 	// than twice the desired amount of time needed to generate a block has
 	// elapsed.
 	if b.chainParams.ReduceMinDifficulty {
-		reductionTime := int64(b.chainParams.MinDiffReductionTime /
-			time.Second)
-		if durationVal > reductionTime {
+		start := time.Unix(0, 0)
+		_, allowed := b.chainParams.MinDifficultyAllowed(
+			start, start.Add(duration), bits,
+		)
+		if allowed {
 			return b.chainParams.PowLimitBits
 		}
 	}
@@ -239,11 +241,12 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 			(b.chainParams.HDCoinType == 390 && lastNode.height < 50000) {
 			// Return minimum difficulty when more than the desired
 			// amount of time has elapsed without mining a block.
-			reductionTime := int64(b.chainParams.MinDiffReductionTime /
-				time.Second)
-			allowMinTime := lastNode.timestamp + reductionTime
-			if newBlockTime.Unix() > allowMinTime {
-				return b.chainParams.PowLimitBits, nil
+			bits, allowed := b.chainParams.MinDifficultyAllowed(
+				time.Unix(lastNode.timestamp, 0), newBlockTime,
+				lastNode.bits,
+			)
+			if allowed {
+				return bits, nil
 			}
 
 			// The block was mined within the desired timeframe, so
@@ -280,36 +283,13 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 	return newTargetBits, nil
 }
 
+// computeNextTarget returns the difficulty bits required after a retarget
+// period which took the given number of seconds to mine, as calculated by
+// CalcNextRequiredBits of the chain params.
 func (b *BlockChain) computeNextTarget(timespanSeconds int64, currentDiff uint32) uint32 {
-	adjustedTimespan := timespanSeconds
-	if timespanSeconds < b.minRetargetTimespan {
-		adjustedTimespan = b.minRetargetTimespan
-	} else if timespanSeconds > b.maxRetargetTimespan {
-		adjustedTimespan = b.maxRetargetTimespan
-	}
-
-	// Calculate new target difficulty as:
-	//  currentDifficulty * (adjustedTimespan / targetTimespan)
-	// The result uses integer division which means it will be slightly
-	// rounded down.  Bitcoind also uses integer division to calculate this
-	// result.
-	oldTarget := CompactToBig(currentDiff)
-	newTarget := new(big.Int).Mul(oldTarget, big.NewInt(adjustedTimespan))
-	targetTimeSpan := int64(b.chainParams.TargetTimespan / time.Second)
-	newTarget.Div(newTarget, big.NewInt(targetTimeSpan))
-
-	// Limit new value to the proof of work limit.
-	if newTarget.Cmp(b.chainParams.PowLimit) > 0 {
-		newTarget.Set(b.chainParams.PowLimit)
-	}
-
-	// Log new target difficulty and return it.  The new target logging is
-	// intentionally converting the bits back to a number instead of using
-	// newTarget since conversion to the compact representation loses
-	// precision.
-	newTargetBits := BigToCompact(newTarget)
-
-	return newTargetBits
+	return b.chainParams.CalcNextRequiredBits(
+		currentDiff, time.Duration(timespanSeconds)*time.Second,
+	)
 }
 
 // ComputeNextTarget ...
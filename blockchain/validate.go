@@ -178,26 +178,6 @@ func isBIP0030Node(node *blockNode) bool {
 	return false
 }
 
-// CalcBlockSubsidy returns the subsidy amount a block at the provided height
-// should have. This is mainly used for determining how much the coinbase for
-// newly generated blocks awards as well as validating the coinbase for blocks
-// has the expected value.
-//
-// The subsidy is halved every SubsidyReductionInterval blocks.  Mathematically
-// this is: baseSubsidy / 2^(height/SubsidyReductionInterval)
-//
-// At the target block generation rate for the main network, this is
-// approximately every 4 years.
-func bitcoinCalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
-	bitcoinBaseSubsidy := 50 * globalcfg.SatoshiPerBitcoin()
-	if chainParams.SubsidyReductionInterval == 0 {
-		return bitcoinBaseSubsidy
-	}
-
-	// Equivalent to: baseSubsidy / 2^(height/subsidyHalvingInterval)
-	return bitcoinBaseSubsidy >> uint(height/chainParams.SubsidyReductionInterval)
-}
-
 // cjdcoinBlocksPerPeriod is the number of blocks which will elapse per payout period
 const cjdcoinBlocksPerPeriod int32 = 144000
 
@@ -208,24 +188,16 @@ func cjdcoinPeriodForBlock(height int32) int32 {
 // cjdcoinCalcBlockSubsidy gets the amount of new money per block during a
 // particular block period. Be careful, this is periods, not block height.
 func cjdcoinCalcBlockSubsidy(period int32) int64 {
-	periods := big.NewInt(int64(period))
-	a := big.NewInt(9)
-	a.Exp(a, periods, nil)
-	a.Mul(a, big.NewInt(int64(4166)*globalcfg.SatoshiPerBitcoin()))
-	b := big.NewInt(10)
-	b.Exp(b, periods, nil)
-	a.Div(a, b)
-	return a.Int64()
+	return chaincfg.PktMainNetParams.BlockSubsidy(period * cjdcoinBlocksPerPeriod)
 }
 
 // CalcBlockSubsidy returns the amount of new money which should be created
 // in the block at the given height. It starts as 4166 cjdcoin per block at block
-// zero and then degrades by 10% every 144000 blocks.
+// zero and then degrades by 10% every 144000 blocks. On networks without a
+// network steward, the subsidy is halved every SubsidyReductionInterval
+// blocks instead.
 func CalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
-	if chainParams.GlobalConf.HasNetworkSteward {
-		return cjdcoinCalcBlockSubsidy(cjdcoinPeriodForBlock(height))
-	}
-	return bitcoinCalcBlockSubsidy(height, chainParams)
+	return chainParams.BlockSubsidy(height)
 }
 
 // PktCalcNetworkStewardPayout gets the amount (of the block subsidy) which needs
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+// The functions below are typed alternatives to WriteBin and ReadBin which
+// make the byte order explicit at the call site, so the byte order and the
+// size of the value can't be mismatched.
+
+// WriteUint16BE writes v to w as 2 big endian bytes.
+func WriteUint16BE(w io.Writer, v uint16) er.R {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	_, err := Write(w, b[:])
+	return err
+}
+
+// WriteUint16LE writes v to w as 2 little endian bytes.
+func WriteUint16LE(w io.Writer, v uint16) er.R {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	_, err := Write(w, b[:])
+	return err
+}
+
+// WriteUint32BE writes v to w as 4 big endian bytes.
+func WriteUint32BE(w io.Writer, v uint32) er.R {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	_, err := Write(w, b[:])
+	return err
+}
+
+// WriteUint32LE writes v to w as 4 little endian bytes.
+func WriteUint32LE(w io.Writer, v uint32) er.R {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	_, err := Write(w, b[:])
+	return err
+}
+
+// WriteUint64BE writes v to w as 8 big endian bytes.
+func WriteUint64BE(w io.Writer, v uint64) er.R {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	_, err := Write(w, b[:])
+	return err
+}
+
+// WriteUint64LE writes v to w as 8 little endian bytes.
+func WriteUint64LE(w io.Writer, v uint64) er.R {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	_, err := Write(w, b[:])
+	return err
+}
+
+// ReadUint16BE reads 2 big endian bytes from r.
+func ReadUint16BE(r io.Reader) (uint16, er.R) {
+	var b [2]byte
+	if _, err := ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
+
+// ReadUint16LE reads 2 little endian bytes from r.
+func ReadUint16LE(r io.Reader) (uint16, er.R) {
+	var b [2]byte
+	if _, err := ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b[:]), nil
+}
+
+// ReadUint32BE reads 4 big endian bytes from r.
+func ReadUint32BE(r io.Reader) (uint32, er.R) {
+	var b [4]byte
+	if _, err := ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// ReadUint32LE reads 4 little endian bytes from r.
+func ReadUint32LE(r io.Reader) (uint32, er.R) {
+	var b [4]byte
+	if _, err := ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+// ReadUint64BE reads 8 big endian bytes from r.
+func ReadUint64BE(r io.Reader) (uint64, er.R) {
+	var b [8]byte
+	if _, err := ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// ReadUint64LE reads 8 little endian bytes from r.
+func ReadUint64LE(r io.Reader) (uint64, er.R) {
+	var b [8]byte
+	if _, err := ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
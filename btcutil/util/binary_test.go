@@ -0,0 +1,56 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTypedBinaryRoundTrip ensures that values written by the typed helpers
+// read back unchanged, and that they agree with the generic WriteBin.
+func TestTypedBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+
+	RequireNoErr(t, WriteUint16BE(&buf, 0x0102))
+	RequireNoErr(t, WriteUint16LE(&buf, 0x0102))
+	RequireNoErr(t, WriteUint32BE(&buf, 0x01020304))
+	RequireNoErr(t, WriteUint32LE(&buf, 0x01020304))
+	RequireNoErr(t, WriteUint64BE(&buf, 0x0102030405060708))
+	RequireNoErr(t, WriteUint64LE(&buf, 0x0102030405060708))
+
+	var generic bytes.Buffer
+	RequireNoErr(t, WriteBin(&generic, binary.BigEndian, uint16(0x0102)))
+	RequireNoErr(t, WriteBin(&generic, binary.LittleEndian, uint16(0x0102)))
+	RequireNoErr(t, WriteBin(&generic, binary.BigEndian, uint32(0x01020304)))
+	RequireNoErr(t, WriteBin(&generic, binary.LittleEndian, uint32(0x01020304)))
+	RequireNoErr(t, WriteBin(&generic, binary.BigEndian, uint64(0x0102030405060708)))
+	RequireNoErr(t, WriteBin(&generic, binary.LittleEndian, uint64(0x0102030405060708)))
+	require.Equal(t, generic.Bytes(), buf.Bytes())
+
+	u16, err := ReadUint16BE(&buf)
+	RequireNoErr(t, err)
+	require.Equal(t, uint16(0x0102), u16)
+	u16, err = ReadUint16LE(&buf)
+	RequireNoErr(t, err)
+	require.Equal(t, uint16(0x0102), u16)
+
+	u32, err := ReadUint32BE(&buf)
+	RequireNoErr(t, err)
+	require.Equal(t, uint32(0x01020304), u32)
+	u32, err = ReadUint32LE(&buf)
+	RequireNoErr(t, err)
+	require.Equal(t, uint32(0x01020304), u32)
+
+	u64, err := ReadUint64BE(&buf)
+	RequireNoErr(t, err)
+	require.Equal(t, uint64(0x0102030405060708), u64)
+	u64, err = ReadUint64LE(&buf)
+	RequireNoErr(t, err)
+	require.Equal(t, uint64(0x0102030405060708), u64)
+
+	// Reading from an exhausted reader must fail.
+	_, err = ReadUint32BE(&buf)
+	RequireErr(t, err)
+}
@@ -61,7 +61,7 @@ func NewWIF(privKey *btcec.PrivateKey, net *chaincfg.Params, compress bool) (*WI
 // IsForNet returns whether or not the decoded WIF structure is associated
 // with the passed bitcoin network.
 func (w *WIF) IsForNet(net *chaincfg.Params) bool {
-	return w.netID == net.PrivateKeyID
+	return net.IsPrivateKeyID(w.netID)
 }
 
 // DecodeWIF creates a new WIF structure by decoding the string encoding of
@@ -6,8 +6,10 @@
 package chaincfg
 
 import (
+	"fmt"
 	"math"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
@@ -56,6 +58,28 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// NewCheckpoint returns a checkpoint at the given height for the block with
+// the given big-endian hex encoded hash.  This may error if the hash can't be
+// parsed.
+func NewCheckpoint(height int32, hashStr string) (Checkpoint, er.R) {
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{Height: height, Hash: hash}, nil
+}
+
+// MustCheckpoint is like NewCheckpoint except it panics if the hash can't be
+// parsed.  It is meant for defining checkpoints from hard-coded, and therefore
+// known good, hashes.
+func MustCheckpoint(height int32, hashStr string) Checkpoint {
+	checkpoint, err := NewCheckpoint(height, hashStr)
+	if err != nil {
+		panic("invalid checkpoint hash: " + err.String())
+	}
+	return checkpoint
+}
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -107,6 +131,14 @@ const (
 	DefinedDeployments
 )
 
+// deploymentNames maps the name of each defined deployment to its ID.  Every
+// deployment ID must have an entry here.
+var deploymentNames = map[string]int{
+	"testdummy": DeploymentTestDummy,
+	"csv":       DeploymentCSV,
+	"segwit":    DeploymentSegwit,
+}
+
 // Params defines a Bitcoin network by its parameters.  These parameters may be
 // used by Bitcoin applications to differentiate networks as well as addresses
 // and keys for one network from those intended for use on another network.
@@ -833,12 +865,33 @@ var (
 	// private extended key is not registered.
 	ErrUnknownHDKeyID = er.GenericErrorType.CodeWithDetail("ErrUnknownHDKeyID",
 		"unknown hd private extended key bytes")
+
+	// ErrUnknownDeployment describes an error where a deployment was
+	// requested by a name which is not known.
+	ErrUnknownDeployment = er.GenericErrorType.CodeWithDetail("ErrUnknownDeployment",
+		"unknown deployment")
+
+	// ErrInvalidParams describes an error where a set of network parameters
+	// violates one of its internal consistency invariants.
+	ErrInvalidParams = er.GenericErrorType.CodeWithDetail("ErrInvalidParams",
+		"inconsistent network parameters")
+
+	// ErrUnknownNet describes an error where a network which is not
+	// registered was requested to be unregistered.
+	ErrUnknownNet = er.GenericErrorType.CodeWithDetail("ErrUnknownNet",
+		"unknown Bitcoin network")
+
+	// ErrUnknownNetName describes an error where network parameters were
+	// requested by a name which is not registered.
+	ErrUnknownNetName = er.GenericErrorType.CodeWithDetail("ErrUnknownNetName",
+		"unknown network name")
 )
 
 var (
-	registeredNets       = make(map[protocol.BitcoinNet]struct{})
-	pubKeyHashAddrIDs    = make(map[byte]struct{})
-	scriptHashAddrIDs    = make(map[byte]struct{})
+	registeredNets       = make(map[protocol.BitcoinNet]*Params)
+	pubKeyHashAddrIDs    = make(map[byte][]string)
+	scriptHashAddrIDs    = make(map[byte][]string)
+	privateKeyIDs        = make(map[byte]struct{})
 	bech32SegwitPrefixes = make(map[string]struct{})
 	hdPrivToPubKeyIDs    = make(map[[4]byte][]byte)
 )
@@ -851,7 +904,7 @@ func (d DNSSeed) String() string {
 // Register registers the network parameters for a Bitcoin network.  This may
 // error with ErrDuplicateNet if the network is already registered (either
 // due to a previous Register call, or the network being one of the default
-// networks).
+// networks), or with ErrInvalidParams if the parameters fail Validate.
 //
 // Network parameters should be registered into this package by a main package
 // as early as possible.  Then, library packages may lookup networks or network
@@ -861,9 +914,12 @@ func Register(params *Params) er.R {
 	if _, ok := registeredNets[params.Net]; ok {
 		return ErrDuplicateNet.Default()
 	}
-	registeredNets[params.Net] = struct{}{}
-	pubKeyHashAddrIDs[params.PubKeyHashAddrID] = struct{}{}
-	scriptHashAddrIDs[params.ScriptHashAddrID] = struct{}{}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	registeredNets[params.Net] = params
+	registerAddrIDs(params)
+	privateKeyIDs[params.PrivateKeyID] = struct{}{}
 	hdPrivToPubKeyIDs[params.HDPrivateKeyID] = params.HDPublicKeyID[:]
 
 	// A valid Bech32 encoded segwit address always has as prefix the
@@ -872,6 +928,109 @@ func Register(params *Params) er.R {
 	return nil
 }
 
+// registerAddrIDs records that the network uses its P2PKH and P2SH address
+// magics.
+func registerAddrIDs(params *Params) {
+	pubKeyHashAddrIDs[params.PubKeyHashAddrID] = append(
+		pubKeyHashAddrIDs[params.PubKeyHashAddrID], params.Name,
+	)
+	scriptHashAddrIDs[params.ScriptHashAddrID] = append(
+		scriptHashAddrIDs[params.ScriptHashAddrID], params.Name,
+	)
+}
+
+// Unregister removes a previously registered network, so that its address
+// encoding magics are no longer considered valid.  Magics which are shared
+// with another registered network remain valid.  This may error with
+// ErrUnknownNet if the network is not registered.
+func Unregister(net protocol.BitcoinNet) er.R {
+	if _, ok := registeredNets[net]; !ok {
+		return ErrUnknownNet.Default()
+	}
+	delete(registeredNets, net)
+
+	// Since several networks may share the same magics, rebuild the
+	// lookup maps from the remaining networks.
+	pubKeyHashAddrIDs = make(map[byte][]string)
+	scriptHashAddrIDs = make(map[byte][]string)
+	privateKeyIDs = make(map[byte]struct{})
+	bech32SegwitPrefixes = make(map[string]struct{})
+	hdPrivToPubKeyIDs = make(map[[4]byte][]byte)
+	for _, params := range registeredNets {
+		registerAddrIDs(params)
+		privateKeyIDs[params.PrivateKeyID] = struct{}{}
+		hdPrivToPubKeyIDs[params.HDPrivateKeyID] = params.HDPublicKeyID[:]
+		bech32SegwitPrefixes[params.Bech32HRPSegwit+"1"] = struct{}{}
+	}
+
+	return nil
+}
+
+// ParamsByName returns the parameters of the registered network with the given
+// name, such as "mainnet" or "cjdcoin".  This may error with ErrUnknownNetName,
+// in which case the error lists the names of all registered networks.
+func ParamsByName(name string) (*Params, er.R) {
+	names := make([]string, 0, len(registeredNets))
+	for _, params := range registeredNets {
+		if params.Name == name {
+			return params, nil
+		}
+		names = append(names, params.Name)
+	}
+	sort.Strings(names)
+
+	return nil, ErrUnknownNetName.New(fmt.Sprintf("%q, known networks "+
+		"are: %s", name, strings.Join(names, ", ")), nil)
+}
+
+// ParamsByNet returns the parameters of the registered network with the given
+// magic.  This may error with ErrUnknownNet.
+func ParamsByNet(net protocol.BitcoinNet) (*Params, er.R) {
+	params, ok := registeredNets[net]
+	if !ok {
+		return nil, ErrUnknownNet.New(net.String(), nil)
+	}
+	return params, nil
+}
+
+// Bech32HRPForNet returns the human-readable part of segwit addresses on the
+// registered network with the given magic.  This may error with ErrUnknownNet.
+func Bech32HRPForNet(net protocol.BitcoinNet) (string, er.R) {
+	params, err := ParamsByNet(net)
+	if err != nil {
+		return "", err
+	}
+	return params.Bech32HRPSegwit, nil
+}
+
+// RegisterPktOnly unregisters the default Bitcoin networks and makes sure the
+// PKT networks are registered, so that only PKT address encodings are
+// considered valid.  Networks registered by the caller are left untouched.
+func RegisterPktOnly() er.R {
+	for _, params := range []*Params{
+		&MainNetParams, &TestNet3Params, &RegressionNetParams,
+		&SimNetParams,
+	} {
+		if _, ok := registeredNets[params.Net]; !ok {
+			continue
+		}
+		if err := Unregister(params.Net); err != nil {
+			return err
+		}
+	}
+
+	for _, params := range []*Params{&PktMainNetParams, &PktTestNetParams} {
+		if _, ok := registeredNets[params.Net]; ok {
+			continue
+		}
+		if err := Register(params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // mustRegister performs the same function as Register except it panics if there
 // is an error.  This should only be called from package init functions.
 func mustRegister(params *Params) {
@@ -880,6 +1039,282 @@ func mustRegister(params *Params) {
 	}
 }
 
+// Validate verifies the internal consistency invariants of the network
+// parameters, in order to catch misconfigured custom networks before they are
+// registered rather than through confusing consensus failures later on.  This
+// may error with ErrInvalidParams.
+//
+// The genesis block is not checked here since it lives in the genesis
+// package, which verifies that it hashes to GenesisHash when registering it.
+func (p *Params) Validate() er.R {
+	invalid := func(format string, args ...interface{}) er.R {
+		return ErrInvalidParams.New(
+			p.Name+": "+fmt.Sprintf(format, args...), nil)
+	}
+
+	if p.Name == "" {
+		return invalid("missing name")
+	}
+	if p.GenesisHash == nil {
+		return invalid("missing genesis hash")
+	}
+
+	// Either of the pow limits may be left unset, as each can be derived
+	// from the other, but not both of them.
+	if p.PowLimit == nil && p.PowLimitBits == 0 {
+		return invalid("missing pow limit")
+	}
+	if p.PowLimit != nil && p.PowLimit.Sign() <= 0 {
+		return invalid("pow limit %v is not positive", p.PowLimit)
+	}
+
+	// The compact pow limit is a truncated encoding of the full pow limit,
+	// so the full limit is compared in its compact encoding.
+	if p.PowLimit != nil && p.PowLimitBits != 0 {
+		bits := difficulty.BigToCompact(p.PowLimit)
+		if bits != p.PowLimitBits {
+			return invalid("pow limit bits %08x do not match pow "+
+				"limit (expected %08x)", p.PowLimitBits, bits)
+		}
+	}
+
+	if p.TargetTimePerBlock <= 0 {
+		return invalid("target time per block %v is not positive",
+			p.TargetTimePerBlock)
+	}
+	if p.TargetTimespan < p.TargetTimePerBlock {
+		return invalid("target timespan %v is shorter than the target "+
+			"time per block %v", p.TargetTimespan,
+			p.TargetTimePerBlock)
+	}
+
+	if p.PubKeyHashAddrID == p.ScriptHashAddrID {
+		return invalid("pubkey hash and script hash address ids are "+
+			"both %02x", p.PubKeyHashAddrID)
+	}
+	if p.HDPrivateKeyID == p.HDPublicKeyID {
+		return invalid("hd private and public key ids are both %x",
+			p.HDPrivateKeyID)
+	}
+
+	// Bech32 human-readable parts must be lowercase printable ascii.
+	if p.Bech32HRPSegwit == "" {
+		return invalid("missing bech32 hrp")
+	}
+	for _, c := range p.Bech32HRPSegwit {
+		if c < 33 || c > 126 || (c >= 'A' && c <= 'Z') {
+			return invalid("invalid bech32 hrp %q", p.Bech32HRPSegwit)
+		}
+	}
+
+	if p.RuleChangeActivationThreshold > p.MinerConfirmationWindow {
+		return invalid("rule change activation threshold %d exceeds "+
+			"miner confirmation window %d",
+			p.RuleChangeActivationThreshold, p.MinerConfirmationWindow)
+	}
+	for i, d := range p.Deployments {
+		// BIP0009 reserves the top 3 bits of the block version.
+		if d.BitNumber >= 29 {
+			return invalid("deployment %d uses bit number %d",
+				i, d.BitNumber)
+		}
+		if d.StartTime > d.ExpireTime {
+			return invalid("deployment %d starts after it expires", i)
+		}
+	}
+
+	return nil
+}
+
+// Deployment returns the consensus deployment with the given name, which is
+// one of "testdummy", "csv" or "segwit" (case insensitive).  This may error
+// with ErrUnknownDeployment.
+func (p *Params) Deployment(name string) (*ConsensusDeployment, er.R) {
+	id, ok := deploymentNames[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownDeployment.New(name, nil)
+	}
+	return &p.Deployments[id], nil
+}
+
+// IsDeploymentDisabled returns whether the consensus deployment with the given
+// name can never start on the network, which is the case when its start time
+// is set to math.MaxInt64.  This may error with ErrUnknownDeployment.
+func (p *Params) IsDeploymentDisabled(name string) (bool, er.R) {
+	d, err := p.Deployment(name)
+	if err != nil {
+		return false, err
+	}
+	return d.StartTime == math.MaxInt64, nil
+}
+
+// pktBlocksPerPeriod is the number of blocks after which the PKT block subsidy
+// is reduced.
+const pktBlocksPerPeriod int32 = 144000
+
+// BlockSubsidy returns the amount of new money, in the smallest unit of the
+// network, which the coinbase of the block at the given height may create.
+//
+// Networks with a network steward start with a subsidy of 4166 coins per block
+// which is reduced by 10% every 144000 blocks, their SubsidyReductionInterval
+// is not used.  For other networks the subsidy starts at 50 coins and halves
+// every SubsidyReductionInterval blocks, or never if the interval isn't
+// positive.  Params without a GlobalConf are assumed to use the units of the
+// globally selected configuration.
+func (p *Params) BlockSubsidy(height int32) int64 {
+	unitsPerCoin := p.GlobalConf.UnitsPerCoin
+	if unitsPerCoin == 0 {
+		unitsPerCoin = globalcfg.SatoshiPerBitcoin()
+	}
+
+	if p.GlobalConf.HasNetworkSteward {
+		// subsidy = 4166 * 0.9^period, computed as 4166 * 9^period /
+		// 10^period to avoid rounding errors.
+		period := big.NewInt(int64(height / pktBlocksPerPeriod))
+		a := new(big.Int).Exp(big.NewInt(9), period, nil)
+		a.Mul(a, big.NewInt(4166*unitsPerCoin))
+		b := new(big.Int).Exp(big.NewInt(10), period, nil)
+		return a.Div(a, b).Int64()
+	}
+
+	baseSubsidy := 50 * unitsPerCoin
+	if p.SubsidyReductionInterval <= 0 {
+		return baseSubsidy
+	}
+	return baseSubsidy >> uint(height/p.SubsidyReductionInterval)
+}
+
+// powLimit returns the proof of work limit of the network, deriving it from
+// PowLimitBits if PowLimit isn't set.
+func (p *Params) powLimit() *big.Int {
+	if p.PowLimit != nil {
+		return p.PowLimit
+	}
+	return difficulty.CompactToBig(p.PowLimitBits)
+}
+
+// CalcNextRequiredBits returns the difficulty bits required after a retarget,
+// given the bits of the last block and the time it took to mine the blocks of
+// the last retarget period.  The timespan is clamped to the TargetTimespan
+// divided or multiplied by the RetargetAdjustmentFactor, and the resulting
+// target is limited to the proof of work limit.  Timespans are only accurate to
+// the second.
+func (p *Params) CalcNextRequiredBits(lastBits uint32,
+	actualTimespan time.Duration) uint32 {
+
+	// Limit the amount of adjustment that can occur to the previous
+	// difficulty.
+	targetTimespan := int64(p.TargetTimespan / time.Second)
+	minTimespan := targetTimespan / p.RetargetAdjustmentFactor
+	maxTimespan := targetTimespan * p.RetargetAdjustmentFactor
+
+	adjustedTimespan := int64(actualTimespan / time.Second)
+	if adjustedTimespan < minTimespan {
+		adjustedTimespan = minTimespan
+	} else if adjustedTimespan > maxTimespan {
+		adjustedTimespan = maxTimespan
+	}
+
+	// Calculate new target difficulty as:
+	//  currentDifficulty * (adjustedTimespan / targetTimespan)
+	// The result uses integer division which means it will be slightly
+	// rounded down.  Bitcoind also uses integer division to calculate this
+	// result.
+	newTarget := difficulty.CompactToBig(lastBits)
+	newTarget.Mul(newTarget, big.NewInt(adjustedTimespan))
+	newTarget.Div(newTarget, big.NewInt(targetTimespan))
+
+	// Limit new value to the proof of work limit.
+	if powLimit := p.powLimit(); newTarget.Cmp(powLimit) > 0 {
+		newTarget.Set(powLimit)
+	}
+
+	return difficulty.BigToCompact(newTarget)
+}
+
+// MinDifficultyAllowed returns whether a block with the given timestamp may
+// be mined at the minimum difficulty, because more than MinDiffReductionTime
+// has passed since the last block, along with the bits required by the rule.
+// Networks without a MinDiffReductionTime never allow it.  Timestamps are only
+// accurate to the second, as in block headers.
+//
+// This only checks the timing of the rule.  It's up to the caller to only
+// apply it where it's in force: on networks with ReduceMinDifficulty set, and
+// only to blocks which aren't at a retarget interval.
+//
+// If the rule doesn't apply, lastBits is returned unchanged.  Note that where
+// the rule is in force, such a block must then have the bits of the last block
+// which wasn't mined at the minimum difficulty, which may differ from lastBits.
+func (p *Params) MinDifficultyAllowed(lastBlockTime, newBlockTime time.Time,
+	lastBits uint32) (uint32, bool) {
+
+	if p.MinDiffReductionTime <= 0 {
+		return lastBits, false
+	}
+
+	// The minimum difficulty is allowed once strictly more than the
+	// reduction time has elapsed without mining a block.
+	reductionTime := int64(p.MinDiffReductionTime / time.Second)
+	allowMinTime := lastBlockTime.Unix() + reductionTime
+	if newBlockTime.Unix() > allowMinTime {
+		return p.PowLimitBits, true
+	}
+
+	return lastBits, false
+}
+
+// AddrType identifies a type of address which a network may support.
+type AddrType int
+
+const (
+	// AddrTypeP2PKH is a pay-to-pubkey-hash address.
+	AddrTypeP2PKH AddrType = iota
+
+	// AddrTypeP2SH is a pay-to-script-hash address.
+	AddrTypeP2SH
+
+	// AddrTypeP2WPKH is a pay-to-witness-pubkey-hash address.
+	AddrTypeP2WPKH
+
+	// AddrTypeP2WSH is a pay-to-witness-script-hash address.
+	AddrTypeP2WSH
+)
+
+// String returns the AddrType as a human-readable name.
+func (t AddrType) String() string {
+	switch t {
+	case AddrTypeP2PKH:
+		return "p2pkh"
+	case AddrTypeP2SH:
+		return "p2sh"
+	case AddrTypeP2WPKH:
+		return "p2wpkh"
+	case AddrTypeP2WSH:
+		return "p2wsh"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
+// SupportedAddressTypes returns the address types which the network supports.
+// Pay-to-pubkey-hash and pay-to-script-hash addresses are always supported,
+// since a zero magic byte is valid for them.  Witness address types are only
+// supported if both their magic byte and the bech32 human-readable part are
+// configured.
+func (p *Params) SupportedAddressTypes() []AddrType {
+	types := []AddrType{AddrTypeP2PKH, AddrTypeP2SH}
+	if p.Bech32HRPSegwit == "" {
+		return types
+	}
+	if p.WitnessPubKeyHashAddrID != 0 {
+		types = append(types, AddrTypeP2WPKH)
+	}
+	if p.WitnessScriptHashAddrID != 0 {
+		types = append(types, AddrTypeP2WSH)
+	}
+	return types
+}
+
 // IsPubKeyHashAddrID returns whether the id is an identifier known to prefix a
 // pay-to-pubkey-hash address on any default or registered network.  This is
 // used when decoding an address string into a specific address type.  It is up
@@ -902,6 +1337,45 @@ func IsScriptHashAddrID(id byte) bool {
 	return ok
 }
 
+// NetworksForPubKeyHashID returns the sorted names of all registered networks
+// whose pay-to-pubkey-hash addresses are prefixed by the id.  If more than one
+// network is returned, an address with this prefix can't be attributed to a
+// single network, which tools may want to warn users about.
+func NetworksForPubKeyHashID(id byte) []string {
+	return sortedNames(pubKeyHashAddrIDs[id])
+}
+
+// NetworksForScriptHashID returns the sorted names of all registered networks
+// whose pay-to-script-hash addresses are prefixed by the id.  If more than one
+// network is returned, an address with this prefix can't be attributed to a
+// single network, which tools may want to warn users about.
+func NetworksForScriptHashID(id byte) []string {
+	return sortedNames(scriptHashAddrIDs[id])
+}
+
+// sortedNames returns a sorted copy of the network names.
+func sortedNames(names []string) []string {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// IsPrivateKeyID returns whether the id is an identifier known to prefix a WIF
+// encoded private key on any default or registered network.  Since several
+// networks share the same identifier, this can't be used on its own to
+// determine the network a key belongs to.
+func IsPrivateKeyID(id byte) bool {
+	_, ok := privateKeyIDs[id]
+	return ok
+}
+
+// IsPrivateKeyID returns whether the id is the identifier which prefixes a WIF
+// encoded private key on this network.
+func (p *Params) IsPrivateKeyID(id byte) bool {
+	return p.PrivateKeyID == id
+}
+
 // IsBech32SegwitPrefix returns whether the prefix is a known prefix for segwit
 // addresses on any default or registered network.  This is used when decoding
 // an address string into a specific address type.
@@ -4,7 +4,12 @@
 
 package chaincfg
 
-import "testing"
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
 
 // TestInvalidHashStr ensures the newShaHashFromStr function panics when used to
 // with an invalid hash string.
@@ -17,6 +22,40 @@ func TestInvalidHashStr(t *testing.T) {
 	newHashFromStr("banana")
 }
 
+// TestNewCheckpoint ensures checkpoints can be created from valid hashes, and
+// that malformed hashes are rejected.
+func TestNewCheckpoint(t *testing.T) {
+	const hashStr = "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"
+
+	checkpoint, err := NewCheckpoint(11111, hashStr)
+	if err != nil {
+		t.Fatalf("unable to create checkpoint: %v", err)
+	}
+	if !reflect.DeepEqual(checkpoint, MainNetParams.Checkpoints[0]) {
+		t.Errorf("expected checkpoint %v, got %v",
+			MainNetParams.Checkpoints[0], checkpoint)
+	}
+	if !reflect.DeepEqual(MustCheckpoint(11111, hashStr), checkpoint) {
+		t.Errorf("MustCheckpoint returned a different checkpoint")
+	}
+
+	for _, malformed := range []string{"banana", hashStr + "00"} {
+		if _, err := NewCheckpoint(1, malformed); err == nil {
+			t.Errorf("expected error for hash %q", malformed)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic for hash %q",
+						malformed)
+				}
+			}()
+			MustCheckpoint(1, malformed)
+		}()
+	}
+}
+
 // TestMustRegisterPanic ensures the mustRegister function panics when used to
 // register an invalid network.
 func TestMustRegisterPanic(t *testing.T) {
@@ -32,3 +71,268 @@ func TestMustRegisterPanic(t *testing.T) {
 	// Intentionally try to register duplicate params to force a panic.
 	mustRegister(&MainNetParams)
 }
+
+// TestValidate ensures the default networks pass validation and that
+// mis-edited params are caught, both by Validate and by Register.
+func TestValidate(t *testing.T) {
+	nets := []*Params{
+		&MainNetParams, &TestNet3Params, &PktTestNetParams,
+		&PktMainNetParams, &RegressionNetParams, &SimNetParams,
+	}
+	for _, p := range nets {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: unexpected validation failure: %v", p.Name,
+				err)
+		}
+	}
+
+	// Either of the pow limits may be derived from the other.
+	p := MainNetParams
+	p.PowLimitBits = 0
+	if err := p.Validate(); err != nil {
+		t.Errorf("unexpected failure without pow limit bits: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(p *Params)
+	}{
+		{"name", func(p *Params) { p.Name = "" }},
+		{"genesis hash", func(p *Params) { p.GenesisHash = nil }},
+		{"pow limit bits", func(p *Params) { p.PowLimitBits++ }},
+		{"missing pow limit", func(p *Params) {
+			p.PowLimit = nil
+			p.PowLimitBits = 0
+		}},
+		{"negative pow limit", func(p *Params) {
+			p.PowLimit = big.NewInt(-1)
+			p.PowLimitBits = 0
+		}},
+		{"target time per block", func(p *Params) {
+			p.TargetTimePerBlock = 0
+		}},
+		{"target timespan", func(p *Params) {
+			p.TargetTimespan = p.TargetTimePerBlock - 1
+		}},
+		{"address ids", func(p *Params) {
+			p.ScriptHashAddrID = p.PubKeyHashAddrID
+		}},
+		{"bech32 hrp", func(p *Params) { p.Bech32HRPSegwit = "BC" }},
+		{"deployment bit", func(p *Params) {
+			p.Deployments[DeploymentTestDummy].BitNumber = 29
+		}},
+	}
+	for _, test := range tests {
+		p := MainNetParams
+		test.mutate(&p)
+		if err := p.Validate(); !ErrInvalidParams.Is(err) {
+			t.Errorf("%s: expected ErrInvalidParams, got %v",
+				test.name, err)
+		}
+
+		// Invalid params can't be registered.
+		p.Net = 1<<32 - 2
+		if err := Register(&p); !ErrInvalidParams.Is(err) {
+			t.Errorf("%s: expected ErrInvalidParams from Register, "+
+				"got %v", test.name, err)
+		}
+	}
+}
+
+// TestSupportedAddressTypes ensures only the address types which are
+// configured for a network are reported as supported.
+func TestSupportedAddressTypes(t *testing.T) {
+	all := []AddrType{
+		AddrTypeP2PKH, AddrTypeP2SH, AddrTypeP2WPKH, AddrTypeP2WSH,
+	}
+	tests := []struct {
+		params *Params
+		want   []AddrType
+	}{
+		{&MainNetParams, all},
+		{&TestNet3Params, all},
+		{&PktTestNetParams, all},
+		{&PktMainNetParams, all},
+		{&SimNetParams, all},
+		{&RegressionNetParams, []AddrType{AddrTypeP2PKH, AddrTypeP2SH}},
+	}
+	for _, test := range tests {
+		got := test.params.SupportedAddressTypes()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.params.Name, got,
+				test.want)
+		}
+	}
+}
+
+// TestDeploymentByName ensures deployments can be looked up by name and that
+// every defined deployment has a name.
+func TestDeploymentByName(t *testing.T) {
+	if len(deploymentNames) != DefinedDeployments {
+		t.Fatalf("%d deployment names for %d deployments",
+			len(deploymentNames), DefinedDeployments)
+	}
+
+	d, err := MainNetParams.Deployment("CSV")
+	if err != nil {
+		t.Fatalf("unable to look up csv deployment: %v", err)
+	}
+	if d != &MainNetParams.Deployments[DeploymentCSV] {
+		t.Fatalf("wrong deployment returned for csv")
+	}
+
+	if _, err := MainNetParams.Deployment("taproot"); !ErrUnknownDeployment.Is(err) {
+		t.Fatalf("expected ErrUnknownDeployment, got %v", err)
+	}
+
+	tests := []struct {
+		params   *Params
+		name     string
+		disabled bool
+	}{
+		{&MainNetParams, "segwit", false},
+		{&PktMainNetParams, "segwit", true},
+		{&PktMainNetParams, "csv", true},
+		{&PktMainNetParams, "testdummy", false},
+	}
+	for _, test := range tests {
+		disabled, err := test.params.IsDeploymentDisabled(test.name)
+		if err != nil {
+			t.Fatalf("%s: unable to check %s: %v", test.params.Name,
+				test.name, err)
+		}
+		if disabled != test.disabled {
+			t.Errorf("%s: %s disabled=%v, want %v", test.params.Name,
+				test.name, disabled, test.disabled)
+		}
+	}
+}
+
+// TestBlockSubsidy ensures the block subsidy follows the halving schedule on
+// Bitcoin networks and the decay schedule on PKT networks.
+func TestBlockSubsidy(t *testing.T) {
+	const (
+		btc = int64(1e8)
+		pkt = int64(0x40000000)
+	)
+
+	noHalving := RegressionNetParams
+	noHalving.SubsidyReductionInterval = -1
+
+	tests := []struct {
+		name   string
+		params *Params
+		height int32
+		want   int64
+	}{
+		{"mainnet genesis", &MainNetParams, 0, 50 * btc},
+		{"mainnet before halving", &MainNetParams, 209999, 50 * btc},
+		{"mainnet first halving", &MainNetParams, 210000, 25 * btc},
+		{"mainnet second halving", &MainNetParams, 420000, 25 * btc / 2},
+		{"regtest first halving", &RegressionNetParams, 150, 25 * btc},
+		{"no halving", &noHalving, 1000000, 50 * btc},
+		{"cjdcoin genesis", &PktMainNetParams, 0, 4166 * pkt},
+		{"cjdcoin end of first period", &PktMainNetParams, 143999, 4166 * pkt},
+		{"cjdcoin second period", &PktMainNetParams, 144000, 4166 * pkt * 9 / 10},
+		{"cjdcoin third period", &PktMainNetParams, 288000, 4166 * pkt * 81 / 100},
+		{"cjdcoin fourth period", &PktMainNetParams, 432000, 4166 * pkt * 729 / 1000},
+		{"cjdcoin testnet ignores interval", &PktTestNetParams, 144000, 4166 * pkt * 9 / 10},
+	}
+
+	for _, test := range tests {
+		if got := test.params.BlockSubsidy(test.height); got != test.want {
+			t.Errorf("%s: got subsidy %d, want %d", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestCalcNextRequiredBits ensures the retarget calculation scales the target
+// by the actual timespan, clamped by the adjustment factor and the pow limit,
+// on both Bitcoin and PKT networks.
+func TestCalcNextRequiredBits(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		params   *Params
+		lastBits uint32
+		timespan time.Duration
+		want     uint32
+	}{
+		{"mainnet on target", &MainNetParams, 0x1b0404cb, 14 * day, 0x1b0404cb},
+		{"mainnet fast", &MainNetParams, 0x1b0404cb, 7 * day, 0x1b020265},
+		{"mainnet slow", &MainNetParams, 0x1b0404cb, 28 * day, 0x1b080996},
+		{"mainnet too fast", &MainNetParams, 0x1b0404cb, time.Hour, 0x1b010132},
+		{"mainnet too slow", &MainNetParams, 0x1b0404cb, 100 * day, 0x1b10132c},
+		{"mainnet slow at pow limit", &MainNetParams, 0x1d00ffff, 28 * day, 0x1d00ffff},
+
+		// PKT retargets every 1.4 days, so a timespan of 14 days is
+		// already clamped by the adjustment factor.
+		{"cjdcoin on target", &PktMainNetParams, 0x1c0404cb, 14 * day / 10, 0x1c0404cb},
+		{"cjdcoin fast", &PktMainNetParams, 0x1c0404cb, 7 * day / 10, 0x1c020265},
+		{"cjdcoin slow", &PktMainNetParams, 0x1c0404cb, 28 * day / 10, 0x1c080996},
+		{"cjdcoin bitcoin timespan", &PktMainNetParams, 0x1c0404cb, 14 * day, 0x1c10132c},
+		{"cjdcoin slow at pow limit", &PktMainNetParams, 0x1f0fffff, 28 * day / 10, 0x1f0fffff},
+	}
+
+	for _, test := range tests {
+		got := test.params.CalcNextRequiredBits(test.lastBits, test.timespan)
+		if got != test.want {
+			t.Errorf("%s: got bits %08x, want %08x", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestMinDifficultyAllowed ensures the minimum difficulty is only allowed on
+// networks with a reduction time, once strictly more than it has passed since
+// the last block.
+func TestMinDifficultyAllowed(t *testing.T) {
+	const lastBits = 0x1b0404cb
+	lastBlockTime := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		name    string
+		params  *Params
+		gap     time.Duration
+		allowed bool
+	}{
+		{"testnet3 under", &TestNet3Params, 20 * time.Minute, false},
+		{"testnet3 over", &TestNet3Params, 20*time.Minute + time.Second, true},
+		{"regtest under", &RegressionNetParams, 20 * time.Minute, false},
+		{"regtest over", &RegressionNetParams, 20*time.Minute + time.Second, true},
+		{"simnet over", &SimNetParams, 20*time.Minute + time.Second, true},
+		{"cjdcoin testnet under", &PktTestNetParams, 2 * time.Minute, false},
+		{"cjdcoin testnet over", &PktTestNetParams, 2*time.Minute + time.Second, true},
+
+		// Sub-second gaps are ignored, as in block headers.
+		{"testnet3 sub-second over", &TestNet3Params, 20*time.Minute + time.Millisecond, false},
+
+		// The rule is timed on networks which only enforced it
+		// during part of their history, while networks without a
+		// reduction time never allow the minimum difficulty.
+		{"cjdcoin mainnet over", &PktMainNetParams, 2*time.Minute + time.Second, true},
+		{"mainnet over", &MainNetParams, 24 * time.Hour, false},
+	}
+
+	for _, test := range tests {
+		bits, allowed := test.params.MinDifficultyAllowed(
+			lastBlockTime, lastBlockTime.Add(test.gap), lastBits,
+		)
+		if allowed != test.allowed {
+			t.Errorf("%s: got allowed %v, want %v", test.name,
+				allowed, test.allowed)
+			continue
+		}
+
+		wantBits := uint32(lastBits)
+		if test.allowed {
+			wantBits = test.params.PowLimitBits
+		}
+		if bits != wantBits {
+			t.Errorf("%s: got bits %08x, want %08x", test.name, bits,
+				wantBits)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package chaincfg_test
 
 import (
 	"bytes"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	. "github.com/kaotisk-hund/cjdcoind/chaincfg"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg/chainhash"
 )
 
 // Define some of the required parameters for a user-registered
@@ -18,8 +21,15 @@ var mockNetParams = Params{
 	PubKeyHashAddrID: 0x9f,
 	ScriptHashAddrID: 0xf9,
 	Bech32HRPSegwit:  "tc",
+	PrivateKeyID:     0x9e,
 	HDPrivateKeyID:   [4]byte{0x01, 0x02, 0x03, 0x04},
 	HDPublicKeyID:    [4]byte{0x05, 0x06, 0x07, 0x08},
+
+	// The parameters must pass Validate to be registered.
+	GenesisHash:        &chainhash.Hash{0x01},
+	PowLimitBits:       0x207fffff,
+	TargetTimespan:     time.Hour * 24 * 14,
+	TargetTimePerBlock: time.Minute * 10,
 }
 
 func TestRegister(t *testing.T) {
@@ -47,6 +57,7 @@ func TestRegister(t *testing.T) {
 		register       []registerTest
 		p2pkhMagics    []magicTest
 		p2shMagics     []magicTest
+		wifMagics      []magicTest
 		segwitPrefixes []prefixTest
 		hdMagics       []hdTest
 	}{
@@ -126,6 +137,32 @@ func TestRegister(t *testing.T) {
 					valid: false,
 				},
 			},
+			wifMagics: []magicTest{
+				{
+					magic: MainNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: TestNet3Params.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: RegressionNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: SimNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: mockNetParams.PrivateKeyID,
+					valid: false,
+				},
+				{
+					magic: 0xFF,
+					valid: false,
+				},
+			},
 			segwitPrefixes: []prefixTest{
 				{
 					prefix: MainNetParams.Bech32HRPSegwit + "1",
@@ -260,6 +297,32 @@ func TestRegister(t *testing.T) {
 					valid: false,
 				},
 			},
+			wifMagics: []magicTest{
+				{
+					magic: MainNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: TestNet3Params.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: RegressionNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: SimNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: mockNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: 0xFF,
+					valid: false,
+				},
+			},
 			segwitPrefixes: []prefixTest{
 				{
 					prefix: MainNetParams.Bech32HRPSegwit + "1",
@@ -387,6 +450,32 @@ func TestRegister(t *testing.T) {
 					valid: false,
 				},
 			},
+			wifMagics: []magicTest{
+				{
+					magic: MainNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: TestNet3Params.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: RegressionNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: SimNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: mockNetParams.PrivateKeyID,
+					valid: true,
+				},
+				{
+					magic: 0xFF,
+					valid: false,
+				},
+			},
 			segwitPrefixes: []prefixTest{
 				{
 					prefix: MainNetParams.Bech32HRPSegwit + "1",
@@ -485,6 +574,13 @@ func TestRegister(t *testing.T) {
 					test.name, i, valid, magTest.valid)
 			}
 		}
+		for i, magTest := range test.wifMagics {
+			valid := IsPrivateKeyID(magTest.magic)
+			if valid != magTest.valid {
+				t.Errorf("%s: WIF magic %d valid mismatch: got %v expected %v",
+					test.name, i, valid, magTest.valid)
+			}
+		}
 		for i, prxTest := range test.segwitPrefixes {
 			valid := IsBech32SegwitPrefix(prxTest.prefix)
 			if valid != prxTest.valid {
@@ -506,3 +602,204 @@ func TestRegister(t *testing.T) {
 		}
 	}
 }
+
+// TestRegisterPktOnly ensures that unregistering the Bitcoin networks removes
+// their magics, while magics shared with the remaining PKT networks stay
+// valid.
+func TestRegisterPktOnly(t *testing.T) {
+	bitcoinNets := []*Params{
+		&MainNetParams, &TestNet3Params, &RegressionNetParams,
+		&SimNetParams,
+	}
+
+	// Restore the default networks for the other tests.
+	defer func() {
+		for _, params := range bitcoinNets {
+			if err := Register(params); err != nil {
+				t.Fatalf("unable to register %s: %v",
+					params.Name, err)
+			}
+		}
+	}()
+
+	if err := RegisterPktOnly(); err != nil {
+		t.Fatalf("RegisterPktOnly failed: %v", err)
+	}
+
+	// Unregistering twice fails.
+	err := Unregister(MainNetParams.Net)
+	if !ErrUnknownNet.Is(err) {
+		t.Fatalf("expected ErrUnknownNet, got %v", err)
+	}
+
+	if IsPubKeyHashAddrID(MainNetParams.PubKeyHashAddrID) {
+		t.Errorf("mainnet P2PKH magic still valid")
+	}
+	if IsPubKeyHashAddrID(SimNetParams.PubKeyHashAddrID) {
+		t.Errorf("simnet P2PKH magic still valid")
+	}
+	if IsPrivateKeyID(MainNetParams.PrivateKeyID) {
+		t.Errorf("mainnet WIF magic still valid")
+	}
+	if IsBech32SegwitPrefix(MainNetParams.Bech32HRPSegwit + "1") {
+		t.Errorf("mainnet segwit prefix still valid")
+	}
+	_, err = HDPrivateKeyToPublicKeyID(MainNetParams.HDPrivateKeyID[:])
+	if !ErrUnknownHDKeyID.Is(err) {
+		t.Errorf("expected ErrUnknownHDKeyID, got %v", err)
+	}
+
+	// The testnet magics are shared with the PKT testnet.
+	if !IsPubKeyHashAddrID(TestNet3Params.PubKeyHashAddrID) {
+		t.Errorf("shared testnet P2PKH magic no longer valid")
+	}
+
+	for _, params := range []*Params{&PktMainNetParams, &PktTestNetParams} {
+		if !IsPubKeyHashAddrID(params.PubKeyHashAddrID) {
+			t.Errorf("%s P2PKH magic not valid", params.Name)
+		}
+		if !IsScriptHashAddrID(params.ScriptHashAddrID) {
+			t.Errorf("%s P2SH magic not valid", params.Name)
+		}
+		if !IsBech32SegwitPrefix(params.Bech32HRPSegwit + "1") {
+			t.Errorf("%s segwit prefix not valid", params.Name)
+		}
+	}
+}
+
+// TestParamsLookup ensures that registered networks can be looked up by name
+// and by magic.
+func TestParamsLookup(t *testing.T) {
+	for _, params := range []*Params{
+		&MainNetParams, &TestNet3Params, &RegressionNetParams,
+		&SimNetParams, &PktMainNetParams, &PktTestNetParams,
+	} {
+		byName, err := ParamsByName(params.Name)
+		if err != nil {
+			t.Fatalf("unable to look up %s by name: %v",
+				params.Name, err)
+		}
+		if byName != params {
+			t.Errorf("%s: wrong params returned by name", params.Name)
+		}
+
+		byNet, err := ParamsByNet(params.Net)
+		if err != nil {
+			t.Fatalf("unable to look up %s by net: %v",
+				params.Name, err)
+		}
+		if byNet != params {
+			t.Errorf("%s: wrong params returned by net", params.Name)
+		}
+	}
+
+	_, err := ParamsByName("nonet")
+	if !ErrUnknownNetName.Is(err) {
+		t.Fatalf("expected ErrUnknownNetName, got %v", err)
+	}
+	if !strings.Contains(err.Message(), "cjdcoin") {
+		t.Errorf("error doesn't list known networks: %v", err)
+	}
+
+	_, err = ParamsByNet(0)
+	if !ErrUnknownNet.Is(err) {
+		t.Fatalf("expected ErrUnknownNet, got %v", err)
+	}
+}
+
+// TestNetworksForAddrID ensures that the networks sharing an address magic are
+// all reported, including the known collision of the Bitcoin and PKT testnets.
+func TestNetworksForAddrID(t *testing.T) {
+	contains := func(names []string, name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, tc := range []struct {
+		name   string
+		lookup func(byte) []string
+		id     byte
+	}{
+		{
+			name:   "P2PKH",
+			lookup: NetworksForPubKeyHashID,
+			id:     TestNet3Params.PubKeyHashAddrID,
+		},
+		{
+			name:   "P2SH",
+			lookup: NetworksForScriptHashID,
+			id:     TestNet3Params.ScriptHashAddrID,
+		},
+	} {
+		names := tc.lookup(tc.id)
+		for _, params := range []*Params{
+			&TestNet3Params, &PktTestNetParams,
+		} {
+			if !contains(names, params.Name) {
+				t.Errorf("%s magic %#x: %s not reported in %v",
+					tc.name, tc.id, params.Name, names)
+			}
+		}
+		if contains(names, MainNetParams.Name) {
+			t.Errorf("%s magic %#x: mainnet reported in %v",
+				tc.name, tc.id, names)
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Errorf("%s magic %#x: names not sorted: %v",
+				tc.name, tc.id, names)
+		}
+
+		// Once testnet3 is unregistered, it's no longer reported.
+		if err := Unregister(TestNet3Params.Net); err != nil {
+			t.Fatalf("unable to unregister testnet3: %v", err)
+		}
+		names = tc.lookup(tc.id)
+		if err := Register(&TestNet3Params); err != nil {
+			t.Fatalf("unable to register testnet3: %v", err)
+		}
+		if contains(names, TestNet3Params.Name) ||
+			!contains(names, PktTestNetParams.Name) {
+
+			t.Errorf("%s magic %#x: unexpected networks after "+
+				"unregistering testnet3: %v", tc.name, tc.id,
+				names)
+		}
+	}
+
+	// A magic which no network uses is not reported.
+	if names := NetworksForPubKeyHashID(0xfe); len(names) != 0 {
+		t.Errorf("unused P2PKH magic reported for %v", names)
+	}
+}
+
+// TestBech32HRPForNet ensures that the segwit HRP of registered networks can be
+// looked up by their magic.
+func TestBech32HRPForNet(t *testing.T) {
+	for _, tc := range []struct {
+		params *Params
+		hrp    string
+	}{
+		{&PktMainNetParams, "cjdcoin"},
+		{&PktTestNetParams, "tpk"},
+		{&MainNetParams, "bc"},
+	} {
+		hrp, err := Bech32HRPForNet(tc.params.Net)
+		if err != nil {
+			t.Fatalf("unable to look up HRP of %s: %v",
+				tc.params.Name, err)
+		}
+		if hrp != tc.hrp {
+			t.Errorf("%s: expected HRP %q, got %q", tc.params.Name,
+				tc.hrp, hrp)
+		}
+	}
+
+	_, err := Bech32HRPForNet(0)
+	if !ErrUnknownNet.Is(err) {
+		t.Fatalf("expected ErrUnknownNet, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package snacl
+
+import (
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// MinTuneN is the lowest scrypt N which TuneScrypt will return, no
+	// matter how slow the local machine is.
+	MinTuneN = DefaultN
+
+	// MaxTuneN is the highest scrypt N which TuneScrypt will return.  With
+	// the default r of 8, this requires 1GiB of memory per derivation.
+	MaxTuneN = 1 << 20
+)
+
+// TuneScrypt benchmarks the local machine and returns scrypt parameters whose
+// key derivation takes roughly targetDuration, without going below the
+// security floor of MinTuneN.  Only N is tuned, r and p are always DefaultR
+// and DefaultP.
+func TuneScrypt(targetDuration time.Duration) (n, r, p int) {
+	return tuneScrypt(targetDuration, measureScrypt)
+}
+
+// measureScrypt returns how long a single scrypt key derivation takes with
+// the given parameters.
+func measureScrypt(n, r, p int) time.Duration {
+	var salt [KeySize]byte
+	start := time.Now()
+	_, _ = scrypt.Key([]byte("tune"), salt[:], n, r, p, KeySize)
+	return time.Since(start)
+}
+
+// tuneScrypt implements TuneScrypt using the passed function to measure the
+// duration of a key derivation.  Since the cost of scrypt scales linearly with
+// N, only the floor is measured and N is doubled for as long as the estimated
+// duration stays within the target.
+func tuneScrypt(targetDuration time.Duration,
+	measure func(n, r, p int) time.Duration) (n, r, p int) {
+
+	n, r, p = MinTuneN, DefaultR, DefaultP
+
+	estimate := measure(n, r, p)
+	for n < MaxTuneN && estimate*2 <= targetDuration {
+		n *= 2
+		estimate *= 2
+	}
+
+	return n, r, p
+}
@@ -0,0 +1,47 @@
+package snacl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTuneScryptFloor(t *testing.T) {
+	// Even an impossibly short target must not go below the floor.
+	n, r, p := TuneScrypt(time.Nanosecond)
+	if n != MinTuneN || r != DefaultR || p != DefaultP {
+		t.Fatalf("got n=%d r=%d p=%d, want n=%d r=%d p=%d", n, r, p,
+			MinTuneN, DefaultR, DefaultP)
+	}
+}
+
+func TestTuneScryptMonotonic(t *testing.T) {
+	// Pretend a derivation takes 10ms per 2^14 of N.
+	measure := func(n, r, p int) time.Duration {
+		return time.Duration(n/MinTuneN) * 10 * time.Millisecond
+	}
+
+	lastN := 0
+	for target := time.Duration(0); target < 2*time.Second; target += 50 * time.Millisecond {
+		n, _, _ := tuneScrypt(target, measure)
+		if n < lastN {
+			t.Fatalf("n decreased from %d to %d at target %v",
+				lastN, n, target)
+		}
+		if n < MinTuneN || n > MaxTuneN {
+			t.Fatalf("n=%d out of bounds at target %v", n, target)
+		}
+		lastN = n
+	}
+
+	// 250ms allows for 2^4 times the floor, which takes 160ms.
+	n, _, _ := tuneScrypt(250*time.Millisecond, measure)
+	if n != MinTuneN<<4 {
+		t.Fatalf("got n=%d, want %d", n, MinTuneN<<4)
+	}
+
+	// A very long target is capped.
+	n, _, _ = tuneScrypt(time.Hour, measure)
+	if n != MaxTuneN {
+		t.Fatalf("got n=%d, want %d", n, MaxTuneN)
+	}
+}
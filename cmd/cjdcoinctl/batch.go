@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/kaotisk-hund/cjdcoind/btcjson"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+// runBatch reads commands from r, one per line in the same form as on the
+// command line, and sends them to the RPC server over a single connection.
+// The response to each command is written to w as a single line of JSON, with
+// the line number of the command as its id.  Commands which can't be created
+// get a response holding the error, and blank lines are skipped.
+//
+// The number of failed commands is returned.  Unless cfg.FailFast is set, a
+// failed command doesn't stop the batch.
+//
+// NOTE: The arguments of the commands are separated by whitespace, so they
+// can't contain any.
+func runBatch(r io.Reader, w io.Writer, cfg *config) (int, er.R) {
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(r)
+	failed := 0
+	for lineNum := 1; ; lineNum++ {
+		line, errr := reader.ReadString('\n')
+		if errr != nil && errr != io.EOF {
+			return failed, er.Errorf("failed to read command: %v",
+				errr)
+		}
+
+		if args := strings.Fields(line); len(args) > 0 {
+			resp := batchCommand(httpClient, cfg, lineNum, args)
+			if err := writeBatchResponse(w, resp); err != nil {
+				return failed, err
+			}
+
+			if resp.Error != nil {
+				failed++
+				if cfg.FailFast {
+					return failed, er.Errorf("command on "+
+						"line %d failed: %s", lineNum,
+						resp.Error.Message)
+				}
+			}
+		}
+
+		if errr == io.EOF {
+			return failed, nil
+		}
+	}
+}
+
+// batchCommand sends the command made of args to the RPC server with id as
+// its JSON-RPC id, and returns the response.  Errors preventing the command
+// from being sent are returned as part of the response.
+func batchCommand(httpClient *http.Client, cfg *config, id int,
+	args []string) *btcjson.Response {
+
+	resp, err := sendBatchCommand(httpClient, cfg, id, args)
+	if err != nil {
+		var respID interface{} = id
+		resp = &btcjson.Response{
+			Error: &btcjson.RPCErr{Message: err.Message()},
+			ID:    &respID,
+		}
+	}
+	return resp
+}
+
+// sendBatchCommand creates the command made of args and sends it to the RPC
+// server with id as its JSON-RPC id.
+func sendBatchCommand(httpClient *http.Client, cfg *config, id int,
+	args []string) (*btcjson.Response, er.R) {
+
+	method := args[0]
+	if err := checkMethod(method); err != nil {
+		return nil, err
+	}
+
+	params := make([]interface{}, 0, len(args[1:]))
+	for _, arg := range args[1:] {
+		params = append(params, arg)
+	}
+	cmd, err := btcjson.NewCmd(method, params...)
+	if err != nil {
+		return nil, er.Errorf("%s command: %v", method, err)
+	}
+
+	marshalledJSON, err := btcjson.MarshalCmd(id, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return postRequest(httpClient, marshalledJSON, cfg, true)
+}
+
+// writeBatchResponse writes the response to w as a single line of JSON.
+func writeBatchResponse(w io.Writer, resp *btcjson.Response) er.R {
+	marshalled, errr := jsoniter.Marshal(resp)
+	if errr != nil {
+		return er.E(errr)
+	}
+
+	var line bytes.Buffer
+	if errr := json.Compact(&line, marshalled); errr != nil {
+		return er.E(errr)
+	}
+	line.WriteByte('\n')
+
+	_, errr = w.Write(line.Bytes())
+	return er.E(errr)
+}
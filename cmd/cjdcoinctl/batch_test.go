@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/kaotisk-hund/cjdcoind/btcjson"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+// newBatchTestServer returns a mock RPC server answering getblockcount and
+// getbestblockhash, and failing any other command.  The number of connections
+// made to it is counted in conns.
+func newBatchTestServer(t *testing.T, conns *int32) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, errr := ioutil.ReadAll(r.Body)
+			if errr != nil {
+				t.Errorf("unable to read request: %v", errr)
+				return
+			}
+			var req btcjson.Request
+			if errr := jsoniter.Unmarshal(body, &req); errr != nil {
+				t.Errorf("unable to decode request: %v", errr)
+				return
+			}
+
+			var result interface{}
+			var rpcErr er.R
+			switch req.Method {
+			case "getblockcount":
+				result = 42
+			case "getbestblockhash":
+				result = "00ab"
+			default:
+				rpcErr = er.New("unsupported")
+			}
+			resp, err := btcjson.MarshalResponse(req.ID, result,
+				rpcErr)
+			if err != nil {
+				t.Errorf("unable to marshal response: %v", err)
+				return
+			}
+			w.Write(resp)
+		}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(conns, 1)
+		}
+	}
+	server.Start()
+	return server
+}
+
+// TestRunBatch ensures the commands read in batch mode are all sent over a
+// single connection, with a response line for each of them.
+func TestRunBatch(t *testing.T) {
+	var conns int32
+	server := newBatchTestServer(t, &conns)
+	defer server.Close()
+
+	input := strings.Join([]string{
+		"getblockcount",
+		"",
+		"getbestblockhash",
+		"notacommand",
+		"getblockhash notanumber",
+		"getdifficulty",
+		"  getblockcount  ",
+	}, "\n")
+
+	tests := []struct {
+		name     string
+		failFast bool
+		ids      []int
+		results  []string
+		errors   []string
+		failed   int
+		err      bool
+	}{
+		{
+			name:    "all commands",
+			ids:     []int{1, 3, 4, 5, 6, 7},
+			results: []string{"42", `"00ab"`, "", "", "", "42"},
+			errors: []string{"", "", "Unrecognized command",
+				"getblockhash command", "unsupported", ""},
+			failed: 3,
+		},
+		{
+			name:     "fail fast",
+			failFast: true,
+			ids:      []int{1, 3, 4},
+			results:  []string{"42", `"00ab"`, ""},
+			errors:   []string{"", "", "Unrecognized command"},
+			failed:   1,
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		atomic.StoreInt32(&conns, 0)
+		cfg := &config{
+			RPCServer: strings.TrimPrefix(server.URL, "http://"),
+			FailFast:  test.failFast,
+		}
+
+		var out bytes.Buffer
+		failed, err := runBatch(strings.NewReader(input), &out, cfg)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if failed != test.failed {
+			t.Errorf("%s: expected %d failed commands, got %d",
+				test.name, test.failed, failed)
+		}
+		if n := atomic.LoadInt32(&conns); n != 1 {
+			t.Errorf("%s: expected a single connection, got %d",
+				test.name, n)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"),
+			"\n")
+		if len(lines) != len(test.ids) {
+			t.Errorf("%s: expected %d response lines, got %d: %q",
+				test.name, len(test.ids), len(lines), lines)
+			continue
+		}
+		for i, line := range lines {
+			var resp struct {
+				Result jsoniter.RawMessage `json:"result"`
+				Error  *btcjson.RPCErr     `json:"error"`
+				ID     int                 `json:"id"`
+			}
+			if errr := jsoniter.Unmarshal([]byte(line), &resp); errr != nil {
+				t.Errorf("%s: invalid response line %q: %v",
+					test.name, line, errr)
+				continue
+			}
+			if resp.ID != test.ids[i] {
+				t.Errorf("%s: expected id %d, got %d", test.name,
+					test.ids[i], resp.ID)
+			}
+			if test.errors[i] == "" {
+				if resp.Error != nil ||
+					string(resp.Result) != test.results[i] {
+
+					t.Errorf("%s: unexpected response %q",
+						test.name, line)
+				}
+				continue
+			}
+			if resp.Error == nil || !strings.Contains(
+				resp.Error.Message, test.errors[i]) {
+
+				t.Errorf("%s: expected error %q, got %q",
+					test.name, test.errors[i], line)
+			}
+		}
+	}
+}
@@ -2,17 +2,14 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
-	jsoniter "github.com/json-iterator/go"
-
 	"github.com/kaotisk-hund/cjdcoind/btcjson"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinconfig/version"
 )
 
@@ -49,6 +46,20 @@ func usage(errorMessage string) {
 	fmt.Fprintln(os.Stderr, listCmdMessage)
 }
 
+// checkMethod returns an error if the method doesn't identify a registered
+// command which is usable from this utility.
+func checkMethod(method string) er.R {
+	usageFlags, err := btcjson.MethodUsageFlags(method)
+	if err != nil {
+		return er.Errorf("Unrecognized command '%s'", method)
+	}
+	if usageFlags&unusableFlags != 0 {
+		return er.Errorf("The '%s' command can only be used via "+
+			"websockets", method)
+	}
+	return nil
+}
+
 func main() {
 	version.SetUserAgentName("cjdcoinctl")
 	cfg, args, err := loadConfig()
@@ -56,6 +67,24 @@ func main() {
 		fmt.Fprintln(os.Stderr, err.String())
 		os.Exit(1)
 	}
+
+	// Execute the commands read from stdin if batch mode was requested.
+	if cfg.Batch {
+		if len(args) > 0 {
+			usage("No command can be specified in batch mode")
+			os.Exit(1)
+		}
+		failed, err := runBatch(os.Stdin, os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(args) < 1 {
 		usage("No command specified")
 		os.Exit(1)
@@ -64,15 +93,8 @@ func main() {
 	// Ensure the specified method identifies a valid registered command and
 	// is one of the usable types.
 	method := args[0]
-	usageFlags, err := btcjson.MethodUsageFlags(method)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unrecognized command '%s'\n", method)
-		fmt.Fprintln(os.Stderr, listCmdMessage)
-		os.Exit(1)
-	}
-	if usageFlags&unusableFlags != 0 {
-		fmt.Fprintf(os.Stderr, "The '%s' command can only be used via "+
-			"websockets\n", method)
+	if err := checkMethod(method); err != nil {
+		fmt.Fprintln(os.Stderr, err.Message())
 		fmt.Fprintln(os.Stderr, listCmdMessage)
 		os.Exit(1)
 	}
@@ -160,28 +182,13 @@ func main() {
 		return
 	}
 
-	// Choose how to display the result based on its type.
-	strResult := string(result.Result)
-	//fmt.Printf("raw result %v", strResult)
-	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
-		var dst bytes.Buffer
-		if err := json.Indent(&dst, result.Result, "", "  "); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to format result: %v",
-				err)
-			os.Exit(1)
-		}
-		fmt.Println(dst.String())
-
-	} else if strings.HasPrefix(strResult, `"`) {
-		var str string
-		if err := jsoniter.Unmarshal(result.Result, &str); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to unmarshal result: %v",
-				err)
-			os.Exit(1)
-		}
-		fmt.Println(str)
-
-	} else if strResult != "null" {
-		fmt.Println(strResult)
+	// Display the result in the requested format.
+	output, err := formatResult(result.Result, cfg.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if output != "" {
+		fmt.Println(output)
 	}
 }
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/kaotisk-hund/cjdcoind/btcjson"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+// completionCommand is a command offered by a shell completion script.
+type completionCommand struct {
+	// name is the method name of the command.
+	name string
+
+	// usage is the one-line usage of the command, which is shown as a hint
+	// of its arguments by the shells supporting descriptions.
+	usage string
+}
+
+// completionFlag is a command line flag offered by a shell completion script.
+type completionFlag struct {
+	short       rune
+	long        string
+	description string
+
+	// hasValue is true if the flag takes a value.
+	hasValue bool
+}
+
+// completionCommands returns the commands usable from this utility, sorted by
+// name.  Wallet commands are only included if useWallet is set, as they are
+// only served when connecting to the wallet.
+func completionCommands(useWallet bool) []completionCommand {
+	var commands []completionCommand
+	for _, method := range btcjson.RegisteredCmdMethods() {
+		flags, err := btcjson.MethodUsageFlags(method)
+		if err != nil || flags&unusableFlags != 0 {
+			continue
+		}
+		if flags&btcjson.UFWalletOnly != 0 && !useWallet {
+			continue
+		}
+
+		usage, err := btcjson.MethodUsageText(method)
+		if err != nil {
+			continue
+		}
+		commands = append(commands, completionCommand{
+			name:  method,
+			usage: usage,
+		})
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].name < commands[j].name
+	})
+	return commands
+}
+
+// completionFlags returns the visible command line flags of this utility.
+func completionFlags() []completionFlag {
+	var cfg config
+	parser := flags.NewParser(&cfg, flags.Default)
+
+	var (
+		result  []completionFlag
+		collect func(g *flags.Group)
+	)
+	collect = func(g *flags.Group) {
+		for _, option := range g.Options() {
+			if option.Hidden {
+				continue
+			}
+
+			_, isBool := option.Value().(bool)
+			result = append(result, completionFlag{
+				short:       option.ShortName,
+				long:        option.LongName,
+				description: option.Description,
+				hasValue:    !isBool,
+			})
+		}
+		for _, group := range g.Groups() {
+			collect(group)
+		}
+	}
+	collect(parser.Group)
+
+	return result
+}
+
+// writeCompletion writes a completion script for the shell, which is one of
+// bash, zsh or fish, to w.
+func writeCompletion(w io.Writer, shell, appName string,
+	useWallet bool) er.R {
+
+	commands := completionCommands(useWallet)
+	flags := completionFlags()
+
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion(appName, commands, flags)
+	case "zsh":
+		script = zshCompletion(appName, commands, flags)
+	case "fish":
+		script = fishCompletion(appName, commands, flags)
+	default:
+		return er.Errorf("unsupported shell %q for completion -- "+
+			"choose one of bash, zsh or fish", shell)
+	}
+
+	_, err := io.WriteString(w, script)
+	return er.E(err)
+}
+
+// bashCompletion returns a bash completion script, completing flags and
+// command names.
+func bashCompletion(appName string, commands []completionCommand,
+	flags []completionFlag) string {
+
+	var allFlagNames, commandNames []string
+	for _, flag := range flags {
+		allFlagNames = append(allFlagNames, flagNames(flag)...)
+	}
+	for _, command := range commands {
+		commandNames = append(commandNames, command.name)
+	}
+
+	funcName := "_" + shellIdentifier(appName)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", appName)
+	fmt.Fprintf(&b, "%s() {\n", funcName)
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "\tlocal flags=\"%s\"\n",
+		strings.Join(allFlagNames, " "))
+	fmt.Fprintf(&b, "\tlocal commands=\"%s\"\n",
+		strings.Join(commandNames, " "))
+	b.WriteString("\tif [[ \"$cur\" == -* ]]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	b.WriteString("\telse\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$commands\" -- \"$cur\"))\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", funcName, appName)
+
+	return b.String()
+}
+
+// zshCompletion returns a zsh completion script, completing flags and command
+// names, with the usage of each command as its description.
+func zshCompletion(appName string, commands []completionCommand,
+	flags []completionFlag) string {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", appName)
+	b.WriteString("local -a commands flags\n")
+
+	b.WriteString("commands=(\n")
+	for _, command := range commands {
+		fmt.Fprintf(&b, "\t%s\n", zshQuote(command.name+":"+
+			command.usage))
+	}
+	b.WriteString(")\n")
+
+	b.WriteString("flags=(\n")
+	for _, flag := range flags {
+		description := strings.NewReplacer(
+			"[", "\\[", "]", "\\]",
+		).Replace(flag.description)
+
+		var value string
+		if flag.hasValue {
+			value = ":value: "
+		}
+		for _, name := range flagNames(flag) {
+			if flag.hasValue && strings.HasPrefix(name, "--") {
+				name += "="
+			}
+			fmt.Fprintf(&b, "\t%s\n", zshQuote(
+				name+"["+description+"]"+value,
+			))
+		}
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("_arguments -s $flags '1:command:->command' " +
+		"'*::argument:_default' && return\n")
+	b.WriteString("case $state in\n")
+	b.WriteString("\tcommand) _describe 'command' commands ;;\n")
+	b.WriteString("esac\n")
+
+	return b.String()
+}
+
+// fishCompletion returns a fish completion script, completing flags and
+// command names, with the usage of each command as its description.
+func fishCompletion(appName string, commands []completionCommand,
+	flags []completionFlag) string {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", appName)
+	fmt.Fprintf(&b, "complete -c %s -f\n", appName)
+	for _, command := range commands {
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand "+
+			"-a %s -d %s\n", appName, command.name,
+			fishQuote(command.usage))
+	}
+	for _, flag := range flags {
+		fmt.Fprintf(&b, "complete -c %s", appName)
+		if flag.short != 0 {
+			fmt.Fprintf(&b, " -s %c", flag.short)
+		}
+		if flag.long != "" {
+			fmt.Fprintf(&b, " -l %s", flag.long)
+		}
+		if flag.hasValue {
+			b.WriteString(" -r")
+		}
+		fmt.Fprintf(&b, " -d %s\n", fishQuote(flag.description))
+	}
+
+	return b.String()
+}
+
+// flagNames returns the short and long names of the flag, including their
+// dashes.
+func flagNames(flag completionFlag) []string {
+	var names []string
+	if flag.short != 0 {
+		names = append(names, "-"+string(flag.short))
+	}
+	if flag.long != "" {
+		names = append(names, "--"+flag.long)
+	}
+	return names
+}
+
+// shellIdentifier returns name with all characters which aren't valid in a
+// shell function name replaced by underscores.
+func shellIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// zshQuote returns s as a single quoted zsh word.
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote returns s as a single quoted fish word.
+func fishQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+	return "'" + s + "'"
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestWriteCompletion ensures the generated completion scripts list the
+// available commands and flags, and only include wallet commands when
+// requested.
+func TestWriteCompletion(t *testing.T) {
+	tests := []struct {
+		name      string
+		shell     string
+		useWallet bool
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name:  "bash",
+			shell: "bash",
+			contains: []string{
+				"complete -F _cjdcoinctl cjdcoinctl",
+				" getblock ", " getblockcount ",
+				" getrawtransaction ", "--rpcuser", "-u ",
+			},
+			excludes: []string{" getbalance ", "--completion"},
+		},
+		{
+			name:      "bash with wallet",
+			shell:     "bash",
+			useWallet: true,
+			contains: []string{
+				" getblockcount ", " getbalance ",
+			},
+		},
+		{
+			name:  "zsh",
+			shell: "zsh",
+			contains: []string{
+				"#compdef cjdcoinctl", "'getblockcount:getblockcount'",
+				"--rpcuser",
+			},
+			excludes: []string{"'getbalance:", "--completion"},
+		},
+		{
+			name:  "fish",
+			shell: "fish",
+			contains: []string{
+				"-a getblockcount", "-s u -l rpcuser -r",
+			},
+			excludes: []string{"-a getbalance ", "-l completion"},
+		},
+	}
+
+	for _, test := range tests {
+		var b bytes.Buffer
+		err := writeCompletion(&b, test.shell, "cjdcoinctl",
+			test.useWallet)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		script := b.String()
+		for _, s := range test.contains {
+			if !strings.Contains(script, s) {
+				t.Errorf("%s: script does not contain %q",
+					test.name, s)
+			}
+		}
+		for _, s := range test.excludes {
+			if strings.Contains(script, s) {
+				t.Errorf("%s: script unexpectedly contains %q",
+					test.name, s)
+			}
+		}
+	}
+}
+
+// TestWriteCompletionUnsupportedShell ensures an error is returned for shells
+// no completion script can be generated for.
+func TestWriteCompletionUnsupportedShell(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeCompletion(&b, "tcsh", "cjdcoinctl", false); err == nil {
+		t.Fatal("expected an error for an unsupported shell")
+	}
+	if b.Len() != 0 {
+		t.Fatalf("unexpected output for an unsupported shell: %q",
+			b.String())
+	}
+}
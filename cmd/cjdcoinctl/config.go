@@ -5,6 +5,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
@@ -25,6 +26,16 @@ const (
 	// able to use.  In particular it doesn't support websockets and
 	// consequently notifications.
 	unusableFlags = btcjson.UFWebsocketOnly | btcjson.UFNotification
+
+	// unixSocketScheme prefixes the path of a Unix domain socket passed as
+	// RPC server address, as in unix:///path/to/sock.
+	unixSocketScheme = "unix://"
+
+	// rpcUserEnvVar and rpcPassEnvVar are the environment variables the
+	// RPC credentials are read from when they are not passed as flags, so
+	// that they don't show up in the process list or shell history.
+	rpcUserEnvVar = "CJDCOIND_RPCUSER"
+	rpcPassEnvVar = "CJDCOIND_RPCPASS"
 )
 
 var (
@@ -97,10 +108,12 @@ type config struct {
 	ShowVersion   bool   `short:"V" long:"version" description:"Display version information and exit"`
 	ListCommands  bool   `short:"l" long:"listcommands" description:"List all of the supported commands and exit"`
 	ConfigFile    string `short:"C" long:"configfile" description:"Path to configuration file"`
-	RPCUser       string `short:"u" long:"rpcuser" description:"RPC username"`
-	RPCPassword   string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
-	RPCServer     string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
+	RPCUser       string `short:"u" long:"rpcuser" description:"RPC username (also read from CJDCOIND_RPCUSER)"`
+	RPCPassword   string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password (also read from CJDCOIND_RPCPASS)"`
+	RPCServer     string `short:"s" long:"rpcserver" description:"RPC server to connect to, or unix:///path/to/sock for a Unix domain socket"`
 	RPCCert       string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
+	ClientCert    string `long:"clientcert" description:"Client certificate to present to the RPC server for mutual TLS authentication"`
+	ClientKey     string `long:"clientkey" description:"Private key of the client certificate"`
 	NoTLS         bool   `long:"notls" description:"Disable TLS"`
 	TLS           bool   `long:"tls" description:"Enable TLS - default false except for wallet"`
 	TestNet3      bool   `long:"testnet" description:"Connect to testnet"`
@@ -110,16 +123,33 @@ type config struct {
 	SimNet        bool   `long:"simnet" description:"Connect to the simulation test network"`
 	TLSSkipVerify bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	Wallet        bool   `long:"wallet" description:"Connect to wallet"`
+	Format        string `long:"format" description:"Output format of the result: json (pretty-printed), raw (as received) or table (for lists of objects)"`
+	Batch         bool   `long:"batch" description:"Execute the commands read from stdin, one per line, over a single connection and print one JSON-RPC response per line"`
+	FailFast      bool   `long:"fail-fast" description:"Stop batch mode at the first command which fails"`
+	Completion    string `long:"completion" hidden:"true" description:"Print a completion script for the shell (bash, zsh or fish) and exit"`
+}
+
+// unixSocketPath returns the path of the Unix domain socket if addr uses the
+// unix:// scheme, and whether it does.
+func unixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixSocketScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, unixSocketScheme), true
 }
 
 // normalizeAddress returns addr with the passed default port appended if
-// there is not already a port specified.
+// there is not already a port specified.  Addresses of Unix domain sockets are
+// returned unchanged.
 func normalizeAddress(addr string,
 	useTestNet3,
 	useSimNet,
 	useBtcMain,
 	usePktTest,
 	useWallet bool) string {
+	if _, ok := unixSocketPath(addr); ok {
+		return addr
+	}
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		var defaultPort string
@@ -161,6 +191,17 @@ func normalizeAddress(addr string,
 	return addr
 }
 
+// defaultRPCCert returns the path of the RPC certificate used to validate the
+// server when none is specified.  Both cjdcoind and cjdcoinwallet write their
+// certificate to the root of their home directory whatever the selected
+// network, so only the server being connected to selects the path.
+func defaultRPCCert(useWallet bool) string {
+	if useWallet {
+		return defaultWalletCertFile
+	}
+	return defaultRPCCertFile
+}
+
 // cleanAndExpandPath expands environement variables and leading ~ in the
 // passed path, cleans the result, and returns it.
 func cleanAndExpandPath(path string) string {
@@ -182,7 +223,9 @@ func cleanAndExpandPath(path string) string {
 // 	1) Start with a default config with sane settings
 // 	2) Pre-parse the command line to check for an alternative config file
 // 	3) Load configuration file overwriting defaults with any specified options
-// 	4) Parse CLI options and overwrite/add any specified options
+// 	4) Overwrite the RPC credentials with the CJDCOIND_RPCUSER and
+// 	   CJDCOIND_RPCPASS environment variables if they are set
+// 	5) Parse CLI options and overwrite/add any specified options
 //
 // The above results in functioning properly without any config settings
 // while still allowing the user to override settings with config files and
@@ -193,6 +236,7 @@ func loadConfig() (*config, []string, er.R) {
 		ConfigFile: defaultConfigFile,
 		RPCServer:  defaultRPCServer,
 		RPCCert:    defaultRPCCertFile,
+		Format:     formatJSON,
 	}
 
 	// Pre-parse the command line options to see if an alternative config
@@ -230,6 +274,19 @@ func loadConfig() (*config, []string, er.R) {
 		os.Exit(0)
 	}
 
+	// Print the completion script for the requested shell and exit if the
+	// associated flag was specified.  Wallet commands are only completed
+	// along with the --wallet flag.
+	if preCfg.Completion != "" {
+		err := writeCompletion(os.Stdout, preCfg.Completion, appName,
+			preCfg.Wallet)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Use config file for RPC server to create default btcctl config
 	var serverConfigPath string
 	if preCfg.Wallet {
@@ -266,6 +323,15 @@ func loadConfig() (*config, []string, er.R) {
 		}
 	}
 
+	// Use the RPC credentials from the environment if they are set.  The
+	// command line options parsed below still take precedence.
+	if user := os.Getenv(rpcUserEnvVar); user != "" {
+		cfg.RPCUser = user
+	}
+	if pass := os.Getenv(rpcPassEnvVar); pass != "" {
+		cfg.RPCPassword = pass
+	}
+
 	// Parse command line options again to ensure they take precedence.
 	remainingArgs, err := parser.Parse()
 	if err != nil {
@@ -291,19 +357,62 @@ func loadConfig() (*config, []string, er.R) {
 		return nil, nil, er.E(err)
 	}
 
+	if !isValidFormat(cfg.Format) {
+		str := "%s: Unknown output format %q -- choose one of " +
+			"json, raw or table"
+		err := fmt.Errorf(str, "loadConfig", cfg.Format)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, er.E(err)
+	}
+
 	if cfg.Wallet && !cfg.NoTLS {
 		cfg.TLS = true
 	}
 
-	// Override the RPC certificate if the --wallet flag was specified and
-	// the user did not specify one.
-	if cfg.Wallet && cfg.RPCCert == defaultRPCCertFile {
-		cfg.RPCCert = defaultWalletCertFile
+	// A Unix domain socket is only reachable locally, so TLS is bypassed
+	// when connecting over one.
+	if path, ok := unixSocketPath(cfg.RPCServer); ok {
+		if path == "" {
+			str := "%s: The unix socket path of the RPC server is " +
+				"empty"
+			err := fmt.Errorf(str, "loadConfig")
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, er.E(err)
+		}
+		cfg.RPCServer = unixSocketScheme + cleanAndExpandPath(path)
+		cfg.TLS = false
+	}
+
+	// Use the certificate of the server being connected to if the user did
+	// not specify one.
+	if cfg.RPCCert == defaultRPCCertFile {
+		cfg.RPCCert = defaultRPCCert(cfg.Wallet)
 	}
 
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
 
+	// A client certificate is useless without its key and vice versa, so
+	// they must be specified together and form a valid key pair.
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		str := "%s: The clientcert and clientkey options must be " +
+			"specified together"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, er.E(err)
+	}
+	if cfg.ClientCert != "" {
+		cfg.ClientCert = cleanAndExpandPath(cfg.ClientCert)
+		cfg.ClientKey = cleanAndExpandPath(cfg.ClientKey)
+		_, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			str := "%s: Unable to load the client certificate: %v"
+			err := fmt.Errorf(str, "loadConfig", err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, er.E(err)
+		}
+	}
+
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
 	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.TestNet3,
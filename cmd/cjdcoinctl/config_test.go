@@ -0,0 +1,379 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNormalizeAddress ensures the default port of the selected network is
+// appended to addresses without one, while Unix socket addresses are left
+// unchanged.
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     string
+		testNet3 bool
+		simNet   bool
+		btcMain  bool
+		pktTest  bool
+		wallet   bool
+		want     string
+	}{
+		{
+			name: "default",
+			addr: "localhost",
+			want: "localhost:64765",
+		},
+		{
+			name:   "default wallet",
+			addr:   "localhost",
+			wallet: true,
+			want:   "localhost:64763",
+		},
+		{
+			name:     "testnet",
+			addr:     "localhost",
+			testNet3: true,
+			want:     "localhost:18334",
+		},
+		{
+			name:   "simnet wallet",
+			addr:   "127.0.0.1",
+			simNet: true,
+			wallet: true,
+			want:   "127.0.0.1:18554",
+		},
+		{
+			name:    "btc mainnet",
+			addr:    "localhost",
+			btcMain: true,
+			want:    "localhost:8334",
+		},
+		{
+			name:    "pkt testnet",
+			addr:    "localhost",
+			pktTest: true,
+			want:    "localhost:64513",
+		},
+		{
+			name: "explicit port",
+			addr: "localhost:1234",
+			want: "localhost:1234",
+		},
+		{
+			name: "ipv6",
+			addr: "::1",
+			want: "[::1]:64765",
+		},
+		{
+			name:   "unix socket",
+			addr:   "unix:///var/run/cjdcoind.sock",
+			wallet: true,
+			want:   "unix:///var/run/cjdcoind.sock",
+		},
+	}
+
+	for _, test := range tests {
+		got := normalizeAddress(test.addr, test.testNet3, test.simNet,
+			test.btcMain, test.pktTest, test.wallet)
+		if got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name,
+				test.want, got)
+		}
+	}
+}
+
+// TestUnixSocketPath ensures the socket path is only extracted from addresses
+// using the unix:// scheme.
+func TestUnixSocketPath(t *testing.T) {
+	tests := []struct {
+		addr string
+		path string
+		ok   bool
+	}{
+		{"unix:///var/run/cjdcoind.sock", "/var/run/cjdcoind.sock", true},
+		{"unix://relative.sock", "relative.sock", true},
+		{"unix://", "", true},
+		{"localhost:64765", "", false},
+		{"unix:/var/run/cjdcoind.sock", "", false},
+	}
+
+	for _, test := range tests {
+		path, ok := unixSocketPath(test.addr)
+		if path != test.path || ok != test.ok {
+			t.Errorf("%q: expected (%q, %v), got (%q, %v)",
+				test.addr, test.path, test.ok, path, ok)
+		}
+	}
+}
+
+// TestSendPostRequestUnixSocket ensures requests are sent over the Unix socket
+// when one is configured.
+func TestSendPostRequestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cjdcoinctl")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockPath := filepath.Join(dir, "rpc.sock")
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("unable to listen on unix socket: %v", err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter,
+			r *http.Request) {
+
+			w.Write([]byte(`{"result":"pong","error":null,"id":1}`))
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	cfg := &config{
+		RPCServer: unixSocketScheme + sockPath,
+	}
+	resp, errr := sendPostRequest([]byte(`{"method":"ping"}`), cfg)
+	if errr != nil {
+		t.Fatalf("unable to send request: %v", errr)
+	}
+	if string(resp.Result) != `"pong"` {
+		t.Fatalf("unexpected result %s", resp.Result)
+	}
+}
+
+// TestLoadConfigRPCCert ensures the default RPC certificate is the one of the
+// server being connected to on every network, while an explicitly specified
+// certificate is kept.
+func TestLoadConfigRPCCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cjdcoinctl")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "custom.cert")
+	networks := []string{"", "--testnet", "--simnet", "--btc",
+		"--cjdcointest", "--cjdcoin"}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "chain",
+			want: defaultRPCCertFile,
+		},
+		{
+			name: "wallet",
+			args: []string{"--wallet"},
+			want: defaultWalletCertFile,
+		},
+		{
+			name: "explicit",
+			args: []string{"--rpccert", certFile},
+			want: certFile,
+		},
+		{
+			name: "explicit wallet",
+			args: []string{"--wallet", "--rpccert", certFile},
+			want: certFile,
+		},
+	}
+
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	for _, test := range tests {
+		for _, network := range networks {
+			os.Args = []string{"cjdcoinctl", "-C",
+				filepath.Join(dir, "cjdcoinctl.conf")}
+			if network != "" {
+				os.Args = append(os.Args, network)
+			}
+			os.Args = append(os.Args, test.args...)
+
+			cfg, _, err := loadConfig()
+			if err != nil {
+				t.Errorf("%s %s: unable to load config: %v",
+					test.name, network, err)
+				continue
+			}
+			if cfg.RPCCert != test.want {
+				t.Errorf("%s %s: expected %q, got %q", test.name,
+					network, test.want, cfg.RPCCert)
+			}
+		}
+	}
+}
+
+// TestLoadConfigClientCert ensures the client certificate and key are only
+// accepted together and when they form a valid key pair.
+func TestLoadConfigClientCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cjdcoinctl")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertPair(t, dir, "client")
+	otherCert, _ := writeCertPair(t, dir, "other")
+
+	tests := []struct {
+		name  string
+		args  []string
+		valid bool
+	}{
+		{
+			name:  "none",
+			valid: true,
+		},
+		{
+			name: "key pair",
+			args: []string{"--clientcert", certFile, "--clientkey",
+				keyFile},
+			valid: true,
+		},
+		{
+			name: "cert only",
+			args: []string{"--clientcert", certFile},
+		},
+		{
+			name: "key only",
+			args: []string{"--clientkey", keyFile},
+		},
+		{
+			name: "mismatched pair",
+			args: []string{"--clientcert", otherCert, "--clientkey",
+				keyFile},
+		},
+		{
+			name: "missing file",
+			args: []string{"--clientcert", certFile, "--clientkey",
+				filepath.Join(dir, "missing.key")},
+		},
+	}
+
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	for _, test := range tests {
+		os.Args = append([]string{"cjdcoinctl", "-C",
+			filepath.Join(dir, "cjdcoinctl.conf")}, test.args...)
+
+		cfg, _, err := loadConfig()
+		if test.valid && err != nil {
+			t.Errorf("%s: unable to load config: %v", test.name, err)
+			continue
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if test.valid && (cfg.ClientCert == "") != (len(test.args) == 0) {
+			t.Errorf("%s: unexpected client cert %q", test.name,
+				cfg.ClientCert)
+		}
+	}
+}
+
+// TestLoadConfigRPCCredentials ensures the RPC credentials are taken from the
+// config file, then the environment and then the command line, each
+// overriding the previous ones.
+func TestLoadConfigRPCCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cjdcoinctl")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "cjdcoinctl.conf")
+	err = ioutil.WriteFile(configFile,
+		[]byte("rpcuser=fileuser\nrpcpass=filepass\n"), 0600)
+	if err != nil {
+		t.Fatalf("unable to write config file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		configFile string
+		envUser    string
+		envPass    string
+		args       []string
+		user       string
+		pass       string
+	}{
+		{
+			name:       "config file",
+			configFile: configFile,
+			user:       "fileuser",
+			pass:       "filepass",
+		},
+		{
+			name:       "env over config file",
+			configFile: configFile,
+			envUser:    "envuser",
+			envPass:    "envpass",
+			user:       "envuser",
+			pass:       "envpass",
+		},
+		{
+			name:       "env password only",
+			configFile: configFile,
+			envPass:    "envpass",
+			user:       "fileuser",
+			pass:       "envpass",
+		},
+		{
+			name:       "flags over env",
+			configFile: configFile,
+			envUser:    "envuser",
+			envPass:    "envpass",
+			args:       []string{"-u", "flaguser", "-P", "flagpass"},
+			user:       "flaguser",
+			pass:       "flagpass",
+		},
+		{
+			name:       "env without config file",
+			configFile: filepath.Join(dir, "missing.conf"),
+			envUser:    "envuser",
+			envPass:    "envpass",
+			user:       "envuser",
+			pass:       "envpass",
+		},
+	}
+
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		os.Unsetenv(rpcUserEnvVar)
+		os.Unsetenv(rpcPassEnvVar)
+	}()
+
+	for _, test := range tests {
+		os.Setenv(rpcUserEnvVar, test.envUser)
+		os.Setenv(rpcPassEnvVar, test.envPass)
+		os.Args = append([]string{"cjdcoinctl", "-C", test.configFile},
+			test.args...)
+
+		cfg, _, err := loadConfig()
+		if err != nil {
+			t.Errorf("%s: unable to load config: %v", test.name, err)
+			continue
+		}
+		if cfg.RPCUser != test.user || cfg.RPCPassword != test.pass {
+			t.Errorf("%s: expected %s/%s, got %s/%s", test.name,
+				test.user, test.pass, cfg.RPCUser,
+				cfg.RPCPassword)
+		}
+	}
+}
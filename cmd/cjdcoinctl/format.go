@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"text/tabwriter"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+const (
+	// formatJSON pretty-prints results which are JSON objects or arrays,
+	// and prints strings without their quotes.
+	formatJSON = "json"
+
+	// formatRaw prints results exactly as they were received.
+	formatRaw = "raw"
+
+	// formatTable prints results which are lists of objects as a table
+	// with a column for each field, and falls back to formatJSON for any
+	// other result.
+	formatTable = "table"
+)
+
+// isValidFormat returns whether format is a known output format.
+func isValidFormat(format string) bool {
+	switch format {
+	case formatJSON, formatRaw, formatTable:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatResult returns the result of an RPC call formatted according to the
+// output format.  An empty string is returned if there is nothing to print.
+func formatResult(result []byte, format string) (string, er.R) {
+	switch format {
+	case formatRaw:
+		return string(result), nil
+
+	case formatTable:
+		table, ok, err := formatTableResult(result)
+		if err != nil || ok {
+			return table, err
+		}
+	}
+
+	return formatJSONResult(result)
+}
+
+// formatJSONResult pretty-prints JSON objects and arrays, and unquotes
+// strings.  Null results yield an empty string.
+func formatJSONResult(result []byte) (string, er.R) {
+	strResult := string(result)
+	switch {
+	case strings.HasPrefix(strResult, "{") ||
+		strings.HasPrefix(strResult, "["):
+
+		var dst bytes.Buffer
+		if err := json.Indent(&dst, result, "", "  "); err != nil {
+			return "", er.Errorf("failed to format result: %v", err)
+		}
+		return dst.String(), nil
+
+	case strings.HasPrefix(strResult, `"`):
+		var str string
+		if err := jsoniter.Unmarshal(result, &str); err != nil {
+			return "", er.Errorf("failed to unmarshal result: %v",
+				err)
+		}
+		return str, nil
+
+	case strResult == "null":
+		return "", nil
+
+	default:
+		return strResult, nil
+	}
+}
+
+// formatTableResult renders a result which is a list of objects as a table with
+// aligned columns.  The columns are the fields of the objects, in the order
+// they first appear, and fields missing from an object are left empty.  The
+// boolean is false if the result isn't a non-empty list of objects.
+func formatTableResult(result []byte) (string, bool, er.R) {
+	var rows []json.RawMessage
+	if err := json.Unmarshal(result, &rows); err != nil || len(rows) == 0 {
+		return "", false, nil
+	}
+
+	var (
+		columns []string
+		known   = make(map[string]struct{})
+		cells   = make([]map[string]string, 0, len(rows))
+	)
+	for _, row := range rows {
+		keys, values, ok := decodeObject(row)
+		if !ok {
+			return "", false, nil
+		}
+
+		rowCells := make(map[string]string, len(keys))
+		for i, key := range keys {
+			if _, ok := known[key]; !ok {
+				known[key] = struct{}{}
+				columns = append(columns, key)
+			}
+			rowCells[key] = formatCell(values[i])
+		}
+		cells = append(cells, rowCells)
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	writeRow := func(fields []string) {
+		// Trailing tabs are left out, so that the last column isn't
+		// padded.
+		w.Write([]byte(strings.Join(fields, "\t") + "\n"))
+	}
+
+	writeRow(columns)
+	for _, rowCells := range cells {
+		fields := make([]string, len(columns))
+		for i, column := range columns {
+			fields[i] = rowCells[column]
+		}
+		writeRow(fields)
+	}
+	if err := w.Flush(); err != nil {
+		return "", false, er.E(err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), true, nil
+}
+
+// decodeObject returns the keys of a JSON object in the order they appear, and
+// their raw values.  The boolean is false if the value isn't a JSON object.
+func decodeObject(raw json.RawMessage) ([]string, []json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, false
+	}
+
+	var (
+		keys   []string
+		values []json.RawMessage
+	)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, nil, false
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, false
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	return keys, values, true
+}
+
+// formatCell renders a JSON value as a table cell.  Strings are unquoted, null
+// values are left empty and nested objects or arrays are kept as compact JSON.
+func formatCell(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+
+	var dst bytes.Buffer
+	if err := json.Compact(&dst, value); err != nil {
+		return string(value)
+	}
+	return dst.String()
+}
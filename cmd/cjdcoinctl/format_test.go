@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+)
+
+// sampleListUnspent is a sample result of listunspent.
+const sampleListUnspent = `[{"txid":"a1b2","vout":0,"address":"pkt1qexample",` +
+	`"amount":1.5,"confirmations":120,"spendable":true},` +
+	`{"txid":"c3d4e5f6","vout":12,"address":"pkt1qother",` +
+	`"amount":1000,"confirmations":3,"spendable":false,` +
+	`"scriptPubKey":"0014abcd"}]`
+
+// TestFormatResult ensures each output format renders sample RPC responses as
+// expected.
+func TestFormatResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		format string
+		want   string
+	}{
+		{
+			name:   "json object",
+			result: `{"chain":"main","blocks":100}`,
+			format: formatJSON,
+			want:   "{\n  \"chain\": \"main\",\n  \"blocks\": 100\n}",
+		},
+		{
+			name:   "json string",
+			result: `"0000abcd"`,
+			format: formatJSON,
+			want:   "0000abcd",
+		},
+		{
+			name:   "json number",
+			result: `42`,
+			format: formatJSON,
+			want:   "42",
+		},
+		{
+			name:   "json null",
+			result: `null`,
+			format: formatJSON,
+			want:   "",
+		},
+		{
+			name:   "raw object",
+			result: `{"chain":"main","blocks":100}`,
+			format: formatRaw,
+			want:   `{"chain":"main","blocks":100}`,
+		},
+		{
+			name:   "raw string",
+			result: `"0000abcd"`,
+			format: formatRaw,
+			want:   `"0000abcd"`,
+		},
+		{
+			name:   "table listunspent",
+			result: sampleListUnspent,
+			format: formatTable,
+			want: "" +
+				"txid      vout  address       amount  confirmations  spendable  scriptPubKey\n" +
+				"a1b2      0     pkt1qexample  1.5     120            true       \n" +
+				"c3d4e5f6  12    pkt1qother    1000    3              false      0014abcd",
+		},
+		{
+			name:   "table nested values",
+			result: `[{"name":"a","tags":["x","y"]},{"name":"bc","tags":null}]`,
+			format: formatTable,
+			want: "" +
+				"name  tags\n" +
+				"a     [\"x\",\"y\"]\n" +
+				"bc    ",
+		},
+		{
+			// Results which aren't lists of objects fall back to
+			// the json format.
+			name:   "table object",
+			result: `{"chain":"main"}`,
+			format: formatTable,
+			want:   "{\n  \"chain\": \"main\"\n}",
+		},
+		{
+			name:   "table list of strings",
+			result: `["a","b"]`,
+			format: formatTable,
+			want:   "[\n  \"a\",\n  \"b\"\n]",
+		},
+		{
+			name:   "table empty list",
+			result: `[]`,
+			format: formatTable,
+			want:   "[]",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := formatResult([]byte(test.result), test.format)
+		if err != nil {
+			t.Fatalf("%s: unable to format result: %v", test.name,
+				err)
+		}
+		if got != test.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name,
+				test.want, got)
+		}
+	}
+}
+
+// TestIsValidFormat ensures only the known output formats are accepted.
+func TestIsValidFormat(t *testing.T) {
+	for _, format := range []string{formatJSON, formatRaw, formatTable} {
+		if !isValidFormat(format) {
+			t.Errorf("format %q not accepted", format)
+		}
+	}
+	for _, format := range []string{"", "JSON", "csv"} {
+		if isValidFormat(format) {
+			t.Errorf("format %q accepted", format)
+		}
+	}
+}
@@ -22,9 +22,22 @@ import (
 func newHTTPClient(cfg *config) (*http.Client, er.R) {
 	var dial func(network, addr string) (net.Conn, error)
 
+	// Dial the Unix domain socket regardless of the address of the
+	// request, if one is configured.
+	if path, ok := unixSocketPath(cfg.RPCServer); ok {
+		dial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
+	}
+
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
-	if cfg.TLS && cfg.RPCCert != "" {
+	if cfg.TLS && (cfg.RPCCert != "" || cfg.ClientCert != "") {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: cfg.TLSSkipVerify,
+		}
+	}
+	if tlsConfig != nil && cfg.RPCCert != "" {
 		pem, err := ioutil.ReadFile(cfg.RPCCert)
 		if err != nil {
 			return nil, er.E(err)
@@ -32,10 +45,18 @@ func newHTTPClient(cfg *config) (*http.Client, er.R) {
 
 		pool := x509.NewCertPool()
 		pool.AppendCertsFromPEM(pem)
-		tlsConfig = &tls.Config{
-			RootCAs:            pool,
-			InsecureSkipVerify: cfg.TLSSkipVerify,
+		tlsConfig.RootCAs = pool
+	}
+
+	// Present the client certificate to servers requiring mutual TLS
+	// authentication.
+	if tlsConfig != nil && cfg.ClientCert != "" {
+		keyPair, err := tls.LoadX509KeyPair(cfg.ClientCert,
+			cfg.ClientKey)
+		if err != nil {
+			return nil, er.E(err)
 		}
+		tlsConfig.Certificates = []tls.Certificate{keyPair}
 	}
 
 	// Create and return the new HTTP client potentially configured with TLS.
@@ -53,30 +74,45 @@ func newHTTPClient(cfg *config) (*http.Client, er.R) {
 // unmarshal the response as a JSON-RPC response and returns either the result
 // field or the error field depending on whether or not there is an error.
 func sendPostRequest(marshalledJSON []byte, cfg *config) (*btcjson.Response, er.R) {
+	// Create the new HTTP client that is configured according to the user-
+	// specified options and submit the request.
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return postRequest(httpClient, marshalledJSON, cfg, false)
+}
+
+// postRequest sends the marshalled JSON-RPC command like sendPostRequest, but
+// using the passed HTTP client.  The connection is left open for further
+// requests with the same client if keepAlive is set.
+func postRequest(httpClient *http.Client, marshalledJSON []byte, cfg *config,
+	keepAlive bool) (*btcjson.Response, er.R) {
+
 	// Generate a request to the configured RPC server.
 	protocol := "http"
 	if cfg.TLS {
 		protocol = "https"
 	}
-	url := protocol + "://" + cfg.RPCServer
+	host := cfg.RPCServer
+	if _, ok := unixSocketPath(cfg.RPCServer); ok {
+		// The host is only used for the Host header, as the socket is
+		// dialed instead.
+		host = "localhost"
+	}
+	url := protocol + "://" + host
 	bodyReader := bytes.NewReader(marshalledJSON)
 	httpRequest, errr := http.NewRequest("POST", url, bodyReader)
 	if errr != nil {
 		return nil, er.E(errr)
 	}
-	httpRequest.Close = true
+	httpRequest.Close = !keepAlive
 	httpRequest.Header.Set("Content-Type", "application/json")
 	httpRequest.Header.Set("X-Pkt-RPC-Version", fmt.Sprintf("%d", version.AppMajorVersion()))
 
 	// Configure basic access authorization.
 	httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
 
-	// Create the new HTTP client that is configured according to the user-
-	// specified options and submit the request.
-	httpClient, err := newHTTPClient(cfg)
-	if err != nil {
-		return nil, err
-	}
 	httpResponse, errr := httpClient.Do(httpRequest)
 	if errr != nil {
 		return nil, er.E(errr)
@@ -85,12 +121,12 @@ func sendPostRequest(marshalledJSON []byte, cfg *config) (*btcjson.Response, er.
 	// Read the raw bytes and close the response.
 	respBytes, errr := ioutil.ReadAll(httpResponse.Body)
 	if errr != nil {
-		err = er.Errorf("error reading json reply: %v", errr)
+		err := er.Errorf("error reading json reply: %v", errr)
 		return nil, err
 	}
 	errrr := httpResponse.Body.Close()
 	if errrr != nil {
-		err = er.Errorf("error closing connection: %v", errrr)
+		err := er.Errorf("error closing connection: %v", errrr)
 		return nil, err
 	}
 
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+)
+
+// writeCertPair writes a newly generated certificate and key to the passed
+// directory and returns their paths.
+func writeCertPair(t *testing.T, dir, name string) (string, string) {
+	cert, key, err := btcutil.NewTLSCertPair("cjdcoinctl",
+		time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("unable to generate cert pair: %v", err)
+	}
+
+	certFile := filepath.Join(dir, name+".cert")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatalf("unable to write cert: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatalf("unable to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// TestNewHTTPClientClientCert ensures the client certificate is added to the
+// TLS config of the client when one is configured.
+func TestNewHTTPClientClientCert(t *testing.T) {
+	dir, errr := ioutil.TempDir("", "cjdcoinctl")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(dir)
+
+	serverCert, _ := writeCertPair(t, dir, "rpc")
+	clientCert, clientKey := writeCertPair(t, dir, "client")
+
+	tests := []struct {
+		name     string
+		cfg      config
+		numCerts int
+	}{
+		{
+			name: "server cert only",
+			cfg: config{
+				TLS:     true,
+				RPCCert: serverCert,
+			},
+		},
+		{
+			name: "client cert",
+			cfg: config{
+				TLS:        true,
+				RPCCert:    serverCert,
+				ClientCert: clientCert,
+				ClientKey:  clientKey,
+			},
+			numCerts: 1,
+		},
+		{
+			name: "client cert without server cert",
+			cfg: config{
+				TLS:        true,
+				ClientCert: clientCert,
+				ClientKey:  clientKey,
+			},
+			numCerts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		client, err := newHTTPClient(&test.cfg)
+		if err != nil {
+			t.Errorf("%s: unable to create client: %v", test.name,
+				err)
+			continue
+		}
+
+		tlsConfig := client.Transport.(*http.Transport).TLSClientConfig
+		if tlsConfig == nil {
+			t.Errorf("%s: TLS config not set", test.name)
+			continue
+		}
+		if len(tlsConfig.Certificates) != test.numCerts {
+			t.Errorf("%s: expected %d client certs, got %d",
+				test.name, test.numCerts,
+				len(tlsConfig.Certificates))
+		}
+	}
+
+	// Without TLS, the client certificate isn't used.
+	cfg := config{
+		ClientCert: clientCert,
+		ClientKey:  clientKey,
+	}
+	client, err := newHTTPClient(&cfg)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if client.Transport.(*http.Transport).TLSClientConfig != nil {
+		t.Fatal("unexpected TLS config without TLS")
+	}
+}
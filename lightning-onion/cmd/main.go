@@ -22,9 +22,57 @@ type OnionHopSpec struct {
 	Payload   string `json:"payload"`
 }
 
+// OnionSpec describes the onion packet to generate.
+//
+// EphemeralKeys optionally lists the hex encoded ephemeral private key of each
+// hop, as found in some test vectors. The first one is used as the session
+// key. Since every hop derives the ephemeral key of the next hop by blinding,
+// the remaining keys can't be chosen freely, so they're checked against the
+// keys derived from the session key.
 type OnionSpec struct {
-	SessionKey string         `json:"session_key,omitempty"`
-	Hops       []OnionHopSpec `json:"hops"`
+	SessionKey    string         `json:"session_key,omitempty"`
+	EphemeralKeys []string       `json:"ephemeral_keys,omitempty"`
+	Hops          []OnionHopSpec `json:"hops"`
+}
+
+// GenerateHopOutput is the JSON description of a single layer of a generated
+// onion packet.
+type GenerateHopOutput struct {
+	PublicKey    string `json:"pubkey"`
+	SharedSecret string `json:"shared_secret,omitempty"`
+	HMAC         string `json:"hmac"`
+}
+
+// GenerateOutput is the JSON output of the generate command.
+type GenerateOutput struct {
+	Hops   []GenerateHopOutput `json:"hops"`
+	Packet string              `json:"packet"`
+}
+
+// ForwardingInfoOutput is the JSON description of the legacy forwarding
+// instructions extracted from an onion packet.
+type ForwardingInfoOutput struct {
+	NextAddress   string `json:"next_address"`
+	ForwardAmount uint64 `json:"forward_amount"`
+	OutgoingCltv  uint32 `json:"outgoing_cltv"`
+}
+
+// DecodeOutput is the JSON output of the decode command.
+type DecodeOutput struct {
+	ForwardingInfo *ForwardingInfoOutput `json:"forwarding_info,omitempty"`
+	Realm          *byte                 `json:"realm,omitempty"`
+	Payload        string                `json:"payload"`
+	IsFinal        bool                  `json:"is_final"`
+	NextPacket     string                `json:"next_packet"`
+}
+
+// printJSON writes the passed value to stdout as a JSON object.
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("Error encoding JSON output: %v", err)
+	}
 }
 
 func parseOnionSpec(spec OnionSpec) (*sphinx.PaymentPath, *btcec.PrivateKey, er.R) {
@@ -45,6 +93,40 @@ func parseOnionSpec(spec OnionSpec) (*sphinx.PaymentPath, *btcec.PrivateKey, er.
 		binSessionKey = bytes.Repeat([]byte{'A'}, 32)
 	}
 
+	if len(spec.Hops) > sphinx.NumMaxHops {
+		return nil, nil, er.Errorf("route has %v hops, at most %v are "+
+			"allowed", len(spec.Hops), sphinx.NumMaxHops)
+	}
+
+	var ephemeralKeys []*btcec.PrivateKey
+	if len(spec.EphemeralKeys) > 0 {
+		if len(spec.EphemeralKeys) != len(spec.Hops) {
+			return nil, nil, er.Errorf("spec has %v ephemeral keys "+
+				"for %v hops", len(spec.EphemeralKeys),
+				len(spec.Hops))
+		}
+
+		for i, hexKey := range spec.EphemeralKeys {
+			binKey, err := util.DecodeHex(hexKey)
+			if err != nil || len(binKey) != 32 {
+				return nil, nil, er.Errorf("ephemeral key %d "+
+					"must be a 32 byte hex string: %v", i,
+					hexKey)
+			}
+
+			key, _ := btcec.PrivKeyFromBytes(btcec.S256(), binKey)
+			ephemeralKeys = append(ephemeralKeys, key)
+		}
+
+		if spec.SessionKey != "" &&
+			!bytes.Equal(binSessionKey, ephemeralKeys[0].Serialize()) {
+
+			return nil, nil, er.Errorf("first ephemeral key must " +
+				"match the session key")
+		}
+		binSessionKey = ephemeralKeys[0].Serialize()
+	}
+
 	sessionKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), binSessionKey)
 
 	for i, hop := range spec.Hops {
@@ -75,6 +157,29 @@ func parseOnionSpec(spec OnionSpec) (*sphinx.PaymentPath, *btcec.PrivateKey, er.
 
 		fmt.Fprintf(os.Stderr, "Node %d pubkey %x\n", i, pubkey.SerializeCompressed())
 	}
+
+	// Make sure the payloads fit within the packet before attempting to
+	// construct it.
+	if _, err := path.TotalPayloadSize(); err != nil {
+		return nil, nil, err
+	}
+
+	if ephemeralKeys != nil {
+		derivedKeys, err := sphinx.EphemeralKeys(&path, sessionKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range ephemeralKeys {
+			if ephemeralKeys[i].D.Cmp(derivedKeys[i].D) != 0 {
+				return nil, nil, er.Errorf("ephemeral key %d "+
+					"doesn't match the key derived by "+
+					"blinding: %x", i,
+					derivedKeys[i].Serialize())
+			}
+		}
+	}
+
 	return &path, sessionKey, nil
 }
 
@@ -82,12 +187,25 @@ func parseOnionSpec(spec OnionSpec) (*sphinx.PaymentPath, *btcec.PrivateKey, er.
 // either generate a fresh mix-header or decode and fully process an existing
 // one given a private key.
 func main() {
-	args := os.Args
+	// The --json flag may appear anywhere on the command line, all other
+	// arguments are positional.
+	var (
+		args       []string
+		jsonOutput bool
+	)
+	for _, arg := range os.Args {
+		if arg == "--json" {
+			jsonOutput = true
+			continue
+		}
+		args = append(args, arg)
+	}
 
 	assocData := bytes.Repeat([]byte{'B'}, 32)
 
 	if len(args) < 3 {
-		fmt.Printf("Usage: %s (generate|decode) <input-file>\n", args[0])
+		fmt.Printf("Usage: %s [--json] (generate|decode) <input-file>\n",
+			args[0])
 		return
 	} else if args[1] == "generate" {
 		var spec OnionSpec
@@ -120,7 +238,44 @@ func main() {
 			log.Fatalf("Error serializing message: %v", err)
 		}
 
-		fmt.Printf("%x\n", w.Bytes())
+		if !jsonOutput {
+			fmt.Printf("%x\n", w.Bytes())
+			return
+		}
+
+		// The shared secrets can only be derived if the caller
+		// supplied the session key.
+		var sharedSecrets []sphinx.Hash256
+		if spec.SessionKey != "" {
+			sharedSecrets, err = sphinx.SharedSecrets(path, sessionKey)
+			if err != nil {
+				log.Fatalf("Error deriving shared secrets: %v", err)
+			}
+		}
+
+		// The HMAC checked by the first hop is the packet's header MAC,
+		// while the HMAC checked by each following hop is the one
+		// included in the payload of the previous hop.
+		output := GenerateOutput{
+			Packet: fmt.Sprintf("%x", w.Bytes()),
+		}
+		hmac := msg.HeaderMAC
+		for i := 0; i < path.TrueRouteLength(); i++ {
+			hop := GenerateHopOutput{
+				PublicKey: fmt.Sprintf("%x",
+					path[i].NodePub.SerializeCompressed()),
+				HMAC: fmt.Sprintf("%x", hmac),
+			}
+			if sharedSecrets != nil {
+				hop.SharedSecret = fmt.Sprintf("%x",
+					sharedSecrets[i])
+			}
+			output.Hops = append(output.Hops, hop)
+
+			hmac = path[i].HopPayload.HMAC
+		}
+
+		printJSON(output)
 	} else if args[1] == "decode" {
 		binKey, err := util.DecodeHex(args[2])
 		if len(binKey) != 32 || err != nil {
@@ -160,6 +315,34 @@ func main() {
 		if err != nil {
 			log.Fatalf("Error serializing message: %v", err)
 		}
-		fmt.Printf("%x\n", w.Bytes())
+
+		if !jsonOutput {
+			fmt.Printf("%x\n", w.Bytes())
+			return
+		}
+
+		output := DecodeOutput{
+			Payload:    fmt.Sprintf("%x", p.Payload.Payload),
+			IsFinal:    p.Action == sphinx.ExitNode,
+			NextPacket: fmt.Sprintf("%x", w.Bytes()),
+		}
+
+		// Only legacy payloads carry a realm and fixed forwarding
+		// instructions, modern payloads are opaque TLV streams.
+		hopData, err := p.Payload.HopData()
+		if err != nil {
+			log.Fatalf("Error parsing hop data: %v", err)
+		}
+		if hopData != nil {
+			output.Realm = &hopData.Realm[0]
+			output.ForwardingInfo = &ForwardingInfoOutput{
+				NextAddress: fmt.Sprintf("%x",
+					hopData.NextAddress[:]),
+				ForwardAmount: hopData.ForwardAmount,
+				OutgoingCltv:  hopData.OutgoingCltv,
+			}
+		}
+
+		printJSON(output)
 	}
 }
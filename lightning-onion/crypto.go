@@ -8,6 +8,7 @@ import (
 	"github.com/aead/chacha20"
 	"github.com/kaotisk-hund/cjdcoind/btcec"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
 const (
@@ -15,8 +16,17 @@ const (
 	// the onion. Any value lower than 32 will truncate the HMAC both
 	// during onion creation as well as during the verification.
 	HMACSize = 32
+
+	// routeBlindingHMACKey is the key type used to derive the factor with
+	// which a node's public key is blinded in a blinded route.
+	routeBlindingHMACKey = "blinded_node_id"
 )
 
+// chaChaPolyZeroNonce is the all-zero nonce used to encrypt the data for
+// each hop of a blinded route. Each hop uses a distinct key, so the nonce is
+// never reused.
+var chaChaPolyZeroNonce [chacha20poly1305.NonceSize]byte
+
 // Hash256 is a statically sized, 32-byte array, typically containing
 // the output of a SHA256 hash.
 type Hash256 [sha256.Size]byte
@@ -84,6 +94,11 @@ type DecryptedError struct {
 
 	// Message is the decrypted error message.
 	Message []byte
+
+	// Route is the ordered list of nodes the error traversed on its way
+	// back to us, starting with the first hop and ending with Sender. Each
+	// entry corresponds to a layer of encryption that was peeled off.
+	Route []*btcec.PublicKey
 }
 
 // zeroHMAC is the special HMAC value that allows the final node to determine
@@ -177,6 +192,35 @@ func blindBaseElement(blindingFactor []byte) *btcec.PublicKey {
 	return &btcec.PublicKey{btcec.S256(), newX, newY}
 }
 
+// chacha20polyEncrypt encrypts the passed plain text using ChaCha20Poly1305
+// with the given key and the all-zero nonce required by the route blinding
+// spec.
+func chacha20polyEncrypt(key, plainTxt []byte) ([]byte, er.R) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, er.E(err)
+	}
+
+	return aead.Seal(nil, chaChaPolyZeroNonce[:], plainTxt, nil), nil
+}
+
+// chacha20polyDecrypt decrypts and authenticates the passed cipher text using
+// ChaCha20Poly1305 with the given key and the all-zero nonce required by the
+// route blinding spec.
+func chacha20polyDecrypt(key, cipherTxt []byte) ([]byte, er.R) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, er.E(err)
+	}
+
+	plainTxt, err := aead.Open(nil, chaChaPolyZeroNonce[:], cipherTxt, nil)
+	if err != nil {
+		return nil, ErrInvalidBlindedHopData.New("", er.E(err))
+	}
+
+	return plainTxt, nil
+}
+
 // sharedSecretGenerator is an interface that abstracts away exactly *how* the
 // shared secret for each hop is generated.
 //
@@ -200,6 +244,41 @@ func (r *Router) generateSharedSecret(dhKey *btcec.PublicKey) (Hash256, er.R) {
 	return r.onionKey.ECDH(dhKey)
 }
 
+// generateBlindedSharedSecret generates the shared secret for an onion packet
+// sent to us as part of a blinded route. The sender only knows our blinded
+// public key, so the ephemeral key is first tweaked with the factor the
+// creator of the route used to blind our key, which is derived from the
+// blinding point. If no blinding point is given, this is equivalent to
+// generateSharedSecret.
+func (r *Router) generateBlindedSharedSecret(dhKey,
+	blindingPoint *btcec.PublicKey) (Hash256, er.R) {
+
+	if blindingPoint == nil {
+		return r.generateSharedSecret(dhKey)
+	}
+
+	// First compute the shared secret with the creator of the route,
+	// ss_receiver = H(k * E_receiver), and from it the factor used to
+	// blind our public key.
+	var ssReceiver Hash256
+	if !btcec.S256().IsOnCurve(blindingPoint.X, blindingPoint.Y) {
+		return ssReceiver, ErrInvalidOnionKey.Default()
+	}
+	ssReceiver, err := r.onionKey.ECDH(blindingPoint)
+	if err != nil {
+		return ssReceiver, err
+	}
+	blindingFactor := generateKey(routeBlindingHMACKey, &ssReceiver)
+
+	// The sender derived its shared secret using our blinded key bf * k.
+	// As scalar multiplication is commutative, we arrive at the same
+	// secret by blinding their ephemeral key instead of our private key:
+	// ss_sender = H(k * (bf * E_sender)).
+	return r.generateSharedSecret(
+		blindGroupElement(dhKey, blindingFactor[:]),
+	)
+}
+
 // onionEncrypt obfuscates the data with compliance with BOLT#4. As we use a
 // stream cipher, calling onionEncrypt on an already encrypted piece of data
 // will decrypt it.
@@ -296,10 +375,16 @@ func (o *OnionErrorDecrypter) DecryptError(encryptedData []byte) (
 		return nil, er.New("unable to retrieve onion failure")
 	}
 
+	// The error passed through every hop up to and including the sender,
+	// which may be the final hop of the route.
+	route := make([]*btcec.PublicKey, sender)
+	copy(route, o.circuit.PaymentPath[:sender])
+
 	return &DecryptedError{
 		SenderIdx: sender,
 		Sender:    o.circuit.PaymentPath[sender-1],
 		Message:   msg,
+		Route:     route,
 	}, nil
 }
 
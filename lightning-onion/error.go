@@ -13,10 +13,10 @@ var (
 	ErrReplayedPacket = Err.CodeWithDetail("ErrReplayedPacket",
 		"sphinx packet replay attempted")
 
-	// ErrInvalidOnionVersion is returned during decoding of the onion
-	// packet, when the received packet has an unknown version byte.
-	ErrInvalidOnionVersion = Err.CodeWithDetail("ErrInvalidOnionVersion",
-		"invalid onion packet version")
+	// ErrUnsupportedOnionVersion is returned when a router is asked to
+	// process an onion packet whose version it doesn't support.
+	ErrUnsupportedOnionVersion = Err.CodeWithDetail("ErrUnsupportedOnionVersion",
+		"unsupported onion packet version")
 
 	// ErrInvalidOnionHMAC is returned during onion parsing process, when received
 	// mac does not corresponds to the generated one.
@@ -32,4 +32,25 @@ var (
 	// log fails because it is missing.
 	ErrLogEntryNotFound = Err.CodeWithDetail("ErrLogEntryNotFound",
 		"sphinx packet is not in log")
+
+	// ErrDuplicateHop is returned when creating an onion packet for a route
+	// which visits the same node more than once, if duplicate hops were
+	// requested to be rejected.
+	ErrDuplicateHop = Err.CodeWithDetail("ErrDuplicateHop",
+		"route contains a duplicate hop")
+
+	// ErrInvalidRouterKey is returned by Router.Ready when the onion key of
+	// the router isn't a valid public key.
+	ErrInvalidRouterKey = Err.CodeWithDetail("ErrInvalidRouterKey",
+		"invalid router onion key")
+
+	// ErrEmptyBlindedPath is returned when attempting to build a blinded
+	// path without any hops.
+	ErrEmptyBlindedPath = Err.CodeWithDetail("ErrEmptyBlindedPath",
+		"a blinded path requires at least one hop")
+
+	// ErrInvalidBlindedHopData is returned when the encrypted data of a
+	// hop in a blinded path can't be decrypted or fails authentication.
+	ErrInvalidBlindedHopData = Err.CodeWithDetail("ErrInvalidBlindedHopData",
+		"invalid blinded hop data")
 )
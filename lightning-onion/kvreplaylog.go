@@ -0,0 +1,309 @@
+package sphinx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
+)
+
+var (
+	// kvSharedHashBucket is the bucket which maps the hash prefix of every
+	// processed packet to the CLTV of its HTLC.
+	kvSharedHashBucket = []byte("sphinx-shared-hash")
+
+	// kvCltvIndexBucket indexes the entries of kvSharedHashBucket by their
+	// CLTV. Keys are the big endian CLTV followed by the hash prefix, so
+	// expired entries can be found by iterating from the start.
+	kvCltvIndexBucket = []byte("sphinx-cltv-index")
+
+	// kvBatchReplayBucket maps batch identifiers to serialized ReplaySets,
+	// which makes processing the same batch twice idempotent.
+	kvBatchReplayBucket = []byte("sphinx-batch-replay")
+)
+
+// ErrKVReplayLogCorrupted signals that the buckets of a KVReplayLog are
+// missing.
+var ErrKVReplayLogCorrupted = Err.CodeWithDetail("ErrKVReplayLogCorrupted",
+	"replay log structure corrupted")
+
+// KVReplayLog is a ReplayLog which persists the hash prefixes of processed
+// packets in a kvdb backend, so replays are still detected after a restart.
+// Entries are never removed automatically, DeleteExpired must be called as
+// the chain progresses to prune entries whose HTLC can no longer be settled.
+type KVReplayLog struct {
+	started int32 // To be used atomically.
+
+	db kvdb.Backend
+}
+
+// NewKVReplayLog creates a new KVReplayLog storing its entries in the given
+// database. The database is owned by the caller, and isn't closed when the log
+// is stopped.
+func NewKVReplayLog(db kvdb.Backend) *KVReplayLog {
+	return &KVReplayLog{
+		db: db,
+	}
+}
+
+// Start creates the buckets used by the log if they don't exist yet.
+func (rl *KVReplayLog) Start() er.R {
+	if !atomic.CompareAndSwapInt32(&rl.started, 0, 1) {
+		return errReplayLogAlreadyStarted.Default()
+	}
+
+	err := kvdb.Update(rl.db, func(tx kvdb.RwTx) er.R {
+		buckets := [][]byte{
+			kvSharedHashBucket, kvCltvIndexBucket,
+			kvBatchReplayBucket,
+		}
+		for _, bucket := range buckets {
+			if _, err := tx.CreateTopLevelBucket(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func() {})
+	if err != nil {
+		atomic.StoreInt32(&rl.started, 0)
+		return err
+	}
+
+	return nil
+}
+
+// Stop stops the log. The underlying database is left open.
+func (rl *KVReplayLog) Stop() er.R {
+	if !atomic.CompareAndSwapInt32(&rl.started, 1, 0) {
+		return errReplayLogNotStarted.Default()
+	}
+
+	return nil
+}
+
+// Started returns true if the log has been started and not stopped since.
+func (rl *KVReplayLog) Started() bool {
+	return atomic.LoadInt32(&rl.started) == 1
+}
+
+// cltvIndexKey returns the key of an entry in the CLTV index.
+func cltvIndexKey(hash *HashPrefix, cltv uint32) []byte {
+	var key [4 + HashPrefixSize]byte
+	binary.BigEndian.PutUint32(key[:4], cltv)
+	copy(key[4:], hash[:])
+
+	return key[:]
+}
+
+// putEntry adds an entry to the shared hash bucket and the CLTV index. It
+// returns ErrReplayedPacket if the hash prefix is already known.
+func putEntry(sharedHashes, cltvIndex kvdb.RwBucket, hash *HashPrefix,
+	cltv uint32) er.R {
+
+	if sharedHashes.Get(hash[:]) != nil {
+		return ErrReplayedPacket.Default()
+	}
+
+	var scratch [4]byte
+	binary.BigEndian.PutUint32(scratch[:], cltv)
+	if err := sharedHashes.Put(hash[:], scratch[:]); err != nil {
+		return err
+	}
+
+	return cltvIndex.Put(cltvIndexKey(hash, cltv), nil)
+}
+
+// Get retrieves an entry from the log given its hash prefix. It returns the
+// value stored and an er.R if one occurs. It returns ErrLogEntryNotFound
+// if the entry is not in the log.
+func (rl *KVReplayLog) Get(hash *HashPrefix) (uint32, er.R) {
+	if !rl.Started() {
+		return 0, errReplayLogNotStarted.Default()
+	}
+
+	var cltv uint32
+	err := kvdb.View(rl.db, func(tx kvdb.RTx) er.R {
+		sharedHashes := tx.ReadBucket(kvSharedHashBucket)
+		if sharedHashes == nil {
+			return ErrKVReplayLogCorrupted.Default()
+		}
+
+		value := sharedHashes.Get(hash[:])
+		if value == nil {
+			return ErrLogEntryNotFound.Default()
+		}
+		cltv = binary.BigEndian.Uint32(value)
+
+		return nil
+	}, func() {
+		cltv = 0
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return cltv, nil
+}
+
+// Put stores an entry into the log given its hash prefix and an accompanying
+// purposefully general type. It returns ErrReplayedPacket if the provided hash
+// prefix already exists in the log.
+func (rl *KVReplayLog) Put(hash *HashPrefix, cltv uint32) er.R {
+	if !rl.Started() {
+		return errReplayLogNotStarted.Default()
+	}
+
+	return kvdb.Batch(rl.db, func(tx kvdb.RwTx) er.R {
+		sharedHashes := tx.ReadWriteBucket(kvSharedHashBucket)
+		cltvIndex := tx.ReadWriteBucket(kvCltvIndexBucket)
+		if sharedHashes == nil || cltvIndex == nil {
+			return ErrKVReplayLogCorrupted.Default()
+		}
+
+		return putEntry(sharedHashes, cltvIndex, hash, cltv)
+	})
+}
+
+// Delete deletes an entry from the log given its hash prefix.
+func (rl *KVReplayLog) Delete(hash *HashPrefix) er.R {
+	if !rl.Started() {
+		return errReplayLogNotStarted.Default()
+	}
+
+	return kvdb.Batch(rl.db, func(tx kvdb.RwTx) er.R {
+		sharedHashes := tx.ReadWriteBucket(kvSharedHashBucket)
+		cltvIndex := tx.ReadWriteBucket(kvCltvIndexBucket)
+		if sharedHashes == nil || cltvIndex == nil {
+			return ErrKVReplayLogCorrupted.Default()
+		}
+
+		value := sharedHashes.Get(hash[:])
+		if value == nil {
+			return nil
+		}
+		cltv := binary.BigEndian.Uint32(value)
+
+		if err := cltvIndex.Delete(cltvIndexKey(hash, cltv)); err != nil {
+			return err
+		}
+
+		return sharedHashes.Delete(hash[:])
+	})
+}
+
+// DeleteExpired deletes all entries with a CLTV below the given height, and
+// returns the number of deleted entries.
+func (rl *KVReplayLog) DeleteExpired(height uint32) (uint32, er.R) {
+	if !rl.Started() {
+		return 0, errReplayLogNotStarted.Default()
+	}
+
+	var numExpired uint32
+	err := kvdb.Batch(rl.db, func(tx kvdb.RwTx) er.R {
+		numExpired = 0
+
+		sharedHashes := tx.ReadWriteBucket(kvSharedHashBucket)
+		cltvIndex := tx.ReadWriteBucket(kvCltvIndexBucket)
+		if sharedHashes == nil || cltvIndex == nil {
+			return ErrKVReplayLogCorrupted.Default()
+		}
+
+		// Collect the expired keys first, as the bucket can't be
+		// modified while iterating over it.
+		var expired [][]byte
+		cursor := cltvIndex.ReadCursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			if binary.BigEndian.Uint32(k[:4]) >= height {
+				break
+			}
+			expired = append(expired, k)
+		}
+
+		for _, k := range expired {
+			if err := sharedHashes.Delete(k[4:]); err != nil {
+				return err
+			}
+			if err := cltvIndex.Delete(k); err != nil {
+				return err
+			}
+			numExpired++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return numExpired, nil
+}
+
+// PutBatch stores a batch of sphinx packets into the log given their hash
+// prefixes and accompanying values. Returns the set of entries in the batch
+// that are replays and an er.R if one occurs.
+//
+// NOTE: As with the other implementations, the result of the first attempt to
+// write a batch is returned for any later attempt with the same batch ID.
+func (rl *KVReplayLog) PutBatch(batch *Batch) (*ReplaySet, er.R) {
+	if !rl.Started() {
+		return nil, errReplayLogNotStarted.Default()
+	}
+
+	var replays *ReplaySet
+	err := kvdb.Batch(rl.db, func(tx kvdb.RwTx) er.R {
+		sharedHashes := tx.ReadWriteBucket(kvSharedHashBucket)
+		cltvIndex := tx.ReadWriteBucket(kvCltvIndexBucket)
+		batchReplays := tx.ReadWriteBucket(kvBatchReplayBucket)
+		if sharedHashes == nil || cltvIndex == nil ||
+			batchReplays == nil {
+
+			return ErrKVReplayLogCorrupted.Default()
+		}
+
+		// If this batch was processed before, return the same result.
+		replays = NewReplaySet()
+		if replayBytes := batchReplays.Get(batch.ID); replayBytes != nil {
+			return replays.Decode(bytes.NewReader(replayBytes))
+		}
+
+		err := batch.ForEach(func(seqNum uint16, hashPrefix *HashPrefix,
+			cltv uint32) er.R {
+
+			err := putEntry(sharedHashes, cltvIndex, hashPrefix, cltv)
+			if ErrReplayedPacket.Is(err) {
+				replays.Add(seqNum)
+				return nil
+			}
+
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		replays.Merge(batch.ReplaySet)
+
+		var replayBuf bytes.Buffer
+		if err := replays.Encode(&replayBuf); err != nil {
+			return err
+		}
+
+		return batchReplays.Put(batch.ID, replayBuf.Bytes())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	batch.ReplaySet = replays
+	batch.IsCommitted = true
+
+	return replays, nil
+}
+
+// A compile time assertion that *KVReplayLog implements the ReplayLog
+// interface.
+var _ ReplayLog = (*KVReplayLog)(nil)
+var _ ReplayLogStatus = (*KVReplayLog)(nil)
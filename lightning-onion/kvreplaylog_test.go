@@ -0,0 +1,131 @@
+package sphinx
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg"
+	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
+)
+
+// openKVReplayLog opens the bolt database in the given directory, and starts a
+// KVReplayLog on top of it.
+func openKVReplayLog(t *testing.T, dir string) (kvdb.Backend, *KVReplayLog) {
+	db, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+		DBPath:     dir,
+		DBFileName: "replay.db",
+	})
+	util.RequireNoErr(t, err)
+
+	rl := NewKVReplayLog(db)
+	util.RequireNoErr(t, rl.Start())
+
+	return db, rl
+}
+
+// TestKVReplayLogPersistence tests that a packet processed before the database
+// is reopened is still rejected as a replay afterwards, until it's pruned.
+func TestKVReplayLogPersistence(t *testing.T) {
+	dir, errr := ioutil.TempDir("", "kvreplaylog")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(dir)
+
+	nodes, _, _, fwdMsg, err := newTestRoute(1)
+	util.RequireNoErr(t, err)
+
+	const cltv = 100
+
+	db, rl := openKVReplayLog(t, dir)
+	router := NewRouter(nodes[0].onionKey, &chaincfg.MainNetParams, rl)
+
+	_, err = router.ProcessOnionPacket(fwdMsg, nil, cltv)
+	util.RequireNoErr(t, err)
+
+	util.RequireNoErr(t, rl.Stop())
+	util.RequireNoErr(t, db.Close())
+
+	// After reopening the database, the packet is recognized as a replay.
+	db, rl = openKVReplayLog(t, dir)
+	defer db.Close()
+	defer rl.Stop()
+
+	router = NewRouter(nodes[0].onionKey, &chaincfg.MainNetParams, rl)
+	_, err = router.ProcessOnionPacket(fwdMsg, nil, cltv)
+	if !ErrReplayedPacket.Is(err) {
+		t.Fatalf("expected ErrReplayedPacket, got %v", err)
+	}
+
+	// Pruning at the CLTV height keeps the entry.
+	numExpired, err := rl.DeleteExpired(cltv)
+	util.RequireNoErr(t, err)
+	if numExpired != 0 {
+		t.Fatalf("expected no expired entries, got %d", numExpired)
+	}
+
+	// Once the CLTV has passed, the entry is pruned.
+	numExpired, err = rl.DeleteExpired(cltv + 1)
+	util.RequireNoErr(t, err)
+	if numExpired != 1 {
+		t.Fatalf("expected 1 expired entry, got %d", numExpired)
+	}
+
+	_, err = router.ProcessOnionPacket(fwdMsg, nil, cltv)
+	util.RequireNoErr(t, err)
+}
+
+// TestKVReplayLogBatch tests that batches are idempotent and detect replays
+// of entries stored outside of the batch.
+func TestKVReplayLogBatch(t *testing.T) {
+	dir, errr := ioutil.TempDir("", "kvreplaylog")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(dir)
+
+	db, rl := openKVReplayLog(t, dir)
+	defer db.Close()
+	defer rl.Stop()
+
+	var hash1, hash2 HashPrefix
+	hash1[0] = 1
+	hash2[0] = 2
+
+	util.RequireNoErr(t, rl.Put(&hash1, 10))
+
+	batch := NewBatch([]byte("batch"))
+	util.RequireNoErr(t, batch.Put(0, &hash1, 10))
+	util.RequireNoErr(t, batch.Put(1, &hash2, 20))
+
+	replays, err := rl.PutBatch(batch)
+	util.RequireNoErr(t, err)
+	if !replays.Contains(0) || replays.Contains(1) {
+		t.Fatalf("unexpected replay set: %v", replays)
+	}
+
+	cltv, err := rl.Get(&hash2)
+	util.RequireNoErr(t, err)
+	if cltv != 20 {
+		t.Fatalf("expected cltv 20, got %d", cltv)
+	}
+
+	// Writing the same batch again returns the original result.
+	batch = NewBatch([]byte("batch"))
+	util.RequireNoErr(t, batch.Put(0, &hash1, 10))
+	util.RequireNoErr(t, batch.Put(1, &hash2, 20))
+
+	replays, err = rl.PutBatch(batch)
+	util.RequireNoErr(t, err)
+	if !replays.Contains(0) || replays.Contains(1) {
+		t.Fatalf("unexpected replay set: %v", replays)
+	}
+
+	util.RequireNoErr(t, rl.Delete(&hash2))
+	_, err = rl.Get(&hash2)
+	if !ErrLogEntryNotFound.Is(err) {
+		t.Fatalf("expected ErrLogEntryNotFound, got %v", err)
+	}
+}
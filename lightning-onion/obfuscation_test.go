@@ -87,6 +87,71 @@ func TestOnionFailure(t *testing.T) {
 	}
 }
 
+// TestOnionFailureRoute checks that the decrypted onion error records the full
+// route the error traversed, including when the error originates from the
+// final hop.
+func TestOnionFailureRoute(t *testing.T) {
+	paymentPath := make([]*btcec.PublicKey, 5)
+	for i := 0; i < len(paymentPath); i++ {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Fatalf("unable to generate random key for sphinx node: %v", err)
+		}
+		paymentPath[i] = privKey.PubKey()
+	}
+	sessionKey, _ := btcec.PrivKeyFromBytes(btcec.S256(),
+		bytes.Repeat([]byte{'A'}, 32))
+
+	sharedSecrets, err := generateSharedSecrets(paymentPath, sessionKey)
+	if err != nil {
+		t.Fatalf("Unexpected error while generating secrets: %v", err)
+	}
+
+	failureData := bytes.Repeat([]byte{'A'}, onionErrorLength-sha256.Size)
+
+	for senderIdx := 1; senderIdx <= len(paymentPath); senderIdx++ {
+		// The failing node creates the error, after which every
+		// preceding hop adds its own layer of obfuscation.
+		obfuscator := &OnionErrorEncrypter{
+			sharedSecret: sharedSecrets[senderIdx-1],
+		}
+		obfuscatedData := obfuscator.EncryptError(true, failureData)
+		for i := senderIdx - 2; i >= 0; i-- {
+			obfuscator = &OnionErrorEncrypter{
+				sharedSecret: sharedSecrets[i],
+			}
+			obfuscatedData = obfuscator.EncryptError(
+				false, obfuscatedData,
+			)
+		}
+
+		deobfuscator := NewOnionErrorDecrypter(&Circuit{
+			SessionKey:  sessionKey,
+			PaymentPath: paymentPath,
+		})
+		decryptedError, err := deobfuscator.DecryptError(obfuscatedData)
+		if err != nil {
+			t.Fatalf("unable to de-obfuscate the onion failure: %v",
+				err)
+		}
+
+		route := decryptedError.Route
+		if len(route) != senderIdx {
+			t.Fatalf("expected route of length %v, got %v",
+				senderIdx, len(route))
+		}
+		for i, node := range route {
+			if !node.IsEqual(paymentPath[i]) {
+				t.Fatalf("sender %v: route mismatch at hop %v",
+					senderIdx, i)
+			}
+		}
+		if !route[len(route)-1].IsEqual(decryptedError.Sender) {
+			t.Fatalf("route does not end with the error sender")
+		}
+	}
+}
+
 // onionErrorData is a specification onion error obfuscation data which is
 // produces by another lightning network node.
 var onionErrorData = []struct {
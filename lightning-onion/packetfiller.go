@@ -2,6 +2,7 @@ package sphinx
 
 import (
 	"crypto/rand"
+	mrand "math/rand"
 
 	"github.com/aead/chacha20"
 	"github.com/kaotisk-hund/cjdcoind/btcec"
@@ -60,3 +61,19 @@ func DeterministicPacketFiller(sessionKey *btcec.PrivateKey,
 
 	return nil
 }
+
+// SeededPacketFiller returns a packet filler that fills the packet with bytes
+// from a PRNG keyed by the given seed, ignoring the session key. Packets built
+// with the same seed are reproducible, while unlike the BlankPacketFiller the
+// padding isn't all zeros. It should ONLY be used in tests or for fuzzing, as
+// the filler bytes are predictable and must never be used in production.
+func SeededPacketFiller(seed int64) PacketFiller {
+	return func(_ *btcec.PrivateKey, mixHeader *[routingInfoSize]byte) er.R {
+		prng := mrand.New(mrand.NewSource(seed))
+		if _, err := prng.Read(mixHeader[:]); err != nil {
+			return er.E(err)
+		}
+
+		return nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 
 	"github.com/kaotisk-hund/cjdcoind/btcec"
@@ -391,9 +392,43 @@ func (p *PaymentPath) TrueRouteLength() int {
 	return routeLength
 }
 
-// TotalPayloadSize returns the sum of the size of each payload in the "true"
-// route.
-func (p *PaymentPath) TotalPayloadSize() int {
+// HasDuplicateHops returns true if the same node appears more than once in the
+// "true" route.
+func (p *PaymentPath) HasDuplicateHops() bool {
+	seen := make(map[[33]byte]struct{}, NumMaxHops)
+	for _, hop := range p {
+		if hop.IsEmpty() {
+			continue
+		}
+
+		var nodePub [33]byte
+		copy(nodePub[:], hop.NodePub.SerializeCompressed())
+		if _, ok := seen[nodePub]; ok {
+			return true
+		}
+		seen[nodePub] = struct{}{}
+	}
+
+	return false
+}
+
+// TotalPayloadSize returns the sum of the serialized size of each payload in
+// the "true" route. If the payloads don't fit within the fixed size routing
+// info of an onion packet, ErrMaxRoutingInfoSizeExceeded is returned along
+// with the size.
+func (p *PaymentPath) TotalPayloadSize() (int, er.R) {
+	totalSize := p.payloadSize()
+	if totalSize > routingInfoSize {
+		return totalSize, ErrMaxRoutingInfoSizeExceeded.New(
+			fmt.Sprintf("payloads take %v bytes", totalSize), nil,
+		)
+	}
+
+	return totalSize, nil
+}
+
+// payloadSize returns the sum of the size of each payload in the "true" route.
+func (p *PaymentPath) payloadSize() int {
 	var totalSize int
 	for _, hop := range p {
 		if hop.IsEmpty() {
@@ -405,3 +440,131 @@ func (p *PaymentPath) TotalPayloadSize() int {
 
 	return totalSize
 }
+
+// BlindedPath is a route to a recipient in which the identities of all hops
+// but the first are hidden from the sender. It holds everything the creator of
+// the path needs to hand to a sender, so that the sender can build a
+// PaymentPath ending in it.
+type BlindedPath struct {
+	// IntroductionPoint is the real node ID of the first hop in the path.
+	// The sender must be able to route to this node.
+	IntroductionPoint *btcec.PublicKey
+
+	// BlindingPoint is the first ephemeral blinding point E_0. The sender
+	// needs to communicate it to the introduction point, which uses it to
+	// derive the shared secret with the creator of the path.
+	BlindingPoint *btcec.PublicKey
+
+	// BlindedHops are the hops of the path in order, starting with the
+	// introduction point.
+	BlindedHops []*BlindedHop
+}
+
+// BlindedHop is a single hop of a blinded path. When building a PaymentPath
+// to a blinded path, the sender uses BlindedNodePub as the hop's NodePub and
+// passes EncryptedData to the hop within its payload.
+type BlindedHop struct {
+	// BlindedNodePub is the blinded node ID of the hop.
+	BlindedNodePub *btcec.PublicKey
+
+	// EncryptedData is the recipient data for this hop, encrypted by the
+	// creator of the path so that only the hop can read it.
+	EncryptedData []byte
+}
+
+// HopInfo is a hop of a route to be blinded, holding the real node ID of the
+// hop along with the plain text data for it.
+type HopInfo struct {
+	// NodePub is the real public key of the node.
+	NodePub *btcec.PublicKey
+
+	// PlainText is the recipient data to encrypt for the node.
+	PlainText []byte
+}
+
+// blind blinds the node ID of the hop and encrypts its data using the shared
+// secret between the hop and the creator of the path.
+func (h *HopInfo) blind(sharedSecret *Hash256) (*BlindedHop, er.R) {
+	rho := generateKey("rho", sharedSecret)
+	encryptedData, err := chacha20polyEncrypt(rho[:], h.PlainText)
+	if err != nil {
+		return nil, err
+	}
+
+	blindingFactor := generateKey(routeBlindingHMACKey, sharedSecret)
+
+	return &BlindedHop{
+		BlindedNodePub: blindGroupElement(h.NodePub, blindingFactor[:]),
+		EncryptedData:  encryptedData,
+	}, nil
+}
+
+// BuildBlindedPath creates a blinded path through the given hops, the first
+// of which is the introduction point, using the session key as the first
+// ephemeral blinding key.
+func BuildBlindedPath(sessionKey *btcec.PrivateKey,
+	hops []*HopInfo) (*BlindedPath, er.R) {
+
+	if len(hops) == 0 {
+		return nil, ErrEmptyBlindedPath.Default()
+	}
+
+	nodeKeys := make([]*btcec.PublicKey, len(hops))
+	for i, hop := range hops {
+		nodeKeys[i] = hop.NodePub
+	}
+
+	// The blinding points of a blinded path are derived exactly like the
+	// ephemeral keys of an onion packet, so we can reuse the shared
+	// secrets of the sphinx construction.
+	sharedSecrets, err := generateSharedSecrets(nodeKeys, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	path := &BlindedPath{
+		IntroductionPoint: hops[0].NodePub,
+		BlindingPoint:     sessionKey.PubKey(),
+		BlindedHops:       make([]*BlindedHop, len(hops)),
+	}
+	for i, hop := range hops {
+		path.BlindedHops[i], err = hop.blind(&sharedSecrets[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return path, nil
+}
+
+// decryptBlindedHopData decrypts the data the creator of a blinded path
+// encrypted for the hop owning the given key, using the blinding point the hop
+// received along with it.
+func decryptBlindedHopData(privKey SingleKeyECDH, blindingPoint *btcec.PublicKey,
+	encryptedData []byte) ([]byte, er.R) {
+
+	sharedSecret, err := privKey.ECDH(blindingPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := Hash256(sharedSecret)
+	rho := generateKey("rho", &ss)
+
+	return chacha20polyDecrypt(rho[:], encryptedData)
+}
+
+// nextBlindingPoint computes the blinding point to pass on to the next hop of
+// a blinded path, E_{i+1} = SHA256(E_i || ss_i) * E_i.
+func nextBlindingPoint(privKey SingleKeyECDH,
+	blindingPoint *btcec.PublicKey) (*btcec.PublicKey, er.R) {
+
+	sharedSecret, err := privKey.ECDH(blindingPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	blindingFactor := computeBlindingFactor(blindingPoint, sharedSecret[:])
+
+	return blindGroupElement(blindingPoint, blindingFactor[:]), nil
+}
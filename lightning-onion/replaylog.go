@@ -1,6 +1,7 @@
 package sphinx
 
 import (
+	"container/list"
 	"crypto/sha256"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
@@ -73,13 +74,37 @@ type ReplayLog interface {
 	PutBatch(*Batch) (*ReplaySet, er.R)
 }
 
+// ReplayLogStatus is implemented by replay logs which are able to report
+// whether they are usable, so that callers can avoid processing packets which
+// couldn't be committed to the log anyway.
+type ReplayLogStatus interface {
+	// Started returns true if the log has been started and not stopped
+	// since.
+	Started() bool
+}
+
 // MemoryReplayLog is a simple ReplayLog implementation that stores all added
 // sphinx packets and processed batches in memory with no persistence.
 //
-// This is designed for use just in testing.
+// This is designed for use just in testing, unless bounded using
+// NewBoundedMemoryReplayLog.
 type MemoryReplayLog struct {
 	batches map[string]*ReplaySet
 	entries map[HashPrefix]uint32
+
+	// maxEntries is the maximum number of entries and batches kept in the
+	// log. If zero, the log is unbounded.
+	maxEntries int
+
+	// entryOrder holds the hash prefixes of the entries in insertion
+	// order, and entryElems the element of each entry within it. They are
+	// only maintained for a bounded log.
+	entryOrder *list.List
+	entryElems map[HashPrefix]*list.Element
+
+	// batchOrder holds the IDs of the processed batches in insertion
+	// order. It is only maintained for a bounded log.
+	batchOrder []string
 }
 
 // NewMemoryReplayLog constructs a new MemoryReplayLog.
@@ -87,10 +112,30 @@ func NewMemoryReplayLog() *MemoryReplayLog {
 	return &MemoryReplayLog{}
 }
 
+// NewBoundedMemoryReplayLog constructs a new MemoryReplayLog which keeps at
+// most maxEntries entries, and as many processed batches. Once the cap is
+// exceeded, the oldest entries are evicted in insertion order, so that memory
+// use stays bounded on a busy router while recent packets remain protected.
+//
+// NOTE: Replays of packets older than the eviction horizon are no longer
+// detected and will be accepted again. The cap must be chosen large enough to
+// cover all packets that could still be replayed profitably, e.g. all those
+// whose CLTV hasn't expired yet.
+func NewBoundedMemoryReplayLog(maxEntries int) *MemoryReplayLog {
+	return &MemoryReplayLog{
+		maxEntries: maxEntries,
+	}
+}
+
 // Start initializes the log and must be called before any other methods.
 func (rl *MemoryReplayLog) Start() er.R {
 	rl.batches = make(map[string]*ReplaySet)
 	rl.entries = make(map[HashPrefix]uint32)
+	if rl.maxEntries > 0 {
+		rl.entryOrder = list.New()
+		rl.entryElems = make(map[HashPrefix]*list.Element)
+		rl.batchOrder = nil
+	}
 	return nil
 }
 
@@ -102,9 +147,17 @@ func (rl *MemoryReplayLog) Stop() er.R {
 
 	rl.batches = nil
 	rl.entries = nil
+	rl.entryOrder = nil
+	rl.entryElems = nil
+	rl.batchOrder = nil
 	return nil
 }
 
+// Started returns true if the log has been started and not stopped since.
+func (rl *MemoryReplayLog) Started() bool {
+	return rl.entries != nil && rl.batches != nil
+}
+
 // Get retrieves an entry from the log given its hash prefix. It returns the
 // value stored and an er.R if one occurs. It returns ErrLogEntryNotFound
 // if the entry is not in the log.
@@ -135,16 +188,38 @@ func (rl *MemoryReplayLog) Put(hash *HashPrefix, cltv uint32) er.R {
 	}
 
 	rl.entries[*hash] = cltv
+
+	if rl.maxEntries > 0 {
+		rl.entryElems[*hash] = rl.entryOrder.PushBack(*hash)
+
+		// Evict the oldest entries if we exceeded the cap.
+		for len(rl.entries) > rl.maxEntries {
+			oldest := rl.entryOrder.Front()
+			rl.removeEntry(oldest.Value.(HashPrefix))
+		}
+	}
+
 	return nil
 }
 
+// removeEntry removes the entry with the given hash prefix from the log,
+// along with its position in the insertion order of a bounded log.
+func (rl *MemoryReplayLog) removeEntry(hash HashPrefix) {
+	delete(rl.entries, hash)
+
+	if elem, ok := rl.entryElems[hash]; ok {
+		rl.entryOrder.Remove(elem)
+		delete(rl.entryElems, hash)
+	}
+}
+
 // Delete deletes an entry from the log given its hash prefix.
 func (rl *MemoryReplayLog) Delete(hash *HashPrefix) er.R {
 	if rl.entries == nil || rl.batches == nil {
 		return errReplayLogNotStarted.Default()
 	}
 
-	delete(rl.entries, *hash)
+	rl.removeEntry(*hash)
 	return nil
 }
 
@@ -179,6 +254,17 @@ func (rl *MemoryReplayLog) PutBatch(batch *Batch) (*ReplaySet, er.R) {
 
 		replays.Merge(batch.ReplaySet)
 		rl.batches[string(batch.ID)] = replays
+
+		// Forget the oldest batches if we exceeded the cap. Their
+		// entries are still subject to replay protection as long as
+		// they remain in the log.
+		if rl.maxEntries > 0 {
+			rl.batchOrder = append(rl.batchOrder, string(batch.ID))
+			for len(rl.batchOrder) > rl.maxEntries {
+				delete(rl.batches, rl.batchOrder[0])
+				rl.batchOrder = rl.batchOrder[1:]
+			}
+		}
 	}
 
 	batch.ReplaySet = replays
@@ -189,3 +275,4 @@ func (rl *MemoryReplayLog) PutBatch(batch *Batch) (*ReplaySet, er.R) {
 
 // A compile time asserting *MemoryReplayLog implements the RelayLog interface.
 var _ ReplayLog = (*MemoryReplayLog)(nil)
+var _ ReplayLogStatus = (*MemoryReplayLog)(nil)
@@ -130,3 +130,77 @@ func TestMemoryReplayLogPutBatch(t *testing.T) {
 		t.Fatalf("Unexpected replay set after adding batch 2 to log: %v", err)
 	}
 }
+
+// TestBoundedMemoryReplayLog tests that a bounded MemoryReplayLog evicts its
+// oldest entries once the cap is exceeded, while still detecting replays of
+// recent packets.
+func TestBoundedMemoryReplayLog(t *testing.T) {
+	const maxEntries = 3
+
+	rl := NewBoundedMemoryReplayLog(maxEntries)
+	rl.Start()
+	defer rl.Stop()
+
+	hashPrefixes := make([]HashPrefix, maxEntries+2)
+	for i := range hashPrefixes {
+		hashPrefixes[i][0] = byte(i + 1)
+
+		err := rl.Put(&hashPrefixes[i], uint32(i))
+		if err != nil {
+			t.Fatalf("Put failed - received unexpected error upon "+
+				"Put: %v", err)
+		}
+	}
+
+	// The two oldest entries should have been evicted.
+	for i := 0; i < 2; i++ {
+		_, err := rl.Get(&hashPrefixes[i])
+		if !ErrLogEntryNotFound.Is(err) {
+			t.Fatalf("expected entry %d to be evicted, got: %v", i,
+				err)
+		}
+	}
+
+	// The most recent entries must still be protected against replays.
+	for i := 2; i < len(hashPrefixes); i++ {
+		err := rl.Put(&hashPrefixes[i], uint32(i))
+		if !ErrReplayedPacket.Is(err) {
+			t.Fatalf("expected replay of entry %d to be detected, "+
+				"got: %v", i, err)
+		}
+	}
+
+	// Deleting an entry frees up its slot, so that inserting a new entry
+	// doesn't evict any other.
+	if err := rl.Delete(&hashPrefixes[2]); err != nil {
+		t.Fatalf("Delete failed - received unexpected error upon "+
+			"Delete: %v", err)
+	}
+	if err := rl.Put(&hashPrefixes[0], 0); err != nil {
+		t.Fatalf("Put failed - received unexpected error upon Put: %v",
+			err)
+	}
+	for _, i := range []int{0, 3, 4} {
+		if _, err := rl.Get(&hashPrefixes[i]); err != nil {
+			t.Fatalf("expected entry %d to be in the log, got: %v",
+				i, err)
+		}
+	}
+
+	// Entries added through batches are subject to the same cap.
+	batch := NewBatch([]byte{1})
+	if err := batch.Put(1, &hashPrefixes[1], 1); err != nil {
+		t.Fatalf("Unexpected error adding entry to batch: %v", err)
+	}
+	replays, err := rl.PutBatch(batch)
+	if err != nil {
+		t.Fatalf("PutBatch failed - received unexpected error upon "+
+			"PutBatch: %v", err)
+	}
+	if replays.Size() != 0 {
+		t.Fatalf("expected no replays, got %d", replays.Size())
+	}
+	if _, err := rl.Get(&hashPrefixes[3]); !ErrLogEntryNotFound.Is(err) {
+		t.Fatalf("expected entry 3 to be evicted, got: %v", err)
+	}
+}
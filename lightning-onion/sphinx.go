@@ -114,6 +114,55 @@ type OnionPacket struct {
 	HeaderMAC [HMACSize]byte
 }
 
+// SharedSecrets returns the shared secret the sender of an onion packet
+// derives with each hop of the passed route, in route order. This is mostly
+// useful for debugging packet construction.
+func SharedSecrets(paymentPath *PaymentPath,
+	sessionKey *btcec.PrivateKey) ([]Hash256, er.R) {
+
+	if paymentPath.TrueRouteLength() == 0 {
+		return nil, er.Errorf("route of length zero passed in")
+	}
+
+	return generateSharedSecrets(paymentPath.NodeKeys(), sessionKey)
+}
+
+// EphemeralKeys returns the ephemeral private key used for the ECDH with each
+// hop of the passed route, in route order. The key of the first hop is the
+// session key, the keys of the following hops are derived from it by
+// repeatedly applying the blinding factor of the previous hop, as each hop
+// does with the ephemeral public key it forwards.
+func EphemeralKeys(paymentPath *PaymentPath,
+	sessionKey *btcec.PrivateKey) ([]*btcec.PrivateKey, er.R) {
+
+	sharedSecrets, err := SharedSecrets(paymentPath, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ephemeralKeys  = make([]*btcec.PrivateKey, len(sharedSecrets))
+		blindingFactor big.Int
+		scalar         big.Int
+	)
+	scalar.Set(sessionKey.D)
+	for i := range sharedSecrets {
+		ephemeralKeys[i], _ = btcec.PrivKeyFromBytes(
+			btcec.S256(), scalar.Bytes(),
+		)
+
+		// c_{i+1} = c_i * b_i (mod |F(G)|).
+		b := computeBlindingFactor(
+			ephemeralKeys[i].PubKey(), sharedSecrets[i][:],
+		)
+		blindingFactor.SetBytes(b[:])
+		scalar.Mul(&scalar, &blindingFactor)
+		scalar.Mod(&scalar, btcec.S256().Params().N)
+	}
+
+	return ephemeralKeys, nil
+}
+
 // generateSharedSecrets by the given nodes pubkeys, generates the shared
 // secrets.
 func generateSharedSecrets(paymentPath []*btcec.PublicKey,
@@ -194,14 +243,45 @@ func generateSharedSecrets(paymentPath []*btcec.PublicKey,
 	return hopSharedSecrets, nil
 }
 
+// onionPacketCfg holds the optional settings for creating an onion packet.
+type onionPacketCfg struct {
+	// rejectDuplicateHops denotes whether routes which visit the same
+	// node more than once should be rejected.
+	rejectDuplicateHops bool
+}
+
+// OnionPacketOption is a functional option which modifies how NewOnionPacket
+// creates an onion packet.
+type OnionPacketOption func(*onionPacketCfg)
+
+// WithRejectDuplicateHops makes NewOnionPacket fail with ErrDuplicateHop if
+// the same node appears more than once in the route. Such a route is almost
+// always a bug, but some rebalancing routes legitimately revisit a node, so
+// this isn't the default.
+func WithRejectDuplicateHops() OnionPacketOption {
+	return func(cfg *onionPacketCfg) {
+		cfg.rejectDuplicateHops = true
+	}
+}
+
 // NewOnionPacket creates a new onion packet which is capable of obliviously
 // routing a message through the mix-net path outline by 'paymentPath'.
 func NewOnionPacket(paymentPath *PaymentPath, sessionKey *btcec.PrivateKey,
-	assocData []byte, cjdcoinFiller PacketFiller) (*OnionPacket, er.R) {
+	assocData []byte, cjdcoinFiller PacketFiller,
+	opts ...OnionPacketOption) (*OnionPacket, er.R) {
+
+	var cfg onionPacketCfg
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	// Check whether total payload size doesn't exceed the hard maximum.
-	if paymentPath.TotalPayloadSize() > routingInfoSize {
-		return nil, ErrMaxRoutingInfoSizeExceeded.Default()
+	if _, err := paymentPath.TotalPayloadSize(); err != nil {
+		return nil, err
+	}
+
+	if cfg.rejectDuplicateHops && paymentPath.HasDuplicateHops() {
+		return nil, ErrDuplicateHop.Default()
 	}
 
 	// If we don't actually have a partially populated route, then we'll
@@ -331,7 +411,7 @@ func generateHeaderPadding(key string, path *PaymentPath, sharedSecrets []Hash25
 
 	// We have to generate a filler that matches all but the last hop (the
 	// last hop won't generate an HMAC)
-	fillerSize := path.TotalPayloadSize() - path[numHops-1].HopPayload.NumBytes()
+	fillerSize := path.payloadSize() - path[numHops-1].HopPayload.NumBytes()
 	filler := make([]byte, fillerSize)
 
 	for i := 0; i < numHops-1; i++ {
@@ -388,6 +468,10 @@ func (f *OnionPacket) encode(w io.Writer) error {
 // encoded within the io.Reader. In the case of any decoding errors, an error
 // will be returned. If the method success, then the new OnionPacket is ready
 // to be processed by an instance of SphinxNode.
+//
+// NOTE: The version of the packet isn't checked, as all versions share the
+// same layout. Whether it's supported is up to the Router processing the
+// packet, see Router.SupportedVersions.
 func (f *OnionPacket) Decode(r io.Reader) er.R {
 	var err er.R
 
@@ -397,12 +481,6 @@ func (f *OnionPacket) Decode(r io.Reader) er.R {
 	}
 	f.Version = buf[0]
 
-	// If version of the onion packet protocol unknown for us than in might
-	// lead to improperly decoded data.
-	if f.Version != baseVersion {
-		return ErrInvalidOnionVersion.Default()
-	}
-
 	var ephemeral [33]byte
 	if _, err := io.ReadFull(r, ephemeral[:]); err != nil {
 		return er.E(err)
@@ -496,6 +574,12 @@ type Router struct {
 	onionKey SingleKeyECDH
 
 	log ReplayLog
+
+	// SupportedVersions is the set of onion packet versions the router
+	// processes. Packets of any other version are rejected with
+	// ErrUnsupportedOnionVersion. If empty, only the base version is
+	// supported.
+	SupportedVersions []byte
 }
 
 // NewRouter creates a new instance of a Sphinx onion Router given the node's
@@ -515,6 +599,22 @@ func NewRouter(nodeKey SingleKeyECDH, net *chaincfg.Params, log ReplayLog) *Rout
 	}
 }
 
+// checkVersion returns ErrUnsupportedOnionVersion if the router doesn't process
+// onion packets of the passed version.
+func (r *Router) checkVersion(version byte) er.R {
+	if len(r.SupportedVersions) == 0 {
+		if version == baseVersion {
+			return nil
+		}
+	} else if bytes.IndexByte(r.SupportedVersions, version) != -1 {
+		return nil
+	}
+
+	return ErrUnsupportedOnionVersion.New(
+		fmt.Sprintf("version %d", version), nil,
+	)
+}
+
 // Start starts / opens the ReplayLog's channeldb and its accompanying
 // garbage collector goroutine.
 func (r *Router) Start() er.R {
@@ -527,22 +627,102 @@ func (r *Router) Stop() {
 	r.log.Stop()
 }
 
+// Ready checks whether the router is able to process onion packets at all. It
+// returns ErrInvalidRouterKey if the onion key of the router is invalid, and an
+// error if the replay log reports that it isn't started, in which case no
+// packet could be committed to it. Replay logs which don't implement
+// ReplayLogStatus are assumed to be usable.
+func (r *Router) Ready() er.R {
+	pubKey := r.onionKey.PubKey()
+	if pubKey == nil || pubKey.X == nil || pubKey.Y == nil ||
+		!btcec.S256().IsOnCurve(pubKey.X, pubKey.Y) {
+
+		return ErrInvalidRouterKey.Default()
+	}
+
+	if status, ok := r.log.(ReplayLogStatus); ok && !status.Started() {
+		return errReplayLogNotStarted.Default()
+	}
+
+	return nil
+}
+
+// processOnionCfg holds the optional settings for processing an onion packet.
+type processOnionCfg struct {
+	// blindingPoint is the blinding point received along with the packet
+	// if we're a hop within a blinded path.
+	blindingPoint *btcec.PublicKey
+}
+
+// ProcessOnionOption is a functional option which modifies how a Router
+// processes an onion packet.
+type ProcessOnionOption func(*processOnionCfg)
+
+// WithBlindingPoint processes the onion packet as a hop within a blinded path,
+// using the blinding point received along with the packet to unblind our node
+// key.
+func WithBlindingPoint(blindingPoint *btcec.PublicKey) ProcessOnionOption {
+	return func(cfg *processOnionCfg) {
+		cfg.blindingPoint = blindingPoint
+	}
+}
+
+// newProcessOnionCfg applies the passed options to a default config.
+func newProcessOnionCfg(opts []ProcessOnionOption) *processOnionCfg {
+	cfg := &processOnionCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
 // ProcessOnionPacket processes an incoming onion packet which has been forward
 // to the target Sphinx router. If the encoded ephemeral key isn't on the
 // target Elliptic Curve, then the packet is rejected. Similarly, if the
 // derived shared secret has been seen before the packet is rejected.  Finally
-// if the MAC doesn't check the packet is again rejected.
+// if the MAC doesn't check the packet is again rejected. If a blinding point
+// is passed using WithBlindingPoint, it is used to unblind our node key.
 //
 // In the case of a successful packet processing, and ProcessedPacket struct is
 // returned which houses the newly parsed packet, along with instructions on
 // what to do next.
 func (r *Router) ProcessOnionPacket(onionPkt *OnionPacket,
-	assocData []byte, incomingCltv uint32) (*ProcessedPacket, er.R) {
+	assocData []byte, incomingCltv uint32,
+	opts ...ProcessOnionOption) (*ProcessedPacket, er.R) {
+
+	packet, _, err := r.ProcessOnionPacketWithSecret(
+		onionPkt, assocData, incomingCltv, opts...,
+	)
+	return packet, err
+}
+
+// ProcessOnionPacketWithSecret processes an incoming onion packet exactly like
+// ProcessOnionPacket, but additionally returns the shared secret derived for
+// this hop. This allows tools probing a route to correlate failures with the
+// hop that produced them.
+//
+// NOTE: The shared secret allows decrypting this hop's layer of the onion and
+// of any failure sent back along the route. It must be kept private, and this
+// method should not be used when forwarding payments in production.
+func (r *Router) ProcessOnionPacketWithSecret(onionPkt *OnionPacket,
+	assocData []byte, incomingCltv uint32,
+	opts ...ProcessOnionOption) (*ProcessedPacket, *Hash256, er.R) {
+
+	cfg := newProcessOnionCfg(opts)
+
+	// Refuse packets of a version we don't know how to process, before
+	// doing any work on them.
+	if err := r.checkVersion(onionPkt.Version); err != nil {
+		return nil, nil, err
+	}
 
 	// Compute the shared secret for this onion packet.
-	sharedSecret, err := r.generateSharedSecret(onionPkt.EphemeralKey)
+	sharedSecret, err := r.generateBlindedSharedSecret(
+		onionPkt.EphemeralKey, cfg.blindingPoint,
+	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Additionally, compute the hash prefix of the shared secret, which
@@ -554,16 +734,16 @@ func (r *Router) ProcessOnionPacket(onionPkt *OnionPacket,
 	// operations.
 	packet, err := processOnionPacket(onionPkt, &sharedSecret, assocData, r)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Atomically compare this hash prefix with the contents of the on-disk
 	// log, persisting it only if this entry was not detected as a replay.
 	if err := r.log.Put(hashPrefix, incomingCltv); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return packet, nil
+	return packet, &sharedSecret, nil
 }
 
 // ReconstructOnionPacket rederives the subsequent onion packet.
@@ -571,10 +751,14 @@ func (r *Router) ProcessOnionPacket(onionPkt *OnionPacket,
 // NOTE: This method does not do any sort of replay protection, and should only
 // be used to reconstruct packets that were successfully processed previously.
 func (r *Router) ReconstructOnionPacket(onionPkt *OnionPacket,
-	assocData []byte) (*ProcessedPacket, er.R) {
+	assocData []byte, opts ...ProcessOnionOption) (*ProcessedPacket, er.R) {
+
+	cfg := newProcessOnionCfg(opts)
 
 	// Compute the shared secret for this onion packet.
-	sharedSecret, err := r.generateSharedSecret(onionPkt.EphemeralKey)
+	sharedSecret, err := r.generateBlindedSharedSecret(
+		onionPkt.EphemeralKey, cfg.blindingPoint,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -582,6 +766,30 @@ func (r *Router) ReconstructOnionPacket(onionPkt *OnionPacket,
 	return processOnionPacket(onionPkt, &sharedSecret, assocData, r)
 }
 
+// DecryptBlindedHopData decrypts the data the creator of a blinded path
+// encrypted for us, using the blinding point received along with it.
+func (r *Router) DecryptBlindedHopData(blindingPoint *btcec.PublicKey,
+	encryptedData []byte) ([]byte, er.R) {
+
+	if !btcec.S256().IsOnCurve(blindingPoint.X, blindingPoint.Y) {
+		return nil, ErrInvalidOnionKey.Default()
+	}
+
+	return decryptBlindedHopData(r.onionKey, blindingPoint, encryptedData)
+}
+
+// NextBlindingPoint derives the blinding point to pass on to the next hop of
+// a blinded path from the one we received.
+func (r *Router) NextBlindingPoint(
+	blindingPoint *btcec.PublicKey) (*btcec.PublicKey, er.R) {
+
+	if !btcec.S256().IsOnCurve(blindingPoint.X, blindingPoint.Y) {
+		return nil, ErrInvalidOnionKey.Default()
+	}
+
+	return nextBlindingPoint(r.onionKey, blindingPoint)
+}
+
 // unwrapPacket wraps a layer of the passed onion packet using the specified
 // shared secret and associated data. The associated data will be used to check
 // the HMAC at each hop to ensure the same data is passed along with the onion
@@ -726,17 +934,26 @@ func (r *Router) BeginTxn(id []byte, nels int) *Tx {
 // to the target Sphinx router. If the encoded ephemeral key isn't on the
 // target Elliptic Curve, then the packet is rejected. Similarly, if the
 // derived shared secret has been seen before the packet is rejected.  Finally
-// if the MAC doesn't check the packet is again rejected.
+// if the MAC doesn't check the packet is again rejected. If a blinding point
+// is passed using WithBlindingPoint, it is used to unblind our node key.
 //
 // In the case of a successful packet processing, and ProcessedPacket struct is
 // returned which houses the newly parsed packet, along with instructions on
 // what to do next.
 func (t *Tx) ProcessOnionPacket(seqNum uint16, onionPkt *OnionPacket,
-	assocData []byte, incomingCltv uint32) er.R {
+	assocData []byte, incomingCltv uint32, opts ...ProcessOnionOption) er.R {
+
+	cfg := newProcessOnionCfg(opts)
+
+	// Refuse packets of a version we don't know how to process, before
+	// doing any work on them.
+	if err := t.router.checkVersion(onionPkt.Version); err != nil {
+		return err
+	}
 
 	// Compute the shared secret for this onion packet.
-	sharedSecret, err := t.router.generateSharedSecret(
-		onionPkt.EphemeralKey,
+	sharedSecret, err := t.router.generateBlindedSharedSecret(
+		onionPkt.EphemeralKey, cfg.blindingPoint,
 	)
 	if err != nil {
 		return err
@@ -899,3 +899,266 @@ func TestVariablePayloadOnion(t *testing.T) {
 			hex.EncodeToString(b.Bytes()))
 	}
 }
+
+// TestSphinxDuplicateHops tests that routes visiting the same node twice are
+// detected, and only rejected when requested.
+func TestSphinxDuplicateHops(t *testing.T) {
+	_, route, _, _, err := newTestRoute(3)
+	util.RequireNoErr(t, err)
+
+	if route.HasDuplicateHops() {
+		t.Fatalf("route without duplicates reported as duplicate")
+	}
+
+	sessionKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), bytes.Repeat([]byte{'A'}, 32),
+	)
+	_, err = NewOnionPacket(
+		route, sessionKey, nil, DeterministicPacketFiller,
+		WithRejectDuplicateHops(),
+	)
+	util.RequireNoErr(t, err)
+
+	// Revisit the first node at the end of the route.
+	route[2].NodePub = route[0].NodePub
+	if !route.HasDuplicateHops() {
+		t.Fatalf("duplicate hop not detected")
+	}
+
+	// Without the option the route is still accepted.
+	_, err = NewOnionPacket(
+		route, sessionKey, nil, DeterministicPacketFiller,
+	)
+	util.RequireNoErr(t, err)
+
+	_, err = NewOnionPacket(
+		route, sessionKey, nil, DeterministicPacketFiller,
+		WithRejectDuplicateHops(),
+	)
+	if !ErrDuplicateHop.Is(err) {
+		t.Fatalf("expected ErrDuplicateHop, got %v", err)
+	}
+}
+
+// TestPaymentPathPayloadSize tests that the total payload size of a path is
+// accepted up to the size of the routing info, and rejected beyond it.
+func TestPaymentPathPayloadSize(t *testing.T) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	util.RequireNoErr(t, err)
+
+	sessionKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), bytes.Repeat([]byte{'A'}, 32),
+	)
+
+	// A single tlv payload of 1265 bytes takes up a 3 byte length prefix
+	// and a 32 byte HMAC, filling up the routing info exactly.
+	tests := []struct {
+		name        string
+		payloadSize int
+		valid       bool
+	}{
+		{
+			name:        "under limit",
+			payloadSize: 1264,
+			valid:       true,
+		},
+		{
+			name:        "at limit",
+			payloadSize: 1265,
+			valid:       true,
+		},
+		{
+			name:        "over limit",
+			payloadSize: 1266,
+			valid:       false,
+		},
+	}
+
+	for _, test := range tests {
+		var path PaymentPath
+		path[0] = OnionHop{
+			NodePub: *privKey.PubKey(),
+			HopPayload: HopPayload{
+				Type:    PayloadTLV,
+				Payload: make([]byte, test.payloadSize),
+			},
+		}
+
+		if path.TrueRouteLength() != 1 {
+			t.Fatalf("%s: expected 1 hop, got %d", test.name,
+				path.TrueRouteLength())
+		}
+
+		size, err := path.TotalPayloadSize()
+		if size != test.payloadSize+3+HMACSize {
+			t.Fatalf("%s: unexpected payload size %d", test.name,
+				size)
+		}
+
+		_, pktErr := NewOnionPacket(
+			&path, sessionKey, nil, DeterministicPacketFiller,
+		)
+		if test.valid {
+			util.RequireNoErr(t, err)
+			util.RequireNoErr(t, pktErr)
+			continue
+		}
+
+		if !ErrMaxRoutingInfoSizeExceeded.Is(err) {
+			t.Fatalf("%s: expected ErrMaxRoutingInfoSizeExceeded, "+
+				"got %v", test.name, err)
+		}
+		if !ErrMaxRoutingInfoSizeExceeded.Is(pktErr) {
+			t.Fatalf("%s: expected ErrMaxRoutingInfoSizeExceeded, "+
+				"got %v", test.name, pktErr)
+		}
+	}
+}
+
+// TestSphinxEphemeralKeys tests that the derived ephemeral keys match the
+// ephemeral keys each hop receives while processing the packet.
+func TestSphinxEphemeralKeys(t *testing.T) {
+	nodes, route, _, fwdMsg, err := newTestRoute(3)
+	util.RequireNoErr(t, err)
+
+	sessionKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), bytes.Repeat([]byte{'A'}, 32),
+	)
+	ephemeralKeys, err := EphemeralKeys(route, sessionKey)
+	util.RequireNoErr(t, err)
+	if len(ephemeralKeys) != len(nodes) {
+		t.Fatalf("expected %d ephemeral keys, got %d", len(nodes),
+			len(ephemeralKeys))
+	}
+
+	for i, node := range nodes {
+		node.log.Start()
+		defer node.log.Stop()
+
+		if !fwdMsg.EphemeralKey.IsEqual(ephemeralKeys[i].PubKey()) {
+			t.Fatalf("ephemeral key of hop %d doesn't match", i)
+		}
+
+		processed, err := node.ProcessOnionPacket(fwdMsg, nil, uint32(i))
+		util.RequireNoErr(t, err)
+		fwdMsg = processed.NextPacket
+	}
+}
+
+// TestSphinxProcessWithSecret tests that the shared secret returned while
+// processing a packet is the one the sender derived for that hop.
+func TestSphinxProcessWithSecret(t *testing.T) {
+	nodes, route, _, fwdMsg, err := newTestRoute(3)
+	util.RequireNoErr(t, err)
+
+	sessionKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), bytes.Repeat([]byte{'A'}, 32),
+	)
+	sharedSecrets, err := SharedSecrets(route, sessionKey)
+	util.RequireNoErr(t, err)
+
+	for i, node := range nodes {
+		node.log.Start()
+		defer node.log.Stop()
+
+		processed, secret, err := node.ProcessOnionPacketWithSecret(
+			fwdMsg, nil, uint32(i),
+		)
+		util.RequireNoErr(t, err)
+		if *secret != sharedSecrets[i] {
+			t.Fatalf("shared secret of hop %d doesn't match", i)
+		}
+
+		// Replays are still rejected.
+		_, _, err = node.ProcessOnionPacketWithSecret(
+			fwdMsg, nil, uint32(i),
+		)
+		if !ErrReplayedPacket.Is(err) {
+			t.Fatalf("expected ErrReplayedPacket, got %v", err)
+		}
+
+		fwdMsg = processed.NextPacket
+	}
+}
+
+// TestSphinxUnsupportedVersion tests that a router rejects onion packets of a
+// version outside of its supported set with a distinct error, both when
+// processing a single packet and within a batch.
+func TestSphinxUnsupportedVersion(t *testing.T) {
+	nodes, _, _, fwdMsg, err := newTestRoute(1)
+	util.RequireNoErr(t, err)
+
+	node := nodes[0]
+	node.log.Start()
+	defer node.log.Stop()
+
+	unknownPkt := *fwdMsg
+	unknownPkt.Version = baseVersion + 1
+
+	// Packets of any version are decoded, leaving the decision to the
+	// router.
+	var b bytes.Buffer
+	util.RequireNoErr(t, unknownPkt.Encode(&b))
+	var decodedPkt OnionPacket
+	util.RequireNoErr(t, decodedPkt.Decode(&b))
+	if decodedPkt.Version != unknownPkt.Version {
+		t.Fatalf("expected version %d, got %d", unknownPkt.Version,
+			decodedPkt.Version)
+	}
+
+	// By default, only the base version is supported.
+	_, err = node.ProcessOnionPacket(&unknownPkt, nil, 1)
+	if !ErrUnsupportedOnionVersion.Is(err) {
+		t.Fatalf("expected ErrUnsupportedOnionVersion, got %v", err)
+	}
+
+	tx := node.BeginTxn([]byte("unsupported"), 1)
+	err = tx.ProcessOnionPacket(0, &unknownPkt, nil, 1)
+	if !ErrUnsupportedOnionVersion.Is(err) {
+		t.Fatalf("expected ErrUnsupportedOnionVersion, got %v", err)
+	}
+
+	// A router which only supports the new version refuses the base
+	// version.
+	node.SupportedVersions = []byte{unknownPkt.Version}
+	_, err = node.ProcessOnionPacket(fwdMsg, nil, 1)
+	if !ErrUnsupportedOnionVersion.Is(err) {
+		t.Fatalf("expected ErrUnsupportedOnionVersion, got %v", err)
+	}
+
+	// Once supported, the packet is processed.
+	node.SupportedVersions = []byte{baseVersion, unknownPkt.Version}
+	_, err = node.ProcessOnionPacket(&unknownPkt, nil, 1)
+	util.RequireNoErr(t, err)
+}
+
+// TestSeededPacketFiller tests that packets built with the same seed are
+// identical, while packets built with different seeds differ.
+func TestSeededPacketFiller(t *testing.T) {
+	_, route, _, _, err := newTestRoute(3)
+	util.RequireNoErr(t, err)
+
+	sessionKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), bytes.Repeat([]byte{'A'}, 32),
+	)
+
+	encodePacket := func(filler PacketFiller) []byte {
+		pkt, err := NewOnionPacket(route, sessionKey, nil, filler)
+		util.RequireNoErr(t, err)
+
+		var b bytes.Buffer
+		util.RequireNoErr(t, pkt.Encode(&b))
+		return b.Bytes()
+	}
+
+	first := encodePacket(SeededPacketFiller(1))
+	if !bytes.Equal(first, encodePacket(SeededPacketFiller(1))) {
+		t.Fatalf("packets built with the same seed differ")
+	}
+	if bytes.Equal(first, encodePacket(SeededPacketFiller(2))) {
+		t.Fatalf("packets built with different seeds are identical")
+	}
+	if bytes.Equal(first, encodePacket(BlankPacketFiller)) {
+		t.Fatalf("seeded packet matches blank packet")
+	}
+}
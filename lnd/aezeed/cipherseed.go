@@ -442,6 +442,12 @@ func (m *Mnemonic) ToCipherSeed(pass []byte) (*CipherSeed, er.R) {
 		return nil, err
 	}
 
+	// The salt isn't part of the plaintext, so we restore it from the
+	// ciphertext. Otherwise enciphering the seed again would use an
+	// all-zero salt.
+	cipherText := mnemonicToCipherText(m)
+	copy(c.salt[:], cipherText[saltOffset:saltOffset+saltSize])
+
 	return &c, nil
 }
 
@@ -329,6 +329,17 @@ func TestChangePassphrase(t *testing.T) {
 	// Now that we have the cipher seed, we'll verify that the plaintext
 	// seed matches *identically*.
 	assertCipherSeedEqual(t, cipherSeed, newCipherSeed)
+
+	// The salt is kept as well, so changing the passphrase back yields
+	// the original mnemonic.
+	oldmnemonic, err := newmnemonic.ChangePass(newPass, pass)
+	if err != nil {
+		t.Fatalf("unable to change passphrase: %v", err)
+	}
+	if oldmnemonic != mnemonic {
+		t.Fatalf("mnemonic mismatch: expected %v, got %v", mnemonic,
+			oldmnemonic)
+	}
 }
 
 // TestChangePassphraseWrongPass tests that if we have a valid enciphered
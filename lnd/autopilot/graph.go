@@ -2,9 +2,11 @@ package autopilot
 
 import (
 	"bytes"
+	"math"
 	"math/big"
 	"net"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -134,6 +136,111 @@ func (d *databaseChannelGraph) ForEachNode(cb func(Node) er.R) er.R {
 	})
 }
 
+// ForEachNodeParallel is a variant of ForEachNode which splits the nodes among
+// the given number of goroutines, each of which calls the callback for its
+// share of the nodes within a read transaction of its own. The callback must
+// therefore be safe for concurrent use, and must not retain the passed node,
+// as its transaction is closed once the goroutine is done. Once a callback
+// returns an error, the workers stop calling the callback for their remaining
+// nodes, and the first error is returned.
+func (d *databaseChannelGraph) ForEachNodeParallel(workers int,
+	cb func(Node) er.R) er.R {
+
+	if workers <= 0 {
+		return er.Errorf("workers must be positive")
+	}
+
+	// We'll first collect the keys of all nodes we'd call the callback
+	// for, so they can be split among the workers.
+	var nodeKeys []route.Vertex
+	err := d.db.ForEachNode(func(_ kvdb.RTx, n *channeldb.LightningNode) er.R {
+		// As in ForEachNode, nodes without any advertised addresses
+		// are skipped.
+		if len(n.Addresses) == 0 {
+			return nil
+		}
+
+		nodeKeys = append(nodeKeys, n.PubKeyBytes)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		quit     = make(chan struct{})
+		errOnce  sync.Once
+		firstErr er.R
+	)
+
+	// fail records the first error returned by a worker, and signals the
+	// other workers to stop.
+	fail := func(err er.R) {
+		errOnce.Do(func() {
+			firstErr = err
+			close(quit)
+		})
+	}
+
+	// traverse calls the callback for each of the given nodes, unless a
+	// worker failed.
+	traverse := func(tx kvdb.RTx, batch []route.Vertex) er.R {
+		for _, nodeKey := range batch {
+			select {
+			case <-quit:
+				return nil
+			default:
+			}
+
+			n, err := d.db.FetchLightningNode(tx, nodeKey)
+			switch {
+			// The node was deleted since we collected the keys.
+			case channeldb.ErrGraphNodeNotFound.Is(err):
+				continue
+
+			case err != nil:
+				return err
+			}
+
+			err = cb(dbNode{
+				tx:   tx,
+				node: n,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	batchSize := (len(nodeKeys) + workers - 1) / workers
+	for start := 0; start < len(nodeKeys); start += batchSize {
+		end := start + batchSize
+		if end > len(nodeKeys) {
+			end = len(nodeKeys)
+		}
+		batch := nodeKeys[start:end]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := kvdb.View(d.db.Database(), func(tx kvdb.RTx) er.R {
+				return traverse(tx, batch)
+			}, func() {})
+			if err != nil {
+				fail(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
 // addRandChannel creates a new channel two target nodes. This function is
 // meant to aide in the generation of random graphs for use within test cases
 // the exercise the autopilot package.
@@ -523,3 +630,97 @@ func Median(vals []btcutil.Amount) btcutil.Amount {
 		return vals[num/2]
 	}
 }
+
+// WeightedMedian returns the weighted median of the slice of Amounts, i.e. the
+// smallest value for which the values up to and including it carry at least
+// half of the total weight. If exactly half of the weight is reached, the mean
+// of that value and the next one is returned, so with equal weights the result
+// is the same as the one of Median. Values with a non-positive weight are
+// ignored. Zero is returned if the lengths of the slices differ or no value
+// has a positive weight. The slices aren't modified.
+func WeightedMedian(vals []btcutil.Amount,
+	weights []btcutil.Amount) btcutil.Amount {
+
+	if len(vals) != len(weights) {
+		return 0
+	}
+
+	type weightedVal struct {
+		val, weight btcutil.Amount
+	}
+
+	var (
+		weighted    = make([]weightedVal, 0, len(vals))
+		totalWeight btcutil.Amount
+	)
+	for i, val := range vals {
+		if weights[i] <= 0 {
+			continue
+		}
+
+		weighted = append(weighted, weightedVal{val, weights[i]})
+		totalWeight += weights[i]
+	}
+	if len(weighted) == 0 {
+		return 0
+	}
+
+	sort.Slice(weighted, func(i, j int) bool {
+		return weighted[i].val < weighted[j].val
+	})
+
+	var cumWeight btcutil.Amount
+	for i, w := range weighted {
+		cumWeight += w.weight
+
+		switch {
+		case cumWeight*2 < totalWeight:
+			continue
+
+		case cumWeight*2 == totalWeight && i+1 < len(weighted):
+			return (w.val + weighted[i+1].val) / 2
+
+		default:
+			return w.val
+		}
+	}
+
+	// Not reached, as the cumulative weight ends up being the total.
+	return weighted[len(weighted)-1].val
+}
+
+// Percentile returns the p-th percentile of the slice of Amounts, with p
+// ranging from 0 to 100. Values of p outside of that range are clamped to it,
+// and NaN is treated as 0.
+// The percentile is linearly interpolated between the two closest values, so
+// the 50th percentile is the same as the result of Median. Zero is returned
+// for an empty slice. The slice isn't modified.
+func Percentile(vals []btcutil.Amount, p float64) btcutil.Amount {
+	if len(vals) == 0 {
+		return 0
+	}
+
+	switch {
+	case p < 0 || math.IsNaN(p):
+		p = 0
+	case p > 100:
+		p = 100
+	}
+
+	sorted := make([]btcutil.Amount, len(vals))
+	copy(sorted, vals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower == len(sorted)-1 {
+		return sorted[lower]
+	}
+
+	frac := rank - float64(lower)
+	diff := sorted[lower+1] - sorted[lower]
+
+	return sorted[lower] + btcutil.Amount(frac*float64(diff))
+}
@@ -0,0 +1,52 @@
+package autopilot
+
+import (
+	"bytes"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+// GraphDensity returns the density of the channel graph, i.e. the ratio of the
+// number of pairs of nodes that share a channel to the number of pairs of
+// nodes there are. As channels are undirected, each one is seen from both of
+// its nodes, and parallel channels between the same nodes are counted once.
+// The density of a graph with fewer than two nodes is zero.
+func GraphDensity(g ChannelGraph) (float64, er.R) {
+	type nodePair struct {
+		node1, node2 NodeID
+	}
+
+	var (
+		numNodes int
+		edges    = make(map[nodePair]struct{})
+	)
+	err := g.ForEachNode(func(n Node) er.R {
+		numNodes++
+
+		nodeID := NodeID(n.PubKey())
+		return n.ForEachChannel(func(e ChannelEdge) er.R {
+			peerID := NodeID(e.Peer.PubKey())
+
+			// Order the pair, so the channel is only counted once
+			// when it's seen from the peer as well.
+			switch bytes.Compare(nodeID[:], peerID[:]) {
+			case -1:
+				edges[nodePair{nodeID, peerID}] = struct{}{}
+			case 1:
+				edges[nodePair{peerID, nodeID}] = struct{}{}
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if numNodes < 2 {
+		return 0, nil
+	}
+
+	possibleEdges := float64(numNodes) * float64(numNodes-1) / 2
+	return float64(len(edges)) / possibleEdges, nil
+}
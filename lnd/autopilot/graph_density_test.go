@@ -0,0 +1,88 @@
+package autopilot
+
+import (
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGraphDensity tests that the density of graphs with a known number of
+// nodes and edges is computed correctly.
+func TestGraphDensity(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		desc    testGraphDesc
+		density float64
+	}{
+		{
+			name:    "empty",
+			desc:    testGraphDesc{},
+			density: 0,
+		},
+		{
+			name:    "single node",
+			desc:    testGraphDesc{nodes: 1},
+			density: 0,
+		},
+		{
+			name:    "no channels",
+			desc:    testGraphDesc{nodes: 4},
+			density: 0,
+		},
+		{
+			name: "complete",
+			desc: testGraphDesc{
+				nodes: 4,
+				edges: map[int][]int{
+					0: {1, 2, 3},
+					1: {2, 3},
+					2: {3},
+				},
+			},
+			density: 1,
+		},
+		{
+			// Parallel channels between the same nodes are only
+			// counted once.
+			name: "parallel channels",
+			desc: testGraphDesc{
+				nodes: 3,
+				edges: map[int][]int{
+					0: {1},
+					1: {0},
+				},
+			},
+			density: 1.0 / 3.0,
+		},
+		{
+			name:    "centrality test graph",
+			desc:    centralityTestGraph,
+			density: 14.0 / 36.0,
+		},
+	}
+
+	for _, chanGraph := range chanGraphs {
+		chanGraph := chanGraph
+
+		t.Run(chanGraph.name, func(t *testing.T) {
+			for _, test := range testCases {
+				graph, cleanup, err := chanGraph.genFunc()
+				util.RequireNoErr(t, err, "unable to create graph")
+				if cleanup != nil {
+					defer cleanup()
+				}
+
+				buildTestGraph(t, graph, test.desc)
+
+				density, err := GraphDensity(graph)
+				util.RequireNoErr(t, err)
+				require.InDelta(
+					t, test.density, density, 1e-9, test.name,
+				)
+			}
+		})
+	}
+}
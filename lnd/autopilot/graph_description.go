@@ -0,0 +1,174 @@
+package autopilot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"sort"
+
+	"github.com/kaotisk-hund/cjdcoind/btcec"
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
+)
+
+// GraphDescription is a serializable description of the topology of an
+// in-memory channel graph. It allows graphs to be stored as JSON, and replayed
+// deterministically in tests and simulations.
+type GraphDescription struct {
+	// Nodes are the nodes of the graph, ordered by their public key.
+	Nodes []NodeDescription `json:"nodes"`
+
+	// Edges are the edges of the graph. As the in-memory graph stores a
+	// channel as an edge for each of its nodes, each channel is described
+	// by two edges.
+	Edges []EdgeDescription `json:"edges"`
+}
+
+// NodeDescription describes a node of a channel graph.
+type NodeDescription struct {
+	// PubKey is the hex encoded compressed public key of the node.
+	PubKey string `json:"pub_key"`
+
+	// Addrs are the TCP addresses the node is listening on.
+	Addrs []string `json:"addrs"`
+}
+
+// EdgeDescription describes an edge of a channel graph, i.e. a channel as seen
+// from one of its nodes.
+type EdgeDescription struct {
+	// ChanID is the short channel ID of the edge.
+	ChanID uint64 `json:"chan_id"`
+
+	// Node is the hex encoded public key of the node the edge belongs to.
+	Node string `json:"node"`
+
+	// Peer is the hex encoded public key of the node at the other end of
+	// the edge.
+	Peer string `json:"peer"`
+
+	// Capacity is the capacity of the channel.
+	Capacity btcutil.Amount `json:"capacity"`
+}
+
+// Export returns a description of the graph. Nodes are ordered by their public
+// key, and the edges of each node keep the order they were added in, so
+// exporting the same graph always yields the same description.
+func (m *memChannelGraph) Export() GraphDescription {
+	nodeIDs := make([]NodeID, 0, len(m.graph))
+	for nodeID := range m.graph {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool {
+		return bytes.Compare(nodeIDs[i][:], nodeIDs[j][:]) < 0
+	})
+
+	desc := GraphDescription{
+		Nodes: make([]NodeDescription, 0, len(nodeIDs)),
+	}
+	for _, nodeID := range nodeIDs {
+		node := m.graph[nodeID]
+		pubKey := hex.EncodeToString(nodeID[:])
+
+		addrs := make([]string, 0, len(node.addrs))
+		for _, addr := range node.addrs {
+			addrs = append(addrs, addr.String())
+		}
+		desc.Nodes = append(desc.Nodes, NodeDescription{
+			PubKey: pubKey,
+			Addrs:  addrs,
+		})
+
+		for _, edge := range node.chans {
+			peerID := edge.Peer.PubKey()
+			desc.Edges = append(desc.Edges, EdgeDescription{
+				ChanID:   edge.ChanID.ToUint64(),
+				Node:     pubKey,
+				Peer:     hex.EncodeToString(peerID[:]),
+				Capacity: edge.Capacity,
+			})
+		}
+	}
+
+	return desc
+}
+
+// ImportMemChannelGraph creates an in-memory channel graph from the given
+// description, as returned by Export. An error is returned if a public key or
+// an address can't be parsed, or an edge refers to a node that isn't
+// described.
+func ImportMemChannelGraph(desc GraphDescription) (*memChannelGraph, er.R) {
+	m := newMemChannelGraph()
+
+	for _, nodeDesc := range desc.Nodes {
+		pub, err := parseDescribedPubKey(nodeDesc.PubKey)
+		if err != nil {
+			return nil, err
+		}
+
+		node := &memNode{
+			pub: pub,
+		}
+		for _, addrStr := range nodeDesc.Addrs {
+			addr, errr := net.ResolveTCPAddr("tcp", addrStr)
+			if errr != nil {
+				return nil, er.Errorf("invalid address %v of "+
+					"node %v: %v", addrStr, nodeDesc.PubKey,
+					errr)
+			}
+			node.addrs = append(node.addrs, addr)
+		}
+
+		m.graph[NewNodeID(pub)] = node
+	}
+
+	// lookupNode returns the node with the given hex encoded public key.
+	lookupNode := func(pubKey string) (*memNode, er.R) {
+		pub, err := parseDescribedPubKey(pubKey)
+		if err != nil {
+			return nil, err
+		}
+
+		node, ok := m.graph[NewNodeID(pub)]
+		if !ok {
+			return nil, er.Errorf("edge refers to unknown node %v",
+				pubKey)
+		}
+
+		return node, nil
+	}
+
+	for _, edgeDesc := range desc.Edges {
+		node, err := lookupNode(edgeDesc.Node)
+		if err != nil {
+			return nil, err
+		}
+		peer, err := lookupNode(edgeDesc.Peer)
+		if err != nil {
+			return nil, err
+		}
+
+		node.chans = append(node.chans, ChannelEdge{
+			ChanID:   lnwire.NewShortChanIDFromInt(edgeDesc.ChanID),
+			Capacity: edgeDesc.Capacity,
+			Peer:     peer,
+		})
+	}
+
+	return m, nil
+}
+
+// parseDescribedPubKey parses a hex encoded public key of a graph description.
+func parseDescribedPubKey(pubKey string) (*btcec.PublicKey, er.R) {
+	pubBytes, errr := hex.DecodeString(pubKey)
+	if errr != nil {
+		return nil, er.Errorf("invalid public key %v: %v", pubKey, errr)
+	}
+
+	pub, err := btcec.ParsePubKey(pubBytes, btcec.S256())
+	if err != nil {
+		return nil, er.Errorf("invalid public key %v: %v", pubKey, err)
+	}
+
+	return pub, nil
+}
@@ -0,0 +1,107 @@
+package autopilot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/stretchr/testify/require"
+)
+
+// memGraphSnapshot returns the addresses and channels of each node of the
+// graph.
+func memGraphSnapshot(t *testing.T,
+	graph *memChannelGraph) map[NodeID]*memNode {
+
+	snapshot := make(map[NodeID]*memNode)
+	err := graph.ForEachNode(func(n Node) er.R {
+		node := &memNode{
+			addrs: n.Addrs(),
+		}
+		snapshot[NodeID(n.PubKey())] = node
+
+		return n.ForEachChannel(func(e ChannelEdge) er.R {
+			// Only keep the key of the peer, as its channels are
+			// part of its own entry.
+			e.Peer = &memNode{
+				pub: e.Peer.(*memNode).pub,
+			}
+			node.chans = append(node.chans, e)
+
+			return nil
+		})
+	})
+	util.RequireNoErr(t, err)
+
+	return snapshot
+}
+
+// TestMemChannelGraphExportImport tests that a graph exported to JSON and
+// imported again has the same nodes and channels as the original one.
+func TestMemChannelGraphExportImport(t *testing.T) {
+	t.Parallel()
+
+	graph := newMemChannelGraph()
+	nodes := buildTestGraph(t, graph, centralityTestGraph)
+
+	// Add a few channels of distinct capacities, one of them to a node
+	// without addresses.
+	for i, capacity := range []btcutil.Amount{1, 12345, 21e14} {
+		_, _, err := graph.addRandChannel(
+			nodes[i], nodes[i+1], capacity,
+		)
+		util.RequireNoErr(t, err)
+	}
+	edge, _, err := graph.addRandChannel(nodes[0], nil, 777)
+	util.RequireNoErr(t, err)
+	edge.Peer.(*memNode).addrs = nil
+
+	desc := graph.Export()
+	require.Len(t, desc.Nodes, centralityTestGraph.nodes+1)
+
+	descJSON, errr := json.Marshal(desc)
+	require.NoError(t, errr)
+
+	var decodedDesc GraphDescription
+	require.NoError(t, json.Unmarshal(descJSON, &decodedDesc))
+
+	imported, err := ImportMemChannelGraph(decodedDesc)
+	util.RequireNoErr(t, err)
+
+	require.Equal(t, memGraphSnapshot(t, graph), memGraphSnapshot(t, imported))
+	require.Equal(t, desc, imported.Export())
+}
+
+// TestImportMemChannelGraphInvalid tests that invalid graph descriptions are
+// rejected.
+func TestImportMemChannelGraphInvalid(t *testing.T) {
+	t.Parallel()
+
+	graph := newMemChannelGraph()
+	_, _, err := graph.addRandChannel(nil, nil, 1000)
+	util.RequireNoErr(t, err)
+	desc := graph.Export()
+
+	invalidPubKey := desc
+	invalidPubKey.Nodes = []NodeDescription{
+		{PubKey: "02abcd"}, desc.Nodes[1],
+	}
+
+	invalidAddr := desc
+	invalidAddr.Nodes = []NodeDescription{
+		{PubKey: desc.Nodes[0].PubKey, Addrs: []string{"invalid"}},
+		desc.Nodes[1],
+	}
+
+	unknownPeer := desc
+	unknownPeer.Nodes = desc.Nodes[:1]
+
+	for _, desc := range []GraphDescription{
+		invalidPubKey, invalidAddr, unknownPeer,
+	} {
+		_, err := ImportMemChannelGraph(desc)
+		util.RequireErr(t, err)
+	}
+}
@@ -0,0 +1,136 @@
+package autopilot
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcec"
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/stretchr/testify/require"
+)
+
+// buildRingGraph adds the given number of nodes to the graph, each of which has
+// a channel with the next one.
+func buildRingGraph(tb testing.TB, graph testGraph, numNodes int) {
+	nodes := make([]*btcec.PublicKey, numNodes)
+	for i := range nodes {
+		key, err := graph.addRandNode()
+		util.RequireNoErr(tb, err, "cannot create random node")
+
+		nodes[i] = key
+	}
+
+	for i := range nodes {
+		_, _, err := graph.addRandChannel(
+			nodes[i], nodes[(i+1)%numNodes], btcutil.UnitsPerCoin(),
+		)
+		util.RequireNoErr(tb, err, "cannot create random channel")
+	}
+}
+
+// TestForEachNodeParallel tests that the parallel traversal of the database
+// graph visits the same nodes as the serial one, and stops once a callback
+// fails.
+func TestForEachNodeParallel(t *testing.T) {
+	t.Parallel()
+
+	const numNodes = 20
+
+	graph, cleanup, err := newDiskChanGraph()
+	util.RequireNoErr(t, err, "unable to create graph")
+	defer cleanup()
+
+	dbGraph := graph.(*databaseChannelGraph)
+	buildRingGraph(t, graph, numNodes)
+
+	// Count the channels of each node with the serial traversal.
+	expected := make(map[NodeID]int)
+	err = dbGraph.ForEachNode(func(n Node) er.R {
+		return n.ForEachChannel(func(ChannelEdge) er.R {
+			expected[NodeID(n.PubKey())]++
+			return nil
+		})
+	})
+	util.RequireNoErr(t, err)
+	require.Len(t, expected, numNodes)
+
+	// Each node must be visited exactly once, even with more workers than
+	// nodes.
+	for _, workers := range []int{1, 3, numNodes, 2 * numNodes} {
+		var mtx sync.Mutex
+		visited := make(map[NodeID]int)
+		err := dbGraph.ForEachNodeParallel(workers, func(n Node) er.R {
+			return n.ForEachChannel(func(ChannelEdge) er.R {
+				mtx.Lock()
+				defer mtx.Unlock()
+
+				visited[NodeID(n.PubKey())]++
+				return nil
+			})
+		})
+		util.RequireNoErr(t, err)
+		require.Equal(t, expected, visited, "workers=%d", workers)
+	}
+
+	// If every callback fails, each worker stops after its first node.
+	const workers = 4
+	var numCalls int32
+	errTest := er.New("test error")
+	err = dbGraph.ForEachNodeParallel(workers, func(Node) er.R {
+		atomic.AddInt32(&numCalls, 1)
+		return errTest
+	})
+	require.Equal(t, errTest, err)
+	require.LessOrEqual(t, atomic.LoadInt32(&numCalls), int32(workers))
+
+	err = dbGraph.ForEachNodeParallel(0, func(Node) er.R {
+		return nil
+	})
+	util.RequireErr(t, err)
+}
+
+// BenchmarkForEachNode compares the serial traversal of a database graph of
+// 10k nodes to the parallel one.
+func BenchmarkForEachNode(b *testing.B) {
+	const numNodes = 10000
+
+	graph, cleanup, err := newDiskChanGraph()
+	util.RequireNoErr(b, err, "unable to create graph")
+	defer cleanup()
+
+	dbGraph := graph.(*databaseChannelGraph)
+	buildRingGraph(b, graph, numNodes)
+
+	// countChannels is a callback which visits the channels of a node, as
+	// the autopilot heuristics do.
+	countChannels := func(n Node) er.R {
+		var numChans int
+		return n.ForEachChannel(func(ChannelEdge) er.R {
+			numChans++
+			return nil
+		})
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := dbGraph.ForEachNode(countChannels)
+			util.RequireNoErr(b, err)
+		}
+	})
+
+	for _, workers := range []int{2, 4, 8} {
+		workers := workers
+		b.Run(fmt.Sprintf("parallel-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				err := dbGraph.ForEachNodeParallel(
+					workers, countChannels,
+				)
+				util.RequireNoErr(b, err)
+			}
+		})
+	}
+}
@@ -48,3 +48,146 @@ func TestMedian(t *testing.T) {
 		}
 	}
 }
+
+// TestWeightedMedian tests the WeightedMedian method.
+func TestWeightedMedian(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		values  []btcutil.Amount
+		weights []btcutil.Amount
+		median  btcutil.Amount
+	}{
+		{
+			values:  []btcutil.Amount{},
+			weights: []btcutil.Amount{},
+			median:  0,
+		},
+		{
+			// Mismatched lengths.
+			values:  []btcutil.Amount{10, 20},
+			weights: []btcutil.Amount{1},
+			median:  0,
+		},
+		{
+			values:  []btcutil.Amount{10, 20, 30},
+			weights: []btcutil.Amount{0, 0, 0},
+			median:  0,
+		},
+		{
+			values:  []btcutil.Amount{10, 20, 30},
+			weights: []btcutil.Amount{1, 1, 1},
+			median:  20,
+		},
+		{
+			values:  []btcutil.Amount{10, 20},
+			weights: []btcutil.Amount{1, 1},
+			median:  15,
+		},
+		{
+			values:  []btcutil.Amount{10, 20, 30},
+			weights: []btcutil.Amount{1, 1, 5},
+			median:  30,
+		},
+		{
+			values:  []btcutil.Amount{30, 10, 20},
+			weights: []btcutil.Amount{5, 1, 1},
+			median:  30,
+		},
+		{
+			values:  []btcutil.Amount{100, 1000, 5000},
+			weights: []btcutil.Amount{3, 1, 1},
+			median:  100,
+		},
+		{
+			// The value with a negative weight is ignored.
+			values:  []btcutil.Amount{10, 20, 30},
+			weights: []btcutil.Amount{-1, 1, 1},
+			median:  25,
+		},
+	}
+
+	for _, test := range testCases {
+		res := autopilot.WeightedMedian(test.values, test.weights)
+		if res != test.median {
+			t.Fatalf("expected weighted median %v of %v with "+
+				"weights %v, got %v", test.median, test.values,
+				test.weights, res)
+		}
+	}
+}
+
+// TestPercentile tests the Percentile method.
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		values     []btcutil.Amount
+		p          float64
+		percentile btcutil.Amount
+	}{
+		{
+			values:     []btcutil.Amount{},
+			p:          75,
+			percentile: 0,
+		},
+		{
+			values:     []btcutil.Amount{10},
+			p:          75,
+			percentile: 10,
+		},
+		{
+			values:     []btcutil.Amount{10, 20},
+			p:          50,
+			percentile: 15,
+		},
+		{
+			values:     []btcutil.Amount{10, 20, 30, 40, 50},
+			p:          0,
+			percentile: 10,
+		},
+		{
+			values:     []btcutil.Amount{10, 20, 30, 40, 50},
+			p:          25,
+			percentile: 20,
+		},
+		{
+			values:     []btcutil.Amount{10, 20, 30, 40, 50},
+			p:          75,
+			percentile: 40,
+		},
+		{
+			values:     []btcutil.Amount{10, 20, 30, 40, 50},
+			p:          90,
+			percentile: 46,
+		},
+		{
+			values:     []btcutil.Amount{10, 20, 30, 40, 50},
+			p:          100,
+			percentile: 50,
+		},
+		{
+			values:     []btcutil.Amount{40, 10, 30, 20},
+			p:          75,
+			percentile: 32,
+		},
+		{
+			values:     []btcutil.Amount{40, 10, 30, 20},
+			p:          -5,
+			percentile: 10,
+		},
+		{
+			values:     []btcutil.Amount{40, 10, 30, 20},
+			p:          150,
+			percentile: 40,
+		},
+	}
+
+	for _, test := range testCases {
+		res := autopilot.Percentile(test.values, test.p)
+		if res != test.percentile {
+			t.Fatalf("expected percentile %v of %v to be %v, got %v",
+				test.p, test.values, test.percentile, res)
+		}
+	}
+}
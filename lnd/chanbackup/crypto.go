@@ -15,6 +15,14 @@ import (
 
 // TODO(roasbeef): interface in front of?
 
+var (
+	// ErrWrongBackupKey is returned if a backup can't be authenticated
+	// with the key derived from the key ring. This means that it was
+	// encrypted under a different key, or that it has been modified.
+	ErrWrongBackupKey = er.GenericErrorType.CodeWithDetail("ErrWrongBackupKey",
+		"backup not encrypted under the derived key")
+)
+
 // baseEncryptionKeyLoc is the KeyLocator that we'll use to derive the base
 // encryption key used for encrypting all static channel backups. We use this
 // to then derive the actual key that we'll use for encryption. We do this
@@ -134,7 +142,7 @@ func decryptPayloadFromReader(payload io.Reader,
 	}
 	plaintext, errr := cipher.Open(nil, nonce, ciphertext, nonce)
 	if errr != nil {
-		return nil, er.E(errr)
+		return nil, ErrWrongBackupKey.New("", er.E(errr))
 	}
 
 	return plaintext, nil
@@ -20,6 +20,10 @@ var (
 
 type mockKeyRing struct {
 	fail bool
+
+	// privKey overrides the key the backup encryption key is derived
+	// from, if set.
+	privKey []byte
 }
 
 func (m *mockKeyRing) DeriveNextKey(keyFam keychain.KeyFamily) (keychain.KeyDescriptor, er.R) {
@@ -30,7 +34,12 @@ func (m *mockKeyRing) DeriveKey(keyLoc keychain.KeyLocator) (keychain.KeyDescrip
 		return keychain.KeyDescriptor{}, er.Errorf("fail")
 	}
 
-	_, pub := btcec.PrivKeyFromBytes(btcec.S256(), testWalletPrivKey)
+	privKey := testWalletPrivKey
+	if m.privKey != nil {
+		privKey = m.privKey
+	}
+
+	_, pub := btcec.PrivKeyFromBytes(btcec.S256(), privKey)
 	return keychain.KeyDescriptor{
 		PubKey: pub,
 	}, nil
@@ -137,7 +146,7 @@ func TestInvalidKeyEncryption(t *testing.T) {
 	t.Parallel()
 
 	var b bytes.Buffer
-	err := encryptPayloadToWriter(b, &b, &mockKeyRing{true})
+	err := encryptPayloadToWriter(b, &b, &mockKeyRing{fail: true})
 	if err == nil {
 		t.Fatalf("expected error due to fail key gen")
 	}
@@ -149,7 +158,7 @@ func TestInvalidKeyDecrytion(t *testing.T) {
 	t.Parallel()
 
 	var b bytes.Buffer
-	_, err := decryptPayloadFromReader(&b, &mockKeyRing{true})
+	_, err := decryptPayloadFromReader(&b, &mockKeyRing{fail: true})
 	if err == nil {
 		t.Fatalf("expected error due to fail key gen")
 	}
@@ -2,11 +2,13 @@ package chanbackup
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/lnd/keychain"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
+	"github.com/kaotisk-hund/cjdcoind/wire"
 )
 
 // MultiBackupVersion denotes the version of the multi channel static channel
@@ -178,4 +180,52 @@ func (p *PackedMulti) Unpack(keyRing keychain.KeyRing) (*Multi, er.R) {
 	return &m, nil
 }
 
+// MergeChannelBackups combines several packed multi-channel backups, e.g. taken
+// at different points in time, into a single packed backup. The backups are
+// expected to be ordered from oldest to newest: if a channel is contained in
+// more than one of them, the entry of the last backup that contains it is
+// kept. All backups must be encrypted under the key derived from the passed
+// key ring, which is also used to pack the merged backup.
+func MergeChannelBackups(keyRing keychain.KeyRing,
+	backups ...PackedMulti) (PackedMulti, er.R) {
+
+	var (
+		merged  Multi
+		indexes = make(map[wire.OutPoint]int)
+	)
+	for i, packed := range backups {
+		multi, err := packed.Unpack(keyRing)
+		switch {
+		case ErrWrongBackupKey.Is(err):
+			return nil, ErrWrongBackupKey.New(fmt.Sprintf(
+				"backup #%d is encrypted under a different key",
+				i), err)
+
+		case err != nil:
+			return nil, err
+		}
+
+		// A channel that we've already seen in an older backup is
+		// replaced in place, so the merged backup keeps the order in
+		// which channels were first seen.
+		for _, single := range multi.StaticBackups {
+			idx, ok := indexes[single.FundingOutpoint]
+			if ok {
+				merged.StaticBackups[idx] = single
+				continue
+			}
+
+			indexes[single.FundingOutpoint] = len(merged.StaticBackups)
+			merged.StaticBackups = append(merged.StaticBackups, single)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := merged.PackToWriter(&b, keyRing); err != nil {
+		return nil, err
+	}
+
+	return PackedMulti(b.Bytes()), nil
+}
+
 // TODO(roasbsef): fuzz parsing
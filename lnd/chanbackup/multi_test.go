@@ -157,3 +157,63 @@ func TestPackedMultiUnpack(t *testing.T) {
 		t, multi.StaticBackups[0], unpackedMulti.StaticBackups[0],
 	)
 }
+
+// TestMergeChannelBackups tests that merging packed multis keeps every channel
+// once, preferring the entry of the newest backup, and that backups encrypted
+// under a different key are rejected.
+func TestMergeChannelBackups(t *testing.T) {
+	t.Parallel()
+
+	keyRing := &mockKeyRing{}
+
+	genSingle := func() Single {
+		channel, err := genRandomOpenChannelShell()
+		if err != nil {
+			t.Fatalf("unable to gen channel: %v", err)
+		}
+		return NewSingle(channel, []net.Addr{addr1})
+	}
+	pack := func(keyRing *mockKeyRing, singles ...Single) PackedMulti {
+		var b bytes.Buffer
+		multi := Multi{StaticBackups: singles}
+		if err := multi.PackToWriter(&b, keyRing); err != nil {
+			t.Fatalf("unable to pack multi: %v", err)
+		}
+		return PackedMulti(b.Bytes())
+	}
+
+	// The shared channel is contained in both backups, the newer one
+	// knowing an additional address for the remote node.
+	shared := genSingle()
+	sharedNew := shared
+	sharedNew.Addresses = []net.Addr{addr1, addr2}
+	unique := genSingle()
+
+	oldBackup := pack(keyRing, shared)
+	newBackup := pack(keyRing, sharedNew, unique)
+
+	packedMerged, err := MergeChannelBackups(keyRing, oldBackup, newBackup)
+	if err != nil {
+		t.Fatalf("unable to merge backups: %v", err)
+	}
+	merged, err := packedMerged.Unpack(keyRing)
+	if err != nil {
+		t.Fatalf("unable to unpack merged backup: %v", err)
+	}
+
+	if len(merged.StaticBackups) != 2 {
+		t.Fatalf("expected 2 singles, got %v",
+			len(merged.StaticBackups))
+	}
+	assertSingleEqual(t, sharedNew, merged.StaticBackups[0])
+	assertSingleEqual(t, unique, merged.StaticBackups[1])
+
+	// A backup encrypted under another key can't be merged.
+	otherKeyRing := &mockKeyRing{privKey: bytes.Repeat([]byte{0x02}, 32)}
+	otherBackup := pack(otherKeyRing, genSingle())
+
+	_, err = MergeChannelBackups(keyRing, oldBackup, otherBackup)
+	if !ErrWrongBackupKey.Is(err) {
+		t.Fatalf("expected wrong backup key error, got %v", err)
+	}
+}
@@ -412,7 +412,7 @@ func TestSinglePackStaticChanBackups(t *testing.T) {
 	// If we attempt to pack again, but force the key ring to fail, then
 	// the entire method should fail.
 	_, err = PackStaticChanBackups(
-		unpackedSingles, &mockKeyRing{true},
+		unpackedSingles, &mockKeyRing{fail: true},
 	)
 	if err == nil {
 		t.Fatalf("pack attempt should fail")
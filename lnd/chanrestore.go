@@ -1,6 +1,7 @@
 package lnd
 
 import (
+	"bytes"
 	"math"
 	"net"
 
@@ -40,6 +41,62 @@ type chanDBRestorer struct {
 	secretKeys keychain.SecretKeyRing
 
 	chainArb *contractcourt.ChainArbitrator
+
+	// ScanStartHeightHint maps the genesis hash of a chain to the height
+	// from which the chain is scanned when none of the restored channels
+	// have a known confirmation height. It allows networks other than
+	// bitcoin mainnet and testnet3 to avoid a rescan from genesis.
+	ScanStartHeightHint map[chainhash.Hash]uint32
+}
+
+// newChanDBRestorer returns a chanDBRestorer for the server's channel database
+// which scans the active chain from the configured height, if any, when
+// restoring channels that have no confirmation height.
+func newChanDBRestorer(s *server) *chanDBRestorer {
+	c := &chanDBRestorer{
+		db:         s.remoteChanDB,
+		secretKeys: s.cc.KeyRing,
+		chainArb:   s.chainArb,
+	}
+
+	if s.cfg.ChanRestoreScanHeight != 0 {
+		genesisHash := *s.cfg.ActiveNetParams.GenesisHash
+		c.ScanStartHeightHint = map[chainhash.Hash]uint32{
+			genesisHash: s.cfg.ChanRestoreScanHeight,
+		}
+	}
+
+	return c
+}
+
+// verifyShaChain sanity checks a shachain producer which was re-derived from a
+// channel backup. If rootPub is set, the root of the producer must match it.
+// If expectedSecret is set, the producer must regenerate it at expectedHeight.
+func verifyShaChain(producer shachain.Producer, rootPub *btcec.PublicKey,
+	expectedHeight uint64, expectedSecret *chainhash.Hash) er.R {
+
+	if rootPub != nil {
+		var b bytes.Buffer
+		if err := producer.Encode(&b); err != nil {
+			return err
+		}
+		_, pub := btcec.PrivKeyFromBytes(btcec.S256(), b.Bytes())
+		if !pub.IsEqual(rootPub) {
+			return er.Errorf("shachain root doesn't match public "+
+				"key %x", rootPub.SerializeCompressed())
+		}
+	}
+
+	secret, err := producer.AtIndex(expectedHeight)
+	if err != nil {
+		return err
+	}
+	if expectedSecret != nil && !secret.IsEqual(expectedSecret) {
+		return er.Errorf("shachain produced unexpected secret at "+
+			"height %v", expectedHeight)
+	}
+
+	return nil
 }
 
 // openChannelShell maps the static channel back up into an open channel
@@ -64,6 +121,18 @@ func (c *chanDBRestorer) openChannelShell(backup chanbackup.Single) (
 	}
 	shaChainProducer := shachain.NewRevocationProducer(*revRoot)
 
+	// Before we go any further, make sure that the producer we derived is
+	// actually the one this channel was created with. The backup doesn't
+	// carry any revocation secrets, so we can only check the root against
+	// the public key committed to in the backup.
+	err = verifyShaChain(
+		shaChainProducer, backup.ShaChainRootDesc.PubKey, 0, nil,
+	)
+	if err != nil {
+		return nil, er.Errorf("unable to verify shachain root for "+
+			"ChannelPoint(%v): %v", backup.FundingOutpoint, err)
+	}
+
 	// Each of the keys in our local channel config only have their
 	// locators populate, so we'll re-derive the raw key now as we'll need
 	// it in order to carry out the DLP protocol.
@@ -167,20 +236,9 @@ func (c *chanDBRestorer) RestoreChansFromSingles(backups ...chanbackup.Single) e
 	// In case there were only unconfirmed channels, we will have to scan
 	// the chain beginning from the launch date of SCBs.
 	if firstChanHeight == math.MaxUint32 {
-		chainHash := channelShells[0].Chan.ChainHash
-		switch {
-		case chainHash.IsEqual(chaincfg.MainNetParams.GenesisHash):
-			firstChanHeight = mainnetSCBLaunchBlock
-
-		case chainHash.IsEqual(chaincfg.TestNet3Params.GenesisHash):
-			firstChanHeight = testnetSCBLaunchBlock
-
-		default:
-			// Worst case: We have no height hint and start at
-			// block 1. Should only happen for SCBs in regtest,
-			// simnet and litecoin.
-			firstChanHeight = 1
-		}
+		firstChanHeight = c.scanStartHeight(
+			channelShells[0].Chan.ChainHash,
+		)
 	}
 
 	// If there were channels in the backup that were not confirmed at the
@@ -238,6 +296,28 @@ func (c *chanDBRestorer) RestoreChansFromSingles(backups ...chanbackup.Single) e
 	return nil
 }
 
+// scanStartHeight returns the height from which to scan the given chain for
+// restored channels that have no confirmation height of their own.
+func (c *chanDBRestorer) scanStartHeight(chainHash chainhash.Hash) uint32 {
+	if height, ok := c.ScanStartHeightHint[chainHash]; ok {
+		return height
+	}
+
+	switch {
+	case chainHash.IsEqual(chaincfg.MainNetParams.GenesisHash):
+		return mainnetSCBLaunchBlock
+
+	case chainHash.IsEqual(chaincfg.TestNet3Params.GenesisHash):
+		return testnetSCBLaunchBlock
+
+	default:
+		// Worst case: We have no height hint and start at block 1.
+		// Should only happen for SCBs in regtest, simnet and networks
+		// without a configured hint.
+		return 1
+	}
+}
+
 // A compile-time constraint to ensure chanDBRestorer implements
 // chanbackup.ChannelRestorer.
 var _ chanbackup.ChannelRestorer = (*chanDBRestorer)(nil)
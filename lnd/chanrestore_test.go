@@ -0,0 +1,94 @@
+package lnd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcec"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg/chainhash"
+	"github.com/kaotisk-hund/cjdcoind/lnd/chainreg"
+	"github.com/kaotisk-hund/cjdcoind/lnd/shachain"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyShaChain ensures that a re-derived shachain producer is only
+// accepted if it matches the committed root and the expected secret.
+func TestVerifyShaChain(t *testing.T) {
+	t.Parallel()
+
+	var root chainhash.Hash
+	copy(root[:], bytes.Repeat([]byte{0x01}, 32))
+	producer := shachain.NewRevocationProducer(root)
+	_, rootPub := btcec.PrivKeyFromBytes(btcec.S256(), root[:])
+
+	secret, err := producer.AtIndex(5)
+	util.RequireNoErr(t, err)
+
+	// The producer matches both the root and the secret.
+	util.RequireNoErr(t, verifyShaChain(producer, rootPub, 5, secret))
+
+	// Without an expected root or secret there's nothing to mismatch.
+	util.RequireNoErr(t, verifyShaChain(producer, nil, 0, nil))
+
+	// A producer derived from the wrong key is rejected.
+	var wrongRoot chainhash.Hash
+	copy(wrongRoot[:], bytes.Repeat([]byte{0x02}, 32))
+	wrongProducer := shachain.NewRevocationProducer(wrongRoot)
+	util.RequireErr(t, verifyShaChain(wrongProducer, rootPub, 5, nil))
+
+	// A secret from a different height is rejected.
+	util.RequireErr(t, verifyShaChain(producer, rootPub, 6, secret))
+}
+
+// TestScanStartHeightHint ensures that a configured height hint is used as the
+// scan start height for restored channels without a known confirmation height.
+func TestScanStartHeightHint(t *testing.T) {
+	t.Parallel()
+
+	pktGenesis := *chaincfg.PktMainNetParams.GenesisHash
+	mainnetGenesis := *chaincfg.MainNetParams.GenesisHash
+
+	restorer := &chanDBRestorer{}
+
+	// Without a hint we fall back to the built-in heights, and to block 1
+	// for unknown networks.
+	require.Equal(
+		t, uint32(mainnetSCBLaunchBlock),
+		restorer.scanStartHeight(mainnetGenesis),
+	)
+	require.Equal(t, uint32(1), restorer.scanStartHeight(pktGenesis))
+
+	// A hint takes precedence for the chain it was given for only.
+	restorer.ScanStartHeightHint = map[chainhash.Hash]uint32{
+		pktGenesis: 1200000,
+	}
+	require.Equal(t, uint32(1200000), restorer.scanStartHeight(pktGenesis))
+	require.Equal(
+		t, uint32(mainnetSCBLaunchBlock),
+		restorer.scanStartHeight(mainnetGenesis),
+	)
+}
+
+// TestNewChanDBRestorerScanHeight ensures that the configured scan start height
+// is used as the hint for the active chain.
+func TestNewChanDBRestorerScanHeight(t *testing.T) {
+	t.Parallel()
+
+	pktGenesis := *chaincfg.PktMainNetParams.GenesisHash
+	s := &server{
+		cfg: &Config{
+			ActiveNetParams: chainreg.PktMainNetParams,
+		},
+		cc: &chainreg.ChainControl{},
+	}
+
+	// Without a configured height we scan from block 1 on PKT.
+	restorer := newChanDBRestorer(s)
+	require.Equal(t, uint32(1), restorer.scanStartHeight(pktGenesis))
+
+	s.cfg.ChanRestoreScanHeight = 1200000
+	restorer = newChanDBRestorer(s)
+	require.Equal(t, uint32(1200000), restorer.scanStartHeight(pktGenesis))
+}
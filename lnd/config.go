@@ -257,6 +257,8 @@ type Config struct {
 
 	ResetWalletTransactions bool `long:"reset-wallet-transactions" description:"Removes all transaction history from the on-chain wallet on startup, forcing a full chain rescan starting at the wallet's birthday. Implements the same functionality as btcwallet's dropwtxmgr command. Should be set to false after successful execution to avoid rescanning on every restart of lnd."`
 
+	ChanRestoreScanHeight uint32 `long:"chanrestorescanheight" description:"The block height from which the chain is scanned when restoring channels from a backup that were unconfirmed at backup time. Defaults to the launch of static channel backups on bitcoin mainnet and testnet3, and to block 1 on other networks, so setting it on PKT avoids scanning the whole chain."`
+
 	PaymentsExpirationGracePeriod time.Duration `long:"payments-expiration-grace-period" description:"A period to wait before force closing channels with outgoing htlcs that have timed-out and are a result of this node initiated payments."`
 	TrickleDelay                  int           `long:"trickledelay" description:"Time in milliseconds between each release of announcements to the network"`
 	ChanEnableTimeout             time.Duration `long:"chan-enable-timeout" description:"The duration that a peer connection must be stable before attempting to send a channel update to reenable or cancel a pending disables of the peer's channels on the network."`
@@ -279,6 +281,8 @@ type Config struct {
 
 	RejectHTLC bool `long:"rejecthtlc" description:"If true, lnd will not forward any HTLCs that are meant as onward payments. This option will still allow lnd to send HTLCs and receive HTLCs but lnd won't be used as a hop."`
 
+	MaxForwardedHTLC uint64 `long:"maxforwardedhtlc" description:"The largest amount in millisatoshi lnd forwards in a single HTLC, regardless of the policies of its channels. 0 means there is no limit."`
+
 	StaggerInitialReconnect bool `long:"stagger-initial-reconnect" description:"If true, will apply a randomized staggering between 0s and 30s when reconnecting to persistent peers on startup. The first 10 reconnections will be attempted instantly, regardless of the flag's value"`
 
 	MaxOutgoingCltvExpiry uint32 `long:"max-cltv-expiry" description:"The maximum number of blocks funds could be locked up for when forwarding payments."`
@@ -621,6 +625,7 @@ func ValidateConfig(cfg Config, usageMessage string) (*Config, er.R) {
 	cfg.LitecoindMode.Dir = CleanAndExpandPath(cfg.LitecoindMode.Dir)
 	cfg.Tor.PrivateKeyPath = CleanAndExpandPath(cfg.Tor.PrivateKeyPath)
 	cfg.Tor.WatchtowerKeyPath = CleanAndExpandPath(cfg.Tor.WatchtowerKeyPath)
+	cfg.Tor.PasswordFile = CleanAndExpandPath(cfg.Tor.PasswordFile)
 	cfg.Watchtower.TowerDir = CleanAndExpandPath(cfg.Watchtower.TowerDir)
 
 	// Create the lnd directory and all other sub directories if they don't
@@ -779,6 +784,9 @@ func ValidateConfig(cfg Config, usageMessage string) (*Config, er.R) {
 	case cfg.DisableListen && (cfg.Tor.V2 || cfg.Tor.V3):
 		return nil, er.New("listening must be enabled when " +
 			"enabling inbound connections over Tor")
+	case cfg.Tor.Password != "" && cfg.Tor.PasswordFile != "":
+		return nil, er.New("either tor.password or tor.passwordfile " +
+			"can be set, but not both")
 	}
 
 	if cfg.Tor.PrivateKeyPath == "" {
@@ -0,0 +1,156 @@
+package contractcourt
+
+import (
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
+	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
+	"github.com/kaotisk-hund/cjdcoind/lnd/input"
+	"github.com/kaotisk-hund/cjdcoind/lnd/sweep"
+	"github.com/kaotisk-hund/cjdcoind/wire"
+)
+
+// TestAnchorResolver tests that the anchor resolver offers the anchor to the
+// sweeper and reports the outcome of the sweep once it is known.
+func TestAnchorResolver(t *testing.T) {
+	t.Parallel()
+
+	const anchorValue = 330
+
+	tests := []struct {
+		name      string
+		sweepErr  *er.ErrorCode
+		outcome   channeldb.ResolverOutcome
+		recovered btcutil.Amount
+		expectErr bool
+	}{
+		{
+			name:      "swept",
+			outcome:   channeldb.ResolverOutcomeClaimed,
+			recovered: anchorValue,
+		},
+		{
+			name:     "remote spend",
+			sweepErr: sweep.ErrRemoteSpend,
+			outcome:  channeldb.ResolverOutcomeUnclaimed,
+		},
+		{
+			// The commitment confirmed without our help and the
+			// anchor isn't worth sweeping, so the sweeper gives
+			// up on it.
+			name:     "abandoned",
+			sweepErr: sweep.ErrTooManyAttempts,
+			outcome:  channeldb.ResolverOutcomeUnclaimed,
+		},
+		{
+			name:      "sweep error",
+			sweepErr:  sweep.ErrSweeperShuttingDown,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			testAnchorResolver(
+				t, anchorValue, test.sweepErr, test.outcome,
+				test.recovered, test.expectErr,
+			)
+		})
+	}
+}
+
+func testAnchorResolver(t *testing.T, anchorValue int64,
+	sweepErr *er.ErrorCode, outcome channeldb.ResolverOutcome,
+	recovered btcutil.Amount, expectErr bool) {
+
+	defer timeout(t)()
+
+	sweeper := newMockSweeper()
+	sweeper.sweepErr = sweepErr
+
+	reportChan := make(chan *channeldb.ResolverReport, 1)
+	cfg := ResolverConfig{
+		ChannelArbitratorConfig: ChannelArbitratorConfig{
+			ChainArbitratorConfig: ChainArbitratorConfig{
+				Sweeper: sweeper,
+			},
+			PutResolverReport: func(_ kvdb.RwTx,
+				report *channeldb.ResolverReport) er.R {
+
+				reportChan <- report
+				return nil
+			},
+		},
+	}
+
+	anchor := wire.OutPoint{Index: 2}
+	signDesc := input.SignDescriptor{
+		Output: &wire.TxOut{
+			Value: anchorValue,
+		},
+		WitnessScript: []byte{0},
+	}
+	resolver := newAnchorResolver(signDesc, anchor, 0, wire.OutPoint{}, cfg)
+
+	resultChan := make(chan resolveResult, 1)
+	go func() {
+		nextResolver, err := resolver.Resolve()
+		resultChan <- resolveResult{
+			nextResolver: nextResolver,
+			err:          err,
+		}
+	}()
+
+	// The anchor should be offered to the sweeper.
+	swept := <-sweeper.sweptInputs
+	if *swept.OutPoint() != anchor {
+		t.Fatalf("expected anchor %v to be swept, got %v", anchor,
+			*swept.OutPoint())
+	}
+	if swept.WitnessType() != input.CommitmentAnchor {
+		t.Fatalf("unexpected witness type %v", swept.WitnessType())
+	}
+
+	result := <-resultChan
+	if expectErr {
+		if result.err == nil {
+			t.Fatal("expected resolution to fail")
+		}
+		if resolver.IsResolved() {
+			t.Fatal("resolver should not be resolved")
+		}
+		return
+	}
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if result.nextResolver != nil {
+		t.Fatal("expected no next resolver")
+	}
+	if !resolver.IsResolved() {
+		t.Fatal("expected resolver to be resolved")
+	}
+
+	report := <-reportChan
+	if report.ResolverType != channeldb.ResolverTypeAnchor {
+		t.Fatalf("unexpected resolver type %v", report.ResolverType)
+	}
+	if report.ResolverOutcome != outcome {
+		t.Fatalf("expected outcome %v, got %v", outcome,
+			report.ResolverOutcome)
+	}
+
+	contractReport := resolver.report()
+	if contractReport.LimboBalance != 0 {
+		t.Fatalf("expected no limbo balance, got %v",
+			contractReport.LimboBalance)
+	}
+	if contractReport.RecoveredBalance != recovered {
+		t.Fatalf("expected recovered balance %v, got %v", recovered,
+			contractReport.RecoveredBalance)
+	}
+}
@@ -223,7 +223,7 @@ func assertResolversEqual(t *testing.T, originalResolver ContractResolver,
 	case *htlcIncomingContestResolver:
 		diskRes := diskResolver.(*htlcIncomingContestResolver)
 		assertSuccessResEqual(
-			&ogRes.htlcSuccessResolver, &diskRes.htlcSuccessResolver,
+			ogRes.htlcSuccessResolver, diskRes.htlcSuccessResolver,
 		)
 
 		if ogRes.htlcExpiry != diskRes.htlcExpiry {
@@ -298,7 +298,6 @@ func TestContractInsertionRetrieval(t *testing.T) {
 		htlc: channeldb.HTLC{
 			RHash: testPreimage,
 		},
-		sweepTx: nil,
 	}
 	resolvers := []ContractResolver{
 		&timeoutResolver,
@@ -323,7 +322,13 @@ func TestContractInsertionRetrieval(t *testing.T) {
 	resolvers = append(resolvers, &htlcOutgoingContestResolver{
 		htlcTimeoutResolver: contestTimeout,
 	})
-	contestSuccess := successResolver
+	contestSuccess := &htlcSuccessResolver{
+		htlcResolution:   successResolver.htlcResolution,
+		outputIncubating: successResolver.outputIncubating,
+		resolved:         successResolver.resolved,
+		broadcastHeight:  successResolver.broadcastHeight,
+		htlc:             successResolver.htlc,
+	}
 	contestSuccess.htlcResolution.ClaimOutpoint = randOutPoint()
 	resolvers = append(resolvers, &htlcIncomingContestResolver{
 		htlcExpiry:          100,
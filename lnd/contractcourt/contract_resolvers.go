@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
 	"github.com/kaotisk-hund/cjdcoind/wire"
 )
@@ -64,6 +65,42 @@ type htlcContractResolver interface {
 	// Supplement adds additional information to the resolver that is
 	// required before Resolve() is called.
 	Supplement(htlc channeldb.HTLC)
+
+	// Examine returns how the resolver would resolve the htlc, without
+	// publishing or incubating anything. It is safe to call concurrently
+	// with Resolve.
+	Examine() (ResolutionPlan, er.R)
+}
+
+// ResolutionPlan describes how an htlc resolver would resolve its htlc. It is
+// meant for diagnostics only.
+type ResolutionPlan struct {
+	// Txs are copies of the pre-signed transactions the resolver would
+	// publish or hand to the incubator, in the order they'd confirm.
+	Txs []*wire.MsgTx
+
+	// Outpoints are the outpoints the resolver would wait to be spent, in
+	// the order they'd be spent. The first one is the htlc output on the
+	// commitment transaction.
+	Outpoints []wire.OutPoint
+
+	// Incubate is true if the resolver would hand the htlc output to the
+	// incubator.
+	Incubate bool
+
+	// Sweep is true if the resolver would offer the htlc output to the
+	// sweeper.
+	Sweep bool
+
+	// Outcome is the outcome the resolution is expected to have.
+	Outcome channeldb.ResolverOutcome
+
+	// PreimageAvailable is true if the preimage of the htlc is known.
+	PreimageAvailable bool
+
+	// CsvDelay is the relative delay of the output that is finally swept,
+	// or zero if there is none.
+	CsvDelay uint32
 }
 
 // reportingContractResolver is a ContractResolver that also exposes a report on
@@ -87,6 +124,11 @@ type ResolverConfig struct {
 	// which contains information about the outcome and should be written
 	// to disk if non-nil.
 	Checkpoint func(ContractResolver, ...*channeldb.ResolverReport) er.R
+
+	// SweepConfTarget is the confirmation target used by the resolver
+	// when it sweeps an output directly. If zero, sweepConfTarget is used.
+	// Resolvers racing a deadline may still pick a lower target.
+	SweepConfTarget uint32
 }
 
 // contractResolverKit is meant to be used as a mix-in struct to be embedded within a
@@ -106,6 +148,36 @@ func newContractResolverKit(cfg ResolverConfig) *contractResolverKit {
 	}
 }
 
+// deadlineConfTarget returns the confirmation target for a sweep that needs to
+// confirm before the given expiry height. The configured target is lowered to
+// the number of blocks left until the expiry, so that claims close to their
+// deadline are swept more aggressively than routine resolutions.
+func (r *contractResolverKit) deadlineConfTarget(expiry uint32) uint32 {
+	target := r.SweepConfTarget
+	if target == 0 {
+		target = sweepConfTarget
+	}
+
+	_, bestHeight, err := r.ChainIO.GetBestBlock()
+	if err != nil {
+		log.Warnf("Unable to determine best height, using conf "+
+			"target %v: %v", target, err)
+		return target
+	}
+
+	// Once the deadline has been reached there is no time left to wait,
+	// so we'll aim for the next block.
+	if int64(expiry) <= int64(bestHeight)+1 {
+		return 1
+	}
+
+	if blocksLeft := expiry - uint32(bestHeight); blocksLeft < target {
+		target = blocksLeft
+	}
+
+	return target
+}
+
 var (
 	// errResolverShuttingDown is returned when the resolver stops
 	// progressing because it received the quit signal.
@@ -31,7 +31,7 @@ type htlcIncomingContestResolver struct {
 
 	// htlcSuccessResolver is the inner resolver that may be utilized if we
 	// learn of the preimage.
-	htlcSuccessResolver
+	*htlcSuccessResolver
 }
 
 // newIncomingContestResolver instantiates a new incoming htlc contest resolver.
@@ -45,7 +45,7 @@ func newIncomingContestResolver(
 
 	return &htlcIncomingContestResolver{
 		htlcExpiry:          htlc.RefundTimeout,
-		htlcSuccessResolver: *success,
+		htlcSuccessResolver: success,
 	}
 }
 
@@ -149,7 +149,11 @@ func (h *htlcIncomingContestResolver) Resolve() (ContractResolver, er.R) {
 			return er.New("preimage does not match hash")
 		}
 
-		// Update htlcResolution with the matching preimage.
+		// Update htlcResolution with the matching preimage. The lock
+		// is held, as Examine may read it concurrently.
+		h.progressLock.Lock()
+		defer h.progressLock.Unlock()
+
 		h.htlcResolution.Preimage = preimage
 
 		log.Infof("%T(%v): extracted preimage=%v from beacon!", h,
@@ -189,7 +193,7 @@ func (h *htlcIncomingContestResolver) Resolve() (ContractResolver, er.R) {
 				return nil, err
 			}
 
-			return &h.htlcSuccessResolver, nil
+			return h.htlcSuccessResolver, nil
 
 		// If the htlc was failed, mark the htlc as
 		// resolved.
@@ -293,7 +297,7 @@ func (h *htlcIncomingContestResolver) Resolve() (ContractResolver, er.R) {
 				return nil, err
 			}
 
-			return &h.htlcSuccessResolver, nil
+			return h.htlcSuccessResolver, nil
 		}
 
 		witnessUpdates = preimageSubscription.WitnessUpdates
@@ -315,7 +319,7 @@ func (h *htlcIncomingContestResolver) Resolve() (ContractResolver, er.R) {
 			// We've learned of the preimage and this information
 			// has been added to our inner resolver. We return it so
 			// it can continue contract resolution.
-			return &h.htlcSuccessResolver, nil
+			return h.htlcSuccessResolver, nil
 
 		case hodlItem := <-hodlChan:
 			htlcResolution := hodlItem.(invoices.HtlcResolution)
@@ -420,7 +424,7 @@ func newIncomingContestResolverFromReader(r io.Reader, resCfg ResolverConfig) (
 	if err != nil {
 		return nil, err
 	}
-	h.htlcSuccessResolver = *successResolver
+	h.htlcSuccessResolver = successResolver
 
 	return h, nil
 }
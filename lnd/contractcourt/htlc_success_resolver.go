@@ -1,7 +1,9 @@
 package contractcourt
 
 import (
+	"fmt"
 	"io"
+	"sync"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
@@ -11,12 +13,87 @@ import (
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
 	"github.com/kaotisk-hund/cjdcoind/lnd/input"
 	"github.com/kaotisk-hund/cjdcoind/lnd/labels"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lntypes"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet"
 	"github.com/kaotisk-hund/cjdcoind/lnd/sweep"
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
 	"github.com/kaotisk-hund/cjdcoind/wire"
 )
 
+// ResolverStage is the stage an htlcSuccessResolver is in.
+type ResolverStage uint8
+
+const (
+	// ResolverStageInit means that the resolver hasn't started resolving
+	// the HTLC yet.
+	ResolverStageInit ResolverStage = iota
+
+	// ResolverStageCraftingSweep means that the resolver is offering the
+	// HTLC on the remote commitment to the sweeper.
+	ResolverStageCraftingSweep
+
+	// ResolverStageAwaitingSweepConf means that the sweeper is sweeping
+	// the HTLC on the remote commitment, and the resolver waits for the
+	// sweep to confirm. As the sweeper may replace its sweep tx to bump
+	// the fee, the txid is only known once the sweep has confirmed.
+	ResolverStageAwaitingSweepConf
+
+	// ResolverStageIncubating means that the second-level success tx of
+	// the HTLC on our commitment is broadcast and its output is being
+	// handed to the incubator.
+	ResolverStageIncubating
+
+	// ResolverStageAwaitingSecondLevelSpend means that the output of the
+	// second-level success tx is incubating, and the resolver waits for it
+	// to be swept once its CSV delay has passed.
+	ResolverStageAwaitingSecondLevelSpend
+
+	// ResolverStageResolved means that the HTLC is fully resolved.
+	ResolverStageResolved
+)
+
+// String returns a human readable version of the resolver stage.
+func (s ResolverStage) String() string {
+	switch s {
+	case ResolverStageInit:
+		return "Init"
+
+	case ResolverStageCraftingSweep:
+		return "CraftingSweep"
+
+	case ResolverStageAwaitingSweepConf:
+		return "AwaitingSweepConf"
+
+	case ResolverStageIncubating:
+		return "Incubating"
+
+	case ResolverStageAwaitingSecondLevelSpend:
+		return "AwaitingSecondLevelSpend"
+
+	case ResolverStageResolved:
+		return "Resolved"
+
+	default:
+		return fmt.Sprintf("ResolverStage(%d)", uint8(s))
+	}
+}
+
+// ResolverProgress describes how far an htlcSuccessResolver got in resolving
+// its HTLC.
+type ResolverProgress struct {
+	// Stage is the current stage of the resolver.
+	Stage ResolverStage
+
+	// Txid is the hash of the tx the current stage is concerned with, if
+	// known. This is the second-level success tx while incubating, and the
+	// final spending tx once resolved.
+	Txid *chainhash.Hash
+
+	// NumConfs is the number of confirmations seen of the tx identified by
+	// Txid.
+	NumConfs uint32
+}
+
 // htlcSuccessResolver is a resolver that's capable of sweeping an incoming
 // HTLC output on-chain. If this is the remote party's commitment, we'll sweep
 // it directly from the commitment output *immediately*. If this is our
@@ -42,16 +119,18 @@ type htlcSuccessResolver struct {
 	// historical queries to the chain for spends/confirmations.
 	broadcastHeight uint32
 
-	// sweepTx will be non-nil if we've already crafted a transaction to
-	// sweep a direct HTLC output. This is only a concern if we're sweeping
-	// from the commitment transaction of the remote party.
-	//
-	// TODO(roasbeef): send off to utxobundler
-	sweepTx *wire.MsgTx
-
 	// htlc contains information on the htlc that we are resolving on-chain.
 	htlc channeldb.HTLC
 
+	// progress is the current progress of the resolver for reporting
+	// purposes.
+	progress ResolverProgress
+
+	// progressLock prevents concurrent access to the resolver progress.
+	// It also guards the preimage and the success tx witness, as the
+	// incoming contest resolver fills them in while resolving.
+	progressLock sync.Mutex
+
 	contractResolverKit
 }
 
@@ -107,102 +186,7 @@ func (h *htlcSuccessResolver) Resolve() (ContractResolver, er.R) {
 	// If we don't have a success transaction, then this means that this is
 	// an output on the remote party's commitment transaction.
 	if h.htlcResolution.SignedSuccessTx == nil {
-		// If we don't already have the sweep transaction constructed,
-		// we'll do so and broadcast it.
-		if h.sweepTx == nil {
-			log.Infof("%T(%x): crafting sweep tx for "+
-				"incoming+remote htlc confirmed", h,
-				h.htlc.RHash[:])
-
-			// Before we can craft out sweeping transaction, we
-			// need to create an input which contains all the items
-			// required to add this input to a sweeping transaction,
-			// and generate a witness.
-			inp := input.MakeHtlcSucceedInput(
-				&h.htlcResolution.ClaimOutpoint,
-				&h.htlcResolution.SweepSignDesc,
-				h.htlcResolution.Preimage[:],
-				h.broadcastHeight,
-				h.htlcResolution.CsvDelay,
-			)
-
-			// With the input created, we can now generate the full
-			// sweep transaction, that we'll use to move these
-			// coins back into the backing wallet.
-			//
-			// TODO: Set tx lock time to current block height
-			// instead of zero. Will be taken care of once sweeper
-			// implementation is complete.
-			//
-			// TODO: Use time-based sweeper and result chan.
-			var err er.R
-			h.sweepTx, err = h.Sweeper.CreateSweepTx(
-				[]input.Input{&inp},
-				sweep.FeePreference{
-					ConfTarget: sweepConfTarget,
-				}, 0,
-			)
-			if err != nil {
-				return nil, err
-			}
-
-			log.Infof("%T(%x): crafted sweep tx=%v", h,
-				h.htlc.RHash[:], spew.Sdump(h.sweepTx))
-
-			// With the sweep transaction signed, we'll now
-			// Checkpoint our state.
-			if err := h.Checkpoint(h); err != nil {
-				log.Errorf("unable to Checkpoint: %v", err)
-				return nil, err
-			}
-		}
-
-		// Regardless of whether an existing transaction was found or newly
-		// constructed, we'll broadcast the sweep transaction to the
-		// network.
-		label := labels.MakeLabel(
-			labels.LabelTypeChannelClose, &h.ShortChanID,
-		)
-		err := h.PublishTx(h.sweepTx, label)
-		if err != nil {
-			log.Infof("%T(%x): unable to publish tx: %v",
-				h, h.htlc.RHash[:], err)
-			return nil, err
-		}
-
-		// With the sweep transaction broadcast, we'll wait for its
-		// confirmation.
-		sweepTXID := h.sweepTx.TxHash()
-		sweepScript := h.sweepTx.TxOut[0].PkScript
-		confNtfn, err := h.Notifier.RegisterConfirmationsNtfn(
-			&sweepTXID, sweepScript, 1, h.broadcastHeight,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		log.Infof("%T(%x): waiting for sweep tx (txid=%v) to be "+
-			"confirmed", h, h.htlc.RHash[:], sweepTXID)
-
-		select {
-		case _, ok := <-confNtfn.Confirmed:
-			if !ok {
-				return nil, errResolverShuttingDown.Default()
-			}
-
-		case <-h.quit:
-			return nil, errResolverShuttingDown.Default()
-		}
-
-		// Once the transaction has received a sufficient number of
-		// confirmations, we'll mark ourselves as fully resolved and exit.
-		h.resolved = true
-
-		// Checkpoint the resolver, and write the outcome to disk.
-		return nil, h.checkpointClaim(
-			&sweepTXID,
-			channeldb.ResolverOutcomeClaimed,
-		)
+		return h.resolveRemoteCommitOutput()
 	}
 
 	log.Infof("%T(%x): broadcasting second-layer transition tx: %v",
@@ -212,6 +196,11 @@ func (h *htlcSuccessResolver) Resolve() (ContractResolver, er.R) {
 	// the claiming process.
 	//
 	// TODO(roasbeef): after changing sighashes send to tx bundler
+	successTxid := h.htlcResolution.SignedSuccessTx.TxHash()
+	if !h.outputIncubating {
+		h.setProgress(ResolverStageIncubating, &successTxid, 0)
+	}
+
 	label := labels.MakeLabel(
 		labels.LabelTypeChannelClose, &h.ShortChanID,
 	)
@@ -245,6 +234,8 @@ func (h *htlcSuccessResolver) Resolve() (ContractResolver, er.R) {
 
 	// To wrap this up, we'll wait until the second-level transaction has
 	// been spent, then fully resolve the contract.
+	h.setProgress(ResolverStageAwaitingSecondLevelSpend, &successTxid, 0)
+
 	spendNtfn, err := h.Notifier.RegisterSpendNtfn(
 		&h.htlcResolution.ClaimOutpoint,
 		h.htlcResolution.SweepSignDesc.Output.PkScript,
@@ -270,11 +261,183 @@ func (h *htlcSuccessResolver) Resolve() (ContractResolver, er.R) {
 	}
 
 	h.resolved = true
+	h.setProgress(ResolverStageResolved, spendTxid, 1)
+
 	return nil, h.checkpointClaim(
 		spendTxid, channeldb.ResolverOutcomeClaimed,
 	)
 }
 
+// setProgress updates the progress of the resolver.
+func (h *htlcSuccessResolver) setProgress(stage ResolverStage,
+	txid *chainhash.Hash, numConfs uint32) {
+
+	h.progressLock.Lock()
+	defer h.progressLock.Unlock()
+
+	h.progress = ResolverProgress{
+		Stage:    stage,
+		Txid:     txid,
+		NumConfs: numConfs,
+	}
+}
+
+// restoreProgress derives the progress of the resolver from its persisted
+// state. As only whether the output is incubating and whether the HTLC is
+// resolved are persisted, this is the furthest stage the resolver is known to
+// have reached.
+func (h *htlcSuccessResolver) restoreProgress() {
+	switch {
+	case h.resolved:
+		h.setProgress(ResolverStageResolved, nil, 0)
+
+	case h.outputIncubating && h.htlcResolution.SignedSuccessTx != nil:
+		successTxid := h.htlcResolution.SignedSuccessTx.TxHash()
+		h.setProgress(
+			ResolverStageAwaitingSecondLevelSpend, &successTxid, 0,
+		)
+
+	default:
+		h.setProgress(ResolverStageInit, nil, 0)
+	}
+}
+
+// Progress returns the current progress of the resolver. It is safe to call
+// concurrently with Resolve.
+func (h *htlcSuccessResolver) Progress() ResolverProgress {
+	h.progressLock.Lock()
+	defer h.progressLock.Unlock()
+
+	return h.progress
+}
+
+// Examine returns how the resolver would resolve the htlc, without publishing
+// or incubating anything. If the preimage isn't known, which may be the case
+// for the incoming contest resolver embedding this resolver, the htlc is
+// expected to time out instead.
+//
+// NOTE: Part of the htlcContractResolver interface.
+func (h *htlcSuccessResolver) Examine() (ResolutionPlan, er.R) {
+	h.progressLock.Lock()
+	preimage := lntypes.Preimage(h.htlcResolution.Preimage)
+	var successTx *wire.MsgTx
+	if h.htlcResolution.SignedSuccessTx != nil {
+		successTx = h.htlcResolution.SignedSuccessTx.Copy()
+	}
+	h.progressLock.Unlock()
+
+	preimageAvailable := preimage.Matches(h.htlc.RHash)
+	if !preimageAvailable {
+		_, preimageAvailable = h.PreimageDB.LookupPreimage(h.htlc.RHash)
+	}
+
+	plan := ResolutionPlan{
+		Outpoints:         []wire.OutPoint{h.HtlcPoint()},
+		Outcome:           channeldb.ResolverOutcomeClaimed,
+		PreimageAvailable: preimageAvailable,
+		CsvDelay:          h.htlcResolution.CsvDelay,
+	}
+
+	switch {
+	// Without the preimage, we can't claim the htlc, so we'll wait for it
+	// to expire.
+	case !preimageAvailable:
+		plan.Outcome = channeldb.ResolverOutcomeTimeout
+
+	// Without a success tx, the htlc output on the remote commitment is
+	// offered to the sweeper.
+	case successTx == nil:
+		plan.Sweep = true
+
+	// Otherwise, the success tx is published and its output is handed to
+	// the incubator.
+	default:
+		plan.Txs = []*wire.MsgTx{successTx}
+		plan.Outpoints = append(
+			plan.Outpoints, h.htlcResolution.ClaimOutpoint,
+		)
+		plan.Incubate = true
+	}
+
+	return plan, nil
+}
+
+// resolveRemoteCommitOutput sweeps the HTLC output on the remote commitment
+// directly. The output is handed to the sweeper rather than swept by a tx of
+// our own, so the sweep is bumped through RBF if fees rise before it
+// confirms.
+func (h *htlcSuccessResolver) resolveRemoteCommitOutput() (
+	ContractResolver, er.R) {
+
+	h.setProgress(ResolverStageCraftingSweep, nil, 0)
+
+	log.Infof("%T(%x): offering incoming+remote htlc output to sweeper",
+		h, h.htlc.RHash[:])
+
+	// Before we can sweep the output, we need to create an input which
+	// contains all the items required to add this input to a sweeping
+	// transaction, and generate a witness.
+	inp := input.MakeHtlcSucceedInput(
+		&h.htlcResolution.ClaimOutpoint,
+		&h.htlcResolution.SweepSignDesc,
+		h.htlcResolution.Preimage[:],
+		h.broadcastHeight,
+		h.htlcResolution.CsvDelay,
+	)
+
+	// The remote party can time out the htlc once it expires, so the
+	// confirmation target is chosen to confirm the sweep before then.
+	// The sweeper estimates the fee rate for our confirmation target
+	// again on every attempt, and replaces its previous sweep tx if the
+	// rate has risen in the meantime.
+	resultChan, err := h.Sweeper.SweepInput(
+		&inp, sweep.Params{
+			Fee: sweep.FeePreference{
+				ConfTarget: h.deadlineConfTarget(
+					h.htlc.RefundTimeout,
+				),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	h.setProgress(ResolverStageAwaitingSweepConf, nil, 0)
+
+	log.Infof("%T(%x): waiting for sweep of incoming+remote htlc to "+
+		"confirm", h, h.htlc.RHash[:])
+
+	var sweepTxID chainhash.Hash
+	select {
+	case sweepResult := <-resultChan:
+		if sweepResult.Err != nil {
+			log.Errorf("%T(%x): unable to sweep htlc: %v", h,
+				h.htlc.RHash[:], sweepResult.Err)
+
+			return nil, sweepResult.Err
+		}
+
+		sweepTxID = sweepResult.Tx.TxHash()
+
+	case <-h.quit:
+		return nil, errResolverShuttingDown.Default()
+	}
+
+	log.Infof("%T(%x): incoming+remote htlc swept by tx %v", h,
+		h.htlc.RHash[:], sweepTxID)
+
+	// Once the sweep has confirmed, we'll mark ourselves as fully
+	// resolved and exit.
+	h.resolved = true
+	h.setProgress(ResolverStageResolved, &sweepTxID, 1)
+
+	// Checkpoint the resolver, and write the outcome to disk.
+	return nil, h.checkpointClaim(
+		&sweepTxID, channeldb.ResolverOutcomeClaimed,
+	)
+}
+
 // checkpointClaim checkpoints the success resolver with the reports it needs.
 // If this htlc was claimed two stages, it will write reports for both stages,
 // otherwise it will just write for the single htlc claim.
@@ -350,7 +513,7 @@ func (h *htlcSuccessResolver) Encode(w io.Writer) er.R {
 	if err := util.WriteBin(w, endian, h.resolved); err != nil {
 		return err
 	}
-	if err := util.WriteBin(w, endian, h.broadcastHeight); err != nil {
+	if err := util.WriteUint32BE(w, h.broadcastHeight); err != nil {
 		return err
 	}
 	if _, err := util.Write(w, h.htlc.RHash[:]); err != nil {
@@ -383,13 +546,17 @@ func newSuccessResolverFromReader(r io.Reader, resCfg ResolverConfig) (
 	if err := util.ReadBin(r, endian, &h.resolved); err != nil {
 		return nil, err
 	}
-	if err := util.ReadBin(r, endian, &h.broadcastHeight); err != nil {
+	broadcastHeight, err := util.ReadUint32BE(r)
+	if err != nil {
 		return nil, err
 	}
+	h.broadcastHeight = broadcastHeight
 	if _, err := util.ReadFull(r, h.htlc.RHash[:]); err != nil {
 		return nil, err
 	}
 
+	h.restoreProgress()
+
 	return h, nil
 }
 
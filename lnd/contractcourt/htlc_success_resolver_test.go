@@ -1,17 +1,21 @@
 package contractcourt
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
 	"github.com/kaotisk-hund/cjdcoind/lnd/chainntnfs"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
+	"github.com/kaotisk-hund/cjdcoind/lnd/input"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lntest/mock"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
 	"github.com/kaotisk-hund/cjdcoind/wire"
+	"github.com/stretchr/testify/require"
 )
 
 var testHtlcAmt = lnwire.MilliSatoshi(200000)
@@ -19,6 +23,7 @@ var testHtlcAmt = lnwire.MilliSatoshi(200000)
 type htlcSuccessResolverTestContext struct {
 	resolver           *htlcSuccessResolver
 	notifier           *mock.ChainNotifier
+	sweeper            *mockSweeper
 	resolverResultChan chan resolveResult
 	t                  *testing.T
 }
@@ -34,15 +39,18 @@ func newHtlcSuccessResolverTextContext(t *testing.T) *htlcSuccessResolverTestCon
 
 	testCtx := &htlcSuccessResolverTestContext{
 		notifier: notifier,
+		sweeper:  newMockSweeper(),
 		t:        t,
 	}
 
 	chainCfg := ChannelArbitratorConfig{
 		ChainArbitratorConfig: ChainArbitratorConfig{
 			Notifier: notifier,
+			ChainIO:  &mock.ChainIO{},
 			PublishTx: func(_ *wire.MsgTx, _ string) er.R {
 				return nil
 			},
+			Sweeper: testCtx.sweeper,
 		},
 		PutResolverReport: func(_ kvdb.RwTx,
 			report *channeldb.ResolverReport) er.R {
@@ -115,12 +123,16 @@ func TestSingleStageSuccess(t *testing.T) {
 		ClaimOutpoint: htlcOutpoint,
 	}
 
-	// We send a confirmation for our sweep tx to indicate that our sweep
-	// succeeded.
+	// The htlc output is handed to the sweeper, which reports the sweep
+	// tx once it confirmed.
 	resolve := func(ctx *htlcSuccessResolverTestContext) {
-		ctx.notifier.ConfChan <- &chainntnfs.TxConfirmation{
-			Tx:          ctx.resolver.sweepTx,
-			BlockHeight: testInitialBlockHeight - 1,
+		inp := <-ctx.sweeper.sweptInputs
+		if *inp.OutPoint() != htlcOutpoint {
+			t.Fatalf("unexpected input swept: %v", inp.OutPoint())
+		}
+		if inp.WitnessType() != input.HtlcAcceptedRemoteSuccess {
+			t.Fatalf("unexpected witness type: %v",
+				inp.WitnessType())
 		}
 	}
 
@@ -222,10 +234,10 @@ func testHtlcSuccess(t *testing.T, resolution lnwallet.IncomingHtlcResolution,
 
 	ctx.resolver.htlcResolution = resolution
 
-	// We set the sweepTx to be non-nil and mark the output as already
-	// incubating so that we do not need to set test values for crafting
-	// our own sweep transaction.
-	ctx.resolver.sweepTx = sweepTx
+	// We let the sweeper report our sweep tx and mark the output as
+	// already incubating so that we do not need to set test values for
+	// the incubation.
+	ctx.sweeper.sweepTx = sweepTx
 	ctx.resolver.outputIncubating = true
 
 	// Start the htlc success resolver.
@@ -241,3 +253,254 @@ func testHtlcSuccess(t *testing.T, resolution lnwallet.IncomingHtlcResolution,
 	// Wait for the resolver to fully complete.
 	ctx.waitForResult()
 }
+
+// TestHtlcSuccessResolverConfTarget tests that the confirmation target used to
+// sweep an htlc is lowered as the htlc approaches its expiry.
+func TestHtlcSuccessResolverConfTarget(t *testing.T) {
+	t.Parallel()
+
+	const bestHeight = 100
+
+	tests := []struct {
+		name       string
+		configured uint32
+		expiry     uint32
+		expected   uint32
+	}{
+		{
+			name:     "default target",
+			expiry:   bestHeight + 100,
+			expected: sweepConfTarget,
+		},
+		{
+			name:       "configured target",
+			configured: 12,
+			expiry:     bestHeight + 100,
+			expected:   12,
+		},
+		{
+			name:       "close to expiry",
+			configured: 12,
+			expiry:     bestHeight + 3,
+			expected:   3,
+		},
+		{
+			name:     "expiring next block",
+			expiry:   bestHeight + 1,
+			expected: 1,
+		},
+		{
+			name:     "expired",
+			expiry:   bestHeight - 1,
+			expected: 1,
+		},
+	}
+
+	for _, test := range tests {
+		ctx := newHtlcSuccessResolverTextContext(t)
+		ctx.resolver.ChainIO = &mock.ChainIO{BestHeight: bestHeight}
+		ctx.resolver.SweepConfTarget = test.configured
+		ctx.resolver.htlc.RefundTimeout = test.expiry
+
+		target := ctx.resolver.deadlineConfTarget(
+			ctx.resolver.htlc.RefundTimeout,
+		)
+		if target != test.expected {
+			t.Fatalf("%v: expected conf target %v, got %v",
+				test.name, test.expected, target)
+		}
+	}
+}
+
+// TestHtlcSuccessResolverProgress tests that the progress of the resolver is
+// reported for both single and two stage claims, and restored from its
+// persisted state.
+func TestHtlcSuccessResolverProgress(t *testing.T) {
+	defer timeout(t)()
+
+	sweepTx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{{}},
+	}
+	sweepTxid := sweepTx.TxHash()
+
+	// The progress reported at the time a tx is published is sent
+	// through this channel.
+	publishProgress := make(chan ResolverProgress, 1)
+	newCtx := func() *htlcSuccessResolverTestContext {
+		ctx := newHtlcSuccessResolverTextContext(t)
+		ctx.resolver.PublishTx = func(_ *wire.MsgTx, _ string) er.R {
+			publishProgress <- ctx.resolver.Progress()
+			return nil
+		}
+		ctx.resolver.Checkpoint = func(_ ContractResolver,
+			_ ...*channeldb.ResolverReport) er.R {
+
+			return nil
+		}
+		return ctx
+	}
+
+	// For an HTLC on the remote commitment, we wait for the sweeper to
+	// report the confirmed sweep tx.
+	ctx := newCtx()
+	require.Equal(t, ResolverStageInit, ctx.resolver.Progress().Stage)
+
+	ctx.resolver.htlcResolution = lnwallet.IncomingHtlcResolution{
+		SweepSignDesc: testSignDesc,
+		ClaimOutpoint: wire.OutPoint{Index: 3},
+	}
+	ctx.sweeper.sweepTx = sweepTx
+	ctx.resolve()
+
+	<-ctx.sweeper.sweptInputs
+	ctx.waitForResult()
+
+	progress := ctx.resolver.Progress()
+	require.Equal(t, ResolverStageResolved, progress.Stage)
+	require.Equal(t, sweepTxid, *progress.Txid)
+	require.Equal(t, uint32(1), progress.NumConfs)
+
+	// For an HTLC on our commitment, the second-level tx is incubated
+	// until its output is spent.
+	ctx = newCtx()
+	successTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 2},
+		}},
+	}
+	successTxid := successTx.TxHash()
+	ctx.resolver.htlcResolution = lnwallet.IncomingHtlcResolution{
+		SignedSuccessTx: successTx,
+		ClaimOutpoint:   wire.OutPoint{Index: 3},
+		SweepSignDesc:   testSignDesc,
+	}
+	ctx.resolver.IncubateOutputs = func(wire.OutPoint,
+		*lnwallet.OutgoingHtlcResolution,
+		*lnwallet.IncomingHtlcResolution, uint32) er.R {
+
+		return nil
+	}
+	ctx.resolve()
+
+	progress = <-publishProgress
+	require.Equal(t, ResolverStageIncubating, progress.Stage)
+	require.Equal(t, successTxid, *progress.Txid)
+
+	ctx.notifier.SpendChan <- &chainntnfs.SpendDetail{
+		SpendingTx:    sweepTx,
+		SpenderTxHash: &sweepTxid,
+	}
+	ctx.waitForResult()
+
+	progress = ctx.resolver.Progress()
+	require.Equal(t, ResolverStageResolved, progress.Stage)
+	require.Equal(t, sweepTxid, *progress.Txid)
+
+	// A resolver whose output is incubating continues to wait for the
+	// second-level spend once restored.
+	ctx.resolver.resolved = false
+	var b bytes.Buffer
+	util.RequireNoErr(t, ctx.resolver.Encode(&b))
+	restored, err := newSuccessResolverFromReader(
+		&b, ctx.resolver.ResolverConfig,
+	)
+	util.RequireNoErr(t, err)
+	progress = restored.Progress()
+	require.Equal(t, ResolverStageAwaitingSecondLevelSpend, progress.Stage)
+	require.Equal(t, successTxid, *progress.Txid)
+}
+
+// TestHtlcSuccessResolverExamine tests that the resolution plan of the success
+// resolver matches the way the htlc would be resolved, and that examining the
+// resolver neither publishes nor incubates anything.
+func TestHtlcSuccessResolverExamine(t *testing.T) {
+	t.Parallel()
+
+	commitOutpoint := wire.OutPoint{Index: 2}
+	htlcOutpoint := wire.OutPoint{Index: 3}
+	successTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: commitOutpoint,
+		}},
+	}
+
+	newCtx := func() *htlcSuccessResolverTestContext {
+		ctx := newHtlcSuccessResolverTextContext(t)
+		ctx.resolver.PreimageDB = newMockWitnessBeacon()
+		ctx.resolver.PublishTx = func(_ *wire.MsgTx, _ string) er.R {
+			t.Fatal("tx published while examining")
+			return nil
+		}
+		ctx.resolver.IncubateOutputs = func(wire.OutPoint,
+			*lnwallet.OutgoingHtlcResolution,
+			*lnwallet.IncomingHtlcResolution, uint32) er.R {
+
+			t.Fatal("output incubated while examining")
+			return nil
+		}
+		return ctx
+	}
+
+	// An htlc on the remote commitment is offered to the sweeper.
+	ctx := newCtx()
+	ctx.resolver.htlcResolution = lnwallet.IncomingHtlcResolution{
+		Preimage:      testResPreimage,
+		ClaimOutpoint: htlcOutpoint,
+		SweepSignDesc: testSignDesc,
+	}
+	plan, err := ctx.resolver.Examine()
+	util.RequireNoErr(t, err)
+	require.Equal(t, ResolutionPlan{
+		Outpoints:         []wire.OutPoint{htlcOutpoint},
+		Sweep:             true,
+		Outcome:           channeldb.ResolverOutcomeClaimed,
+		PreimageAvailable: true,
+	}, plan)
+
+	// An htlc on our commitment is claimed through the success tx, whose
+	// output is incubated until its CSV delay has passed. The preimage is
+	// found in the preimage db.
+	ctx = newCtx()
+	ctx.resolver.PreimageDB.(*mockWitnessBeacon).lookupPreimage[testResHash] =
+		testResPreimage
+	ctx.resolver.htlcResolution = lnwallet.IncomingHtlcResolution{
+		SignedSuccessTx: successTx,
+		CsvDelay:        144,
+		ClaimOutpoint:   htlcOutpoint,
+		SweepSignDesc:   testSignDesc,
+	}
+	plan, err = ctx.resolver.Examine()
+	util.RequireNoErr(t, err)
+
+	// The plan holds a copy of the success tx, so it can't be used to
+	// modify the resolver.
+	require.Len(t, plan.Txs, 1)
+	require.NotSame(t, successTx, plan.Txs[0])
+	require.Equal(t, successTx.TxHash(), plan.Txs[0].TxHash())
+
+	plan.Txs = nil
+	require.Equal(t, ResolutionPlan{
+		Outpoints:         []wire.OutPoint{commitOutpoint, htlcOutpoint},
+		Incubate:          true,
+		Outcome:           channeldb.ResolverOutcomeClaimed,
+		PreimageAvailable: true,
+		CsvDelay:          144,
+	}, plan)
+
+	// Without the preimage, the htlc is expected to time out.
+	ctx = newCtx()
+	ctx.resolver.htlcResolution = lnwallet.IncomingHtlcResolution{
+		SignedSuccessTx: successTx,
+		CsvDelay:        144,
+		ClaimOutpoint:   htlcOutpoint,
+		SweepSignDesc:   testSignDesc,
+	}
+	plan, err = ctx.resolver.Examine()
+	util.RequireNoErr(t, err)
+	require.Equal(t, ResolutionPlan{
+		Outpoints: []wire.OutPoint{commitOutpoint},
+		Outcome:   channeldb.ResolverOutcomeTimeout,
+		CsvDelay:  144,
+	}, plan)
+}
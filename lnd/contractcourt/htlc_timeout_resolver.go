@@ -509,6 +509,33 @@ func (h *htlcTimeoutResolver) HtlcPoint() wire.OutPoint {
 	return h.htlcResolution.HtlcPoint()
 }
 
+// Examine returns how the resolver would resolve the htlc, without handing
+// anything to the incubator.
+//
+// NOTE: Part of the htlcContractResolver interface.
+func (h *htlcTimeoutResolver) Examine() (ResolutionPlan, er.R) {
+	_, preimageAvailable := h.PreimageDB.LookupPreimage(h.htlc.RHash)
+
+	plan := ResolutionPlan{
+		Outpoints:         []wire.OutPoint{h.HtlcPoint()},
+		Incubate:          true,
+		Outcome:           channeldb.ResolverOutcomeTimeout,
+		PreimageAvailable: preimageAvailable,
+		CsvDelay:          h.htlcResolution.CsvDelay,
+	}
+
+	// If this is our commitment, the incubator publishes the timeout tx
+	// once the htlc expires, and sweeps its output after the CSV delay.
+	if timeoutTx := h.htlcResolution.SignedTimeoutTx; timeoutTx != nil {
+		plan.Txs = []*wire.MsgTx{timeoutTx.Copy()}
+		plan.Outpoints = append(
+			plan.Outpoints, h.htlcResolution.ClaimOutpoint,
+		)
+	}
+
+	return plan, nil
+}
+
 // A compile time assertion to ensure htlcTimeoutResolver meets the
 // ContractResolver interface.
 var _ htlcContractResolver = (*htlcTimeoutResolver)(nil)
@@ -2,13 +2,17 @@ package htlcswitch
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
+	"github.com/kaotisk-hund/cjdcoind/lnd/clock"
 	"github.com/kaotisk-hund/cjdcoind/lnd/htlcswitch/hop"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
@@ -130,6 +134,10 @@ type CircuitMap interface {
 	// NumOpen returns the number of circuits with HTLCs that have been
 	// forwarded via an outgoing link.
 	NumOpen() int
+
+	// StaleCircuits returns the open circuits which were opened more than
+	// olderThan ago, oldest first.
+	StaleCircuits(olderThan time.Duration) []*PaymentCircuit
 }
 
 var (
@@ -144,6 +152,11 @@ var (
 	// keystones, which are set in place once a forwarded packet is
 	// assigned an index on an outgoing commitment txn.
 	circuitKeystoneKey = []byte("circuit-keystones")
+
+	// circuitOpenTimeKey is used to retrieve the bucket containing the
+	// time at which each open circuit was opened, keyed by its outgoing
+	// circuit key. Entries are removed along with the keystones.
+	circuitOpenTimeKey = []byte("circuit-open-times")
 )
 
 // circuitMap is a data structure that implements thread safe, persistent
@@ -169,6 +182,11 @@ type circuitMap struct {
 	// circuit from disk.
 	closed map[CircuitKey]struct{}
 
+	// openTimes is an in-memory mapping of the outgoing circuit keys of
+	// all full payment circuits to the time they were opened, which is
+	// also synchronized with the persistent state of the circuit map.
+	openTimes map[CircuitKey]time.Time
+
 	// hashIndex is a volatile index that facilitates fast queries by
 	// payment hash against the contents of circuits. This index can be
 	// reconstructed entirely from the set of persisted full circuits on
@@ -187,10 +205,18 @@ type CircuitMapConfig struct {
 	// ExtractErrorEncrypter derives the shared secret used to encrypt
 	// errors from the obfuscator's ephemeral public key.
 	ExtractErrorEncrypter hop.ErrorEncrypterExtracter
+
+	// Clock is the time source used to record when circuits are opened.
+	// The default clock is used if it is nil.
+	Clock clock.Clock
 }
 
 // NewCircuitMap creates a new instance of the circuitMap.
 func NewCircuitMap(cfg *CircuitMapConfig) (CircuitMap, er.R) {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewDefaultClock()
+	}
+
 	cm := &circuitMap{
 		cfg: cfg,
 	}
@@ -227,10 +253,36 @@ func (cm *circuitMap) initBuckets() er.R {
 		}
 
 		_, err = tx.CreateTopLevelBucket(circuitAddKey)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateTopLevelBucket(circuitOpenTimeKey)
 		return err
 	}, func() {})
 }
 
+// encodeOpenTime serializes the time at which a circuit was opened.
+func encodeOpenTime(t time.Time) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(t.UnixNano()))
+	return b[:]
+}
+
+// openTime returns the current time as it's recorded for circuits opened now,
+// which is the same once it has been persisted and read back.
+func (cm *circuitMap) openTime() time.Time {
+	return time.Unix(0, cm.cfg.Clock.Now().UnixNano())
+}
+
+// decodeOpenTime deserializes the time at which a circuit was opened.
+func decodeOpenTime(b []byte) (time.Time, er.R) {
+	if len(b) != 8 {
+		return time.Time{}, ErrCorruptedCircuitMap.Default()
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b))), nil
+}
+
 // restoreMemState loads the contents of the half circuit and full circuit
 // buckets from disk and reconstructs the in-memory representation of the
 // circuit map. Afterwards, the state of the hash index is reconstructed using
@@ -241,8 +293,10 @@ func (cm *circuitMap) restoreMemState() er.R {
 	log.Infof("Restoring in-memory circuit state from disk")
 
 	var (
-		opened  map[CircuitKey]*PaymentCircuit
-		pending map[CircuitKey]*PaymentCircuit
+		opened    map[CircuitKey]*PaymentCircuit
+		pending   map[CircuitKey]*PaymentCircuit
+		openTimes map[CircuitKey]time.Time
+		now       = cm.openTime()
 	)
 
 	if err := kvdb.Update(cm.cfg.DB, func(tx kvdb.RwTx) er.R {
@@ -330,7 +384,11 @@ func (cm *circuitMap) restoreMemState() er.R {
 			}
 		}
 
-		return nil
+		// Restore the time at which each of the open circuits was
+		// opened.
+		var err er.R
+		openTimes, err = restoreOpenTimes(tx, opened, now)
+		return err
 
 	}, func() {
 		opened = make(map[CircuitKey]*PaymentCircuit)
@@ -341,6 +399,7 @@ func (cm *circuitMap) restoreMemState() er.R {
 
 	cm.pending = pending
 	cm.opened = opened
+	cm.openTimes = openTimes
 	cm.closed = make(map[CircuitKey]struct{})
 
 	log.Infof("Payment circuits loaded: num_pending=%v, num_open=%v",
@@ -356,6 +415,62 @@ func (cm *circuitMap) restoreMemState() er.R {
 	return nil
 }
 
+// restoreOpenTimes returns the time at which each of the open circuits was
+// opened from disk, and removes the times of circuits which aren't open
+// anymore. Circuits opened before their open time was recorded are considered
+// to be opened at the passed time, which is persisted.
+func restoreOpenTimes(tx kvdb.RwTx, opened map[CircuitKey]*PaymentCircuit,
+	now time.Time) (map[CircuitKey]time.Time, er.R) {
+
+	openTimeBkt := tx.ReadWriteBucket(circuitOpenTimeKey)
+	if openTimeBkt == nil {
+		return nil, ErrCorruptedCircuitMap.Default()
+	}
+
+	// Collect the times without an open circuit first, as the bucket
+	// can't be modified while iterating over it.
+	var strayTimes [][]byte
+	if err := openTimeBkt.ForEach(func(k, _ []byte) er.R {
+		var outKey CircuitKey
+		if err := outKey.SetBytes(k); err != nil {
+			return err
+		}
+		if _, ok := opened[outKey]; !ok {
+			strayTimes = append(strayTimes, k)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, k := range strayTimes {
+		if err := openTimeBkt.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+
+	openTimes := make(map[CircuitKey]time.Time, len(opened))
+	for outKey := range opened {
+		v := openTimeBkt.Get(outKey.Bytes())
+		if v == nil {
+			err := openTimeBkt.Put(outKey.Bytes(), encodeOpenTime(now))
+			if err != nil {
+				return nil, err
+			}
+			openTimes[outKey] = now
+			continue
+		}
+
+		openedAt, err := decodeOpenTime(v)
+		if err != nil {
+			return nil, err
+		}
+		openTimes[outKey] = openedAt
+	}
+
+	return openTimes, nil
+}
+
 // decodeCircuit reconstructs an in-memory payment circuit from a byte slice.
 // The byte slice is assumed to have been generated by the circuit's Encode
 // method. If the decoding is successful, the onion obfuscator will be
@@ -464,6 +579,7 @@ func (cm *circuitMap) TrimOpenCircuits(chanID lnwire.ShortChannelID,
 
 		circuit.Outgoing = nil
 		delete(cm.opened, outKey)
+		delete(cm.openTimes, outKey)
 		trimmedOutKeys = append(trimmedOutKeys, outKey)
 		cm.removeCircuitFromHashIndex(circuit)
 	}
@@ -475,7 +591,8 @@ func (cm *circuitMap) TrimOpenCircuits(chanID lnwire.ShortChannelID,
 
 	return kvdb.Update(cm.cfg.DB, func(tx kvdb.RwTx) er.R {
 		keystoneBkt := tx.ReadWriteBucket(circuitKeystoneKey)
-		if keystoneBkt == nil {
+		openTimeBkt := tx.ReadWriteBucket(circuitOpenTimeKey)
+		if keystoneBkt == nil || openTimeBkt == nil {
 			return ErrCorruptedCircuitMap.Default()
 		}
 
@@ -484,6 +601,10 @@ func (cm *circuitMap) TrimOpenCircuits(chanID lnwire.ShortChannelID,
 			if err != nil {
 				return err
 			}
+			err = openTimeBkt.Delete(outKey.Bytes())
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -716,11 +837,13 @@ func (cm *circuitMap) OpenCircuits(keystones ...Keystone) er.R {
 	}
 	cm.mtx.RUnlock()
 
+	openedAt := cm.openTime()
 	err := kvdb.Update(cm.cfg.DB, func(tx kvdb.RwTx) er.R {
 		// Now, load the circuit bucket to which we will write the
 		// already serialized circuit.
 		keystoneBkt := tx.ReadWriteBucket(circuitKeystoneKey)
-		if keystoneBkt == nil {
+		openTimeBkt := tx.ReadWriteBucket(circuitOpenTimeKey)
+		if keystoneBkt == nil || openTimeBkt == nil {
 			return ErrCorruptedCircuitMap.Default()
 		}
 
@@ -731,6 +854,11 @@ func (cm *circuitMap) OpenCircuits(keystones ...Keystone) er.R {
 			if err != nil {
 				return err
 			}
+
+			err = openTimeBkt.Put(outBytes, encodeOpenTime(openedAt))
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -751,6 +879,7 @@ func (cm *circuitMap) OpenCircuits(keystones ...Keystone) er.R {
 		// index.
 		circuit.Outgoing = &CircuitKey{}
 		*circuit.Outgoing = ks.OutKey
+		cm.openTimes[ks.OutKey] = openedAt
 
 		cm.opened[ks.OutKey] = circuit
 		cm.addCircuitToHashIndex(circuit)
@@ -829,6 +958,7 @@ func (cm *circuitMap) DeleteCircuits(inKeys ...CircuitKey) er.R {
 	var (
 		closingCircuits = make(map[CircuitKey]struct{})
 		removedCircuits = make(map[CircuitKey]*PaymentCircuit)
+		removedTimes    = make(map[CircuitKey]time.Time)
 	)
 
 	cm.mtx.Lock()
@@ -849,7 +979,10 @@ func (cm *circuitMap) DeleteCircuits(inKeys ...CircuitKey) er.R {
 		}
 
 		if circuit.HasKeystone() {
-			delete(cm.opened, circuit.OutKey())
+			outKey := circuit.OutKey()
+			delete(cm.opened, outKey)
+			removedTimes[outKey] = cm.openTimes[outKey]
+			delete(cm.openTimes, outKey)
 			cm.removeCircuitFromHashIndex(circuit)
 		}
 
@@ -864,7 +997,8 @@ func (cm *circuitMap) DeleteCircuits(inKeys ...CircuitKey) er.R {
 			// outgoing circuit key.
 			if circuit.HasKeystone() {
 				keystoneBkt := tx.ReadWriteBucket(circuitKeystoneKey)
-				if keystoneBkt == nil {
+				openTimeBkt := tx.ReadWriteBucket(circuitOpenTimeKey)
+				if keystoneBkt == nil || openTimeBkt == nil {
 					return ErrCorruptedCircuitMap.Default()
 				}
 
@@ -874,6 +1008,10 @@ func (cm *circuitMap) DeleteCircuits(inKeys ...CircuitKey) er.R {
 				if err != nil {
 					return err
 				}
+				err = openTimeBkt.Delete(outKey.Bytes())
+				if err != nil {
+					return err
+				}
 			}
 
 			// Remove the circuit itself based on the incoming
@@ -908,7 +1046,9 @@ func (cm *circuitMap) DeleteCircuits(inKeys ...CircuitKey) er.R {
 		}
 
 		if circuit.HasKeystone() {
-			cm.opened[circuit.OutKey()] = circuit
+			outKey := circuit.OutKey()
+			cm.opened[outKey] = circuit
+			cm.openTimes[outKey] = removedTimes[outKey]
 			cm.addCircuitToHashIndex(circuit)
 		}
 	}
@@ -954,3 +1094,28 @@ func (cm *circuitMap) NumOpen() int {
 
 	return len(cm.opened)
 }
+
+// StaleCircuits returns the open circuits which were opened more than
+// olderThan ago, oldest first. These are HTLCs forwarded via an outgoing link
+// which are still waiting for a settle/fail, and may hint at stuck forwards.
+func (cm *circuitMap) StaleCircuits(olderThan time.Duration) []*PaymentCircuit {
+	now := cm.cfg.Clock.Now()
+
+	cm.mtx.RLock()
+	defer cm.mtx.RUnlock()
+
+	var stale []*PaymentCircuit
+	for outKey, circuit := range cm.opened {
+		if now.Sub(cm.openTimes[outKey]) > olderThan {
+			stale = append(stale, circuit)
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		iTime := cm.openTimes[stale[i].OutKey()]
+		jTime := cm.openTimes[stale[j].OutKey()]
+		return iTime.Before(jTime)
+	})
+
+	return stale
+}
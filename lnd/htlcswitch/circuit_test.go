@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/kaotisk-hund/cjdcoind/btcec"
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
@@ -12,6 +13,7 @@ import (
 	bitcoinCfg "github.com/kaotisk-hund/cjdcoind/chaincfg"
 	sphinx "github.com/kaotisk-hund/cjdcoind/lightning-onion"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
+	"github.com/kaotisk-hund/cjdcoind/lnd/clock"
 	"github.com/kaotisk-hund/cjdcoind/lnd/htlcswitch"
 	"github.com/kaotisk-hund/cjdcoind/lnd/htlcswitch/hop"
 	"github.com/kaotisk-hund/cjdcoind/lnd/keychain"
@@ -643,6 +645,7 @@ func restartCircuitMap(t *testing.T, cfg *htlcswitch.CircuitMapConfig) (
 	cfg2 := &htlcswitch.CircuitMapConfig{
 		DB:                    makeCircuitDB(t, dbPath),
 		ExtractErrorEncrypter: cfg.ExtractErrorEncrypter,
+		Clock:                 cfg.Clock,
 	}
 	cm2, err := htlcswitch.NewCircuitMap(cfg2)
 	if err != nil {
@@ -1385,3 +1388,97 @@ func TestCircuitMapDeleteOpenCircuit(t *testing.T) {
 			circuit2, nil)
 	}
 }
+
+// TestCircuitMapStaleCircuits checks that open circuits are reported as stale
+// once they have been open for longer than the given duration, also after a
+// restart, and that they aren't reported anymore once deleted.
+func TestCircuitMapStaleCircuits(t *testing.T) {
+	t.Parallel()
+
+	var (
+		chan1     = lnwire.NewShortChanIDFromInt(1)
+		chan2     = lnwire.NewShortChanIDFromInt(2)
+		startTime = time.Unix(1000, 0)
+		testClock = clock.NewTestClock(startTime)
+	)
+
+	onionProcessor := newOnionProcessor(t)
+	cfg := &htlcswitch.CircuitMapConfig{
+		DB:                    makeCircuitDB(t, ""),
+		ExtractErrorEncrypter: onionProcessor.ExtractErrorEncrypter,
+		Clock:                 testClock,
+	}
+	circuitMap, err := htlcswitch.NewCircuitMap(cfg)
+	if err != nil {
+		t.Fatalf("unable to create persistent circuit map: %v", err)
+	}
+
+	// Commit two circuits, but only open the first one.
+	circuits := make([]*htlcswitch.PaymentCircuit, 2)
+	for i := range circuits {
+		circuits[i] = &htlcswitch.PaymentCircuit{
+			Incoming: htlcswitch.CircuitKey{
+				ChanID: chan1,
+				HtlcID: uint64(i),
+			},
+			ErrorEncrypter: testExtracter,
+		}
+	}
+	if _, err := circuitMap.CommitCircuits(circuits...); err != nil {
+		t.Fatalf("failed to commit circuits: %v", err)
+	}
+
+	keystone := htlcswitch.Keystone{
+		InKey: circuits[0].Incoming,
+		OutKey: htlcswitch.CircuitKey{
+			ChanID: chan2,
+			HtlcID: 0,
+		},
+	}
+	if err := circuitMap.OpenCircuits(keystone); err != nil {
+		t.Fatalf("failed to open circuits: %v", err)
+	}
+
+	assertStale := func(cm htlcswitch.CircuitMap, olderThan time.Duration,
+		expected int) {
+
+		t.Helper()
+
+		stale := cm.StaleCircuits(olderThan)
+		if len(stale) != expected {
+			t.Fatalf("expected %d stale circuits, got %d",
+				expected, len(stale))
+		}
+		for _, circuit := range stale {
+			if circuit.Incoming != keystone.InKey {
+				t.Fatalf("unexpected stale circuit %v",
+					circuit.Incoming)
+			}
+		}
+	}
+
+	// The circuit was only just opened, so it isn't stale yet.
+	assertStale(circuitMap, time.Minute, 0)
+
+	// After advancing the clock past the threshold, the open circuit is
+	// reported, while the pending one is not.
+	testClock.SetTime(startTime.Add(2 * time.Minute))
+	assertStale(circuitMap, time.Hour, 0)
+	assertStale(circuitMap, time.Minute, 1)
+
+	// The open time is persisted, so the circuit is still stale after a
+	// restart, but only opened two minutes ago.
+	cfg, circuitMap = restartCircuitMap(t, cfg)
+	assertStale(circuitMap, time.Minute, 1)
+	assertStale(circuitMap, 3*time.Minute, 0)
+
+	// Once the circuit is deleted, it isn't reported anymore, also after
+	// another restart.
+	if err := circuitMap.DeleteCircuits(keystone.InKey); err != nil {
+		t.Fatalf("unable to delete circuit: %v", err)
+	}
+	assertStale(circuitMap, time.Minute, 0)
+
+	_, circuitMap = restartCircuitMap(t, cfg)
+	assertStale(circuitMap, time.Minute, 0)
+}
@@ -157,6 +157,12 @@ func (d *DecayedLog) Stop() er.R {
 	return nil
 }
 
+// Started returns true if the log has been started and not stopped since.
+func (d *DecayedLog) Started() bool {
+	return atomic.LoadInt32(&d.started) == 1 &&
+		atomic.LoadInt32(&d.stopped) == 0
+}
+
 // garbageCollector deletes entries from sharedHashBucket whose expiry height
 // has already past. This function MUST be run as a goroutine.
 func (d *DecayedLog) garbageCollector(epochClient *chainntnfs.BlockEpochEvent) {
@@ -411,3 +417,4 @@ func (d *DecayedLog) PutBatch(b *sphinx.Batch) (*sphinx.ReplaySet, er.R) {
 // A compile time check to see if DecayedLog adheres to the PersistLog
 // interface.
 var _ sphinx.ReplayLog = (*DecayedLog)(nil)
+var _ sphinx.ReplayLogStatus = (*DecayedLog)(nil)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 
+	"github.com/kaotisk-hund/cjdcoind/btcec"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	sphinx "github.com/kaotisk-hund/cjdcoind/lightning-onion"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
@@ -92,6 +93,12 @@ type ForwardingError struct {
 	// zero is the self node.
 	FailureSourceIdx int
 
+	// FailureRoute is the ordered list of nodes the failure traversed,
+	// starting with the first hop and ending with the node that sent the
+	// failure. It is only populated by decrypters that are configured to
+	// record it.
+	FailureRoute []*btcec.PublicKey
+
 	// msg is the wire message associated with the error. This value may
 	// be nil in the case where we fail to decode failure message sent by
 	// a peer.
@@ -108,6 +115,12 @@ func (f *ForwardingError) WireMessage() lnwire.FailureMessage {
 	return f.msg
 }
 
+// FailedHop returns the index of the hop in the route that generated the
+// failure. Index zero is the self node.
+func (f *ForwardingError) FailedHop() int {
+	return f.FailureSourceIdx
+}
+
 // Error implements the built-in error interface. We use this method to allow
 // the switch or any callers to insert additional context to the error message
 // returned.
@@ -162,6 +175,10 @@ type OnionErrorDecrypter interface {
 // returned errors to concrete lnwire.FailureMessage instances.
 type SphinxErrorDecrypter struct {
 	OnionErrorDecrypter
+
+	// RecordRoute, if set, makes DecryptError populate the FailureRoute of
+	// the returned ForwardingError with the nodes the error traversed.
+	RecordRoute bool
 }
 
 // DecryptError peels off each layer of onion encryption from the first hop, to
@@ -181,11 +198,19 @@ func (s *SphinxErrorDecrypter) DecryptError(reason lnwire.OpaqueReason) (
 	// field nil.
 	r := bytes.NewReader(failure.Message)
 	failureMsg, err := lnwire.DecodeFailure(r, 0)
+
+	var fwdErr *ForwardingError
 	if err != nil {
-		return NewUnknownForwardingError(failure.SenderIdx), nil
+		fwdErr = NewUnknownForwardingError(failure.SenderIdx)
+	} else {
+		fwdErr = NewForwardingError(failureMsg, failure.SenderIdx)
+	}
+
+	if s.RecordRoute {
+		fwdErr.FailureRoute = failure.Route
 	}
 
-	return NewForwardingError(failureMsg, failure.SenderIdx), nil
+	return fwdErr, nil
 }
 
 // A compile time check to ensure ErrorDecrypter implements the Deobfuscator
@@ -0,0 +1,87 @@
+package htlcswitch
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcec"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSphinxErrorDecrypterFailedHop asserts that the forwarding error returned
+// by the sphinx error decrypter carries the index of the hop which generated
+// the failure, both when the failure can and can't be decoded.
+func TestSphinxErrorDecrypterFailedHop(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	err := lnwire.EncodeFailure(&b, &lnwire.FailTemporaryNodeFailure{}, 0)
+	util.RequireNoErr(t, err)
+
+	for hop := 0; hop < 4; hop++ {
+		deobfuscator := SphinxErrorDecrypter{
+			OnionErrorDecrypter: &mockOnionErrorDecryptor{
+				sourceIdx: hop,
+				message:   b.Bytes(),
+			},
+		}
+		fwdErr, err := deobfuscator.DecryptError(nil)
+		util.RequireNoErr(t, err)
+		require.Equal(t, hop, fwdErr.FailedHop())
+		require.IsType(
+			t, &lnwire.FailTemporaryNodeFailure{}, fwdErr.WireMessage(),
+		)
+
+		deobfuscator = SphinxErrorDecrypter{
+			OnionErrorDecrypter: &mockOnionErrorDecryptor{
+				sourceIdx: hop,
+				message:   []byte{200},
+			},
+		}
+		fwdErr, err = deobfuscator.DecryptError(nil)
+		util.RequireNoErr(t, err)
+		require.Equal(t, hop, fwdErr.FailedHop())
+		require.Nil(t, fwdErr.WireMessage())
+	}
+}
+
+// TestSphinxErrorDecrypterRecordRoute asserts that the route the failure
+// traversed is only exposed on the forwarding error when the decrypter is
+// configured to record it.
+func TestSphinxErrorDecrypterRecordRoute(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	err := lnwire.EncodeFailure(&b, &lnwire.FailTemporaryNodeFailure{}, 0)
+	util.RequireNoErr(t, err)
+
+	route := make([]*btcec.PublicKey, 3)
+	for i := range route {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		util.RequireNoErr(t, err)
+		route[i] = privKey.PubKey()
+	}
+
+	for _, message := range [][]byte{b.Bytes(), {200}} {
+		onionDecrypter := &mockOnionErrorDecryptor{
+			sourceIdx: len(route),
+			message:   message,
+			route:     route,
+		}
+
+		deobfuscator := SphinxErrorDecrypter{
+			OnionErrorDecrypter: onionDecrypter,
+		}
+		fwdErr, err := deobfuscator.DecryptError(nil)
+		util.RequireNoErr(t, err)
+		require.Nil(t, fwdErr.FailureRoute)
+
+		deobfuscator.RecordRoute = true
+		fwdErr, err = deobfuscator.DecryptError(nil)
+		util.RequireNoErr(t, err)
+		require.Equal(t, len(route), fwdErr.FailedHop())
+		require.Equal(t, route, fwdErr.FailureRoute)
+	}
+}
@@ -1,6 +1,11 @@
 package hop
 
 import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
 )
 
@@ -27,3 +32,91 @@ type ForwardingInfo struct {
 	// in the outgoing HTLC.
 	OutgoingCTLV uint32
 }
+
+// forwardingInfoJSON is the JSON representation of a ForwardingInfo.
+type forwardingInfoJSON struct {
+	Network         string `json:"network"`
+	NextHop         string `json:"next_hop"`
+	AmountToForward uint64 `json:"amt_to_forward"`
+	OutgoingCTLV    uint32 `json:"outgoing_cltv"`
+}
+
+// MarshalJSON encodes the forwarding info as a JSON object, with the next hop
+// in the block:tx:output format and the amount in milli-satoshis.
+func (f ForwardingInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(forwardingInfoJSON{
+		Network:         f.Network.String(),
+		NextHop:         f.NextHop.String(),
+		AmountToForward: uint64(f.AmountToForward),
+		OutgoingCTLV:    f.OutgoingCTLV,
+	})
+}
+
+// UnmarshalJSON decodes forwarding info encoded by MarshalJSON.
+func (f *ForwardingInfo) UnmarshalJSON(b []byte) error {
+	var info forwardingInfoJSON
+	if err := json.Unmarshal(b, &info); err != nil {
+		return err
+	}
+
+	network, err := parseNetwork(info.Network)
+	if err != nil {
+		return er.Native(err)
+	}
+	nextHop, err := parseShortChanID(info.NextHop)
+	if err != nil {
+		return er.Native(err)
+	}
+
+	*f = ForwardingInfo{
+		Network:         network,
+		NextHop:         nextHop,
+		AmountToForward: lnwire.MilliSatoshi(info.AmountToForward),
+		OutgoingCTLV:    info.OutgoingCTLV,
+	}
+
+	return nil
+}
+
+// parseNetwork returns the Network with the given string representation.
+func parseNetwork(s string) (Network, er.R) {
+	for _, network := range []Network{BitcoinNetwork, LitecoinNetwork} {
+		if strings.EqualFold(s, network.String()) {
+			return network, nil
+		}
+	}
+
+	return 0, er.Errorf("unknown network %q", s)
+}
+
+// parseShortChanID parses a short channel ID in the block:tx:output format
+// returned by ShortChannelID.String.
+func parseShortChanID(s string) (lnwire.ShortChannelID, er.R) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return lnwire.ShortChannelID{}, er.Errorf("short channel id "+
+			"%q is not of the format block:tx:output", s)
+	}
+
+	// The block height and transaction index are encoded in 3 bytes.
+	blockHeight, err := strconv.ParseUint(parts[0], 10, 24)
+	if err != nil {
+		return lnwire.ShortChannelID{}, er.E(err)
+	}
+
+	txIndex, err := strconv.ParseUint(parts[1], 10, 24)
+	if err != nil {
+		return lnwire.ShortChannelID{}, er.E(err)
+	}
+
+	txPosition, err := strconv.ParseUint(parts[2], 10, 16)
+	if err != nil {
+		return lnwire.ShortChannelID{}, er.E(err)
+	}
+
+	return lnwire.ShortChannelID{
+		BlockHeight: uint32(blockHeight),
+		TxIndex:     uint32(txIndex),
+		TxPosition:  uint16(txPosition),
+	}, nil
+}
@@ -0,0 +1,70 @@
+package hop
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestForwardingInfoJSON tests that forwarding info is encoded in the expected
+// JSON format, and decoded back to the same value.
+func TestForwardingInfoJSON(t *testing.T) {
+	t.Parallel()
+
+	info := ForwardingInfo{
+		Network: BitcoinNetwork,
+		NextHop: lnwire.ShortChannelID{
+			BlockHeight: 1<<24 - 1,
+			TxIndex:     1<<24 - 1,
+			TxPosition:  1<<16 - 1,
+		},
+		AmountToForward: 1234567,
+		OutgoingCTLV:    650000,
+	}
+
+	b, errr := json.Marshal(info)
+	require.NoError(t, errr)
+	require.JSONEq(t, `{
+		"network": "Bitcoin",
+		"next_hop": "16777215:16777215:65535",
+		"amt_to_forward": 1234567,
+		"outgoing_cltv": 650000
+	}`, string(b))
+
+	var decoded ForwardingInfo
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, info, decoded)
+
+	// Pointers should be encoded the same way, which is what happens when
+	// the info is part of a larger structure.
+	info.Network = LitecoinNetwork
+	info.NextHop = lnwire.NewShortChanIDFromInt(0)
+	b, errr = json.Marshal(&info)
+	require.NoError(t, errr)
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, info, decoded)
+}
+
+// TestForwardingInfoJSONInvalid tests that invalid networks and short channel
+// IDs are rejected when decoding forwarding info.
+func TestForwardingInfoJSONInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		`{"network": "Kekcoin", "next_hop": "1:2:3"}`,
+		`{"network": "Bitcoin", "next_hop": "1:2"}`,
+		`{"network": "Bitcoin", "next_hop": "1:2:3:4"}`,
+		`{"network": "Bitcoin", "next_hop": "16777216:2:3"}`,
+		`{"network": "Bitcoin", "next_hop": "1:16777216:3"}`,
+		`{"network": "Bitcoin", "next_hop": "1:2:65536"}`,
+		`{"network": "Bitcoin", "next_hop": "1:x:3"}`,
+		`{"network": "Bitcoin", "next_hop": "1:2:3", "outgoing_cltv": -1}`,
+	}
+
+	for _, test := range tests {
+		var info ForwardingInfo
+		require.Error(t, json.Unmarshal([]byte(test), &info), test)
+	}
+}
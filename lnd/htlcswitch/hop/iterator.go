@@ -3,9 +3,11 @@ package hop
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/kaotisk-hund/cjdcoind/btcec"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
 	sphinx "github.com/kaotisk-hund/cjdcoind/lightning-onion"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
@@ -26,9 +28,23 @@ type Iterator interface {
 	HopPayload() (*Payload, er.R)
 
 	// EncodeNextHop encodes the onion packet destined for the next hop
-	// into the passed io.Writer.
+	// into the passed io.Writer, followed by the extra onion blob if there
+	// is one.
 	EncodeNextHop(w io.Writer) er.R
 
+	// ExtraOnionBlob returns any extra data which trailed the onion packet
+	// destined to this hop, or nil if there was none. EncodeNextHop writes
+	// this data after the next onion packet.
+	//
+	// NOTE: The onion of an UpdateAddHTLC has a fixed size, so the link
+	// can't pass this data along and fails htlcs which have any.
+	ExtraOnionBlob() []byte
+
+	// CustomRecords returns the custom records included in the payload
+	// destined to this hop. An empty map is returned for legacy payloads,
+	// which can't carry any.
+	CustomRecords() map[uint64][]byte
+
 	// ExtractErrorEncrypter returns the ErrorEncrypter needed for this hop,
 	// along with a failure code to signal if the decoding was successful.
 	ExtractErrorEncrypter(ErrorEncrypterExtracter) (ErrorEncrypter,
@@ -47,16 +63,20 @@ type sphinxHopIterator struct {
 	// includes the information required to properly forward the packet to
 	// the next hop.
 	processedPacket *sphinx.ProcessedPacket
+
+	// extraOnionBlob is any extra data which trailed the original packet.
+	extraOnionBlob []byte
 }
 
 // makeSphinxHopIterator converts a processed packet returned from a sphinx
 // router and converts it into an hop iterator for usage in the link.
 func makeSphinxHopIterator(ogPacket *sphinx.OnionPacket,
-	packet *sphinx.ProcessedPacket) *sphinxHopIterator {
+	packet *sphinx.ProcessedPacket, extraOnionBlob []byte) *sphinxHopIterator {
 
 	return &sphinxHopIterator{
 		ogPacket:        ogPacket,
 		processedPacket: packet,
+		extraOnionBlob:  extraOnionBlob,
 	}
 }
 
@@ -68,7 +88,37 @@ var _ Iterator = (*sphinxHopIterator)(nil)
 //
 // NOTE: Part of the HopIterator interface.
 func (r *sphinxHopIterator) EncodeNextHop(w io.Writer) er.R {
-	return r.processedPacket.NextPacket.Encode(w)
+	if err := r.processedPacket.NextPacket.Encode(w); err != nil {
+		return err
+	}
+
+	if len(r.extraOnionBlob) == 0 {
+		return nil
+	}
+	_, err := util.Write(w, r.extraOnionBlob)
+	return err
+}
+
+// ExtraOnionBlob returns any extra data which trailed the onion packet
+// destined to this hop, or nil if there was none.
+//
+// NOTE: Part of the HopIterator interface.
+func (r *sphinxHopIterator) ExtraOnionBlob() []byte {
+	return r.extraOnionBlob
+}
+
+// CustomRecords returns the custom records included in the payload destined to
+// this hop. An empty map is returned for legacy payloads, and for payloads
+// which can't be decoded, the error being reported by HopPayload instead.
+//
+// NOTE: Part of the HopIterator interface.
+func (r *sphinxHopIterator) CustomRecords() map[uint64][]byte {
+	payload, err := r.HopPayload()
+	if err != nil {
+		return make(map[uint64][]byte)
+	}
+
+	return payload.CustomRecords()
 }
 
 // HopPayload returns the set of fields that detail exactly _how_ this hop
@@ -150,8 +200,6 @@ func (p *OnionProcessor) DecodeHopIterator(r io.Reader, rHash []byte,
 	onionPkt := &sphinx.OnionPacket{}
 	if err := onionPkt.Decode(r); err != nil {
 		switch {
-		case sphinx.ErrInvalidOnionVersion.Is(err):
-			return nil, lnwire.CodeInvalidOnionVersion
 		case sphinx.ErrInvalidOnionKey.Is(err):
 			return nil, lnwire.CodeInvalidOnionKey
 		default:
@@ -160,6 +208,12 @@ func (p *OnionProcessor) DecodeHopIterator(r io.Reader, rHash []byte,
 		}
 	}
 
+	extraOnionBlob, err := readExtraOnionBlob(r)
+	if err != nil {
+		log.Errorf("unable to read extra onion blob: %v", err)
+		return nil, lnwire.CodeInvalidOnionKey
+	}
+
 	// Attempt to process the Sphinx packet. We include the payment hash of
 	// the HTLC as it's authenticated within the Sphinx packet itself as
 	// associated data in order to thwart attempts a replay attacks. In the
@@ -170,7 +224,7 @@ func (p *OnionProcessor) DecodeHopIterator(r io.Reader, rHash []byte,
 	)
 	if err != nil {
 		switch {
-		case sphinx.ErrInvalidOnionVersion.Is(err):
+		case sphinx.ErrUnsupportedOnionVersion.Is(err):
 			return nil, lnwire.CodeInvalidOnionVersion
 		case sphinx.ErrInvalidOnionHMAC.Is(err):
 			return nil, lnwire.CodeInvalidOnionHmac
@@ -182,7 +236,9 @@ func (p *OnionProcessor) DecodeHopIterator(r io.Reader, rHash []byte,
 		}
 	}
 
-	return makeSphinxHopIterator(onionPkt, sphinxPacket), lnwire.CodeNone
+	return makeSphinxHopIterator(
+		onionPkt, sphinxPacket, extraOnionBlob,
+	), lnwire.CodeNone
 }
 
 // ReconstructHopIterator attempts to decode a valid sphinx packet from the passed io.Reader
@@ -195,6 +251,10 @@ func (p *OnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte) (
 	if err := onionPkt.Decode(r); err != nil {
 		return nil, err
 	}
+	extraOnionBlob, err := readExtraOnionBlob(r)
+	if err != nil {
+		return nil, err
+	}
 
 	// Attempt to process the Sphinx packet. We include the payment hash of
 	// the HTLC as it's authenticated within the Sphinx packet itself as
@@ -206,7 +266,20 @@ func (p *OnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte) (
 		return nil, err
 	}
 
-	return makeSphinxHopIterator(onionPkt, sphinxPacket), nil
+	return makeSphinxHopIterator(onionPkt, sphinxPacket, extraOnionBlob), nil
+}
+
+// readExtraOnionBlob reads any data which trails the onion packet from the
+// passed reader, returning nil if there is none.
+func readExtraOnionBlob(r io.Reader) ([]byte, er.R) {
+	extraOnionBlob, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, er.E(err)
+	}
+	if len(extraOnionBlob) == 0 {
+		return nil, nil
+	}
+	return extraOnionBlob, nil
 }
 
 // DecodeHopIteratorRequest encapsulates all date necessary to process an onion
@@ -247,9 +320,26 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 	var (
 		batchSize = len(reqs)
 		onionPkts = make([]sphinx.OnionPacket, batchSize)
+		extras    = make([][]byte, batchSize)
 		resps     = make([]DecodeHopIteratorResponse, batchSize)
 	)
 
+	// If the router is unable to process any packet, because its key is
+	// invalid or its replay log is unavailable, the whole batch would fail
+	// anyway. In that case we fail it right away with the same failure
+	// code a failed commit results in, rather than doing the ECDH for each
+	// packet first.
+	if err := p.router.Ready(); err != nil {
+		log.Errorf("unable to process onion packet batch %x: %v",
+			id, err)
+
+		for i := range resps {
+			resps[i].FailCode = lnwire.CodeTemporaryChannelFailure
+		}
+
+		return resps, err
+	}
+
 	tx := p.router.BeginTxn(id, batchSize)
 
 	for i, req := range reqs {
@@ -261,10 +351,6 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 		case nil == err:
 			// success
 
-		case sphinx.ErrInvalidOnionVersion.Is(err):
-			resp.FailCode = lnwire.CodeInvalidOnionVersion
-			continue
-
 		case sphinx.ErrInvalidOnionKey.Is(err):
 			resp.FailCode = lnwire.CodeInvalidOnionKey
 			continue
@@ -275,6 +361,13 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 			continue
 		}
 
+		extras[i], err = readExtraOnionBlob(req.OnionReader)
+		if err != nil {
+			log.Errorf("unable to read extra onion blob: %v", err)
+			resp.FailCode = lnwire.CodeInvalidOnionKey
+			continue
+		}
+
 		err = tx.ProcessOnionPacket(
 			uint16(i), onionPkt, req.RHash, req.IncomingCltv,
 		)
@@ -282,7 +375,7 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 		case err == nil:
 			// success
 
-		case sphinx.ErrInvalidOnionVersion.Is(err):
+		case sphinx.ErrUnsupportedOnionVersion.Is(err):
 			resp.FailCode = lnwire.CodeInvalidOnionVersion
 			continue
 
@@ -356,7 +449,9 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 
 		// Finally, construct a hop iterator from our processed sphinx
 		// packet, simultaneously caching the original onion packet.
-		resp.HopIterator = makeSphinxHopIterator(&onionPkts[i], &packets[i])
+		resp.HopIterator = makeSphinxHopIterator(
+			&onionPkts[i], &packets[i], extras[i],
+		)
 	}
 
 	return resps, nil
@@ -375,8 +470,6 @@ func (p *OnionProcessor) ExtractErrorEncrypter(ephemeralKey *btcec.PublicKey) (
 	)
 	if err != nil {
 		switch {
-		case sphinx.ErrInvalidOnionVersion.Is(err):
-			return nil, lnwire.CodeInvalidOnionVersion
 		case sphinx.ErrInvalidOnionHMAC.Is(err):
 			return nil, lnwire.CodeInvalidOnionHmac
 		case sphinx.ErrInvalidOnionKey.Is(err):
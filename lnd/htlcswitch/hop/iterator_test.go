@@ -6,10 +6,14 @@ import (
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/kaotisk-hund/cjdcoind/btcec"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg"
 	sphinx "github.com/kaotisk-hund/cjdcoind/lightning-onion"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
 	"github.com/kaotisk-hund/cjdcoind/lnd/record"
 	"github.com/kaotisk-hund/cjdcoind/lnd/tlv"
+	"github.com/stretchr/testify/require"
 )
 
 // TestSphinxHopIteratorForwardingInstructions tests that we're able to
@@ -99,3 +103,302 @@ func TestSphinxHopIteratorForwardingInstructions(t *testing.T) {
 		}
 	}
 }
+
+// TestSphinxHopIteratorExtraOnionBlob tests that extra data trailing an onion
+// packet is exposed by the decoded hop iterator, and that it's preserved when
+// encoding the packet for the next hop.
+func TestSphinxHopIteratorExtraOnionBlob(t *testing.T) {
+	t.Parallel()
+
+	// Create a two hop route, with an onion processor for each hop.
+	var (
+		route      sphinx.PaymentPath
+		processors [2]*OnionProcessor
+	)
+	for i := range processors {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		util.RequireNoErr(t, err)
+
+		router := sphinx.NewRouter(
+			&sphinx.PrivKeyECDH{PrivKey: privKey},
+			&chaincfg.MainNetParams, sphinx.NewMemoryReplayLog(),
+		)
+		processors[i] = NewOnionProcessor(router)
+		util.RequireNoErr(t, processors[i].Start())
+		defer processors[i].Stop()
+
+		hopData := sphinx.HopData{
+			ForwardAmount: 1000,
+			OutgoingCltv:  uint32(100 - i),
+		}
+		hopPayload, err := sphinx.NewHopPayload(&hopData, nil)
+		util.RequireNoErr(t, err)
+
+		route[i] = sphinx.OnionHop{
+			NodePub:    *privKey.PubKey(),
+			HopPayload: hopPayload,
+		}
+	}
+
+	sessionKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), bytes.Repeat([]byte{'A'}, 32),
+	)
+	rHash := bytes.Repeat([]byte{'B'}, 32)
+	onionPkt, err := sphinx.NewOnionPacket(
+		&route, sessionKey, rHash, sphinx.DeterministicPacketFiller,
+	)
+	util.RequireNoErr(t, err)
+
+	// Append the extra data to the encoded onion packet.
+	extra := []byte("extra onion data")
+	var b bytes.Buffer
+	util.RequireNoErr(t, onionPkt.Encode(&b))
+	_, err = util.Write(&b, extra)
+	util.RequireNoErr(t, err)
+
+	// The first hop should decode the extra data.
+	iterator, failCode := processors[0].DecodeHopIterator(&b, rHash, 200)
+	require.Equal(t, lnwire.CodeNone, failCode)
+	require.Equal(t, extra, iterator.ExtraOnionBlob())
+
+	// After forwarding, the extra data should reach the second hop.
+	var next bytes.Buffer
+	util.RequireNoErr(t, iterator.EncodeNextHop(&next))
+
+	iterator, failCode = processors[1].DecodeHopIterator(&next, rHash, 100)
+	require.Equal(t, lnwire.CodeNone, failCode)
+	require.Equal(t, extra, iterator.ExtraOnionBlob())
+
+	// A packet without extra data has no extra onion blob.
+	b.Reset()
+	util.RequireNoErr(t, onionPkt.Encode(&b))
+	iterator, err = processors[0].ReconstructHopIterator(&b, rHash)
+	util.RequireNoErr(t, err)
+	require.Nil(t, iterator.ExtraOnionBlob())
+}
+
+// newTestOnionBatch creates an onion processor using the given replay log, along
+// with numPkts encoded single hop onion packets destined to it.
+func newTestOnionBatch(t testing.TB, log sphinx.ReplayLog,
+	numPkts int) (*OnionProcessor, [][]byte, []byte) {
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	util.RequireNoErr(t, err)
+
+	router := sphinx.NewRouter(
+		&sphinx.PrivKeyECDH{PrivKey: privKey}, &chaincfg.MainNetParams,
+		log,
+	)
+	processor := NewOnionProcessor(router)
+
+	hopPayload, err := sphinx.NewHopPayload(&sphinx.HopData{
+		ForwardAmount: 1000,
+		OutgoingCltv:  100,
+	}, nil)
+	util.RequireNoErr(t, err)
+
+	route := sphinx.PaymentPath{{
+		NodePub:    *privKey.PubKey(),
+		HopPayload: hopPayload,
+	}}
+
+	rHash := bytes.Repeat([]byte{'B'}, 32)
+	pkts := make([][]byte, numPkts)
+	for i := range pkts {
+		sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+		util.RequireNoErr(t, err)
+
+		onionPkt, err := sphinx.NewOnionPacket(
+			&route, sessionKey, rHash,
+			sphinx.DeterministicPacketFiller,
+		)
+		util.RequireNoErr(t, err)
+
+		var b bytes.Buffer
+		util.RequireNoErr(t, onionPkt.Encode(&b))
+		pkts[i] = b.Bytes()
+	}
+
+	return processor, pkts, rHash
+}
+
+// newTestDecodeRequests creates a batch of decode requests for the given
+// encoded onion packets.
+func newTestDecodeRequests(pkts [][]byte,
+	rHash []byte) []DecodeHopIteratorRequest {
+
+	reqs := make([]DecodeHopIteratorRequest, len(pkts))
+	for i, pkt := range pkts {
+		reqs[i] = DecodeHopIteratorRequest{
+			OnionReader:  bytes.NewReader(pkt),
+			RHash:        rHash,
+			IncomingCltv: 200,
+		}
+	}
+
+	return reqs
+}
+
+// TestDecodeHopIteratorsRouterNotReady tests that a batch is failed as a whole
+// if the replay log of the router isn't available, and decoded as usual once it
+// is.
+func TestDecodeHopIteratorsRouterNotReady(t *testing.T) {
+	t.Parallel()
+
+	processor, pkts, rHash := newTestOnionBatch(
+		t, sphinx.NewMemoryReplayLog(), 5,
+	)
+
+	// The replay log hasn't been started yet, so the whole batch should
+	// fail with the same code a failed commit results in.
+	resps, err := processor.DecodeHopIterators(
+		[]byte("batch"), newTestDecodeRequests(pkts, rHash),
+	)
+	util.RequireErr(t, err)
+	require.Len(t, resps, len(pkts))
+	for _, resp := range resps {
+		require.Nil(t, resp.HopIterator)
+		require.Equal(
+			t, lnwire.CodeTemporaryChannelFailure, resp.FailCode,
+		)
+	}
+
+	// Once started, the same batch should be decoded successfully.
+	util.RequireNoErr(t, processor.Start())
+	defer processor.Stop()
+
+	resps, err = processor.DecodeHopIterators(
+		[]byte("batch"), newTestDecodeRequests(pkts, rHash),
+	)
+	util.RequireNoErr(t, err)
+	require.Len(t, resps, len(pkts))
+	for _, resp := range resps {
+		require.Equal(t, lnwire.CodeNone, resp.FailCode)
+		require.NotNil(t, resp.HopIterator)
+	}
+}
+
+// TestDecodeHopIteratorUnsupportedVersion tests that onion packets of a version
+// outside of the router's supported set are failed with
+// CodeInvalidOnionVersion, and processed once the version is supported.
+func TestDecodeHopIteratorUnsupportedVersion(t *testing.T) {
+	t.Parallel()
+
+	processor, pkts, rHash := newTestOnionBatch(
+		t, sphinx.NewMemoryReplayLog(), 2,
+	)
+	util.RequireNoErr(t, processor.Start())
+	defer processor.Stop()
+
+	// The version is the first byte of an encoded packet, and isn't
+	// covered by its HMAC.
+	const newVersion = 1
+	for _, pkt := range pkts {
+		pkt[0] = newVersion
+	}
+
+	_, failCode := processor.DecodeHopIterator(
+		bytes.NewReader(pkts[0]), rHash, 200,
+	)
+	require.Equal(t, lnwire.CodeInvalidOnionVersion, failCode)
+
+	resps, err := processor.DecodeHopIterators(
+		[]byte("unsupported"), newTestDecodeRequests(pkts[1:], rHash),
+	)
+	util.RequireNoErr(t, err)
+	require.Equal(t, lnwire.CodeInvalidOnionVersion, resps[0].FailCode)
+
+	// Once the router supports the new version, the packets are
+	// processed.
+	processor.router.SupportedVersions = []byte{0, newVersion}
+
+	_, failCode = processor.DecodeHopIterator(
+		bytes.NewReader(pkts[0]), rHash, 200,
+	)
+	require.Equal(t, lnwire.CodeNone, failCode)
+
+	resps, err = processor.DecodeHopIterators(
+		[]byte("supported"), newTestDecodeRequests(pkts[1:], rHash),
+	)
+	util.RequireNoErr(t, err)
+	require.Equal(t, lnwire.CodeNone, resps[0].FailCode)
+}
+
+// benchmarkDecodeHopIterators decodes a batch of 500 onion packets, either
+// with a started replay log, or with one that isn't started, in which case the
+// whole batch fails.
+func benchmarkDecodeHopIterators(b *testing.B, started bool) {
+	processor, pkts, rHash := newTestOnionBatch(
+		b, sphinx.NewMemoryReplayLog(), 500,
+	)
+	if started {
+		util.RequireNoErr(b, processor.Start())
+		defer processor.Stop()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		reqs := newTestDecodeRequests(pkts, rHash)
+		b.StartTimer()
+
+		_, err := processor.DecodeHopIterators([]byte("batch"), reqs)
+		if started != (err == nil) {
+			b.Fatalf("unexpected result: %v", err)
+		}
+	}
+}
+
+// BenchmarkDecodeHopIterators measures decoding a batch of 500 onion packets.
+func BenchmarkDecodeHopIterators(b *testing.B) {
+	benchmarkDecodeHopIterators(b, true)
+}
+
+// BenchmarkDecodeHopIteratorsNotReady measures failing a batch of 500 onion
+// packets because the replay log isn't available, which no longer requires an
+// ECDH operation per packet.
+func BenchmarkDecodeHopIteratorsNotReady(b *testing.B) {
+	benchmarkDecodeHopIterators(b, false)
+}
+
+// TestSphinxHopIteratorCustomRecords tests that the custom records of a TLV
+// payload can be read back through the iterator, while a legacy payload yields
+// an empty map.
+func TestSphinxHopIteratorCustomRecords(t *testing.T) {
+	t.Parallel()
+
+	customRecords := map[uint64][]byte{
+		record.CustomTypeStart: []byte("custom"),
+	}
+	payload, err := NewTLVPayload(ForwardingInfo{
+		NextHop:         Exit,
+		AmountToForward: 1000,
+		OutgoingCTLV:    144,
+	}, customRecords)
+	util.RequireNoErr(t, err)
+
+	var b bytes.Buffer
+	util.RequireNoErr(t, payload.Encode(&b))
+
+	iterator := sphinxHopIterator{
+		processedPacket: &sphinx.ProcessedPacket{
+			Payload: sphinx.HopPayload{
+				Type:    sphinx.PayloadTLV,
+				Payload: b.Bytes(),
+			},
+		},
+	}
+	require.Equal(t, customRecords, iterator.CustomRecords())
+
+	iterator.processedPacket = &sphinx.ProcessedPacket{
+		Payload: sphinx.HopPayload{
+			Type: sphinx.PayloadLegacy,
+		},
+		ForwardingInstructions: &sphinx.HopData{},
+	}
+	records := iterator.CustomRecords()
+	require.NotNil(t, records)
+	require.Empty(t, records)
+}
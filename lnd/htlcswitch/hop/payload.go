@@ -108,6 +108,27 @@ func NewLegacyPayload(f *sphinx.HopData) *Payload {
 	}
 }
 
+// NewTLVPayload builds a Payload to be delivered to a hop in a TLV onion
+// payload, from the forwarding parameters and the extra records to include.
+// The extra records are copied, and must be in the custom type range so that
+// they can't overwrite the records used for forwarding.
+func NewTLVPayload(fwdInfo ForwardingInfo,
+	extraRecords map[uint64][]byte) (*Payload, er.R) {
+
+	customRecords := make(record.CustomSet, len(extraRecords))
+	for t, value := range extraRecords {
+		customRecords[t] = append([]byte(nil), value...)
+	}
+	if err := customRecords.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Payload{
+		FwdInfo:       fwdInfo,
+		customRecords: customRecords,
+	}, nil
+}
+
 // NewPayloadFromReader builds a new Hop from the passed io.Reader. The reader
 // should correspond to the bytes encapsulated in a TLV onion payload.
 func NewPayloadFromReader(r io.Reader) (*Payload, er.R) {
@@ -172,6 +193,49 @@ func NewPayloadFromReader(r io.Reader) (*Payload, er.R) {
 	}, nil
 }
 
+// Encode writes the payload to the passed io.Writer as a TLV onion payload,
+// which can be parsed back with NewPayloadFromReader. The next hop id is
+// omitted for the exit hop, and an MPP record may only be included for it.
+func (h *Payload) Encode(w io.Writer) er.R {
+	var (
+		amt        = uint64(h.FwdInfo.AmountToForward)
+		cltv       = h.FwdInfo.OutgoingCTLV
+		nextHop    = h.FwdInfo.NextHop.ToUint64()
+		isFinalHop = h.FwdInfo.NextHop == Exit
+	)
+
+	records := []tlv.Record{
+		record.NewAmtToFwdRecord(&amt),
+		record.NewLockTimeRecord(&cltv),
+	}
+	if !isFinalHop {
+		records = append(records, record.NewNextHopIDRecord(&nextHop))
+	}
+
+	if h.MPP != nil {
+		if !isFinalHop {
+			return er.E(ErrInvalidPayload{
+				Type:      record.MPPOnionType,
+				Violation: IncludedViolation,
+				FinalHop:  false,
+			})
+		}
+		records = append(records, h.MPP.Record())
+	}
+
+	records = append(records, tlv.MapToRecords(h.customRecords)...)
+
+	// Sort the records to produce a canonical stream.
+	tlv.SortRecords(records)
+
+	tlvStream, err := tlv.NewStream(records...)
+	if err != nil {
+		return err
+	}
+
+	return tlvStream.Encode(w)
+}
+
 // ForwardingInfo returns the basic parameters required for HTLC forwarding,
 // e.g. amount, cltv, and next hop.
 func (h *Payload) ForwardingInfo() ForwardingInfo {
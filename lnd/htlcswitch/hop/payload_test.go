@@ -254,3 +254,140 @@ func testDecodeHopPayloadValidation(t *testing.T, test decodePayloadTest) {
 		t.Fatalf("invalid custom records")
 	}
 }
+
+// TestTLVPayloadRoundTrip asserts that a payload built with NewTLVPayload is
+// decoded back to the same forwarding info, MPP and custom records.
+func TestTLVPayloadRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		fwdInfo hop.ForwardingInfo
+		mpp     *record.MPP
+		records map[uint64][]byte
+	}{
+		{
+			name: "intermediate hop",
+			fwdInfo: hop.ForwardingInfo{
+				Network:         hop.BitcoinNetwork,
+				NextHop:         lnwire.NewShortChanIDFromInt(123),
+				AmountToForward: 1000,
+				OutgoingCTLV:    144,
+			},
+			records: map[uint64][]byte{
+				record.CustomTypeStart: {0x01, 0x02},
+			},
+		},
+		{
+			name: "final hop",
+			fwdInfo: hop.ForwardingInfo{
+				Network:         hop.BitcoinNetwork,
+				NextHop:         hop.Exit,
+				AmountToForward: 5000,
+				OutgoingCTLV:    40,
+			},
+			mpp: record.NewMPP(8000, [32]byte{0x11}),
+			records: map[uint64][]byte{
+				record.KeySendType:         {0x22},
+				record.CustomTypeStart + 1: {},
+			},
+		},
+		{
+			name: "no custom records",
+			fwdInfo: hop.ForwardingInfo{
+				Network:         hop.BitcoinNetwork,
+				NextHop:         hop.Exit,
+				AmountToForward: 1,
+				OutgoingCTLV:    1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := hop.NewTLVPayload(
+				test.fwdInfo, test.records,
+			)
+			if err != nil {
+				t.Fatalf("unable to create payload: %v", err)
+			}
+			payload.MPP = test.mpp
+
+			var b bytes.Buffer
+			if err := payload.Encode(&b); err != nil {
+				t.Fatalf("unable to encode payload: %v", err)
+			}
+
+			decoded, err := hop.NewPayloadFromReader(&b)
+			if err != nil {
+				t.Fatalf("unable to decode payload: %v", err)
+			}
+
+			if decoded.ForwardingInfo() != test.fwdInfo {
+				t.Fatalf("expected forwarding info %v, got %v",
+					test.fwdInfo, decoded.ForwardingInfo())
+			}
+			if !reflect.DeepEqual(decoded.MultiPath(), test.mpp) {
+				t.Fatalf("expected MPP %v, got %v", test.mpp,
+					decoded.MultiPath())
+			}
+
+			expRecords := record.CustomSet(test.records)
+			if expRecords == nil {
+				expRecords = make(record.CustomSet)
+			}
+			if !reflect.DeepEqual(
+				expRecords, decoded.CustomRecords(),
+			) {
+
+				t.Fatalf("expected custom records %v, got %v",
+					expRecords, decoded.CustomRecords())
+			}
+		})
+	}
+}
+
+// TestTLVPayloadReservedTypes asserts that extra records can't overwrite the
+// records reserved for forwarding, and that an MPP record isn't encoded for
+// an intermediate hop.
+func TestTLVPayloadReservedTypes(t *testing.T) {
+	t.Parallel()
+
+	fwdInfo := hop.ForwardingInfo{
+		Network:         hop.BitcoinNetwork,
+		NextHop:         lnwire.NewShortChanIDFromInt(1),
+		AmountToForward: 1000,
+		OutgoingCTLV:    144,
+	}
+
+	reservedTypes := []uint64{
+		uint64(record.AmtOnionType), uint64(record.LockTimeOnionType),
+		uint64(record.NextHopOnionType), uint64(record.MPPOnionType),
+		record.CustomTypeStart - 1,
+	}
+	for _, reservedType := range reservedTypes {
+		_, err := hop.NewTLVPayload(fwdInfo, map[uint64][]byte{
+			reservedType: {0x01},
+		})
+		if err == nil {
+			t.Fatalf("expected extra record of type %d to be "+
+				"rejected", reservedType)
+		}
+	}
+
+	payload, err := hop.NewTLVPayload(fwdInfo, nil)
+	if err != nil {
+		t.Fatalf("unable to create payload: %v", err)
+	}
+	payload.MPP = record.NewMPP(1000, [32]byte{})
+
+	expErr := hop.ErrInvalidPayload{
+		Type:      record.MPPOnionType,
+		Violation: hop.IncludedViolation,
+	}
+	err = payload.Encode(&bytes.Buffer{})
+	if !reflect.DeepEqual(er.Wrapped(err), expErr) {
+		t.Fatalf("expected error %v, got %v", expErr, err)
+	}
+}
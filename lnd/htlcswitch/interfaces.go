@@ -106,6 +106,10 @@ type ChannelLink interface {
 	// policy to govern if it an incoming HTLC should be forwarded or not.
 	UpdateForwardingPolicy(ForwardingPolicy)
 
+	// ForwardingPolicy returns the forwarding policy which is currently in
+	// force on the target ChannelLink.
+	ForwardingPolicy() ForwardingPolicy
+
 	// CheckHtlcForward should return a nil error if the passed HTLC details
 	// satisfy the current forwarding policy fo the target link. Otherwise,
 	// a LinkError with a valid protocol failure message should be returned
@@ -168,6 +172,18 @@ type ForwardingLog interface {
 	AddForwardingEvents([]channeldb.ForwardingEvent) er.R
 }
 
+// ForwardingLogQuerier is implemented by a ForwardingLog which can read back
+// the events written to it, such as the one of channeldb.
+type ForwardingLogQuerier interface {
+	// Query returns the forwarding events within the time slice of the
+	// given query.
+	Query(channeldb.ForwardingEventQuery) (channeldb.ForwardingLogTimeSlice, er.R)
+}
+
+// A compile time check to ensure the forwarding log of channeldb can be used
+// to compute forwarding statistics.
+var _ ForwardingLogQuerier = (*channeldb.ForwardingLog)(nil)
+
 // TowerClient is the primary interface used by the daemon to backup pre-signed
 // justice transactions to watchtowers.
 type TowerClient interface {
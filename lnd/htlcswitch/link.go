@@ -2176,6 +2176,17 @@ func (l *channelLink) UpdateForwardingPolicy(newPolicy ForwardingPolicy) {
 	l.cfg.FwrdingPolicy = newPolicy
 }
 
+// ForwardingPolicy returns the forwarding policy which is currently in force
+// on the link.
+//
+// NOTE: Part of the ChannelLink interface.
+func (l *channelLink) ForwardingPolicy() ForwardingPolicy {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.cfg.FwrdingPolicy
+}
+
 // CheckHtlcForward should return a nil error if the passed HTLC details
 // satisfy the current forwarding policy fo the target link. Otherwise,
 // a LinkError with a valid protocol failure message should be returned
@@ -2766,6 +2777,21 @@ func (l *channelLink) processRemoteAdds(fwdPkg *channeldb.FwdPkg,
 				continue
 			}
 
+			// The onion of an UpdateAddHTLC has a fixed size, so
+			// any extra data which trailed the onion destined to
+			// us can't be passed along to the next hop. Rather
+			// than silently dropping it, we fail the htlc.
+			if extra := chanIterator.ExtraOnionBlob(); len(extra) != 0 {
+				log.Errorf("unable to forward extra onion blob "+
+					"of %d bytes", len(extra))
+
+				failure := lnwire.NewInvalidOnionPayload(0, 0)
+				l.sendHTLCError(
+					pd, NewLinkError(failure), obfuscator, false,
+				)
+				continue
+			}
+
 			// TODO(roasbeef): ensure don't accept outrageous
 			// timeout for htlc
 
@@ -35,6 +35,7 @@ import (
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet/chainfee"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
+	"github.com/kaotisk-hund/cjdcoind/lnd/record"
 	"github.com/kaotisk-hund/cjdcoind/lnd/ticker"
 	"github.com/kaotisk-hund/cjdcoind/wire"
 )
@@ -844,6 +845,67 @@ func TestLinkForwardMaxHTLCPolicyMismatch(t *testing.T) {
 	}
 }
 
+// TestChannelLinkMultiHopCustomRecords tests that the custom records of the
+// payload destined to the exit hop survive the forward through an
+// intermediate hop, and end up in the invoice.
+func TestChannelLinkMultiHopCustomRecords(t *testing.T) {
+	t.Parallel()
+
+	channels, cleanUp, _, err := createClusterChannels(
+		btcutil.UnitsPerCoin()*5,
+		btcutil.UnitsPerCoin()*5)
+	if err != nil {
+		t.Fatalf("unable to create channel: %v", err)
+	}
+	defer cleanUp()
+
+	n := newThreeHopNetwork(t, channels.aliceToBob, channels.bobToAlice,
+		channels.bobToCarol, channels.carolToBob, testStartingHeight)
+	if err := n.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer n.stop()
+
+	amount := lnwire.NewMSatFromSatoshis(10)
+	htlcAmt, htlcExpiry, hops := generateHops(amount, testStartingHeight,
+		n.firstBobChannelLink, n.carolChannelLink)
+
+	// Give Carol a TLV payload carrying a custom record.
+	customRecords := record.CustomSet{
+		record.CustomTypeStart: []byte("custom"),
+	}
+	hops[1], err = hop.NewTLVPayload(hops[1].FwdInfo, customRecords)
+	if err != nil {
+		t.Fatalf("unable to create payload: %v", err)
+	}
+
+	firstHop := n.firstBobChannelLink.ShortChanID()
+	payResp, err := makePayment(
+		n.aliceServer, n.carolServer, firstHop, hops, amount, htlcAmt,
+		htlcExpiry,
+	).Wait(30 * time.Second)
+	if err != nil {
+		t.Fatalf("unable to send payment: %v", err)
+	}
+
+	invoice, err := n.carolServer.registry.LookupInvoice(payResp)
+	if err != nil {
+		t.Fatalf("unable to get invoice: %v", err)
+	}
+	if invoice.State != channeldb.ContractSettled {
+		t.Fatal("carol invoice haven't been settled")
+	}
+	if len(invoice.Htlcs) != 1 {
+		t.Fatalf("expected 1 invoice htlc, got %d", len(invoice.Htlcs))
+	}
+	for _, htlc := range invoice.Htlcs {
+		if !reflect.DeepEqual(htlc.CustomRecords, customRecords) {
+			t.Fatalf("expected custom records %v, got %v",
+				customRecords, htlc.CustomRecords)
+		}
+	}
+}
+
 // TestUpdateForwardingPolicy tests that the forwarding policy for a link is
 // able to be updated properly. We'll first create an HTLC that meets the
 // specified policy, assert that it succeeds, update the policy (to invalidate
@@ -1081,6 +1143,71 @@ func TestChannelLinkMultiHopInsufficientPayment(t *testing.T) {
 	}
 }
 
+// TestChannelLinkMultiHopExtraOnionBlob checks that an htlc whose onion is
+// trailed by extra data is failed back by the forwarding node, as the data
+// can't be passed along within the fixed size onion of the outgoing htlc.
+func TestChannelLinkMultiHopExtraOnionBlob(t *testing.T) {
+	t.Parallel()
+
+	channels, cleanUp, _, err := createClusterChannels(
+		btcutil.UnitsPerCoin()*5,
+		btcutil.UnitsPerCoin()*5)
+	if err != nil {
+		t.Fatalf("unable to create channel: %v", err)
+	}
+	defer cleanUp()
+
+	n := newThreeHopNetwork(t, channels.aliceToBob, channels.bobToAlice,
+		channels.bobToCarol, channels.carolToBob, testStartingHeight)
+
+	// Bob decodes an extra onion blob from the onion alice sends him.
+	n.bobOnionDecoder.extraOnionBlob = []byte("extra onion data")
+
+	if err := n.start(); err != nil {
+		t.Fatalf("unable to start three hop network: %v", err)
+	}
+	defer n.stop()
+
+	carolBandwidthBefore := n.carolChannelLink.Bandwidth()
+	aliceBandwidthBefore := n.aliceChannelLink.Bandwidth()
+
+	amount := lnwire.NewMSatFromSatoshis(btcutil.UnitsPerCoin())
+	htlcAmt, totalTimelock, hops := generateHops(amount, testStartingHeight,
+		n.firstBobChannelLink, n.carolChannelLink)
+
+	receiver := n.carolServer
+	firstHop := n.firstBobChannelLink.ShortChanID()
+	rhash, err := makePayment(
+		n.aliceServer, n.carolServer, firstHop, hops, amount, htlcAmt,
+		totalTimelock,
+	).Wait(30 * time.Second)
+	if err == nil {
+		t.Fatal("error haven't been received")
+	}
+	assertFailureCode(t, err, lnwire.CodeInvalidOnionPayload)
+
+	// Wait for Alice to receive the revocation.
+	time.Sleep(100 * time.Millisecond)
+
+	// The htlc should never have reached carol.
+	invoice, err := receiver.registry.LookupInvoice(rhash)
+	if err != nil {
+		t.Fatalf("unable to get invoice: %v", err)
+	}
+	if invoice.State == channeldb.ContractSettled {
+		t.Fatal("carol invoice have been settled")
+	}
+
+	if n.aliceChannelLink.Bandwidth() != aliceBandwidthBefore {
+		t.Fatal("the bandwidth of alice channel link which handles " +
+			"alice->bob channel should be the same")
+	}
+	if n.carolChannelLink.Bandwidth() != carolBandwidthBefore {
+		t.Fatal("the bandwidth of carol channel link which handles " +
+			"bob->carol channel should be the same")
+	}
+}
+
 // TestChannelLinkMultiHopUnknownPaymentHash checks that we receive remote error
 // from Alice if she received not suitable payment hash for htlc.
 func TestChannelLinkMultiHopUnknownPaymentHash(t *testing.T) {
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -28,6 +29,7 @@ import (
 	"github.com/kaotisk-hund/cjdcoind/lnd/lntypes"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet/chainfee"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
+	"github.com/kaotisk-hund/cjdcoind/lnd/record"
 	"github.com/kaotisk-hund/cjdcoind/lnd/ticker"
 	"github.com/kaotisk-hund/cjdcoind/wire"
 )
@@ -116,6 +118,39 @@ func (m *mockForwardingLog) AddForwardingEvents(events []channeldb.ForwardingEve
 	return nil
 }
 
+func (m *mockForwardingLog) Query(q channeldb.ForwardingEventQuery) (
+	channeldb.ForwardingLogTimeSlice, er.R) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	var events []channeldb.ForwardingEvent
+	for timestamp, event := range m.events {
+		if timestamp.Before(q.StartTime) || timestamp.After(q.EndTime) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	if uint32(len(events)) < q.IndexOffset {
+		events = nil
+	} else {
+		events = events[q.IndexOffset:]
+	}
+	if uint32(len(events)) > q.NumMaxEvents {
+		events = events[:q.NumMaxEvents]
+	}
+
+	return channeldb.ForwardingLogTimeSlice{
+		ForwardingEventQuery: q,
+		ForwardingEvents:     events,
+		LastIndexOffset:      q.IndexOffset + uint32(len(events)),
+	}, nil
+}
+
 type mockServer struct {
 	started  int32 // To be used atomically.
 	shutdown int32 // To be used atomically.
@@ -272,6 +307,13 @@ func (s *mockServer) QuitSignal() <-chan struct{} {
 // of encrypting the path in onion blob just stores the path as a list of hops.
 type mockHopIterator struct {
 	hops []*hop.Payload
+
+	// current is the payload destined to this hop, once it has been
+	// returned by HopPayload.
+	current *hop.Payload
+
+	// extraOnionBlob is returned by ExtraOnionBlob.
+	extraOnionBlob []byte
 }
 
 func newMockHopIterator(hops ...*hop.Payload) hop.Iterator {
@@ -281,11 +323,23 @@ func newMockHopIterator(hops ...*hop.Payload) hop.Iterator {
 func (r *mockHopIterator) HopPayload() (*hop.Payload, er.R) {
 	h := r.hops[0]
 	r.hops = r.hops[1:]
+	r.current = h
 	return h, nil
 }
 
 func (r *mockHopIterator) ExtraOnionBlob() []byte {
-	return nil
+	return r.extraOnionBlob
+}
+
+func (r *mockHopIterator) CustomRecords() map[uint64][]byte {
+	h := r.current
+	if h == nil {
+		if len(r.hops) == 0 {
+			return make(map[uint64][]byte)
+		}
+		h = r.hops[0]
+	}
+	return h.CustomRecords()
 }
 
 func (r *mockHopIterator) ExtractErrorEncrypter(
@@ -308,6 +362,9 @@ func (r *mockHopIterator) EncodeNextHop(w io.Writer) er.R {
 		if err := encodeFwdInfo(w, &fwdInfo); err != nil {
 			return err
 		}
+		if err := encodeCustomRecords(w, hop.CustomRecords()); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -322,14 +379,45 @@ func encodeFwdInfo(w io.Writer, f *hop.ForwardingInfo) er.R {
 		return err
 	}
 
-	if err := util.WriteBin(w, binary.BigEndian, f.AmountToForward); err != nil {
+	if err := util.WriteUint64BE(w, uint64(f.AmountToForward)); err != nil {
 		return err
 	}
 
-	if err := util.WriteBin(w, binary.BigEndian, f.OutgoingCTLV); err != nil {
+	if err := util.WriteUint32BE(w, f.OutgoingCTLV); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// encodeCustomRecords writes the number of custom records followed by each of
+// them, sorted by type, as its type, length and value.
+func encodeCustomRecords(w io.Writer, records record.CustomSet) er.R {
+	if err := util.WriteUint32BE(w, uint32(len(records))); err != nil {
 		return err
 	}
 
+	types := make([]uint64, 0, len(records))
+	for t := range records {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	for _, t := range types {
+		if err := util.WriteUint64BE(w, t); err != nil {
+			return err
+		}
+		value := records[t]
+		if err := util.WriteUint32BE(w, uint32(len(value))); err != nil {
+			return err
+		}
+		if _, err := util.Write(w, value); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -390,11 +478,15 @@ func (o *mockObfuscator) EncryptMalformedError(reason lnwire.OpaqueReason) lnwir
 }
 
 // mockDeobfuscator mock implementation of the failure deobfuscator which
-// only decodes the failure do not makes any onion obfuscation.
-type mockDeobfuscator struct{}
+// only decodes the failure do not makes any onion obfuscation. As the failure
+// isn't onion encrypted, the index of the failing hop can't be learnt from it,
+// so a fixed index is reported instead.
+type mockDeobfuscator struct {
+	sourceIdx int
+}
 
 func newMockDeobfuscator() ErrorDecrypter {
-	return &mockDeobfuscator{}
+	return &mockDeobfuscator{sourceIdx: 1}
 }
 
 func (o *mockDeobfuscator) DecryptError(reason lnwire.OpaqueReason) (*ForwardingError, er.R) {
@@ -405,7 +497,7 @@ func (o *mockDeobfuscator) DecryptError(reason lnwire.OpaqueReason) (*Forwarding
 		return nil, err
 	}
 
-	return NewForwardingError(failure, 1), nil
+	return NewForwardingError(failure, o.sourceIdx), nil
 }
 
 var _ ErrorDecrypter = (*mockDeobfuscator)(nil)
@@ -418,6 +510,10 @@ type mockIteratorDecoder struct {
 	responses map[[32]byte][]hop.DecodeHopIteratorResponse
 
 	decodeFail bool
+
+	// extraOnionBlob, if set, is returned as the extra onion blob of all
+	// decoded iterators.
+	extraOnionBlob []byte
 }
 
 func newMockIteratorDecoder() *mockIteratorDecoder {
@@ -443,6 +539,21 @@ func (p *mockIteratorDecoder) DecodeHopIterator(r io.Reader, rHash []byte,
 			return nil, lnwire.CodeTemporaryChannelFailure
 		}
 
+		customRecords, err := decodeCustomRecords(r)
+		if err != nil {
+			return nil, lnwire.CodeTemporaryChannelFailure
+		}
+
+		// Hops with custom records must have been sent a TLV payload,
+		// the others are given a legacy one.
+		if len(customRecords) > 0 {
+			hops[i], err = hop.NewTLVPayload(f, customRecords)
+			if err != nil {
+				return nil, lnwire.CodeTemporaryChannelFailure
+			}
+			continue
+		}
+
 		var nextHopBytes [8]byte
 		binary.BigEndian.PutUint64(nextHopBytes[:], f.NextHop.ToUint64())
 
@@ -454,7 +565,10 @@ func (p *mockIteratorDecoder) DecodeHopIterator(r io.Reader, rHash []byte,
 		})
 	}
 
-	return newMockHopIterator(hops...), lnwire.CodeNone
+	return &mockHopIterator{
+		hops:           hops,
+		extraOnionBlob: p.extraOnionBlob,
+	}, lnwire.CodeNone
 }
 
 func (p *mockIteratorDecoder) DecodeHopIterators(id []byte,
@@ -507,15 +621,41 @@ func decodeFwdInfo(r io.Reader, f *hop.ForwardingInfo) er.R {
 		return err
 	}
 
-	if err := util.ReadBin(r, binary.BigEndian, &f.AmountToForward); err != nil {
+	amt, err := util.ReadUint64BE(r)
+	if err != nil {
 		return err
 	}
+	f.AmountToForward = lnwire.MilliSatoshi(amt)
 
-	if err := util.ReadBin(r, binary.BigEndian, &f.OutgoingCTLV); err != nil {
-		return err
+	f.OutgoingCTLV, err = util.ReadUint32BE(r)
+	return err
+}
+
+// decodeCustomRecords reads custom records written by encodeCustomRecords.
+func decodeCustomRecords(r io.Reader) (record.CustomSet, er.R) {
+	numRecords, err := util.ReadUint32BE(r)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	records := make(record.CustomSet, numRecords)
+	for i := uint32(0); i < numRecords; i++ {
+		t, err := util.ReadUint64BE(r)
+		if err != nil {
+			return nil, err
+		}
+		length, err := util.ReadUint32BE(r)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, length)
+		if _, err := util.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		records[t] = value
+	}
+
+	return records, nil
 }
 
 // messageInterceptor is function that handles the incoming peer messages and
@@ -651,6 +791,8 @@ type mockChannelLink struct {
 	checkHtlcTransitResult *LinkError
 
 	checkHtlcForwardResult *LinkError
+
+	forwardingPolicy ForwardingPolicy
 }
 
 // completeCircuit is a helper method for adding the finalized payment circuit
@@ -712,8 +854,14 @@ func (f *mockChannelLink) HandleLocalAddPacket(cjdcoin *htlcPacket) er.R {
 func (f *mockChannelLink) HandleChannelUpdate(lnwire.Message) {
 }
 
-func (f *mockChannelLink) UpdateForwardingPolicy(_ ForwardingPolicy) {
+func (f *mockChannelLink) UpdateForwardingPolicy(policy ForwardingPolicy) {
+	f.forwardingPolicy = policy
+}
+
+func (f *mockChannelLink) ForwardingPolicy() ForwardingPolicy {
+	return f.forwardingPolicy
 }
+
 func (f *mockChannelLink) CheckHtlcForward([32]byte, lnwire.MilliSatoshi,
 	lnwire.MilliSatoshi, uint32, uint32, uint32) *LinkError {
 
@@ -912,9 +1060,14 @@ func (m *mockCircuitMap) NumOpen() int {
 	return 0
 }
 
+func (m *mockCircuitMap) StaleCircuits(olderThan time.Duration) []*PaymentCircuit {
+	return nil
+}
+
 type mockOnionErrorDecryptor struct {
 	sourceIdx int
 	message   []byte
+	route     []*btcec.PublicKey
 	err       er.R
 }
 
@@ -924,6 +1077,7 @@ func (m *mockOnionErrorDecryptor) DecryptError(encryptedData []byte) (
 	return &sphinx.DecryptedError{
 		SenderIdx: m.sourceIdx,
 		Message:   m.message,
+		Route:     m.route,
 	}, m.err
 }
 
@@ -2,6 +2,7 @@ package htlcswitch
 
 import (
 	"bytes"
+	"fmt"
 	"math/rand"
 	"sync"
 	"sync/atomic"
@@ -72,8 +73,23 @@ var (
 	// ErrLocalAddFailed signals that the ADD htlc for a local payment
 	// failed to be processed.
 	ErrLocalAddFailed = Err.CodeWithDetail("ErrLocalAddFailed", "local add HTLC failed")
+
+	// ErrReplayUnavailable is returned when a forward can't be replayed,
+	// because the switch can't decode onions or the onion of the incoming
+	// htlc is no longer stored.
+	ErrReplayUnavailable = Err.CodeWithDetail("ErrReplayUnavailable",
+		"forward can't be replayed")
+
+	// ErrForwardingLogNotQueryable is returned when forwarding statistics
+	// are requested, but the configured forwarding log can't be queried.
+	ErrForwardingLogNotQueryable = Err.CodeWithDetail("ErrForwardingLogNotQueryable",
+		"forwarding log can't be queried")
 )
 
+// fwdStatsQueryBatch is the maximum number of forwarding events read from the
+// forwarding log at once when computing forwarding statistics.
+const fwdStatsQueryBatch = 1000
+
 // plexPacket encapsulates switch packet and adds error channel to receive
 // error from request handler.
 type plexPacket struct {
@@ -150,6 +166,12 @@ type Config struct {
 	// are not stored directly within the database.
 	ExtractErrorEncrypter hop.ErrorEncrypterExtracter
 
+	// DecodeHopIterators decodes the onions of a batch of incoming htlcs.
+	// It is used by ReplayForward to re-derive the forwarding decision of
+	// an htlc, and may be nil if replaying forwards isn't needed.
+	DecodeHopIterators func([]byte, []hop.DecodeHopIteratorRequest) (
+		[]hop.DecodeHopIteratorResponse, er.R)
+
 	// FetchLastChannelUpdate retrieves the latest routing policy for a
 	// target channel. This channel will typically be the outgoing channel
 	// specified when we receive an incoming HTLC.  This will be used to
@@ -185,6 +207,11 @@ type Config struct {
 	// HTLCs that are not from the source hop.
 	RejectHTLC bool
 
+	// MaxForwardedHTLC is the largest amount the switch forwards in a
+	// single HTLC, regardless of the policies and bandwidth of the
+	// outgoing links. Zero means there is no limit.
+	MaxForwardedHTLC lnwire.MilliSatoshi
+
 	// Clock is a time source for the switch.
 	Clock clock.Clock
 
@@ -212,6 +239,13 @@ type Switch struct {
 	// This will be retrieved by the registered links atomically.
 	bestHeight uint32
 
+	// drainMode is non-zero while the switch is draining, i.e. rejecting
+	// new forwards while the HTLCs of existing circuits are still settled
+	// or failed back. drainLocal is non-zero if locally-originated
+	// payments are rejected as well while draining.
+	drainMode  uint32 // To be used atomically.
+	drainLocal uint32 // To be used atomically.
+
 	wg   sync.WaitGroup
 	quit chan struct{}
 
@@ -281,6 +315,11 @@ type Switch struct {
 	fwdEventMtx         sync.Mutex
 	pendingFwdingEvents []channeldb.ForwardingEvent
 
+	// fwdFlushMtx is held while forwarding events are flushed to the
+	// forwarding log, so the events being written are never missing from
+	// both pendingFwdingEvents and the log when computing statistics.
+	fwdFlushMtx sync.Mutex
+
 	// blockEpochStream is an active block epoch event stream backed by an
 	// active ChainNotifier instance. This will be used to retrieve the
 	// lastest height of the chain.
@@ -297,6 +336,7 @@ func New(cfg Config, currentHeight uint32) (*Switch, er.R) {
 	circuitMap, err := NewCircuitMap(&CircuitMapConfig{
 		DB:                    cfg.DB,
 		ExtractErrorEncrypter: cfg.ExtractErrorEncrypter,
+		Clock:                 cfg.Clock,
 	})
 	if err != nil {
 		return nil, err
@@ -507,6 +547,36 @@ func (s *Switch) SendHTLC(firstHop lnwire.ShortChannelID, paymentID uint64,
 	return link.HandleLocalAddPacket(packet)
 }
 
+// SetDrainMode enables or disables drain mode. While draining, new forwards
+// are rejected with a channel disabled failure, but settles and fails of
+// existing circuits are still processed, so the switch can be stopped once all
+// in-flight HTLCs are resolved. Locally-originated payments are still allowed,
+// unless disabled through SetDrainLocalPayments.
+func (s *Switch) SetDrainMode(drain bool) {
+	var v uint32
+	if drain {
+		v = 1
+	}
+	atomic.StoreUint32(&s.drainMode, v)
+
+	log.Infof("Switch drain mode enabled: %v", drain)
+}
+
+// SetDrainLocalPayments sets whether locally-originated payments are rejected
+// as well while the switch is in drain mode.
+func (s *Switch) SetDrainLocalPayments(reject bool) {
+	var v uint32
+	if reject {
+		v = 1
+	}
+	atomic.StoreUint32(&s.drainLocal, v)
+}
+
+// isDraining returns true if the switch is in drain mode.
+func (s *Switch) isDraining() bool {
+	return atomic.LoadUint32(&s.drainMode) == 1
+}
+
 // UpdateForwardingPolicies sends a message to the switch to update the
 // forwarding policies for the set of target channels, keyed in chanPolicies.
 //
@@ -743,6 +813,16 @@ func (s *Switch) routeAsync(packet *htlcPacket, errChan chan er.R,
 func (s *Switch) getLocalLink(cjdcoin *htlcPacket, htlc *lnwire.UpdateAddHTLC) (
 	ChannelLink, *LinkError) {
 
+	if s.isDraining() && atomic.LoadUint32(&s.drainLocal) == 1 {
+		log.Debugf("Rejecting local payment(%x), switch is draining",
+			htlc.PaymentHash[:])
+
+		return nil, NewDetailedLinkError(
+			&lnwire.FailChannelDisabled{},
+			OutgoingFailureForwardsDisabled,
+		)
+	}
+
 	// Try to find links by node destination.
 	s.indexMtx.RLock()
 	link, err := s.getLinkByShortID(cjdcoin.outgoingChanID)
@@ -968,124 +1048,11 @@ func (s *Switch) handlePacketForward(packet *htlcPacket) er.R {
 	// payment circuit within our internal state so we can properly forward
 	// the ultimate settle message back latter.
 	case *lnwire.UpdateAddHTLC:
-		// Check if the node is set to reject all onward HTLCs and also make
-		// sure that HTLC is not from the source node.
-		if s.cfg.RejectHTLC {
-			failure := NewDetailedLinkError(
-				&lnwire.FailChannelDisabled{},
-				OutgoingFailureForwardsDisabled,
-			)
-
-			return s.failAddPacket(packet, failure)
-		}
-
-		// Before we attempt to find a non-strict forwarding path for
-		// this htlc, check whether the htlc is being routed over the
-		// same incoming and outgoing channel. If our node does not
-		// allow forwards of this nature, we fail the htlc early. This
-		// check is in place to disallow inefficiently routed htlcs from
-		// locking up our balance.
-		linkErr := checkCircularForward(
-			packet.incomingChanID, packet.outgoingChanID,
-			s.cfg.AllowCircularRoute, htlc.PaymentHash,
-		)
+		destinations, linkErr := s.forwardDestinations(packet, htlc)
 		if linkErr != nil {
 			return s.failAddPacket(packet, linkErr)
 		}
 
-		s.indexMtx.RLock()
-		targetLink, err := s.getLinkByShortID(packet.outgoingChanID)
-		if err != nil {
-			s.indexMtx.RUnlock()
-
-			log.Debugf("unable to find link with "+
-				"destination %v", packet.outgoingChanID)
-
-			// If packet was forwarded from another channel link
-			// than we should notify this link that some error
-			// occurred.
-			linkError := NewLinkError(
-				&lnwire.FailUnknownNextPeer{},
-			)
-
-			return s.failAddPacket(packet, linkError)
-		}
-		targetPeerKey := targetLink.Peer().PubKey()
-		interfaceLinks, _ := s.getLinks(targetPeerKey)
-		s.indexMtx.RUnlock()
-
-		// We'll keep track of any HTLC failures during the link
-		// selection process. This way we can return the error for
-		// precise link that the sender selected, while optimistically
-		// trying all links to utilize our available bandwidth.
-		linkErrs := make(map[lnwire.ShortChannelID]*LinkError)
-
-		// Find all destination channel links with appropriate
-		// bandwidth.
-		var destinations []ChannelLink
-		for _, link := range interfaceLinks {
-			var failure *LinkError
-
-			// We'll skip any links that aren't yet eligible for
-			// forwarding.
-			if !link.EligibleToForward() {
-				failure = NewDetailedLinkError(
-					&lnwire.FailUnknownNextPeer{},
-					OutgoingFailureLinkNotEligible,
-				)
-			} else {
-				// We'll ensure that the HTLC satisfies the
-				// current forwarding conditions of this target
-				// link.
-				currentHeight := atomic.LoadUint32(&s.bestHeight)
-				failure = link.CheckHtlcForward(
-					htlc.PaymentHash, packet.incomingAmount,
-					packet.amount, packet.incomingTimeout,
-					packet.outgoingTimeout, currentHeight,
-				)
-			}
-
-			// If this link can forward the htlc, add it to the set
-			// of destinations.
-			if failure == nil {
-				destinations = append(destinations, link)
-				continue
-			}
-
-			linkErrs[link.ShortChanID()] = failure
-		}
-
-		// If we had a forwarding failure due to the HTLC not
-		// satisfying the current policy, then we'll send back an
-		// error, but ensure we send back the error sourced at the
-		// *target* link.
-		if len(destinations) == 0 {
-			// At this point, some or all of the links rejected the
-			// HTLC so we couldn't forward it. So we'll try to look
-			// up the error that came from the source.
-			linkErr, ok := linkErrs[packet.outgoingChanID]
-			if !ok {
-				// If we can't find the error of the source,
-				// then we'll return an unknown next peer,
-				// though this should never happen.
-				linkErr = NewLinkError(
-					&lnwire.FailUnknownNextPeer{},
-				)
-				log.Warnf("unable to find err source for "+
-					"outgoing_link=%v, errors=%v",
-					packet.outgoingChanID, log.C(func() string {
-						return spew.Sdump(linkErrs)
-					}))
-			}
-
-			log.Tracef("incoming HTLC(%x) violated "+
-				"target outgoing link (id=%v) policy: %v",
-				htlc.PaymentHash[:], packet.outgoingChanID,
-				linkErr)
-
-			return s.failAddPacket(packet, linkErr)
-		}
-
 		// Choose a random link out of the set of links that can forward
 		// this htlc. The reason for randomization is to evenly
 		// distribute the htlc load without making assumptions about
@@ -1231,6 +1198,404 @@ func checkCircularForward(incoming, outgoing lnwire.ShortChannelID,
 	)
 }
 
+// ForwardResult describes the outcome of a simulated forward through the
+// switch.
+type ForwardResult struct {
+	// OutgoingChanID is the short channel ID of the link which the htlc
+	// would be forwarded over.
+	OutgoingChanID lnwire.ShortChannelID
+
+	// Fee is the fee which we would earn by forwarding the htlc.
+	Fee lnwire.MilliSatoshi
+}
+
+// SimulateForward runs the same checks as a real forward of the passed add
+// packet, and reports whether the forward would succeed and the fee that
+// would be taken. Unlike a real forward, the packet isn't added to any mailbox
+// or circuit, so this has no side effects. If the forward would fail, the
+// LinkError which would be sent back to the source of the htlc is returned.
+func (s *Switch) SimulateForward(incoming htlcPacket) (*ForwardResult, *LinkError) {
+	htlc, ok := incoming.htlc.(*lnwire.UpdateAddHTLC)
+	if !ok {
+		return nil, NewLinkError(&lnwire.FailTemporaryNodeFailure{})
+	}
+
+	destinations, linkErr := s.forwardDestinations(&incoming, htlc)
+	if linkErr != nil {
+		return nil, linkErr
+	}
+
+	// A real forward picks a random destination, so prefer the link the
+	// sender asked for if it's among the candidates.
+	destination := destinations[0]
+	for _, link := range destinations {
+		if link.ShortChanID() == incoming.outgoingChanID {
+			destination = link
+			break
+		}
+	}
+
+	return &ForwardResult{
+		OutgoingChanID: destination.ShortChanID(),
+		Fee:            incoming.incomingAmount - incoming.amount,
+	}, nil
+}
+
+// ForwardAction enumerates the decisions the switch can take for an incoming
+// htlc.
+type ForwardAction uint8
+
+const (
+	// ForwardActionForward indicates that the htlc is forwarded to the
+	// next hop.
+	ForwardActionForward ForwardAction = iota
+
+	// ForwardActionSettle indicates that we are the exit hop of the htlc,
+	// so it is handed to the invoice registry for settlement.
+	ForwardActionSettle
+
+	// ForwardActionFail indicates that the htlc is failed back to its
+	// source.
+	ForwardActionFail
+)
+
+// String returns a human readable representation of the forward action.
+func (a ForwardAction) String() string {
+	switch a {
+	case ForwardActionForward:
+		return "forward"
+
+	case ForwardActionSettle:
+		return "settle"
+
+	case ForwardActionFail:
+		return "fail"
+
+	default:
+		return "unknown"
+	}
+}
+
+// ForwardDecision describes the decision the switch takes for an incoming
+// htlc.
+type ForwardDecision struct {
+	// Action is the decision taken for the htlc.
+	Action ForwardAction
+
+	// OutgoingChanID is the short channel ID of the link which the htlc
+	// would be forwarded over. It is only set for ForwardActionForward.
+	OutgoingChanID lnwire.ShortChannelID
+
+	// FailureCode is the failure that would be sent back to the source of
+	// the htlc. It is only set for ForwardActionFail.
+	FailureCode lnwire.FailCode
+}
+
+// ReplayForward re-derives the forwarding decision for the incoming htlc of
+// the circuit identified by inKey. The onion of the htlc is read from the
+// forwarding package of the incoming channel and decoded again, after which
+// the decision is run against the current state of the switch and its links.
+// No state is mutated, so this can be used to investigate why a forward
+// failed.
+func (s *Switch) ReplayForward(inKey CircuitKey) (ForwardDecision, er.R) {
+	if s.cfg.DecodeHopIterators == nil {
+		return ForwardDecision{}, ErrReplayUnavailable.New(
+			"no hop decoder configured", nil,
+		)
+	}
+
+	circuit := s.circuits.LookupCircuit(inKey)
+	if circuit == nil {
+		return ForwardDecision{}, ErrUnknownCircuit.Default()
+	}
+
+	// Locally initiated payments don't have an incoming onion.
+	if inKey.ChanID == hop.Source {
+		return ForwardDecision{}, ErrReplayUnavailable.New(
+			"circuit of a local payment", nil,
+		)
+	}
+
+	fwdPkg, add, err := s.fetchCircuitAdd(circuit)
+	if err != nil {
+		return ForwardDecision{}, err
+	}
+
+	// Decoding the onions with the ID of their forwarding package doesn't
+	// trigger the replay protection, as they were already decoded within
+	// the same batch. The replay set stored for the batch is indexed by
+	// the position of each add within it though, so we decode the whole
+	// batch again in its original order, just like the link did.
+	decodeReqs := make(
+		[]hop.DecodeHopIteratorRequest, 0, len(fwdPkg.Adds),
+	)
+	for _, update := range fwdPkg.Adds {
+		pkgAdd, ok := update.UpdateMsg.(*lnwire.UpdateAddHTLC)
+		if !ok {
+			continue
+		}
+
+		decodeReqs = append(decodeReqs, hop.DecodeHopIteratorRequest{
+			OnionReader:  bytes.NewReader(pkgAdd.OnionBlob[:]),
+			RHash:        pkgAdd.PaymentHash[:],
+			IncomingCltv: pkgAdd.Expiry,
+		})
+	}
+
+	decodeResps, err := s.cfg.DecodeHopIterators(fwdPkg.ID(), decodeReqs)
+	if err != nil {
+		return ForwardDecision{}, err
+	}
+
+	fail := func(code lnwire.FailCode) (ForwardDecision, er.R) {
+		return ForwardDecision{
+			Action:      ForwardActionFail,
+			FailureCode: code,
+		}, nil
+	}
+
+	iterator, failureCode := decodeResps[circuit.AddRef.Index].Result()
+	if failureCode != lnwire.CodeNone {
+		return fail(failureCode)
+	}
+
+	payload, err := iterator.HopPayload()
+	if err != nil {
+		return fail(lnwire.CodeInvalidOnionPayload)
+	}
+	fwdInfo := payload.ForwardingInfo()
+
+	// As the exit hop, the htlc would be settled if it matches its onion
+	// payload. Whether the invoice registry accepts it is not replayed.
+	if fwdInfo.NextHop == hop.Exit {
+		switch {
+		case add.Amount != fwdInfo.AmountToForward:
+			return fail(lnwire.CodeFinalIncorrectHtlcAmount)
+
+		case add.Expiry != fwdInfo.OutgoingCTLV:
+			return fail(lnwire.CodeFinalIncorrectCltvExpiry)
+		}
+
+		return ForwardDecision{Action: ForwardActionSettle}, nil
+	}
+
+	result, linkErr := s.SimulateForward(htlcPacket{
+		incomingChanID:  inKey.ChanID,
+		incomingHTLCID:  inKey.HtlcID,
+		outgoingChanID:  fwdInfo.NextHop,
+		incomingAmount:  add.Amount,
+		amount:          fwdInfo.AmountToForward,
+		incomingTimeout: add.Expiry,
+		outgoingTimeout: fwdInfo.OutgoingCTLV,
+		htlc: &lnwire.UpdateAddHTLC{
+			Expiry:      fwdInfo.OutgoingCTLV,
+			Amount:      fwdInfo.AmountToForward,
+			PaymentHash: add.PaymentHash,
+		},
+	})
+	if linkErr != nil {
+		return fail(linkErr.WireMessage().Code())
+	}
+
+	return ForwardDecision{
+		Action:         ForwardActionForward,
+		OutgoingChanID: result.OutgoingChanID,
+	}, nil
+}
+
+// fetchCircuitAdd looks up the add of the incoming htlc of the given circuit
+// in the forwarding packages of the incoming channel. The forwarding package
+// which contains it is returned as well.
+func (s *Switch) fetchCircuitAdd(circuit *PaymentCircuit) (*channeldb.FwdPkg,
+	*lnwire.UpdateAddHTLC, er.R) {
+
+	fwdPkgs, err := s.loadChannelFwdPkgs(circuit.Incoming.ChanID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, fwdPkg := range fwdPkgs {
+		if fwdPkg.Height != circuit.AddRef.Height {
+			continue
+		}
+
+		idx := int(circuit.AddRef.Index)
+		if idx >= len(fwdPkg.Adds) {
+			break
+		}
+
+		add, ok := fwdPkg.Adds[idx].UpdateMsg.(*lnwire.UpdateAddHTLC)
+		if !ok {
+			break
+		}
+
+		return fwdPkg, add, nil
+	}
+
+	return nil, nil, ErrReplayUnavailable.New(fmt.Sprintf(
+		"add %v of circuit %v no longer stored", circuit.AddRef,
+		circuit.Incoming), nil)
+}
+
+// forwardDestinations returns the set of links towards the target peer of
+// the passed add packet which are able to forward it, taking into account the
+// switch config and the current forwarding conditions of each link. If none of
+// the links can forward the htlc, the LinkError which should be sent back to
+// the source of the htlc is returned instead.
+func (s *Switch) forwardDestinations(packet *htlcPacket,
+	htlc *lnwire.UpdateAddHTLC) ([]ChannelLink, *LinkError) {
+
+	// Check if the node is set to reject all onward HTLCs and also make
+	// sure that HTLC is not from the source node.
+	if s.cfg.RejectHTLC {
+		failure := NewDetailedLinkError(
+			&lnwire.FailChannelDisabled{},
+			OutgoingFailureForwardsDisabled,
+		)
+
+		return nil, failure
+	}
+
+	// While draining, no new circuits are opened for forwards.
+	if s.isDraining() {
+		log.Debugf("Rejecting incoming HTLC(%x), switch is draining",
+			htlc.PaymentHash[:])
+
+		failure := NewDetailedLinkError(
+			&lnwire.FailChannelDisabled{},
+			OutgoingFailureForwardsDisabled,
+		)
+
+		return nil, failure
+	}
+
+	// Reject HTLCs exceeding the global cap on forwarded amounts before
+	// considering any of the links.
+	if s.cfg.MaxForwardedHTLC != 0 &&
+		packet.amount > s.cfg.MaxForwardedHTLC {
+
+		log.Debugf("incoming HTLC(%x) amount %v exceeds max forwarded "+
+			"htlc %v", htlc.PaymentHash[:], packet.amount,
+			s.cfg.MaxForwardedHTLC)
+
+		failure := NewDetailedLinkError(
+			&lnwire.FailTemporaryChannelFailure{},
+			OutgoingFailureHTLCExceedsMax,
+		)
+
+		return nil, failure
+	}
+
+	// Before we attempt to find a non-strict forwarding path for
+	// this htlc, check whether the htlc is being routed over the
+	// same incoming and outgoing channel. If our node does not
+	// allow forwards of this nature, we fail the htlc early. This
+	// check is in place to disallow inefficiently routed htlcs from
+	// locking up our balance.
+	linkErr := checkCircularForward(
+		packet.incomingChanID, packet.outgoingChanID,
+		s.cfg.AllowCircularRoute, htlc.PaymentHash,
+	)
+	if linkErr != nil {
+		return nil, linkErr
+	}
+
+	s.indexMtx.RLock()
+	targetLink, err := s.getLinkByShortID(packet.outgoingChanID)
+	if err != nil {
+		s.indexMtx.RUnlock()
+
+		log.Debugf("unable to find link with "+
+			"destination %v", packet.outgoingChanID)
+
+		// If packet was forwarded from another channel link
+		// than we should notify this link that some error
+		// occurred.
+		linkError := NewLinkError(
+			&lnwire.FailUnknownNextPeer{},
+		)
+
+		return nil, linkError
+	}
+	targetPeerKey := targetLink.Peer().PubKey()
+	interfaceLinks, _ := s.getLinks(targetPeerKey)
+	s.indexMtx.RUnlock()
+
+	// We'll keep track of any HTLC failures during the link
+	// selection process. This way we can return the error for
+	// precise link that the sender selected, while optimistically
+	// trying all links to utilize our available bandwidth.
+	linkErrs := make(map[lnwire.ShortChannelID]*LinkError)
+
+	// Find all destination channel links with appropriate
+	// bandwidth.
+	var destinations []ChannelLink
+	for _, link := range interfaceLinks {
+		var failure *LinkError
+
+		// We'll skip any links that aren't yet eligible for
+		// forwarding.
+		if !link.EligibleToForward() {
+			failure = NewDetailedLinkError(
+				&lnwire.FailUnknownNextPeer{},
+				OutgoingFailureLinkNotEligible,
+			)
+		} else {
+			// We'll ensure that the HTLC satisfies the
+			// current forwarding conditions of this target
+			// link.
+			currentHeight := atomic.LoadUint32(&s.bestHeight)
+			failure = link.CheckHtlcForward(
+				htlc.PaymentHash, packet.incomingAmount,
+				packet.amount, packet.incomingTimeout,
+				packet.outgoingTimeout, currentHeight,
+			)
+		}
+
+		// If this link can forward the htlc, add it to the set
+		// of destinations.
+		if failure == nil {
+			destinations = append(destinations, link)
+			continue
+		}
+
+		linkErrs[link.ShortChanID()] = failure
+	}
+
+	// If we had a forwarding failure due to the HTLC not
+	// satisfying the current policy, then we'll send back an
+	// error, but ensure we send back the error sourced at the
+	// *target* link.
+	if len(destinations) == 0 {
+		// At this point, some or all of the links rejected the
+		// HTLC so we couldn't forward it. So we'll try to look
+		// up the error that came from the source.
+		linkErr, ok := linkErrs[packet.outgoingChanID]
+		if !ok {
+			// If we can't find the error of the source,
+			// then we'll return an unknown next peer,
+			// though this should never happen.
+			linkErr = NewLinkError(
+				&lnwire.FailUnknownNextPeer{},
+			)
+			log.Warnf("unable to find err source for "+
+				"outgoing_link=%v, errors=%v",
+				packet.outgoingChanID, log.C(func() string {
+					return spew.Sdump(linkErrs)
+				}))
+		}
+
+		log.Tracef("incoming HTLC(%x) violated "+
+			"target outgoing link (id=%v) policy: %v",
+			htlc.PaymentHash[:], packet.outgoingChanID,
+			linkErr)
+
+		return nil, linkErr
+	}
+
+	return destinations, nil
+}
+
 // failAddPacket encrypts a fail packet back to an add packet's source.
 // The ciphertext will be derived from the failure message proivded by context.
 // This method returns the failErr if all other steps complete successfully.
@@ -2037,6 +2402,21 @@ func (s *Switch) getLink(chanID lnwire.ChannelID) (ChannelLink, er.R) {
 	return link, nil
 }
 
+// ForwardingPolicy returns the forwarding policy which is currently in force
+// on the link for the target channel. If the switch has no link for the
+// channel, ErrChannelLinkNotFound is returned.
+func (s *Switch) ForwardingPolicy(chanID lnwire.ChannelID) (*ForwardingPolicy, er.R) {
+	s.indexMtx.RLock()
+	link, err := s.getLink(chanID)
+	s.indexMtx.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	policy := link.ForwardingPolicy()
+	return &policy, nil
+}
+
 // getLinkByShortID attempts to return the link which possesses the target
 // short channel ID.
 //
@@ -2219,6 +2599,9 @@ func (s *Switch) deleteCircuits(inKeys ...CircuitKey) er.R {
 // out the set of forwarding events to disk. External callers can also use this
 // method to ensure all data is flushed to dis before querying the log.
 func (s *Switch) FlushForwardingEvents() er.R {
+	s.fwdFlushMtx.Lock()
+	defer s.fwdFlushMtx.Unlock()
+
 	// First, we'll obtain a copy of the current set of pending forwarding
 	// events.
 	s.fwdEventMtx.Lock()
@@ -2243,6 +2626,84 @@ func (s *Switch) FlushForwardingEvents() er.R {
 	return s.cfg.FwdingLog.AddForwardingEvents(events)
 }
 
+// ForwardingStats summarizes the forwarding events of a time window.
+type ForwardingStats struct {
+	// NumEvents is the number of payment circuits completed.
+	NumEvents uint64
+
+	// TotalForwarded is the total amount sent out over the outgoing
+	// channels.
+	TotalForwarded lnwire.MilliSatoshi
+
+	// TotalFees is the total amount of fees earned.
+	TotalFees lnwire.MilliSatoshi
+}
+
+// ForwardingStats returns statistics of the forwarding events of the given
+// window up until now. Events which are already written to the forwarding log
+// are read back from it, while those which will only be flushed on the next
+// tick of the FwdEventTicker are taken from memory, so no flush is forced.
+//
+// NOTE: The configured FwdingLog must implement ForwardingLogQuerier.
+func (s *Switch) ForwardingStats(window time.Duration) (ForwardingStats, er.R) {
+	var stats ForwardingStats
+
+	if window <= 0 {
+		return stats, er.Errorf("invalid window %v, must be positive",
+			window)
+	}
+
+	querier, ok := s.cfg.FwdingLog.(ForwardingLogQuerier)
+	if !ok {
+		return stats, ErrForwardingLogNotQueryable.Default()
+	}
+
+	addEvent := func(event *channeldb.ForwardingEvent) {
+		stats.NumEvents++
+		stats.TotalForwarded += event.AmtOut
+		stats.TotalFees += event.AmtIn - event.AmtOut
+	}
+
+	// Prevent a concurrent flush, which would otherwise let us count the
+	// events being written twice or not at all.
+	s.fwdFlushMtx.Lock()
+	defer s.fwdFlushMtx.Unlock()
+
+	endTime := time.Now()
+	startTime := endTime.Add(-window)
+
+	s.fwdEventMtx.Lock()
+	for i := range s.pendingFwdingEvents {
+		event := &s.pendingFwdingEvents[i]
+		if event.Timestamp.Before(startTime) {
+			continue
+		}
+		addEvent(event)
+	}
+	s.fwdEventMtx.Unlock()
+
+	query := channeldb.ForwardingEventQuery{
+		StartTime:    startTime,
+		EndTime:      endTime,
+		NumMaxEvents: fwdStatsQueryBatch,
+	}
+	for {
+		timeSlice, err := querier.Query(query)
+		if err != nil {
+			return ForwardingStats{}, err
+		}
+
+		for i := range timeSlice.ForwardingEvents {
+			addEvent(&timeSlice.ForwardingEvents[i])
+		}
+
+		if len(timeSlice.ForwardingEvents) < fwdStatsQueryBatch {
+			return stats, nil
+		}
+		query.IndexOffset = timeSlice.LastIndexOffset
+	}
+}
+
 // BestHeight returns the best height known to the switch.
 func (s *Switch) BestHeight() uint32 {
 	return atomic.LoadUint32(&s.bestHeight)
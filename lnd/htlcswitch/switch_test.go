@@ -1,18 +1,24 @@
 package htlcswitch
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"io/ioutil"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/kaotisk-hund/cjdcoind/btcec"
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg"
+	sphinx "github.com/kaotisk-hund/cjdcoind/lightning-onion"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb"
+	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
 	"github.com/kaotisk-hund/cjdcoind/lnd/htlcswitch/hop"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lntypes"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwire"
@@ -160,6 +166,60 @@ func TestSwitchHasActiveLink(t *testing.T) {
 	}
 }
 
+// TestSwitchForwardingPolicy tests that the switch reports the forwarding
+// policy which is in force on a link, and that unknown channels are rejected.
+func TestSwitchForwardingPolicy(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create alice server: %v", err)
+	}
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	if err != nil {
+		t.Fatalf("unable to init switch: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("unable to start switch: %v", err)
+	}
+	defer s.Stop()
+
+	chanID1, chanID2, aliceChanID, _ := genIDs()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	if err := s.AddLink(aliceChannelLink); err != nil {
+		t.Fatalf("unable to add alice link: %v", err)
+	}
+
+	policy := ForwardingPolicy{
+		MinHTLCOut:    1,
+		MaxHTLC:       100000,
+		BaseFee:       1000,
+		FeeRate:       10,
+		TimeLockDelta: 40,
+	}
+	aliceChannelLink.UpdateForwardingPolicy(policy)
+
+	fetched, err := s.ForwardingPolicy(chanID1)
+	if err != nil {
+		t.Fatalf("unable to fetch forwarding policy: %v", err)
+	}
+	if *fetched != policy {
+		t.Fatalf("expected policy %v, got %v", policy, *fetched)
+	}
+
+	// A channel without a link should result in a not found error.
+	_, err = s.ForwardingPolicy(chanID2)
+	if !ErrChannelLinkNotFound.Is(err) {
+		t.Fatalf("expected link not found error, got %v", err)
+	}
+}
+
 // TestSwitchSendPending checks the inability of htlc switch to forward adds
 // over pending links, and the UpdateShortChanID makes a pending link live.
 func TestSwitchSendPending(t *testing.T) {
@@ -398,6 +458,695 @@ func TestSwitchForward(t *testing.T) {
 	}
 }
 
+// TestSwitchSimulateForward checks that a simulated forward reports the
+// outcome of the forwarding checks without touching any mailbox or circuit.
+func TestSwitchSimulateForward(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create alice server: %v", err)
+	}
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create bob server: %v", err)
+	}
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	if err != nil {
+		t.Fatalf("unable to init switch: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("unable to start switch: %v", err)
+	}
+	defer s.Stop()
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	if err := s.AddLink(aliceChannelLink); err != nil {
+		t.Fatalf("unable to add alice link: %v", err)
+	}
+	if err := s.AddLink(bobChannelLink); err != nil {
+		t.Fatalf("unable to add bob link: %v", err)
+	}
+
+	preimage, err := genPreimage()
+	if err != nil {
+		t.Fatalf("unable to generate preimage: %v", err)
+	}
+	rhash := sha256.Sum256(preimage[:])
+	packet := htlcPacket{
+		incomingChanID: aliceChannelLink.ShortChanID(),
+		incomingHTLCID: 0,
+		outgoingChanID: bobChannelLink.ShortChanID(),
+		incomingAmount: 1010,
+		amount:         1000,
+		obfuscator:     NewMockObfuscator(),
+		htlc: &lnwire.UpdateAddHTLC{
+			PaymentHash: rhash,
+			Amount:      1000,
+		},
+	}
+
+	result, linkErr := s.SimulateForward(packet)
+	if linkErr != nil {
+		t.Fatalf("unexpected forwarding failure: %v", linkErr)
+	}
+	if result.OutgoingChanID != bobChanID {
+		t.Fatalf("expected outgoing channel %v, got %v", bobChanID,
+			result.OutgoingChanID)
+	}
+	if result.Fee != 10 {
+		t.Fatalf("expected fee of 10 msat, got %v", result.Fee)
+	}
+
+	// The link's forwarding checks should be reflected in the result.
+	bobChannelLink.checkHtlcForwardResult = NewLinkError(
+		&lnwire.FailFeeInsufficient{},
+	)
+	_, linkErr = s.SimulateForward(packet)
+	if linkErr == nil {
+		t.Fatalf("expected forwarding failure")
+	}
+	if _, ok := linkErr.WireMessage().(*lnwire.FailFeeInsufficient); !ok {
+		t.Fatalf("expected fee insufficient failure, got %T",
+			linkErr.WireMessage())
+	}
+
+	// Neither simulation should have had any side effects.
+	select {
+	case <-bobChannelLink.packets:
+		t.Fatal("simulated forward reached the destination link")
+	case <-aliceChannelLink.packets:
+		t.Fatal("simulated forward failed back to the source link")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if s.circuits.NumPending() != 0 || s.circuits.NumOpen() != 0 {
+		t.Fatal("simulated forward should not add circuits")
+	}
+}
+
+// TestSwitchReplayForward checks that the forwarding decision of a committed
+// circuit can be replayed from the onion stored in the forwarding package of
+// the incoming channel, without any side effects.
+func TestSwitchReplayForward(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create alice server: %v", err)
+	}
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create bob server: %v", err)
+	}
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	if err != nil {
+		t.Fatalf("unable to init switch: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("unable to start switch: %v", err)
+	}
+	defer s.Stop()
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	if err := s.AddLink(aliceChannelLink); err != nil {
+		t.Fatalf("unable to add alice link: %v", err)
+	}
+	if err := s.AddLink(bobChannelLink); err != nil {
+		t.Fatalf("unable to add bob link: %v", err)
+	}
+
+	inKey := CircuitKey{ChanID: aliceChanID, HtlcID: 0}
+
+	// Without a hop decoder, forwards can't be replayed.
+	_, err = s.ReplayForward(inKey)
+	if !ErrReplayUnavailable.Is(err) {
+		t.Fatalf("expected replay to be unavailable, got %v", err)
+	}
+
+	// The mock decoder caches the iterators it returns for a batch, and
+	// these are consumed when read. So we use a fresh decoder for every
+	// replay, like the sphinx decoder returns fresh iterators.
+	replay := func() (ForwardDecision, er.R) {
+		decoder := newMockIteratorDecoder()
+		s.cfg.DecodeHopIterators = decoder.DecodeHopIterators
+		return s.ReplayForward(inKey)
+	}
+
+	// Store the add which alice forwarded to us within her forwarding
+	// package, instructing us to forward it to bob.
+	payload, err := hop.NewTLVPayload(hop.ForwardingInfo{
+		NextHop:         bobChanID,
+		AmountToForward: 1000,
+		OutgoingCTLV:    testStartingHeight + 100,
+	}, nil)
+	util.RequireNoErr(t, err)
+	blob, err := generateRoute(payload)
+	util.RequireNoErr(t, err)
+
+	preimage, err := genPreimage()
+	if err != nil {
+		t.Fatalf("unable to generate preimage: %v", err)
+	}
+	add := &lnwire.UpdateAddHTLC{
+		PaymentHash: sha256.Sum256(preimage[:]),
+		Amount:      1010,
+		Expiry:      testStartingHeight + 140,
+		OnionBlob:   blob,
+	}
+
+	const pkgHeight = 5
+	fwdPkg := channeldb.NewFwdPkg(
+		aliceChanID, pkgHeight, []channeldb.LogUpdate{{UpdateMsg: add}},
+		nil,
+	)
+	err = kvdb.Update(s.cfg.DB, func(tx kvdb.RwTx) er.R {
+		packager := channeldb.NewChannelPackager(aliceChanID)
+		return packager.AddFwdPkg(tx, fwdPkg)
+	}, func() {})
+	util.RequireNoErr(t, err)
+
+	// The circuit hasn't been committed yet.
+	_, err = replay()
+	if !ErrUnknownCircuit.Is(err) {
+		t.Fatalf("expected unknown circuit, got %v", err)
+	}
+
+	_, err = s.circuits.CommitCircuits(&PaymentCircuit{
+		AddRef: channeldb.AddRef{
+			Height: pkgHeight,
+			Index:  0,
+		},
+		Incoming:       inKey,
+		PaymentHash:    add.PaymentHash,
+		IncomingAmount: add.Amount,
+		OutgoingAmount: 1000,
+		ErrorEncrypter: NewMockObfuscator(),
+	})
+	util.RequireNoErr(t, err)
+
+	decision, err := replay()
+	util.RequireNoErr(t, err)
+	if decision.Action != ForwardActionForward {
+		t.Fatalf("expected forward, got %v", decision.Action)
+	}
+	if decision.OutgoingChanID != bobChanID {
+		t.Fatalf("expected outgoing channel %v, got %v", bobChanID,
+			decision.OutgoingChanID)
+	}
+
+	// A policy change of the outgoing link is reflected in the replayed
+	// decision.
+	bobChannelLink.checkHtlcForwardResult = NewLinkError(
+		&lnwire.FailFeeInsufficient{},
+	)
+	decision, err = replay()
+	util.RequireNoErr(t, err)
+	if decision.Action != ForwardActionFail {
+		t.Fatalf("expected fail, got %v", decision.Action)
+	}
+	if decision.FailureCode != lnwire.CodeFeeInsufficient {
+		t.Fatalf("expected fee insufficient, got %v",
+			decision.FailureCode)
+	}
+
+	// Replaying should have had no side effects.
+	select {
+	case <-bobChannelLink.packets:
+		t.Fatal("replayed forward reached the destination link")
+	case <-aliceChannelLink.packets:
+		t.Fatal("replayed forward failed back to the source link")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if s.circuits.LookupCircuit(inKey) == nil {
+		t.Fatal("circuit removed by replay")
+	}
+}
+
+// TestSwitchReplayForwardBatchIndex checks that replaying the forwarding
+// decision of an add reports whether that very add was replayed, using the
+// replay set stored for its whole forwarding package.
+func TestSwitchReplayForwardBatchIndex(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	util.RequireNoErr(t, err)
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	util.RequireNoErr(t, err)
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	util.RequireNoErr(t, err)
+	util.RequireNoErr(t, s.Start())
+	defer s.Stop()
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	util.RequireNoErr(t, s.AddLink(aliceChannelLink))
+	util.RequireNoErr(t, s.AddLink(bobChannelLink))
+
+	// Use a real onion processor, as the replay set of a batch is what's
+	// under test.
+	nodeKey, err := btcec.NewPrivateKey(btcec.S256())
+	util.RequireNoErr(t, err)
+	router := sphinx.NewRouter(
+		&sphinx.PrivKeyECDH{PrivKey: nodeKey}, &chaincfg.SimNetParams,
+		sphinx.NewMemoryReplayLog(),
+	)
+	processor := hop.NewOnionProcessor(router)
+	util.RequireNoErr(t, processor.Start())
+	defer processor.Stop()
+	s.cfg.DecodeHopIterators = processor.DecodeHopIterators
+
+	// Each onion instructs us to forward to bob.
+	newAdd := func() *lnwire.UpdateAddHTLC {
+		nextKey, err := btcec.NewPrivateKey(btcec.S256())
+		util.RequireNoErr(t, err)
+
+		hopData := sphinx.HopData{
+			ForwardAmount: 1000,
+			OutgoingCltv:  testStartingHeight + 100,
+		}
+		binary.BigEndian.PutUint64(
+			hopData.NextAddress[:], bobChanID.ToUint64(),
+		)
+		payload, err := sphinx.NewHopPayload(&hopData, nil)
+		util.RequireNoErr(t, err)
+		finalPayload, err := sphinx.NewHopPayload(&sphinx.HopData{}, nil)
+		util.RequireNoErr(t, err)
+
+		route := sphinx.PaymentPath{
+			{NodePub: *nodeKey.PubKey(), HopPayload: payload},
+			{NodePub: *nextKey.PubKey(), HopPayload: finalPayload},
+		}
+
+		preimage, err := genPreimage()
+		util.RequireNoErr(t, err)
+		add := &lnwire.UpdateAddHTLC{
+			PaymentHash: sha256.Sum256(preimage[:]),
+			Amount:      1010,
+			Expiry:      testStartingHeight + 140,
+		}
+
+		sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+		util.RequireNoErr(t, err)
+		onionPkt, err := sphinx.NewOnionPacket(
+			&route, sessionKey, add.PaymentHash[:],
+			sphinx.DeterministicPacketFiller,
+		)
+		util.RequireNoErr(t, err)
+		util.RequireNoErr(
+			t, onionPkt.Encode(bytes.NewBuffer(add.OnionBlob[0:0])),
+		)
+
+		return add
+	}
+	replayedAdd, freshAdd := newAdd(), newAdd()
+
+	decodeReq := func(add *lnwire.UpdateAddHTLC) hop.DecodeHopIteratorRequest {
+		return hop.DecodeHopIteratorRequest{
+			OnionReader:  bytes.NewReader(add.OnionBlob[:]),
+			RHash:        add.PaymentHash[:],
+			IncomingCltv: add.Expiry,
+		}
+	}
+
+	// The onion of the first add was already seen in an earlier batch.
+	_, err = processor.DecodeHopIterators(
+		[]byte("earlier batch"),
+		[]hop.DecodeHopIteratorRequest{decodeReq(replayedAdd)},
+	)
+	util.RequireNoErr(t, err)
+
+	// Store and process the forwarding package of alice holding the
+	// replayed add at index 0, and a fresh add at index 1, like the link
+	// does.
+	const pkgHeight = 5
+	fwdPkg := channeldb.NewFwdPkg(
+		aliceChanID, pkgHeight, []channeldb.LogUpdate{
+			{UpdateMsg: replayedAdd},
+			{UpdateMsg: freshAdd},
+		}, nil,
+	)
+	err = kvdb.Update(s.cfg.DB, func(tx kvdb.RwTx) er.R {
+		packager := channeldb.NewChannelPackager(aliceChanID)
+		return packager.AddFwdPkg(tx, fwdPkg)
+	}, func() {})
+	util.RequireNoErr(t, err)
+
+	resps, err := processor.DecodeHopIterators(
+		fwdPkg.ID(), []hop.DecodeHopIteratorRequest{
+			decodeReq(replayedAdd), decodeReq(freshAdd),
+		},
+	)
+	util.RequireNoErr(t, err)
+	if resps[0].FailCode != lnwire.CodeTemporaryChannelFailure {
+		t.Fatalf("expected replayed add to fail, got %v",
+			resps[0].FailCode)
+	}
+	if resps[1].FailCode != lnwire.CodeNone {
+		t.Fatalf("expected fresh add to succeed, got %v",
+			resps[1].FailCode)
+	}
+
+	inKeys := make([]CircuitKey, 2)
+	for i, add := range []*lnwire.UpdateAddHTLC{replayedAdd, freshAdd} {
+		inKeys[i] = CircuitKey{ChanID: aliceChanID, HtlcID: uint64(i)}
+		_, err = s.circuits.CommitCircuits(&PaymentCircuit{
+			AddRef: channeldb.AddRef{
+				Height: pkgHeight,
+				Index:  uint16(i),
+			},
+			Incoming:       inKeys[i],
+			PaymentHash:    add.PaymentHash,
+			IncomingAmount: add.Amount,
+			OutgoingAmount: 1000,
+			ErrorEncrypter: NewMockObfuscator(),
+		})
+		util.RequireNoErr(t, err)
+	}
+
+	// The replayed add is reported as such, while the fresh add is
+	// forwarded.
+	decision, err := s.ReplayForward(inKeys[0])
+	util.RequireNoErr(t, err)
+	if decision.Action != ForwardActionFail ||
+		decision.FailureCode != lnwire.CodeTemporaryChannelFailure {
+
+		t.Fatalf("expected temporary channel failure, got %v %v",
+			decision.Action, decision.FailureCode)
+	}
+
+	decision, err = s.ReplayForward(inKeys[1])
+	util.RequireNoErr(t, err)
+	if decision.Action != ForwardActionForward {
+		t.Fatalf("expected forward, got %v %v", decision.Action,
+			decision.FailureCode)
+	}
+	if decision.OutgoingChanID != bobChanID {
+		t.Fatalf("expected outgoing channel %v, got %v", bobChanID,
+			decision.OutgoingChanID)
+	}
+}
+
+// TestSwitchMaxForwardedHTLC tests that HTLCs exceeding the configured maximum
+// forwarded amount are failed back, while HTLCs up to it are forwarded.
+func TestSwitchMaxForwardedHTLC(t *testing.T) {
+	t.Parallel()
+
+	const maxForwarded = lnwire.MilliSatoshi(100000)
+
+	tests := []struct {
+		name          string
+		amount        lnwire.MilliSatoshi
+		expectForward bool
+	}{
+		{
+			name:          "just under cap",
+			amount:        maxForwarded - 1,
+			expectForward: true,
+		},
+		{
+			name:          "at cap",
+			amount:        maxForwarded,
+			expectForward: true,
+		},
+		{
+			name:          "just over cap",
+			amount:        maxForwarded + 1,
+			expectForward: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			alicePeer, err := newMockServer(
+				t, "alice", testStartingHeight, nil,
+				testDefaultDelta,
+			)
+			if err != nil {
+				t.Fatalf("unable to create alice server: %v",
+					err)
+			}
+			bobPeer, err := newMockServer(
+				t, "bob", testStartingHeight, nil,
+				testDefaultDelta,
+			)
+			if err != nil {
+				t.Fatalf("unable to create bob server: %v", err)
+			}
+
+			s, err := initSwitchWithDB(testStartingHeight, nil)
+			if err != nil {
+				t.Fatalf("unable to init switch: %v", err)
+			}
+			s.cfg.MaxForwardedHTLC = maxForwarded
+			if err := s.Start(); err != nil {
+				t.Fatalf("unable to start switch: %v", err)
+			}
+			defer func() { _ = s.Stop() }()
+
+			chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+			aliceChannelLink := newMockChannelLink(
+				s, chanID1, aliceChanID, alicePeer, true,
+			)
+			bobChannelLink := newMockChannelLink(
+				s, chanID2, bobChanID, bobPeer, true,
+			)
+			if err := s.AddLink(aliceChannelLink); err != nil {
+				t.Fatalf("unable to add alice link: %v", err)
+			}
+			if err := s.AddLink(bobChannelLink); err != nil {
+				t.Fatalf("unable to add bob link: %v", err)
+			}
+
+			preimage, err := genPreimage()
+			if err != nil {
+				t.Fatalf("unable to generate preimage: %v", err)
+			}
+			rhash := sha256.Sum256(preimage[:])
+			packet := &htlcPacket{
+				incomingChanID: aliceChannelLink.ShortChanID(),
+				incomingHTLCID: 0,
+				outgoingChanID: bobChannelLink.ShortChanID(),
+				incomingAmount: test.amount + 10,
+				amount:         test.amount,
+				obfuscator:     NewMockObfuscator(),
+				htlc: &lnwire.UpdateAddHTLC{
+					PaymentHash: rhash,
+					Amount:      test.amount,
+				},
+			}
+
+			if err := s.ForwardPackets(nil, packet); err != nil {
+				t.Fatalf("unable to forward packet: %v", err)
+			}
+
+			select {
+			case <-bobChannelLink.packets:
+				if !test.expectForward {
+					t.Fatal("htlc over cap was forwarded")
+				}
+
+			case p := <-aliceChannelLink.packets:
+				if test.expectForward {
+					t.Fatalf("htlc was failed back: %v",
+						p.linkFailure)
+				}
+
+				expectedErr := NewDetailedLinkError(
+					&lnwire.FailTemporaryChannelFailure{},
+					OutgoingFailureHTLCExceedsMax,
+				)
+				if !reflect.DeepEqual(p.linkFailure, expectedErr) {
+					t.Fatalf("expected: %v, got: %v",
+						expectedErr, p.linkFailure)
+				}
+
+			case <-time.After(time.Second):
+				t.Fatal("no timely reply from switch")
+			}
+		})
+	}
+}
+
+// TestSwitchDrainMode checks that a draining switch rejects new forwards, but
+// still completes the circuits which were opened before, and that local
+// payments are only rejected if requested.
+func TestSwitchDrainMode(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create alice server: %v", err)
+	}
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create bob server: %v", err)
+	}
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	if err != nil {
+		t.Fatalf("unable to init switch: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("unable to start switch: %v", err)
+	}
+	defer func() { _ = s.Stop() }()
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	if err := s.AddLink(aliceChannelLink); err != nil {
+		t.Fatalf("unable to add alice link: %v", err)
+	}
+	if err := s.AddLink(bobChannelLink); err != nil {
+		t.Fatalf("unable to add bob link: %v", err)
+	}
+
+	newAddPacket := func(htlcID uint64) (*htlcPacket, [32]byte) {
+		preimage, err := genPreimage()
+		if err != nil {
+			t.Fatalf("unable to generate preimage: %v", err)
+		}
+		rhash := sha256.Sum256(preimage[:])
+
+		return &htlcPacket{
+			incomingChanID: aliceChannelLink.ShortChanID(),
+			incomingHTLCID: htlcID,
+			outgoingChanID: bobChannelLink.ShortChanID(),
+			obfuscator:     NewMockObfuscator(),
+			htlc: &lnwire.UpdateAddHTLC{
+				PaymentHash: rhash,
+				Amount:      1,
+			},
+		}, preimage
+	}
+
+	// Open a circuit from Alice to Bob before draining.
+	packet, preimage := newAddPacket(0)
+	if err := s.ForwardPackets(nil, packet); err != nil {
+		t.Fatalf("unable to forward packet: %v", err)
+	}
+	select {
+	case <-bobChannelLink.packets:
+		if err := bobChannelLink.completeCircuit(packet); err != nil {
+			t.Fatalf("unable to complete payment circuit: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request was not propagated to destination")
+	}
+
+	s.SetDrainMode(true)
+
+	// A new forward must now be failed back to Alice.
+	packet, _ = newAddPacket(1)
+	if err := s.ForwardPackets(nil, packet); err != nil {
+		t.Fatalf("unable to forward packet: %v", err)
+	}
+	select {
+	case <-bobChannelLink.packets:
+		t.Fatal("htlc was forwarded while draining")
+
+	case p := <-aliceChannelLink.packets:
+		expectedErr := NewDetailedLinkError(
+			&lnwire.FailChannelDisabled{},
+			OutgoingFailureForwardsDisabled,
+		)
+		if !reflect.DeepEqual(p.linkFailure, expectedErr) {
+			t.Fatalf("expected: %v, got: %v", expectedErr,
+				p.linkFailure)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("no timely reply from switch")
+	}
+
+	// The settle of the circuit opened before draining must still reach
+	// Alice.
+	packet = &htlcPacket{
+		outgoingChanID: bobChannelLink.ShortChanID(),
+		outgoingHTLCID: 0,
+		amount:         1,
+		htlc: &lnwire.UpdateFulfillHTLC{
+			PaymentPreimage: preimage,
+		},
+	}
+	if err := s.ForwardPackets(nil, packet); err != nil {
+		t.Fatalf("unable to forward packet: %v", err)
+	}
+	select {
+	case p := <-aliceChannelLink.packets:
+		if err := aliceChannelLink.deleteCircuit(p); err != nil {
+			t.Fatalf("unable to remove circuit: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("settle was not propagated to alice")
+	}
+
+	if s.circuits.NumOpen() != 0 {
+		t.Fatal("wrong amount of circuits")
+	}
+
+	// Local payments are only rejected once requested.
+	s.SetDrainLocalPayments(true)
+	addMsg := &lnwire.UpdateAddHTLC{
+		PaymentHash: [32]byte{1},
+		Amount:      1,
+	}
+	err = s.SendHTLC(bobChannelLink.ShortChanID(), 0, addMsg)
+	if err == nil {
+		t.Fatalf("local payment should be rejected while draining")
+	}
+	if s.circuits.NumOpen() != 0 {
+		t.Fatal("wrong amount of circuits")
+	}
+}
+
 func TestSwitchForwardFailAfterFullAdd(t *testing.T) {
 	t.Parallel()
 
@@ -2429,6 +3178,81 @@ func TestMultiHopPaymentForwardingEvents(t *testing.T) {
 	}
 }
 
+// TestSwitchForwardingStats tests that the forwarding statistics of a window
+// include both the events flushed to the forwarding log and those still
+// pending, and that flushing the pending events doesn't change them.
+func TestSwitchForwardingStats(t *testing.T) {
+	t.Parallel()
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	if err != nil {
+		t.Fatalf("unable to init switch: %v", err)
+	}
+
+	newEvent := func(timestamp time.Time) channeldb.ForwardingEvent {
+		return channeldb.ForwardingEvent{
+			Timestamp: timestamp,
+			AmtIn:     1100,
+			AmtOut:    1000,
+		}
+	}
+
+	// Write more events than are read from the log at once to the log,
+	// along with one which is outside of the window.
+	now := time.Now()
+	const numFlushed = fwdStatsQueryBatch + 10
+	flushed := []channeldb.ForwardingEvent{newEvent(now.Add(-2 * time.Hour))}
+	for i := 0; i < numFlushed; i++ {
+		flushed = append(flushed, newEvent(
+			now.Add(-time.Minute+time.Duration(i)*time.Millisecond),
+		))
+	}
+	if err := s.cfg.FwdingLog.AddForwardingEvents(flushed); err != nil {
+		t.Fatalf("unable to add forwarding events: %v", err)
+	}
+
+	// Add some events which haven't been flushed yet.
+	const numPending = 3
+	for i := 0; i < numPending; i++ {
+		s.pendingFwdingEvents = append(
+			s.pendingFwdingEvents,
+			newEvent(now.Add(-time.Duration(i)*time.Millisecond)),
+		)
+	}
+
+	const numEvents = numFlushed + numPending
+	expected := ForwardingStats{
+		NumEvents:      numEvents,
+		TotalForwarded: numEvents * 1000,
+		TotalFees:      numEvents * 100,
+	}
+
+	stats, err := s.ForwardingStats(time.Hour)
+	if err != nil {
+		t.Fatalf("unable to get forwarding stats: %v", err)
+	}
+	if stats != expected {
+		t.Fatalf("expected stats %v, got %v", expected, stats)
+	}
+
+	// Flushing the pending events must not change the result.
+	if err := s.FlushForwardingEvents(); err != nil {
+		t.Fatalf("unable to flush forwarding events: %v", err)
+	}
+	stats, err = s.ForwardingStats(time.Hour)
+	if err != nil {
+		t.Fatalf("unable to get forwarding stats: %v", err)
+	}
+	if stats != expected {
+		t.Fatalf("expected stats %v after flush, got %v", expected,
+			stats)
+	}
+
+	if _, err := s.ForwardingStats(0); err == nil {
+		t.Fatalf("expected an invalid window to be rejected")
+	}
+}
+
 // TestUpdateFailMalformedHTLCErrorConversion tests that we're able to properly
 // convert malformed HTLC errors that originate at the direct link, as well as
 // during multi-hop HTLC forwarding.
@@ -8,7 +8,9 @@ type Tor struct {
 	StreamIsolation   bool   `long:"streamisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	Control           string `long:"control" description:"The host:port that Tor is listening on for Tor control connections"`
 	TargetIPAddress   string `long:"targetipaddress" description:"IP address that Tor should use as the target of the hidden service"`
+	TargetIPv6Address string `long:"targetipv6address" description:"IPv6 address that Tor should use as the target of the hidden service. If targetipaddress is also set, it must be an IPv4 address and Tor will use either target"`
 	Password          string `long:"password" description:"The password used to arrive at the HashedControlPassword for the control port. If provided, the HASHEDPASSWORD authentication method will be used instead of the SAFECOOKIE one."`
+	PasswordFile      string `long:"passwordfile" description:"The path of a file holding the password used for the HASHEDPASSWORD authentication method. It's read each time the control port is connected to, so the password can be rotated. Mutually exclusive with password."`
 	V2                bool   `long:"v2" description:"Automatically set up a v2 onion service to listen for inbound connections"`
 	V3                bool   `long:"v3" description:"Automatically set up a v3 onion service to listen for inbound connections"`
 	PrivateKeyPath    string `long:"privatekeypath" description:"The path to the private key of the onion service being created"`
@@ -533,6 +533,14 @@ func Main(cfg *Config, lisCfg ListenerCfg, shutdownChan <-chan struct{}) er.R {
 		return err
 	}
 
+	// If the wallet was unlocked by a client that asked for progress
+	// updates, keep it informed until the recovery, if any, is done.
+	if walletInitParams.UnlockProgress != nil {
+		go reportUnlockProgress(
+			activeChainControl.Wallet, walletInitParams.UnlockProgress,
+		)
+	}
+
 	// Finally before we start the server, we'll register the "holy
 	// trinity" of interface for our current "home chain" with the active
 	// chainRegistry interface.
@@ -578,9 +586,32 @@ func Main(cfg *Config, lisCfg ListenerCfg, shutdownChan <-chan struct{}) er.R {
 	// the regular lnd server.
 	var torController *tor.Controller
 	if cfg.Tor.Active && (cfg.Tor.V2 || cfg.Tor.V3) {
-		torController = tor.NewController(
-			cfg.Tor.Control, cfg.Tor.TargetIPAddress, cfg.Tor.Password,
-		)
+		if cfg.Tor.PasswordFile != "" {
+			torController, err = tor.NewControllerFromPasswordFile(
+				cfg.Tor.Control, cfg.Tor.TargetIPAddress,
+				cfg.Tor.PasswordFile,
+			)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+		} else {
+			torController = tor.NewController(
+				cfg.Tor.Control, cfg.Tor.TargetIPAddress,
+				cfg.Tor.Password,
+			)
+		}
+
+		if cfg.Tor.TargetIPv6Address != "" {
+			err := torController.SetTargets(
+				cfg.Tor.TargetIPAddress,
+				cfg.Tor.TargetIPv6Address,
+			)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+		}
 
 		// Start the tor controller before giving it to any other subsystems.
 		if err := torController.Start(); err != nil {
@@ -1171,6 +1202,53 @@ type WalletUnlockParams struct {
 	// MacResponseChan is the channel for sending back the admin macaroon to
 	// the WalletUnlocker service.
 	MacResponseChan chan []byte
+
+	// UnlockProgress is used to report the progress of the wallet
+	// recovery back to the WalletUnlocker service. It is nil if the client
+	// didn't ask for progress updates.
+	UnlockProgress walletunlocker.UnlockProgressFunc
+}
+
+// reportUnlockProgress reports the progress of the wallet recovery to the
+// given progress function until the recovery is complete. If the wallet isn't
+// in recovery mode, the unlock is reported as complete right away.
+func reportUnlockProgress(w lnwallet.WalletController,
+	progress walletunlocker.UnlockProgressFunc) {
+
+	isRecoveryMode, _, err := w.GetRecoveryInfo()
+	if err != nil {
+		log.Warnf("Unable to get wallet recovery info: %v", err)
+	}
+	if !isRecoveryMode {
+		progress(lnrpc.UnlockWalletProgress_COMPLETE, 1)
+		return
+	}
+
+	progress(lnrpc.UnlockWalletProgress_RESCAN_STARTED, 0)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-signal.ShutdownChannel():
+			return
+		}
+
+		_, recoveryProgress, err := w.GetRecoveryInfo()
+		if err != nil {
+			log.Warnf("Unable to get wallet recovery info: %v", err)
+			continue
+		}
+
+		if recoveryProgress >= 1 {
+			progress(lnrpc.UnlockWalletProgress_COMPLETE, 1)
+			return
+		}
+		progress(lnrpc.UnlockWalletProgress_RESCAN_PROGRESS,
+			recoveryProgress)
+	}
 }
 
 // waitForWalletPassword will spin up gRPC and REST endpoints for the
@@ -1390,6 +1468,7 @@ func waitForWalletPassword(cfg *Config, restEndpoints []net.Addr,
 			UnloadWallet:    unlockMsg.UnloadWallet,
 			StatelessInit:   unlockMsg.StatelessInit,
 			MacResponseChan: pwService.MacResponseChan,
+			UnlockProgress:  unlockMsg.Progress,
 		}, shutdown, nil
 
 	case <-signal.ShutdownChannel():
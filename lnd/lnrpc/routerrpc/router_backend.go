@@ -956,7 +956,7 @@ func marshallError(sendError er.R) (*lnrpc.Failure, er.R) {
 	// we failed locally.
 	fErr, ok := rtErr.(*htlcswitch.ForwardingError)
 	if ok {
-		response.FailureSourceIndex = uint32(fErr.FailureSourceIdx)
+		response.FailureSourceIndex = uint32(fErr.FailedHop())
 	}
 
 	return response, nil
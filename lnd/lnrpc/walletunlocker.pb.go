@@ -24,6 +24,43 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type UnlockWalletProgress_Stage int32
+
+const (
+	//
+	//The wallet passphrase was verified and the wallet has been
+	//decrypted.
+	UnlockWalletProgress_SCRYPT_DONE UnlockWalletProgress_Stage = 0
+	// The recovery rescan has been started.
+	UnlockWalletProgress_RESCAN_STARTED UnlockWalletProgress_Stage = 1
+	// The recovery rescan has made progress.
+	UnlockWalletProgress_RESCAN_PROGRESS UnlockWalletProgress_Stage = 2
+	// The wallet is unlocked and, if requested, recovered.
+	UnlockWalletProgress_COMPLETE UnlockWalletProgress_Stage = 3
+)
+
+var UnlockWalletProgress_Stage_name = map[int32]string{
+	0: "SCRYPT_DONE",
+	1: "RESCAN_STARTED",
+	2: "RESCAN_PROGRESS",
+	3: "COMPLETE",
+}
+
+var UnlockWalletProgress_Stage_value = map[string]int32{
+	"SCRYPT_DONE":     0,
+	"RESCAN_STARTED":  1,
+	"RESCAN_PROGRESS": 2,
+	"COMPLETE":        3,
+}
+
+func (x UnlockWalletProgress_Stage) String() string {
+	return proto.EnumName(UnlockWalletProgress_Stage_name, int32(x))
+}
+
+func (UnlockWalletProgress_Stage) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{8, 0}
+}
+
 type GenSeedRequest struct {
 	//
 	//aezeed_passphrase is an optional user provided passphrase that will be used
@@ -34,7 +71,13 @@ type GenSeedRequest struct {
 	//seed_entropy is an optional 16-bytes generated via CSPRNG. If not
 	//specified, then a fresh set of randomness will be used to create the seed.
 	//When using REST, this field must be encoded as base64.
-	SeedEntropy          []byte   `protobuf:"bytes,2,opt,name=seed_entropy,json=seedEntropy,proto3" json:"seed_entropy,omitempty"`
+	SeedEntropy []byte `protobuf:"bytes,2,opt,name=seed_entropy,json=seedEntropy,proto3" json:"seed_entropy,omitempty"`
+	//
+	//seed_birthday_unix is an optional unix timestamp of the birthday to stamp
+	//the cipher seed with, e.g. when restoring an old seed from a backup. The
+	//recovery rescan of the wallet starts from this time. If not specified, the
+	//current time is used. It must not lie in the future.
+	SeedBirthdayUnix     int64    `protobuf:"varint,3,opt,name=seed_birthday_unix,json=seedBirthdayUnix,proto3" json:"seed_birthday_unix,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -79,6 +122,13 @@ func (m *GenSeedRequest) GetSeedEntropy() []byte {
 	return nil
 }
 
+func (m *GenSeedRequest) GetSeedBirthdayUnix() int64 {
+	if m != nil {
+		return m.SeedBirthdayUnix
+	}
+	return 0
+}
+
 type GenSeedResponse struct {
 	//
 	//cipher_seed_mnemonic is a 24-word mnemonic that encodes a prior aezeed
@@ -135,6 +185,86 @@ func (m *GenSeedResponse) GetEncipheredSeed() []byte {
 	return nil
 }
 
+type WalletExistsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WalletExistsRequest) Reset()         { *m = WalletExistsRequest{} }
+func (m *WalletExistsRequest) String() string { return proto.CompactTextString(m) }
+func (*WalletExistsRequest) ProtoMessage()    {}
+func (*WalletExistsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{2}
+}
+
+func (m *WalletExistsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WalletExistsRequest.Unmarshal(m, b)
+}
+func (m *WalletExistsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WalletExistsRequest.Marshal(b, m, deterministic)
+}
+func (m *WalletExistsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WalletExistsRequest.Merge(m, src)
+}
+func (m *WalletExistsRequest) XXX_Size() int {
+	return xxx_messageInfo_WalletExistsRequest.Size(m)
+}
+func (m *WalletExistsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WalletExistsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WalletExistsRequest proto.InternalMessageInfo
+
+type WalletExistsResponse struct {
+	// Whether a wallet exists at wallet_path.
+	Exists bool `protobuf:"varint,1,opt,name=exists,proto3" json:"exists,omitempty"`
+	// The path of the wallet database on disk.
+	WalletPath           string   `protobuf:"bytes,2,opt,name=wallet_path,json=walletPath,proto3" json:"wallet_path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WalletExistsResponse) Reset()         { *m = WalletExistsResponse{} }
+func (m *WalletExistsResponse) String() string { return proto.CompactTextString(m) }
+func (*WalletExistsResponse) ProtoMessage()    {}
+func (*WalletExistsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{3}
+}
+
+func (m *WalletExistsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WalletExistsResponse.Unmarshal(m, b)
+}
+func (m *WalletExistsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WalletExistsResponse.Marshal(b, m, deterministic)
+}
+func (m *WalletExistsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WalletExistsResponse.Merge(m, src)
+}
+func (m *WalletExistsResponse) XXX_Size() int {
+	return xxx_messageInfo_WalletExistsResponse.Size(m)
+}
+func (m *WalletExistsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_WalletExistsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WalletExistsResponse proto.InternalMessageInfo
+
+func (m *WalletExistsResponse) GetExists() bool {
+	if m != nil {
+		return m.Exists
+	}
+	return false
+}
+
+func (m *WalletExistsResponse) GetWalletPath() string {
+	if m != nil {
+		return m.WalletPath
+	}
+	return ""
+}
+
 type InitWalletRequest struct {
 	//
 	//wallet_password is the passphrase that should be used to encrypt the
@@ -182,7 +312,7 @@ func (m *InitWalletRequest) Reset()         { *m = InitWalletRequest{} }
 func (m *InitWalletRequest) String() string { return proto.CompactTextString(m) }
 func (*InitWalletRequest) ProtoMessage()    {}
 func (*InitWalletRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_76e3ed10ed53e4fd, []int{2}
+	return fileDescriptor_76e3ed10ed53e4fd, []int{4}
 }
 
 func (m *InitWalletRequest) XXX_Unmarshal(b []byte) error {
@@ -262,7 +392,7 @@ func (m *InitWalletResponse) Reset()         { *m = InitWalletResponse{} }
 func (m *InitWalletResponse) String() string { return proto.CompactTextString(m) }
 func (*InitWalletResponse) ProtoMessage()    {}
 func (*InitWalletResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_76e3ed10ed53e4fd, []int{3}
+	return fileDescriptor_76e3ed10ed53e4fd, []int{5}
 }
 
 func (m *InitWalletResponse) XXX_Unmarshal(b []byte) error {
@@ -324,7 +454,7 @@ func (m *UnlockWalletRequest) Reset()         { *m = UnlockWalletRequest{} }
 func (m *UnlockWalletRequest) String() string { return proto.CompactTextString(m) }
 func (*UnlockWalletRequest) ProtoMessage()    {}
 func (*UnlockWalletRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_76e3ed10ed53e4fd, []int{4}
+	return fileDescriptor_76e3ed10ed53e4fd, []int{6}
 }
 
 func (m *UnlockWalletRequest) XXX_Unmarshal(b []byte) error {
@@ -383,7 +513,7 @@ func (m *UnlockWalletResponse) Reset()         { *m = UnlockWalletResponse{} }
 func (m *UnlockWalletResponse) String() string { return proto.CompactTextString(m) }
 func (*UnlockWalletResponse) ProtoMessage()    {}
 func (*UnlockWalletResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_76e3ed10ed53e4fd, []int{5}
+	return fileDescriptor_76e3ed10ed53e4fd, []int{7}
 }
 
 func (m *UnlockWalletResponse) XXX_Unmarshal(b []byte) error {
@@ -404,6 +534,210 @@ func (m *UnlockWalletResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_UnlockWalletResponse proto.InternalMessageInfo
 
+type UnlockWalletProgress struct {
+	// The stage of the unlock this update refers to.
+	Stage UnlockWalletProgress_Stage `protobuf:"varint,1,opt,name=stage,proto3,enum=lnrpc.UnlockWalletProgress_Stage" json:"stage,omitempty"`
+	//
+	//The progress of the recovery rescan, ranging from 0 to 1. Only set for
+	//the RESCAN_PROGRESS stage.
+	Progress             float64  `protobuf:"fixed64,2,opt,name=progress,proto3" json:"progress,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnlockWalletProgress) Reset()         { *m = UnlockWalletProgress{} }
+func (m *UnlockWalletProgress) String() string { return proto.CompactTextString(m) }
+func (*UnlockWalletProgress) ProtoMessage()    {}
+func (*UnlockWalletProgress) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{8}
+}
+
+func (m *UnlockWalletProgress) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnlockWalletProgress.Unmarshal(m, b)
+}
+func (m *UnlockWalletProgress) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnlockWalletProgress.Marshal(b, m, deterministic)
+}
+func (m *UnlockWalletProgress) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnlockWalletProgress.Merge(m, src)
+}
+func (m *UnlockWalletProgress) XXX_Size() int {
+	return xxx_messageInfo_UnlockWalletProgress.Size(m)
+}
+func (m *UnlockWalletProgress) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnlockWalletProgress.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnlockWalletProgress proto.InternalMessageInfo
+
+func (m *UnlockWalletProgress) GetStage() UnlockWalletProgress_Stage {
+	if m != nil {
+		return m.Stage
+	}
+	return UnlockWalletProgress_SCRYPT_DONE
+}
+
+func (m *UnlockWalletProgress) GetProgress() float64 {
+	if m != nil {
+		return m.Progress
+	}
+	return 0
+}
+
+type CheckPasswordResponse struct {
+	// Whether the password decrypts the wallet.
+	Valid                bool     `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CheckPasswordResponse) Reset()         { *m = CheckPasswordResponse{} }
+func (m *CheckPasswordResponse) String() string { return proto.CompactTextString(m) }
+func (*CheckPasswordResponse) ProtoMessage()    {}
+func (*CheckPasswordResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{9}
+}
+
+func (m *CheckPasswordResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CheckPasswordResponse.Unmarshal(m, b)
+}
+func (m *CheckPasswordResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CheckPasswordResponse.Marshal(b, m, deterministic)
+}
+func (m *CheckPasswordResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CheckPasswordResponse.Merge(m, src)
+}
+func (m *CheckPasswordResponse) XXX_Size() int {
+	return xxx_messageInfo_CheckPasswordResponse.Size(m)
+}
+func (m *CheckPasswordResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CheckPasswordResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CheckPasswordResponse proto.InternalMessageInfo
+
+func (m *CheckPasswordResponse) GetValid() bool {
+	if m != nil {
+		return m.Valid
+	}
+	return false
+}
+
+type ChangeSeedPassphraseRequest struct {
+	// The 24-word mnemonic of the cipher seed to re-encipher.
+	CipherSeedMnemonic []string `protobuf:"bytes,1,rep,name=cipher_seed_mnemonic,json=cipherSeedMnemonic,proto3" json:"cipher_seed_mnemonic,omitempty"`
+	//
+	//The passphrase the cipher seed is currently enciphered with, which may be
+	//empty. When using REST, this field must be encoded as base64.
+	CurrentAezeedPassphrase []byte `protobuf:"bytes,2,opt,name=current_aezeed_passphrase,json=currentAezeedPassphrase,proto3" json:"current_aezeed_passphrase,omitempty"`
+	//
+	//The passphrase to encipher the cipher seed with, which may be empty. When
+	//using REST, this field must be encoded as base64.
+	NewAezeedPassphrase  []byte   `protobuf:"bytes,3,opt,name=new_aezeed_passphrase,json=newAezeedPassphrase,proto3" json:"new_aezeed_passphrase,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChangeSeedPassphraseRequest) Reset()         { *m = ChangeSeedPassphraseRequest{} }
+func (m *ChangeSeedPassphraseRequest) String() string { return proto.CompactTextString(m) }
+func (*ChangeSeedPassphraseRequest) ProtoMessage()    {}
+func (*ChangeSeedPassphraseRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{10}
+}
+
+func (m *ChangeSeedPassphraseRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChangeSeedPassphraseRequest.Unmarshal(m, b)
+}
+func (m *ChangeSeedPassphraseRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChangeSeedPassphraseRequest.Marshal(b, m, deterministic)
+}
+func (m *ChangeSeedPassphraseRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChangeSeedPassphraseRequest.Merge(m, src)
+}
+func (m *ChangeSeedPassphraseRequest) XXX_Size() int {
+	return xxx_messageInfo_ChangeSeedPassphraseRequest.Size(m)
+}
+func (m *ChangeSeedPassphraseRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChangeSeedPassphraseRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChangeSeedPassphraseRequest proto.InternalMessageInfo
+
+func (m *ChangeSeedPassphraseRequest) GetCipherSeedMnemonic() []string {
+	if m != nil {
+		return m.CipherSeedMnemonic
+	}
+	return nil
+}
+
+func (m *ChangeSeedPassphraseRequest) GetCurrentAezeedPassphrase() []byte {
+	if m != nil {
+		return m.CurrentAezeedPassphrase
+	}
+	return nil
+}
+
+func (m *ChangeSeedPassphraseRequest) GetNewAezeedPassphrase() []byte {
+	if m != nil {
+		return m.NewAezeedPassphrase
+	}
+	return nil
+}
+
+type ChangeSeedPassphraseResponse struct {
+	// The 24-word mnemonic of the cipher seed under the new passphrase.
+	CipherSeedMnemonic []string `protobuf:"bytes,1,rep,name=cipher_seed_mnemonic,json=cipherSeedMnemonic,proto3" json:"cipher_seed_mnemonic,omitempty"`
+	//
+	//The raw aezeed cipher seed bytes under the new passphrase, before being
+	//run through the mnemonic encoding scheme.
+	EncipheredSeed       []byte   `protobuf:"bytes,2,opt,name=enciphered_seed,json=encipheredSeed,proto3" json:"enciphered_seed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChangeSeedPassphraseResponse) Reset()         { *m = ChangeSeedPassphraseResponse{} }
+func (m *ChangeSeedPassphraseResponse) String() string { return proto.CompactTextString(m) }
+func (*ChangeSeedPassphraseResponse) ProtoMessage()    {}
+func (*ChangeSeedPassphraseResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_76e3ed10ed53e4fd, []int{11}
+}
+
+func (m *ChangeSeedPassphraseResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChangeSeedPassphraseResponse.Unmarshal(m, b)
+}
+func (m *ChangeSeedPassphraseResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChangeSeedPassphraseResponse.Marshal(b, m, deterministic)
+}
+func (m *ChangeSeedPassphraseResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChangeSeedPassphraseResponse.Merge(m, src)
+}
+func (m *ChangeSeedPassphraseResponse) XXX_Size() int {
+	return xxx_messageInfo_ChangeSeedPassphraseResponse.Size(m)
+}
+func (m *ChangeSeedPassphraseResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChangeSeedPassphraseResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChangeSeedPassphraseResponse proto.InternalMessageInfo
+
+func (m *ChangeSeedPassphraseResponse) GetCipherSeedMnemonic() []string {
+	if m != nil {
+		return m.CipherSeedMnemonic
+	}
+	return nil
+}
+
+func (m *ChangeSeedPassphraseResponse) GetEncipheredSeed() []byte {
+	if m != nil {
+		return m.EncipheredSeed
+	}
+	return nil
+}
+
 type ChangePasswordRequest struct {
 	//
 	//current_password should be the current valid passphrase used to unlock the
@@ -433,7 +767,7 @@ func (m *ChangePasswordRequest) Reset()         { *m = ChangePasswordRequest{} }
 func (m *ChangePasswordRequest) String() string { return proto.CompactTextString(m) }
 func (*ChangePasswordRequest) ProtoMessage()    {}
 func (*ChangePasswordRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_76e3ed10ed53e4fd, []int{6}
+	return fileDescriptor_76e3ed10ed53e4fd, []int{12}
 }
 
 func (m *ChangePasswordRequest) XXX_Unmarshal(b []byte) error {
@@ -500,7 +834,7 @@ func (m *ChangePasswordResponse) Reset()         { *m = ChangePasswordResponse{}
 func (m *ChangePasswordResponse) String() string { return proto.CompactTextString(m) }
 func (*ChangePasswordResponse) ProtoMessage()    {}
 func (*ChangePasswordResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_76e3ed10ed53e4fd, []int{7}
+	return fileDescriptor_76e3ed10ed53e4fd, []int{13}
 }
 
 func (m *ChangePasswordResponse) XXX_Unmarshal(b []byte) error {
@@ -529,12 +863,19 @@ func (m *ChangePasswordResponse) GetAdminMacaroon() []byte {
 }
 
 func init() {
+	proto.RegisterEnum("lnrpc.UnlockWalletProgress_Stage", UnlockWalletProgress_Stage_name, UnlockWalletProgress_Stage_value)
 	proto.RegisterType((*GenSeedRequest)(nil), "lnrpc.GenSeedRequest")
 	proto.RegisterType((*GenSeedResponse)(nil), "lnrpc.GenSeedResponse")
+	proto.RegisterType((*WalletExistsRequest)(nil), "lnrpc.WalletExistsRequest")
+	proto.RegisterType((*WalletExistsResponse)(nil), "lnrpc.WalletExistsResponse")
 	proto.RegisterType((*InitWalletRequest)(nil), "lnrpc.InitWalletRequest")
 	proto.RegisterType((*InitWalletResponse)(nil), "lnrpc.InitWalletResponse")
 	proto.RegisterType((*UnlockWalletRequest)(nil), "lnrpc.UnlockWalletRequest")
 	proto.RegisterType((*UnlockWalletResponse)(nil), "lnrpc.UnlockWalletResponse")
+	proto.RegisterType((*UnlockWalletProgress)(nil), "lnrpc.UnlockWalletProgress")
+	proto.RegisterType((*CheckPasswordResponse)(nil), "lnrpc.CheckPasswordResponse")
+	proto.RegisterType((*ChangeSeedPassphraseRequest)(nil), "lnrpc.ChangeSeedPassphraseRequest")
+	proto.RegisterType((*ChangeSeedPassphraseResponse)(nil), "lnrpc.ChangeSeedPassphraseResponse")
 	proto.RegisterType((*ChangePasswordRequest)(nil), "lnrpc.ChangePasswordRequest")
 	proto.RegisterType((*ChangePasswordResponse)(nil), "lnrpc.ChangePasswordResponse")
 }
@@ -542,45 +883,65 @@ func init() {
 func init() { proto.RegisterFile("walletunlocker.proto", fileDescriptor_76e3ed10ed53e4fd) }
 
 var fileDescriptor_76e3ed10ed53e4fd = []byte{
-	// 599 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x94, 0xdf, 0x6a, 0xd4, 0x4e,
-	0x14, 0xc7, 0xc9, 0x6e, 0xdb, 0xdf, 0xaf, 0xa7, 0xdb, 0x6c, 0x3b, 0xb6, 0x25, 0x8d, 0x0a, 0xdb,
-	0x60, 0xe9, 0x8a, 0xb8, 0xd5, 0x7a, 0x23, 0x78, 0x21, 0x56, 0xa4, 0x88, 0x14, 0x4a, 0x4a, 0x29,
-	0x78, 0x13, 0xa7, 0x93, 0x43, 0x13, 0x36, 0x3b, 0x33, 0xce, 0xcc, 0x1a, 0xd6, 0xf7, 0xf1, 0xda,
-	0x47, 0xf0, 0x1d, 0x7c, 0x22, 0x49, 0x32, 0xd9, 0x76, 0xbb, 0x59, 0xf0, 0xcf, 0x45, 0x20, 0x7c,
-	0xce, 0x39, 0x33, 0xe7, 0xfb, 0x3d, 0x33, 0x03, 0x5b, 0x39, 0xcd, 0x32, 0x34, 0x63, 0x9e, 0x09,
-	0x36, 0x44, 0x35, 0x90, 0x4a, 0x18, 0x41, 0x96, 0x33, 0xae, 0x24, 0xf3, 0x57, 0x95, 0x64, 0x15,
-	0x09, 0x3e, 0x81, 0x7b, 0x82, 0xfc, 0x1c, 0x31, 0x0e, 0xf1, 0xf3, 0x18, 0xb5, 0x21, 0x4f, 0x60,
-	0x93, 0xe2, 0x57, 0xc4, 0x38, 0x92, 0x54, 0x6b, 0x99, 0x28, 0xaa, 0xd1, 0x73, 0x7a, 0x4e, 0xbf,
-	0x13, 0x6e, 0x54, 0x81, 0xb3, 0x29, 0x27, 0x7b, 0xd0, 0xd1, 0x45, 0x2a, 0x72, 0xa3, 0x84, 0x9c,
-	0x78, 0xad, 0x32, 0x6f, 0xad, 0x60, 0xef, 0x2a, 0x14, 0x64, 0xd0, 0x9d, 0xee, 0xa0, 0xa5, 0xe0,
-	0x1a, 0xc9, 0x33, 0xd8, 0x62, 0xa9, 0x4c, 0x50, 0x45, 0x65, 0xf1, 0x88, 0xe3, 0x48, 0xf0, 0x94,
-	0x79, 0x4e, 0xaf, 0xdd, 0x5f, 0x0d, 0x49, 0x15, 0x2b, 0x2a, 0x4e, 0x6d, 0x84, 0x1c, 0x40, 0x17,
-	0x79, 0xc5, 0x31, 0x2e, 0xab, 0xec, 0x56, 0xee, 0x0d, 0x2e, 0x0a, 0x82, 0xef, 0x2d, 0xd8, 0x7c,
-	0xcf, 0x53, 0x73, 0x59, 0xca, 0xaf, 0x35, 0x1d, 0x40, 0xb7, 0xf2, 0xa3, 0xd4, 0x94, 0x0b, 0x15,
-	0x5b, 0x45, 0x6e, 0x85, 0xcf, 0x2c, 0x5d, 0xd8, 0x59, 0x6b, 0x61, 0x67, 0x8d, 0x76, 0xb5, 0x17,
-	0xd8, 0x75, 0x00, 0x5d, 0x85, 0x4c, 0x7c, 0x41, 0x35, 0x89, 0xf2, 0x94, 0xc7, 0x22, 0xf7, 0x96,
-	0x7a, 0x4e, 0x7f, 0x39, 0x74, 0x6b, 0x7c, 0x59, 0x52, 0x72, 0x0c, 0x5d, 0x96, 0x50, 0xce, 0x31,
-	0x8b, 0xae, 0x28, 0x1b, 0x8e, 0xa5, 0xf6, 0x96, 0x7b, 0x4e, 0x7f, 0xed, 0x68, 0x77, 0x50, 0x8e,
-	0x70, 0xf0, 0x36, 0xa1, 0xfc, 0xb8, 0x8c, 0x9c, 0x73, 0x2a, 0x75, 0x22, 0x4c, 0xe8, 0xda, 0x8a,
-	0x0a, 0x6b, 0xb2, 0x0f, 0xae, 0x36, 0xd4, 0x60, 0x86, 0x5a, 0x47, 0x29, 0x4f, 0x8d, 0xb7, 0xd2,
-	0x73, 0xfa, 0xff, 0x87, 0xeb, 0x53, 0x5a, 0x18, 0x15, 0xbc, 0x02, 0x72, 0xdb, 0x30, 0x3b, 0xa2,
-	0x7d, 0x70, 0x69, 0x3c, 0x4a, 0x79, 0x34, 0xa2, 0x8c, 0x2a, 0x21, 0xb8, 0x35, 0x6c, 0xbd, 0xa4,
-	0xa7, 0x16, 0x06, 0x3f, 0x1d, 0xb8, 0x77, 0x51, 0x9e, 0xb1, 0xbf, 0x34, 0xbc, 0xc1, 0x91, 0xd6,
-	0xef, 0x3a, 0xd2, 0xfe, 0x77, 0x47, 0x96, 0x9a, 0x1c, 0xd9, 0x81, 0xad, 0x59, 0x4d, 0x95, 0x27,
-	0xc1, 0x0f, 0x07, 0xb6, 0x8b, 0x5d, 0xae, 0xb1, 0x6e, 0xbf, 0x96, 0xfb, 0x18, 0x36, 0xd8, 0x58,
-	0x29, 0xe4, 0x73, 0x7a, 0xbb, 0x96, 0x4f, 0x05, 0xef, 0x41, 0x87, 0x63, 0x7e, 0x93, 0x66, 0x6f,
-	0x0c, 0xc7, 0x7c, 0x9a, 0x32, 0xdf, 0x66, 0xbb, 0xa1, 0x4d, 0xf2, 0x1c, 0xb6, 0x8b, 0x95, 0xea,
-	0x01, 0x45, 0x4a, 0x08, 0x13, 0x0d, 0x71, 0x62, 0x45, 0x11, 0x8e, 0x79, 0x3d, 0xa7, 0x50, 0x08,
-	0xf3, 0x01, 0x27, 0xc1, 0x6b, 0xd8, 0xb9, 0x2b, 0xe0, 0x8f, 0xe6, 0x7d, 0xf4, 0xad, 0x05, 0x6e,
-	0xe5, 0xca, 0x85, 0x7d, 0x59, 0xc8, 0x4b, 0xf8, 0xcf, 0xde, 0x6f, 0xb2, 0x6d, 0x47, 0x31, 0xfb,
-	0xa2, 0xf8, 0x3b, 0x77, 0xb1, 0xdd, 0xf3, 0x0d, 0xc0, 0xcd, 0xc9, 0x23, 0x9e, 0xcd, 0x9a, 0xbb,
-	0xbd, 0xfe, 0x6e, 0x43, 0xc4, 0x2e, 0x71, 0x02, 0x9d, 0xdb, 0xa3, 0x22, 0xbe, 0x4d, 0x6d, 0x38,
-	0x93, 0xfe, 0xfd, 0xc6, 0x98, 0x5d, 0xe8, 0x14, 0xdc, 0x59, 0x67, 0xc8, 0x83, 0x5b, 0xe7, 0x6a,
-	0x6e, 0xe2, 0xfe, 0xc3, 0x05, 0xd1, 0x6a, 0xb9, 0xe3, 0x47, 0x1f, 0x83, 0xeb, 0xd4, 0x24, 0xe3,
-	0xab, 0x01, 0x13, 0xa3, 0x43, 0x39, 0x34, 0x4f, 0x19, 0xd5, 0x49, 0xf1, 0x13, 0x1f, 0x66, 0xbc,
-	0xf8, 0x94, 0x64, 0x57, 0x2b, 0xe5, 0x1b, 0xfc, 0xe2, 0x57, 0x00, 0x00, 0x00, 0xff, 0xff, 0xeb,
-	0x10, 0x06, 0xea, 0xad, 0x05, 0x00, 0x00,
+	// 928 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xd1, 0x6e, 0x23, 0x35,
+	0x14, 0x65, 0x92, 0xa6, 0xb4, 0xb7, 0xe9, 0x24, 0xeb, 0x26, 0x25, 0x4d, 0x8b, 0x48, 0x07, 0xad,
+	0x1a, 0x60, 0x37, 0x5d, 0xca, 0x03, 0x08, 0x1e, 0x50, 0x9b, 0x8d, 0xaa, 0x15, 0x74, 0x13, 0xcd,
+	0xb4, 0x5a, 0xc1, 0xcb, 0xc8, 0x9d, 0xb1, 0x3a, 0x43, 0x12, 0x7b, 0xb0, 0x9d, 0x4d, 0xc3, 0x47,
+	0xf0, 0x2b, 0xfc, 0x00, 0xd2, 0xfe, 0x03, 0xbf, 0xc1, 0x4f, 0xa0, 0xd8, 0xce, 0x34, 0x49, 0x27,
+	0x15, 0x05, 0xed, 0x43, 0x1e, 0xe6, 0xdc, 0x73, 0xaf, 0xed, 0xe3, 0x7b, 0xae, 0x03, 0x95, 0x31,
+	0x1e, 0x0c, 0x88, 0x1c, 0xd1, 0x01, 0x0b, 0xfa, 0x84, 0xb7, 0x12, 0xce, 0x24, 0x43, 0x85, 0x01,
+	0xe5, 0x49, 0x50, 0xdf, 0xe4, 0x49, 0xa0, 0x11, 0xe7, 0x77, 0x0b, 0xec, 0x73, 0x42, 0x3d, 0x42,
+	0x42, 0x97, 0xfc, 0x3a, 0x22, 0x42, 0xa2, 0x2f, 0xe0, 0x09, 0x26, 0xbf, 0x11, 0x12, 0xfa, 0x09,
+	0x16, 0x22, 0x89, 0x38, 0x16, 0xa4, 0x66, 0x35, 0xac, 0x66, 0xd1, 0x2d, 0xeb, 0x40, 0x2f, 0xc5,
+	0xd1, 0x21, 0x14, 0xc5, 0x94, 0x4a, 0xa8, 0xe4, 0x2c, 0x99, 0xd4, 0x72, 0x8a, 0xb7, 0x35, 0xc5,
+	0x3a, 0x1a, 0x42, 0xcf, 0x00, 0x29, 0xca, 0x75, 0xcc, 0x65, 0x14, 0xe2, 0x89, 0x3f, 0xa2, 0xf1,
+	0x6d, 0x2d, 0xdf, 0xb0, 0x9a, 0x79, 0xb7, 0x3c, 0x8d, 0x9c, 0x99, 0xc0, 0x15, 0x8d, 0x6f, 0x9d,
+	0x01, 0x94, 0xd2, 0xfd, 0x88, 0x84, 0x51, 0x41, 0xd0, 0x0b, 0xa8, 0x04, 0x71, 0x12, 0x11, 0xee,
+	0xab, 0x3a, 0x43, 0x4a, 0x86, 0x8c, 0xc6, 0x41, 0xcd, 0x6a, 0xe4, 0x9b, 0x9b, 0x2e, 0xd2, 0xb1,
+	0x69, 0xc6, 0x85, 0x89, 0xa0, 0x23, 0x28, 0x11, 0xaa, 0x71, 0x12, 0xaa, 0x2c, 0xb3, 0x31, 0xfb,
+	0x0e, 0x9e, 0x26, 0x38, 0x55, 0xd8, 0x79, 0xa3, 0x84, 0xea, 0xdc, 0xc6, 0x42, 0x0a, 0x23, 0x81,
+	0xd3, 0x85, 0xca, 0x22, 0x6c, 0x76, 0xb2, 0x0b, 0xeb, 0x44, 0x21, 0x4a, 0x8f, 0x0d, 0xd7, 0x7c,
+	0xa1, 0x4f, 0x60, 0x4b, 0xeb, 0xed, 0x27, 0x58, 0x46, 0x6a, 0xad, 0x4d, 0x17, 0x34, 0xd4, 0xc3,
+	0x32, 0x72, 0xfe, 0xc8, 0xc1, 0x93, 0x57, 0x34, 0x96, 0xba, 0xea, 0x4c, 0xe9, 0x23, 0x28, 0xa5,
+	0x69, 0x42, 0x8c, 0x19, 0x0f, 0x8d, 0xce, 0xf6, 0x2c, 0x55, 0xa3, 0x2b, 0x15, 0xc8, 0xad, 0x54,
+	0x20, 0xf3, 0x12, 0xf3, 0x2b, 0x2e, 0xf1, 0x08, 0x4a, 0x9c, 0x04, 0xec, 0x2d, 0xe1, 0x13, 0x7f,
+	0x1c, 0xd3, 0x90, 0x8d, 0x6b, 0x6b, 0x0d, 0xab, 0x59, 0x70, 0xed, 0x19, 0xfc, 0x46, 0xa1, 0xe8,
+	0x0c, 0x4a, 0x41, 0x84, 0x29, 0x25, 0x03, 0xff, 0x1a, 0x07, 0xfd, 0x51, 0x22, 0x6a, 0x85, 0x86,
+	0xd5, 0xdc, 0x3a, 0xd9, 0x6b, 0xa9, 0xce, 0x6a, 0xb5, 0x23, 0x4c, 0xcf, 0x54, 0xc4, 0xa3, 0x38,
+	0x11, 0x11, 0x93, 0xae, 0x6d, 0x32, 0x34, 0x2c, 0xd0, 0x53, 0xb0, 0x85, 0xc4, 0x92, 0x0c, 0x88,
+	0x10, 0x7e, 0x4c, 0x63, 0x59, 0x5b, 0x57, 0x5a, 0x6e, 0xa7, 0xe8, 0x54, 0x28, 0xe7, 0x3b, 0x40,
+	0xf3, 0x82, 0x99, 0x0b, 0x78, 0x0a, 0x36, 0x0e, 0x87, 0x31, 0xf5, 0x87, 0x38, 0xc0, 0x9c, 0x31,
+	0x6a, 0x04, 0xdb, 0x56, 0xe8, 0x85, 0x01, 0x9d, 0xbf, 0x2c, 0xd8, 0xb9, 0x52, 0xad, 0xff, 0x1f,
+	0x05, 0xcf, 0x50, 0x24, 0xf7, 0x6f, 0x15, 0xc9, 0xff, 0x7f, 0x45, 0xd6, 0xb2, 0x14, 0xd9, 0x85,
+	0xca, 0xe2, 0x99, 0xb4, 0x26, 0xce, 0x9f, 0xd6, 0x62, 0xa0, 0xc7, 0xd9, 0x0d, 0x27, 0x42, 0xa0,
+	0xaf, 0xa1, 0x20, 0x24, 0xbe, 0xd1, 0xe6, 0xb5, 0x4f, 0x0e, 0xcd, 0x8e, 0xb2, 0xb8, 0x2d, 0x6f,
+	0x4a, 0x74, 0x35, 0x1f, 0xd5, 0x61, 0x23, 0x31, 0x01, 0x75, 0x6c, 0xcb, 0x4d, 0xbf, 0x9d, 0x2e,
+	0x14, 0x14, 0x17, 0x95, 0x60, 0xcb, 0x6b, 0xbb, 0x3f, 0xf5, 0x2e, 0xfd, 0x97, 0xdd, 0xd7, 0x9d,
+	0xf2, 0x07, 0x08, 0x81, 0xed, 0x76, 0xbc, 0xf6, 0xe9, 0x6b, 0xdf, 0xbb, 0x3c, 0x75, 0x2f, 0x3b,
+	0x2f, 0xcb, 0x16, 0xda, 0x81, 0x92, 0xc1, 0x7a, 0x6e, 0xf7, 0xdc, 0xed, 0x78, 0x5e, 0x39, 0x87,
+	0x8a, 0xb0, 0xd1, 0xee, 0x5e, 0xf4, 0x7e, 0xec, 0x5c, 0x76, 0xca, 0x79, 0xe7, 0x39, 0x54, 0xdb,
+	0x11, 0x09, 0xfa, 0x33, 0xed, 0xd3, 0xbb, 0xae, 0x40, 0xe1, 0x2d, 0x1e, 0xc4, 0xa1, 0xf1, 0x9a,
+	0xfe, 0x70, 0xde, 0x59, 0xb0, 0x3f, 0xd5, 0xf4, 0x86, 0x78, 0x0b, 0x4d, 0x3c, 0xbb, 0xe2, 0xc7,
+	0x0f, 0x8b, 0x6f, 0x61, 0x2f, 0x18, 0x71, 0x4e, 0xa8, 0xf4, 0xef, 0x5b, 0x46, 0x8f, 0x8d, 0x8f,
+	0x0c, 0xe1, 0x74, 0xd9, 0x39, 0x27, 0x50, 0xa5, 0x64, 0xec, 0xaf, 0xb2, 0xda, 0x0e, 0x25, 0xe3,
+	0xe5, 0x1c, 0x67, 0x02, 0x07, 0xd9, 0x07, 0x78, 0xff, 0xe3, 0xee, 0x9d, 0x05, 0x55, 0xbd, 0xf6,
+	0x9d, 0xda, 0x5a, 0xb6, 0xcf, 0xa0, 0x3c, 0x13, 0x61, 0xc9, 0x1a, 0x25, 0x83, 0xa7, 0xde, 0x38,
+	0x84, 0xe2, 0xf4, 0xcc, 0x29, 0xcd, 0x8c, 0x7c, 0x4a, 0xc6, 0x29, 0xe5, 0x7e, 0x47, 0xe7, 0x33,
+	0x3a, 0x1a, 0x7d, 0xa9, 0xd5, 0x9b, 0x79, 0xd9, 0xe7, 0x8c, 0x49, 0xbf, 0x4f, 0x26, 0xa6, 0xff,
+	0x11, 0x25, 0xe3, 0x99, 0xa5, 0x5d, 0xc6, 0xe4, 0x0f, 0x64, 0xe2, 0x7c, 0x0f, 0xbb, 0xcb, 0x07,
+	0x78, 0xd4, 0x68, 0x38, 0xf9, 0x7b, 0x0d, 0x6c, 0xdd, 0xfb, 0x57, 0xe6, 0x6d, 0x44, 0xdf, 0xc0,
+	0x87, 0xe6, 0xc9, 0x41, 0x55, 0xe3, 0x91, 0xc5, 0x27, 0xb1, 0xbe, 0xbb, 0x0c, 0x9b, 0x35, 0xcf,
+	0xa1, 0x38, 0xff, 0x4e, 0xa0, 0xba, 0xe1, 0x65, 0xbc, 0x29, 0xf5, 0xfd, 0xcc, 0x98, 0x29, 0x74,
+	0x0a, 0x70, 0x37, 0xed, 0x50, 0xcd, 0x50, 0xef, 0xbd, 0x18, 0xf5, 0xbd, 0x8c, 0xc8, 0xdd, 0x5e,
+	0xe6, 0x9d, 0x9d, 0xee, 0x25, 0x63, 0x0e, 0xd6, 0xf7, 0x33, 0x63, 0xa6, 0x50, 0x17, 0xd0, 0x3c,
+	0xee, 0x49, 0x4e, 0xf0, 0xf0, 0xd1, 0xe5, 0x66, 0x93, 0xe5, 0x85, 0x85, 0x5e, 0xc1, 0xf6, 0x82,
+	0xc3, 0x1f, 0xac, 0x75, 0x90, 0xce, 0xcd, 0xac, 0x99, 0xe0, 0x43, 0x25, 0xcb, 0x3b, 0xc8, 0x99,
+	0x9b, 0xb6, 0x2b, 0x26, 0x43, 0xfd, 0xd3, 0x07, 0x39, 0x66, 0x81, 0x0b, 0xb0, 0x17, 0xfb, 0x0b,
+	0x1d, 0x2c, 0xa4, 0x2d, 0xf9, 0xa6, 0xfe, 0xf1, 0x8a, 0xa8, 0x2e, 0x77, 0xf6, 0xec, 0xe7, 0xcf,
+	0x6f, 0x62, 0x19, 0x8d, 0xae, 0x5b, 0x01, 0x1b, 0x1e, 0xf7, 0x31, 0x93, 0xb1, 0xe8, 0x3f, 0x8f,
+	0x46, 0x34, 0x3c, 0x0e, 0x7e, 0x09, 0x03, 0x16, 0xd3, 0xf0, 0x78, 0xa0, 0x7e, 0x3c, 0x09, 0xae,
+	0xd7, 0xd5, 0x7f, 0xb2, 0xaf, 0xfe, 0x19, 0x00, 0x45, 0xbf, 0xfa, 0x65, 0xbd, 0x09, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -606,6 +967,11 @@ type WalletUnlockerClient interface {
 	//wallet.
 	GenSeed(ctx context.Context, in *GenSeedRequest, opts ...grpc.CallOption) (*GenSeedResponse, error)
 	//
+	//WalletExists reports whether a wallet already exists on disk, so a client
+	//can decide whether to call InitWallet or UnlockWallet. No password is
+	//required and the wallet isn't opened.
+	WalletExists(ctx context.Context, in *WalletExistsRequest, opts ...grpc.CallOption) (*WalletExistsResponse, error)
+	//
 	//InitWallet is used when lnd is starting up for the first time to fully
 	//initialize the daemon and its internal wallet. At the very least a wallet
 	//password must be provided. This will be used to encrypt sensitive material
@@ -623,6 +989,23 @@ type WalletUnlockerClient interface {
 	//UnlockWallet is used at startup of lnd to provide a password to unlock
 	//the wallet database.
 	UnlockWallet(ctx context.Context, in *UnlockWalletRequest, opts ...grpc.CallOption) (*UnlockWalletResponse, error)
+	//
+	//UnlockWalletStream behaves like UnlockWallet, but additionally streams
+	//progress updates to the client while the wallet is being decrypted and,
+	//if a recovery window was given, while the recovery rescan is running. The
+	//stream is closed once the unlock is complete.
+	UnlockWalletStream(ctx context.Context, in *UnlockWalletRequest, opts ...grpc.CallOption) (WalletUnlocker_UnlockWalletStreamClient, error)
+	//
+	//CheckPassword checks whether the given password decrypts the existing
+	//wallet, without unlocking it. The wallet is opened and closed again right
+	//away, so this still incurs the full cost of the key derivation and must
+	//not be called in a tight loop.
+	CheckPassword(ctx context.Context, in *UnlockWalletRequest, opts ...grpc.CallOption) (*CheckPasswordResponse, error)
+	//
+	//ChangeSeedPassphrase re-enciphers an aezeed cipher seed under a new
+	//passphrase and returns the resulting mnemonic. This only operates on the
+	//given seed and doesn't touch the wallet.
+	ChangeSeedPassphrase(ctx context.Context, in *ChangeSeedPassphraseRequest, opts ...grpc.CallOption) (*ChangeSeedPassphraseResponse, error)
 	// lncli: `changepassword`
 	//ChangePassword changes the password of the encrypted wallet. This will
 	//automatically unlock the wallet database if successful.
@@ -646,6 +1029,15 @@ func (c *walletUnlockerClient) GenSeed(ctx context.Context, in *GenSeedRequest,
 	return out, nil
 }
 
+func (c *walletUnlockerClient) WalletExists(ctx context.Context, in *WalletExistsRequest, opts ...grpc.CallOption) (*WalletExistsResponse, error) {
+	out := new(WalletExistsResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.WalletUnlocker/WalletExists", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *walletUnlockerClient) InitWallet(ctx context.Context, in *InitWalletRequest, opts ...grpc.CallOption) (*InitWalletResponse, error) {
 	out := new(InitWalletResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.WalletUnlocker/InitWallet", in, out, opts...)
@@ -664,6 +1056,56 @@ func (c *walletUnlockerClient) UnlockWallet(ctx context.Context, in *UnlockWalle
 	return out, nil
 }
 
+func (c *walletUnlockerClient) UnlockWalletStream(ctx context.Context, in *UnlockWalletRequest, opts ...grpc.CallOption) (WalletUnlocker_UnlockWalletStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_WalletUnlocker_serviceDesc.Streams[0], "/lnrpc.WalletUnlocker/UnlockWalletStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &walletUnlockerUnlockWalletStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WalletUnlocker_UnlockWalletStreamClient interface {
+	Recv() (*UnlockWalletProgress, error)
+	grpc.ClientStream
+}
+
+type walletUnlockerUnlockWalletStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *walletUnlockerUnlockWalletStreamClient) Recv() (*UnlockWalletProgress, error) {
+	m := new(UnlockWalletProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *walletUnlockerClient) CheckPassword(ctx context.Context, in *UnlockWalletRequest, opts ...grpc.CallOption) (*CheckPasswordResponse, error) {
+	out := new(CheckPasswordResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.WalletUnlocker/CheckPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletUnlockerClient) ChangeSeedPassphrase(ctx context.Context, in *ChangeSeedPassphraseRequest, opts ...grpc.CallOption) (*ChangeSeedPassphraseResponse, error) {
+	out := new(ChangeSeedPassphraseResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.WalletUnlocker/ChangeSeedPassphrase", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *walletUnlockerClient) ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error) {
 	out := new(ChangePasswordResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.WalletUnlocker/ChangePassword", in, out, opts...)
@@ -686,6 +1128,11 @@ type WalletUnlockerServer interface {
 	//wallet.
 	GenSeed(context.Context, *GenSeedRequest) (*GenSeedResponse, error)
 	//
+	//WalletExists reports whether a wallet already exists on disk, so a client
+	//can decide whether to call InitWallet or UnlockWallet. No password is
+	//required and the wallet isn't opened.
+	WalletExists(context.Context, *WalletExistsRequest) (*WalletExistsResponse, error)
+	//
 	//InitWallet is used when lnd is starting up for the first time to fully
 	//initialize the daemon and its internal wallet. At the very least a wallet
 	//password must be provided. This will be used to encrypt sensitive material
@@ -703,6 +1150,23 @@ type WalletUnlockerServer interface {
 	//UnlockWallet is used at startup of lnd to provide a password to unlock
 	//the wallet database.
 	UnlockWallet(context.Context, *UnlockWalletRequest) (*UnlockWalletResponse, error)
+	//
+	//UnlockWalletStream behaves like UnlockWallet, but additionally streams
+	//progress updates to the client while the wallet is being decrypted and,
+	//if a recovery window was given, while the recovery rescan is running. The
+	//stream is closed once the unlock is complete.
+	UnlockWalletStream(*UnlockWalletRequest, WalletUnlocker_UnlockWalletStreamServer) error
+	//
+	//CheckPassword checks whether the given password decrypts the existing
+	//wallet, without unlocking it. The wallet is opened and closed again right
+	//away, so this still incurs the full cost of the key derivation and must
+	//not be called in a tight loop.
+	CheckPassword(context.Context, *UnlockWalletRequest) (*CheckPasswordResponse, error)
+	//
+	//ChangeSeedPassphrase re-enciphers an aezeed cipher seed under a new
+	//passphrase and returns the resulting mnemonic. This only operates on the
+	//given seed and doesn't touch the wallet.
+	ChangeSeedPassphrase(context.Context, *ChangeSeedPassphraseRequest) (*ChangeSeedPassphraseResponse, error)
 	// lncli: `changepassword`
 	//ChangePassword changes the password of the encrypted wallet. This will
 	//automatically unlock the wallet database if successful.
@@ -716,12 +1180,24 @@ type UnimplementedWalletUnlockerServer struct {
 func (*UnimplementedWalletUnlockerServer) GenSeed(ctx context.Context, req *GenSeedRequest) (*GenSeedResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GenSeed not implemented")
 }
+func (*UnimplementedWalletUnlockerServer) WalletExists(ctx context.Context, req *WalletExistsRequest) (*WalletExistsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WalletExists not implemented")
+}
 func (*UnimplementedWalletUnlockerServer) InitWallet(ctx context.Context, req *InitWalletRequest) (*InitWalletResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InitWallet not implemented")
 }
 func (*UnimplementedWalletUnlockerServer) UnlockWallet(ctx context.Context, req *UnlockWalletRequest) (*UnlockWalletResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UnlockWallet not implemented")
 }
+func (*UnimplementedWalletUnlockerServer) UnlockWalletStream(req *UnlockWalletRequest, srv WalletUnlocker_UnlockWalletStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method UnlockWalletStream not implemented")
+}
+func (*UnimplementedWalletUnlockerServer) CheckPassword(ctx context.Context, req *UnlockWalletRequest) (*CheckPasswordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckPassword not implemented")
+}
+func (*UnimplementedWalletUnlockerServer) ChangeSeedPassphrase(ctx context.Context, req *ChangeSeedPassphraseRequest) (*ChangeSeedPassphraseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangeSeedPassphrase not implemented")
+}
 func (*UnimplementedWalletUnlockerServer) ChangePassword(ctx context.Context, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
 }
@@ -748,6 +1224,24 @@ func _WalletUnlocker_GenSeed_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WalletUnlocker_WalletExists_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WalletExistsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletUnlockerServer).WalletExists(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.WalletUnlocker/WalletExists",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletUnlockerServer).WalletExists(ctx, req.(*WalletExistsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _WalletUnlocker_InitWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(InitWalletRequest)
 	if err := dec(in); err != nil {
@@ -784,6 +1278,63 @@ func _WalletUnlocker_UnlockWallet_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WalletUnlocker_UnlockWalletStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UnlockWalletRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WalletUnlockerServer).UnlockWalletStream(m, &walletUnlockerUnlockWalletStreamServer{stream})
+}
+
+type WalletUnlocker_UnlockWalletStreamServer interface {
+	Send(*UnlockWalletProgress) error
+	grpc.ServerStream
+}
+
+type walletUnlockerUnlockWalletStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *walletUnlockerUnlockWalletStreamServer) Send(m *UnlockWalletProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WalletUnlocker_CheckPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletUnlockerServer).CheckPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.WalletUnlocker/CheckPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletUnlockerServer).CheckPassword(ctx, req.(*UnlockWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletUnlocker_ChangeSeedPassphrase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeSeedPassphraseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletUnlockerServer).ChangeSeedPassphrase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.WalletUnlocker/ChangeSeedPassphrase",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletUnlockerServer).ChangeSeedPassphrase(ctx, req.(*ChangeSeedPassphraseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _WalletUnlocker_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ChangePasswordRequest)
 	if err := dec(in); err != nil {
@@ -810,6 +1361,10 @@ var _WalletUnlocker_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GenSeed",
 			Handler:    _WalletUnlocker_GenSeed_Handler,
 		},
+		{
+			MethodName: "WalletExists",
+			Handler:    _WalletUnlocker_WalletExists_Handler,
+		},
 		{
 			MethodName: "InitWallet",
 			Handler:    _WalletUnlocker_InitWallet_Handler,
@@ -818,11 +1373,25 @@ var _WalletUnlocker_serviceDesc = grpc.ServiceDesc{
 			MethodName: "UnlockWallet",
 			Handler:    _WalletUnlocker_UnlockWallet_Handler,
 		},
+		{
+			MethodName: "CheckPassword",
+			Handler:    _WalletUnlocker_CheckPassword_Handler,
+		},
+		{
+			MethodName: "ChangeSeedPassphrase",
+			Handler:    _WalletUnlocker_ChangeSeedPassphrase_Handler,
+		},
 		{
 			MethodName: "ChangePassword",
 			Handler:    _WalletUnlocker_ChangePassword_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UnlockWalletStream",
+			Handler:       _WalletUnlocker_UnlockWalletStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "walletunlocker.proto",
 }
@@ -6,6 +6,7 @@ import (
 	prand "math/rand"
 	"net"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -745,3 +746,130 @@ func (w *WebAPIEstimator) feeUpdateManager() {
 // A compile-time assertion to ensure that WebAPIEstimator implements the
 // Estimator interface.
 var _ Estimator = (*WebAPIEstimator)(nil)
+
+// FloorEstimator is an Estimator which wraps another Estimator, and ensures
+// that its fee estimates never fall below its relay fee. Transactions paying a
+// lower fee rate would be rejected by the network, so consumers that build
+// transactions from the estimates can use it to avoid doing so.
+type FloorEstimator struct {
+	// Estimator is the fee estimator whose estimates are clamped.
+	Estimator
+}
+
+// NewFloorEstimator creates a new FloorEstimator wrapping the passed fee
+// estimator.
+func NewFloorEstimator(estimator Estimator) *FloorEstimator {
+	return &FloorEstimator{
+		Estimator: estimator,
+	}
+}
+
+// EstimateFeePerKW returns the fee rate estimated by the wrapped estimator,
+// raised to its relay fee if it falls below it.
+//
+// NOTE: This method is part of the Estimator interface.
+func (f *FloorEstimator) EstimateFeePerKW(numBlocks uint32) (SatPerKWeight, er.R) {
+	feeRate, err := f.Estimator.EstimateFeePerKW(numBlocks)
+	if err != nil {
+		return 0, err
+	}
+
+	relayFee := f.Estimator.RelayFeePerKW()
+	if feeRate < relayFee {
+		log.Debugf("Estimated fee rate of %v is below the relay fee, "+
+			"using relay fee of %v", feeRate, relayFee)
+
+		feeRate = relayFee
+	}
+
+	return feeRate, nil
+}
+
+// A compile-time assertion to ensure that FloorEstimator implements the
+// Estimator interface.
+var _ Estimator = (*FloorEstimator)(nil)
+
+// SmoothedEstimator is an Estimator which wraps another Estimator, and returns
+// the median of its most recent estimates for a confirmation target rather
+// than the latest one. This keeps a transient spike of the underlying
+// estimate from making us overpay on fees.
+type SmoothedEstimator struct {
+	// Estimator is the fee estimator whose estimates are smoothed.
+	Estimator
+
+	// windowSize is the number of recent estimates kept per confirmation
+	// target.
+	windowSize int
+
+	// windows holds the most recent estimates per confirmation target,
+	// oldest first.
+	windows map[uint32][]SatPerKWeight
+
+	mu sync.Mutex
+}
+
+// NewSmoothedEstimator creates a new SmoothedEstimator which returns the median
+// of the last windowSize estimates of the passed fee estimator. A window size
+// below one is treated as one, which disables smoothing.
+func NewSmoothedEstimator(estimator Estimator,
+	windowSize int) *SmoothedEstimator {
+
+	if windowSize < 1 {
+		windowSize = 1
+	}
+
+	return &SmoothedEstimator{
+		Estimator:  estimator,
+		windowSize: windowSize,
+		windows:    make(map[uint32][]SatPerKWeight),
+	}
+}
+
+// EstimateFeePerKW queries the wrapped estimator for the confirmation target,
+// adds the result to the window of recent estimates for that target, and
+// returns the median of the window.
+//
+// NOTE: This method is part of the Estimator interface.
+func (s *SmoothedEstimator) EstimateFeePerKW(numBlocks uint32) (SatPerKWeight, er.R) {
+	feeRate, err := s.Estimator.EstimateFeePerKW(numBlocks)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window := append(s.windows[numBlocks], feeRate)
+	if len(window) > s.windowSize {
+		window = window[len(window)-s.windowSize:]
+	}
+	s.windows[numBlocks] = window
+
+	median := medianFeeRate(window)
+
+	log.Tracef("Smoothed fee rate of %v to %v for conf target of %v",
+		feeRate, median, numBlocks)
+
+	return median, nil
+}
+
+// medianFeeRate returns the median of the passed fee rates. For an even number
+// of fee rates, the mean of the two middle ones is returned.
+func medianFeeRate(feeRates []SatPerKWeight) SatPerKWeight {
+	sorted := make([]SatPerKWeight, len(feeRates))
+	copy(sorted, feeRates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
+
+// A compile-time assertion to ensure that SmoothedEstimator implements the
+// Estimator interface.
+var _ Estimator = (*SmoothedEstimator)(nil)
@@ -108,6 +108,111 @@ func TestStaticFeeEstimator(t *testing.T) {
 	}
 }
 
+// TestFloorEstimator checks that the FloorEstimator never returns a fee rate
+// below the relay fee of the estimator it wraps.
+func TestFloorEstimator(t *testing.T) {
+	t.Parallel()
+
+	const relayFee = FeePerKwFloor * 4
+
+	tests := []struct {
+		name     string
+		estimate SatPerKWeight
+		expected SatPerKWeight
+	}{
+		{
+			name:     "below relay fee",
+			estimate: 1,
+			expected: relayFee,
+		},
+		{
+			name:     "at relay fee",
+			estimate: relayFee,
+			expected: relayFee,
+		},
+		{
+			name:     "above relay fee",
+			estimate: relayFee * 10,
+			expected: relayFee * 10,
+		},
+	}
+
+	for _, test := range tests {
+		feeEstimator := NewFloorEstimator(
+			NewStaticEstimator(test.estimate, relayFee),
+		)
+
+		feeRate, err := feeEstimator.EstimateFeePerKW(6)
+		if err != nil {
+			t.Fatalf("%v: unable to get fee rate: %v", test.name,
+				err)
+		}
+		if feeRate != test.expected {
+			t.Fatalf("%v: expected fee rate %v, got %v", test.name,
+				test.expected, feeRate)
+		}
+		if feeEstimator.RelayFeePerKW() != relayFee {
+			t.Fatalf("%v: expected relay fee %v, got %v", test.name,
+				relayFee, feeEstimator.RelayFeePerKW())
+		}
+	}
+}
+
+// sequenceEstimator is an Estimator which returns the fee rates in its
+// sequence one after another.
+type sequenceEstimator struct {
+	*StaticEstimator
+
+	feeRates []SatPerKWeight
+}
+
+func (e *sequenceEstimator) EstimateFeePerKW(_ uint32) (SatPerKWeight, er.R) {
+	feeRate := e.feeRates[0]
+	e.feeRates = e.feeRates[1:]
+	return feeRate, nil
+}
+
+// TestSmoothedEstimator checks that the SmoothedEstimator returns the median of
+// the recent estimates for a conf target, so a single spike is smoothed out.
+func TestSmoothedEstimator(t *testing.T) {
+	t.Parallel()
+
+	const (
+		stable = SatPerKWeight(1000)
+		spike  = SatPerKWeight(50000)
+	)
+
+	feeEstimator := NewSmoothedEstimator(&sequenceEstimator{
+		StaticEstimator: NewStaticEstimator(0, FeePerKwFloor),
+		feeRates: []SatPerKWeight{
+			stable, stable + 100, stable - 100, spike, stable,
+			spike, spike, spike,
+		},
+	}, 5)
+
+	expected := []SatPerKWeight{
+		// The window fills up with stable values.
+		stable, stable + 50, stable,
+
+		// The spike is outweighed by the stable values.
+		stable + 50, stable,
+
+		// Once most of the window consists of the spike, the spike
+		// becomes the median.
+		stable + 100, spike, spike,
+	}
+	for i, exp := range expected {
+		feeRate, err := feeEstimator.EstimateFeePerKW(6)
+		if err != nil {
+			t.Fatalf("#%v: unable to get fee rate: %v", i, err)
+		}
+		if feeRate != exp {
+			t.Fatalf("#%v: expected fee rate %v, got %v", i, exp,
+				feeRate)
+		}
+	}
+}
+
 // TestSparseConfFeeSource checks that SparseConfFeeSource generates URLs and
 // parses API responses as expected.
 func TestSparseConfFeeSource(t *testing.T) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path"
+	"time"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
@@ -323,12 +324,43 @@ func (svc *Service) NewMacaroon(
 	return m, er.E(e)
 }
 
+// NewMacaroonWithExpiry works like NewMacaroon, but adds a `time-before`
+// first-party caveat to the macaroon, so that it expires after the given
+// duration from now. As the checker for `time-before` is registered by
+// default, the expiry is enforced by ValidateMacaroon.
+func (svc *Service) NewMacaroonWithExpiry(ctx context.Context,
+	rootKeyID []byte, expiry time.Duration,
+	ops ...bakery.Op) (*bakery.Macaroon, er.R) {
+
+	if len(rootKeyID) == 0 {
+		return nil, ErrMissingRootKeyID.Default()
+	}
+	if expiry <= 0 {
+		return nil, er.Errorf("invalid macaroon expiry %v", expiry)
+	}
+
+	ctx = ContextWithRootKeyID(ctx, rootKeyID)
+
+	caveats := []checkers.Caveat{
+		checkers.TimeBeforeCaveat(time.Now().Add(expiry)),
+	}
+	m, e := svc.Oven.NewMacaroon(ctx, bakery.LatestVersion, caveats, ops...)
+	return m, er.E(e)
+}
+
 // ListMacaroonIDs returns all the root key ID values except the value of
 // encryptedKeyID.
 func (svc *Service) ListMacaroonIDs(ctxt context.Context) ([][]byte, er.R) {
 	return svc.rks.ListMacaroonIDs(ctxt)
 }
 
+// ListMacaroonInfo returns the ID and creation time of all root keys.
+func (svc *Service) ListMacaroonInfo(ctxt context.Context) ([]RootKeyInfo,
+	er.R) {
+
+	return svc.rks.ListMacaroonInfo(ctxt)
+}
+
 // DeleteMacaroonID removes one specific root key ID. If the root key ID is
 // found and deleted, it will be returned.
 func (svc *Service) DeleteMacaroonID(ctxt context.Context,
@@ -342,6 +374,12 @@ func (svc *Service) GenerateNewRootKey() er.R {
 	return svc.rks.GenerateNewRootKey()
 }
 
+// RotateRootKey calls the underlying root key store's RotateRootKey and
+// returns the result.
+func (svc *Service) RotateRootKey(oldID, newID []byte) er.R {
+	return svc.rks.RotateRootKey(oldID, newID)
+}
+
 // ChangePassword calls the underlying root key store's ChangePassword and
 // returns the result.
 func (svc *Service) ChangePassword(oldPw, newPw []byte) er.R {
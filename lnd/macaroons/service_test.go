@@ -7,7 +7,9 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
 	"github.com/kaotisk-hund/cjdcoind/lnd/macaroons"
@@ -253,3 +255,118 @@ func TestDeleteMacaroonID(t *testing.T) {
 	ids, _ := service.ListMacaroonIDs(ctxb)
 	require.Equal(t, expectedIDs[1:], ids, "root key IDs mismatch")
 }
+
+// TestNewMacaroonWithExpiry tests that a macaroon baked with an expiry
+// validates until it expires, and is rejected afterwards.
+func TestNewMacaroonWithExpiry(t *testing.T) {
+	// First, initialize the service and unlock it.
+	tempDir := setupTestRootKeyStorage(t)
+	defer os.RemoveAll(tempDir)
+	service, err := macaroons.NewService(tempDir, "lnd", false)
+	util.RequireNoErr(t, err, "Error creating new service")
+	defer service.Close()
+
+	err = service.CreateUnlock(&defaultPw)
+	util.RequireNoErr(t, err, "Error unlocking root key storage")
+
+	// An expiry requires a root key ID and a positive duration, just like
+	// regular macaroons require a root key ID.
+	_, err = service.NewMacaroonWithExpiry(
+		context.TODO(), nil, time.Second, testOperation,
+	)
+	require.True(t, macaroons.ErrMissingRootKeyID.Is(err))
+	_, err = service.NewMacaroonWithExpiry(
+		context.TODO(), macaroons.DefaultRootKeyID, 0, testOperation,
+	)
+	util.RequireErr(t, err)
+
+	// Then, bake a macaroon that expires in a second.
+	mac, err := service.NewMacaroonWithExpiry(
+		context.TODO(), macaroons.DefaultRootKeyID, time.Second,
+		testOperation,
+	)
+	util.RequireNoErr(t, err, "Error creating macaroon from service")
+	require.Len(t, mac.M().Caveats(), 1)
+
+	macaroonBinary, errr := mac.M().MarshalBinary()
+	require.NoError(t, errr)
+	md := metadata.New(map[string]string{
+		"macaroon": hex.EncodeToString(macaroonBinary),
+	})
+	mockContext := metadata.NewIncomingContext(context.Background(), md)
+
+	// The macaroon should be valid right away.
+	err = service.ValidateMacaroon(
+		mockContext, []bakery.Op{testOperation}, "FooMethod",
+	)
+	util.RequireNoErr(t, err, "Error validating the macaroon")
+
+	// Once the expiry has passed, it should be rejected.
+	time.Sleep(1100 * time.Millisecond)
+	err = service.ValidateMacaroon(
+		mockContext, []bakery.Op{testOperation}, "FooMethod",
+	)
+	util.RequireErr(t, err)
+	require.Contains(t, err.Message(), "macaroon has expired")
+}
+
+// TestRotateRootKey tests that macaroons baked with a rotated root key still
+// validate until the old root key is deleted.
+func TestRotateRootKey(t *testing.T) {
+	ctxb := context.Background()
+
+	// First, initialize the service and unlock it.
+	tempDir := setupTestRootKeyStorage(t)
+	defer os.RemoveAll(tempDir)
+	service, err := macaroons.NewService(tempDir, "lnd", false)
+	util.RequireNoErr(t, err, "Error creating new service")
+	defer service.Close()
+
+	err = service.CreateUnlock(&defaultPw)
+	util.RequireNoErr(t, err, "Error unlocking root key storage")
+
+	validate := func(mac *bakery.Macaroon) er.R {
+		macaroonBinary, errr := mac.M().MarshalBinary()
+		require.NoError(t, errr)
+		md := metadata.New(map[string]string{
+			"macaroon": hex.EncodeToString(macaroonBinary),
+		})
+		return service.ValidateMacaroon(
+			metadata.NewIncomingContext(ctxb, md),
+			[]bakery.Op{testOperation}, "FooMethod",
+		)
+	}
+
+	// Bake a macaroon with the old root key.
+	oldID, newID := []byte("1"), []byte("2")
+	oldMac, err := service.NewMacaroon(ctxb, oldID, testOperation)
+	util.RequireNoErr(t, err, "Error creating macaroon from service")
+
+	// Rotating requires both IDs, an existing old key, and a new ID that
+	// isn't in use yet.
+	err = service.RotateRootKey(nil, newID)
+	require.True(t, macaroons.ErrMissingRootKeyID.Is(err))
+	err = service.RotateRootKey([]byte("unknown"), newID)
+	require.True(t, macaroons.ErrRootKeyNotFound.Is(err))
+	err = service.RotateRootKey(oldID, []byte("enckey"))
+	require.True(t, macaroons.ErrKeyValueForbidden.Is(err))
+
+	err = service.RotateRootKey(oldID, newID)
+	util.RequireNoErr(t, err, "Error rotating root key")
+
+	// Rotating to the same ID again would replace the new key.
+	err = service.RotateRootKey(oldID, newID)
+	require.True(t, macaroons.ErrRootKeyExists.Is(err))
+
+	// During the grace period, macaroons baked with either key verify.
+	newMac, err := service.NewMacaroon(ctxb, newID, testOperation)
+	util.RequireNoErr(t, err, "Error creating macaroon from service")
+	util.RequireNoErr(t, validate(oldMac), "old macaroon rejected")
+	util.RequireNoErr(t, validate(newMac), "new macaroon rejected")
+
+	// Once the old key is deleted, only the new macaroon verifies.
+	_, err = service.DeleteMacaroonID(ctxb, oldID)
+	util.RequireNoErr(t, err, "Error deleting root key")
+	util.RequireErr(t, validate(oldMac))
+	util.RequireNoErr(t, validate(newMac), "new macaroon rejected")
+}
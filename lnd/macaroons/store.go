@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	prand "math/rand"
 	"sync"
+	"time"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
 	"github.com/kaotisk-hund/cjdcoind/lnd/channeldb/kvdb"
 
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinwallet/snacl"
-	"github.com/kaotisk-hund/cjdcoind/cjdcoinwallet/walletdb"
 )
 
 const (
@@ -19,10 +22,18 @@ const (
 	RootKeyLen = 32
 )
 
+// byteOrder is the byte order used to encode the creation times of root keys.
+var byteOrder = binary.BigEndian
+
 var (
 	// rootKeyBucketName is the name of the root key store bucket.
 	rootKeyBucketName = []byte("macrootkeys")
 
+	// rootKeyInfoBucketName is the name of the bucket which maps root key
+	// IDs to the time the root key was created at. Stores created before
+	// it was added don't have entries for their existing root keys.
+	rootKeyInfoBucketName = []byte("macrootkeyinfo")
+
 	// DefaultRootKeyID is the ID of the default root key. The first is
 	// just 0, to emulate the memory storage that comes with bakery.
 	DefaultRootKeyID = []byte("0")
@@ -63,22 +74,121 @@ var (
 	// even if one was expected to be generated.
 	ErrEncKeyNotFound = Err.CodeWithDetail("ErrEncKeyNotFound",
 		"macaroon encryption key not found")
+
+	// ErrRootKeyNotFound specifies that the root key to rotate away from
+	// doesn't exist.
+	ErrRootKeyNotFound = Err.CodeWithDetail("ErrRootKeyNotFound",
+		"root key not found")
+
+	// ErrRootKeyExists specifies that the root key to rotate to already
+	// exists, and would be replaced by the rotation.
+	ErrRootKeyExists = Err.CodeWithDetail("ErrRootKeyExists",
+		"root key already exists")
 )
 
+const (
+	// minScryptN and maxScryptN are the bounds of the scrypt CPU/memory
+	// cost accepted by NewRootKeyStorageWithParams. At the upper bound,
+	// deriving the key with the default R takes 1 GiB of memory.
+	minScryptN = 2
+	maxScryptN = 1 << 20
+
+	// maxScryptRP is the upper bound of R*P imposed by scrypt.
+	maxScryptRP = 1 << 30
+)
+
+// ScryptParams are the scrypt cost parameters used to derive the key which
+// encrypts the root keys from the password.
+type ScryptParams struct {
+	// N is the CPU/memory cost, which must be a power of two.
+	N int
+
+	// R is the block size.
+	R int
+
+	// P is the parallelization.
+	P int
+}
+
+// validate checks that the parameters are usable with scrypt and within sane
+// bounds.
+func (p ScryptParams) validate() er.R {
+	switch {
+	case p.N < minScryptN || p.N > maxScryptN:
+		return er.Errorf("scrypt N %d must be between %d and %d", p.N,
+			minScryptN, maxScryptN)
+
+	case p.N&(p.N-1) != 0:
+		return er.Errorf("scrypt N %d must be a power of two", p.N)
+
+	case p.R <= 0 || p.P <= 0:
+		return er.Errorf("scrypt R %d and P %d must be positive", p.R,
+			p.P)
+
+	case uint64(p.R)*uint64(p.P) >= maxScryptRP:
+		return er.Errorf("scrypt R %d * P %d must be below %d", p.R,
+			p.P, maxScryptRP)
+	}
+
+	return nil
+}
+
+// maxUnlockBackoff is the longest CreateUnlock sleeps after a failed attempt,
+// unless UnlockAttemptDelay itself is longer.
+const maxUnlockBackoff = time.Minute
+
 // RootKeyStorage implements the bakery.RootKeyStorage interface.
 type RootKeyStorage struct {
 	kvdb.Backend
 
+	// UnlockAttemptDelay, if non-zero, makes CreateUnlock sleep after an
+	// attempt with a wrong password, to slow down online brute forcing of
+	// the password. The delay doubles with every consecutive failed
+	// attempt, up to maxUnlockBackoff, and a random jitter of up to half
+	// the delay is added.
+	UnlockAttemptDelay time.Duration
+
+	// scryptParams are the scrypt parameters used for new encryption
+	// keys.
+	scryptParams ScryptParams
+
 	encKeyMtx sync.RWMutex
 	encKey    *snacl.SecretKey
+
+	// failedUnlocks is the number of consecutive failed unlock attempts.
+	// It's guarded by encKeyMtx.
+	failedUnlocks uint32
 }
 
 // NewRootKeyStorage creates a RootKeyStorage instance.
 // TODO(aakselrod): Add support for encryption of data with passphrase.
 func NewRootKeyStorage(db kvdb.Backend) (*RootKeyStorage, er.R) {
-	// If the store's bucket doesn't exist, create it.
+	return NewRootKeyStorageWithParams(db, ScryptParams{
+		N: scryptN,
+		R: scryptR,
+		P: scryptP,
+	})
+}
+
+// NewRootKeyStorageWithParams creates a RootKeyStorage instance which derives
+// new encryption keys from passwords with the given scrypt parameters. The
+// parameters are stored along with the encryption key, so an existing store
+// keeps being unlocked with the parameters it was created with. New parameters
+// only take effect for an existing store once its password is changed.
+func NewRootKeyStorageWithParams(db kvdb.Backend,
+	params ScryptParams) (*RootKeyStorage, er.R) {
+
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	// If the store's buckets don't exist, create them.
 	err := kvdb.Update(db, func(tx kvdb.RwTx) er.R {
 		_, err := tx.CreateTopLevelBucket(rootKeyBucketName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateTopLevelBucket(rootKeyInfoBucketName)
 		return err
 	}, func() {})
 	if err != nil {
@@ -86,7 +196,11 @@ func NewRootKeyStorage(db kvdb.Backend) (*RootKeyStorage, er.R) {
 	}
 
 	// Return the DB wrapped in a RootKeyStorage object.
-	return &RootKeyStorage{Backend: db, encKey: nil}, nil
+	return &RootKeyStorage{
+		Backend:      db,
+		scryptParams: params,
+		encKey:       nil,
+	}, nil
 }
 
 // CreateUnlock sets an encryption key if one is not already set, otherwise it
@@ -105,7 +219,7 @@ func (r *RootKeyStorage) CreateUnlock(password *[]byte) er.R {
 		return ErrPasswordRequired.Default()
 	}
 
-	return kvdb.Update(r, func(tx kvdb.RwTx) er.R {
+	err := kvdb.Update(r, func(tx kvdb.RwTx) er.R {
 		bucket := tx.ReadWriteBucket(rootKeyBucketName)
 		if bucket == nil {
 			return ErrRootKeyBucketNotFound.Default()
@@ -131,7 +245,8 @@ func (r *RootKeyStorage) CreateUnlock(password *[]byte) er.R {
 
 		// We haven't yet stored a key, so create a new one.
 		encKey, err := snacl.NewSecretKey(
-			password, scryptN, scryptR, scryptP,
+			password, r.scryptParams.N, r.scryptParams.R,
+			r.scryptParams.P,
 		)
 		if err != nil {
 			return err
@@ -145,6 +260,42 @@ func (r *RootKeyStorage) CreateUnlock(password *[]byte) er.R {
 		r.encKey = encKey
 		return nil
 	}, func() {})
+
+	switch {
+	case err == nil:
+		r.failedUnlocks = 0
+
+	// The lock is held while sleeping, so that concurrent attempts are
+	// delayed as well.
+	case snacl.ErrInvalidPassword.Is(err) && r.UnlockAttemptDelay > 0:
+		r.failedUnlocks++
+		time.Sleep(unlockBackoff(r.UnlockAttemptDelay, r.failedUnlocks))
+	}
+
+	return err
+}
+
+// unlockBackoff returns how long to sleep after the given number of
+// consecutive failed unlock attempts.
+func unlockBackoff(delay time.Duration, failures uint32) time.Duration {
+	backoff := delay
+	for i := uint32(1); i < failures && backoff < maxUnlockBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxUnlockBackoff && delay <= maxUnlockBackoff {
+		backoff = maxUnlockBackoff
+	}
+
+	return backoff + time.Duration(prand.Int63n(int64(backoff/2)+1))
+}
+
+// ResetUnlockBackoff forgets about previous failed unlock attempts, so that the
+// next failed attempt is only delayed by UnlockAttemptDelay.
+func (r *RootKeyStorage) ResetUnlockBackoff() {
+	r.encKeyMtx.Lock()
+	defer r.encKeyMtx.Unlock()
+
+	r.failedUnlocks = 0
 }
 
 // ChangePassword decrypts the macaroon root key with the old password and then
@@ -189,7 +340,8 @@ func (r *RootKeyStorage) ChangePassword(oldPw, newPw []byte) er.R {
 
 		// Create a new encryption key from the new password.
 		encKeyNew, err := snacl.NewSecretKey(
-			&newPw, scryptN, scryptR, scryptP,
+			&newPw, r.scryptParams.N, r.scryptParams.R,
+			r.scryptParams.P,
 		)
 		if err != nil {
 			return err
@@ -306,7 +458,7 @@ func (r *RootKeyStorage) RootKey(ctx context.Context) ([]byte, []byte, error) {
 
 		// Otherwise, create a new root key, encrypt it,
 		// and store it in the bucket.
-		newKey, err := generateAndStoreNewRootKey(bucket, id, r.encKey)
+		newKey, err := generateAndStoreNewRootKey(tx, id, r.encKey)
 		rootKey = newKey
 		return err
 	}, func() {
@@ -333,12 +485,58 @@ func (r *RootKeyStorage) GenerateNewRootKey() er.R {
 			return ErrRootKeyBucketNotFound.Default()
 		}
 		_, err := generateAndStoreNewRootKey(
-			bucket, DefaultRootKeyID, r.encKey,
+			tx, DefaultRootKeyID, r.encKey,
 		)
 		return err
 	}, func() {})
 }
 
+// RotateRootKey generates a new root key under newID, leaving the root key
+// under oldID in place. Macaroons baked with either key verify until the old
+// one is removed with DeleteMacaroonID, which allows for a grace period during
+// which clients can switch to macaroons baked with the new key.
+func (r *RootKeyStorage) RotateRootKey(oldID, newID []byte) er.R {
+	r.encKeyMtx.RLock()
+	defer r.encKeyMtx.RUnlock()
+
+	if r.encKey == nil {
+		return ErrStoreLocked.Default()
+	}
+
+	if len(oldID) == 0 || len(newID) == 0 {
+		return ErrMissingRootKeyID.Default()
+	}
+	if bytes.Equal(oldID, encryptionKeyID) ||
+		bytes.Equal(newID, encryptionKeyID) {
+
+		return ErrKeyValueForbidden.Default()
+	}
+
+	return kvdb.Update(r, func(tx kvdb.RwTx) er.R {
+		bucket := tx.ReadWriteBucket(rootKeyBucketName)
+		if bucket == nil {
+			return ErrRootKeyBucketNotFound.Default()
+		}
+
+		if bucket.Get(oldID) == nil {
+			return ErrRootKeyNotFound.New(
+				fmt.Sprintf("root key id %s", oldID), nil,
+			)
+		}
+
+		// Rotating to an existing ID would invalidate all macaroons
+		// baked with it, which is what this is meant to avoid.
+		if bucket.Get(newID) != nil {
+			return ErrRootKeyExists.New(
+				fmt.Sprintf("root key id %s", newID), nil,
+			)
+		}
+
+		_, err := generateAndStoreNewRootKey(tx, newID, r.encKey)
+		return err
+	}, func() {})
+}
+
 // Close closes the underlying database and zeroes the encryption key stored
 // in memory.
 func (r *RootKeyStorage) Close() er.R {
@@ -353,11 +551,17 @@ func (r *RootKeyStorage) Close() er.R {
 }
 
 // generateAndStoreNewRootKey creates a new random RootKeyLen-byte root key,
-// encrypts it with the given encryption key and stores it in the bucket.
-// Any previously set key will be overwritten.
-func generateAndStoreNewRootKey(bucket walletdb.ReadWriteBucket, id []byte,
+// encrypts it with the given encryption key and stores it in the root key
+// bucket, along with its creation time. Any previously set key will be
+// overwritten.
+func generateAndStoreNewRootKey(tx kvdb.RwTx, id []byte,
 	key *snacl.SecretKey) ([]byte, er.R) {
 
+	bucket := tx.ReadWriteBucket(rootKeyBucketName)
+	if bucket == nil {
+		return nil, ErrRootKeyBucketNotFound.Default()
+	}
+
 	rootKey := make([]byte, RootKeyLen)
 	if _, err := util.ReadFull(rand.Reader, rootKey); err != nil {
 		return nil, err
@@ -367,7 +571,19 @@ func generateAndStoreNewRootKey(bucket walletdb.ReadWriteBucket, id []byte,
 	if err != nil {
 		return nil, err
 	}
-	return rootKey, bucket.Put(id, encryptedKey)
+	if err := bucket.Put(id, encryptedKey); err != nil {
+		return nil, err
+	}
+
+	infoBucket := tx.ReadWriteBucket(rootKeyInfoBucketName)
+	if infoBucket == nil {
+		return nil, ErrRootKeyBucketNotFound.Default()
+	}
+
+	var createdAt [8]byte
+	byteOrder.PutUint64(createdAt[:], uint64(time.Now().Unix()))
+
+	return rootKey, infoBucket.Put(id, createdAt[:])
 }
 
 // ListMacaroonIDs returns all the root key ID values except the value of
@@ -408,6 +624,70 @@ func (r *RootKeyStorage) ListMacaroonIDs(_ context.Context) ([][]byte, er.R) {
 	return rootKeySlice, nil
 }
 
+// RootKeyInfo describes a root key in the store.
+type RootKeyInfo struct {
+	// ID is the root key ID.
+	ID []byte
+
+	// CreatedAt is the time the root key was created at. It's the zero
+	// time for root keys created before creation times were recorded.
+	CreatedAt time.Time
+}
+
+// ListMacaroonInfo returns the ID and creation time of all root keys, in the
+// same order as ListMacaroonIDs.
+func (r *RootKeyStorage) ListMacaroonInfo(_ context.Context) ([]RootKeyInfo,
+	er.R) {
+
+	r.encKeyMtx.RLock()
+	defer r.encKeyMtx.RUnlock()
+
+	// Check it's unlocked.
+	if r.encKey == nil {
+		return nil, ErrStoreLocked.Default()
+	}
+
+	var infos []RootKeyInfo
+	err := kvdb.View(r, func(tx kvdb.RTx) er.R {
+		bucket := tx.ReadBucket(rootKeyBucketName)
+		if bucket == nil {
+			return ErrRootKeyBucketNotFound.Default()
+		}
+
+		// Missing creation times are reported as the zero time
+		// rather than as an error, as older stores don't have them.
+		infoBucket := tx.ReadBucket(rootKeyInfoBucketName)
+
+		return bucket.ForEach(func(k, _ []byte) er.R {
+			if bytes.Equal(k, encryptionKeyID) {
+				return nil
+			}
+
+			info := RootKeyInfo{
+				ID: append([]byte(nil), k...),
+			}
+			if infoBucket != nil {
+				createdAt := infoBucket.Get(k)
+				if len(createdAt) == 8 {
+					info.CreatedAt = time.Unix(int64(
+						byteOrder.Uint64(createdAt),
+					), 0)
+				}
+			}
+			infos = append(infos, info)
+
+			return nil
+		})
+	}, func() {
+		infos = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
 // DeleteMacaroonID removes one specific root key ID. If the root key ID is
 // found and deleted, it will be returned.
 func (r *RootKeyStorage) DeleteMacaroonID(
@@ -446,6 +726,12 @@ func (r *RootKeyStorage) DeleteMacaroonID(
 		if err := bucket.Delete(rootKeyID); err != nil {
 			return err
 		}
+		infoBucket := tx.ReadWriteBucket(rootKeyInfoBucketName)
+		if infoBucket != nil {
+			if err := infoBucket.Delete(rootKeyID); err != nil {
+				return err
+			}
+		}
 		rootKeyIDDeleted = rootKeyID
 
 		return nil
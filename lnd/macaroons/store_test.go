@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
@@ -222,3 +223,187 @@ func TestStoreChangePassword(t *testing.T) {
 	require.NoError(t, errr)
 	require.Equal(t, rootKey, rootKeyDb)
 }
+
+// TestStoreListMacaroonInfo tests that the creation times of root keys are
+// recorded, and that root keys of stores created before that are listed with
+// the zero time.
+func TestStoreListMacaroonInfo(t *testing.T) {
+	tempDir, cleanup, store := newTestStore(t)
+	defer cleanup()
+
+	_, err := store.ListMacaroonInfo(context.TODO())
+	require.True(t, macaroons.ErrStoreLocked.Is(err))
+
+	pw := []byte("weks")
+	err = store.CreateUnlock(&pw)
+	util.RequireNoErr(t, err)
+
+	// Create a root key, then turn the store into one of the old format by
+	// removing the bucket holding the creation times.
+	oldID := []byte("old")
+	oldCtx := macaroons.ContextWithRootKeyID(context.TODO(), oldID)
+	_, _, errr := store.RootKey(oldCtx)
+	require.NoError(t, errr)
+
+	err = kvdb.Update(store, func(tx kvdb.RwTx) er.R {
+		return tx.DeleteTopLevelBucket([]byte("macrootkeyinfo"))
+	}, func() {})
+	util.RequireNoErr(t, err)
+
+	// Without the bucket, the creation time is reported as the zero time.
+	infos, err := store.ListMacaroonInfo(context.TODO())
+	util.RequireNoErr(t, err)
+	require.Equal(t, []macaroons.RootKeyInfo{{ID: oldID}}, infos)
+
+	// Reopening the store migrates it, after which new root keys have their
+	// creation time recorded.
+	_ = store.Close()
+	_, store = openTestStore(t, tempDir)
+	defer store.Close()
+
+	err = store.CreateUnlock(&pw)
+	util.RequireNoErr(t, err)
+
+	before := time.Now().Truncate(time.Second)
+	_, _, errr = store.RootKey(defaultRootKeyIDContext)
+	require.NoError(t, errr)
+
+	infos, err = store.ListMacaroonInfo(context.TODO())
+	util.RequireNoErr(t, err)
+	require.Len(t, infos, 2)
+
+	require.Equal(t, macaroons.DefaultRootKeyID, infos[0].ID)
+	require.False(t, infos[0].CreatedAt.Before(before))
+	require.False(t, infos[0].CreatedAt.After(time.Now()))
+
+	require.Equal(t, oldID, infos[1].ID)
+	require.True(t, infos[1].CreatedAt.IsZero())
+
+	// Deleting a root key removes its creation time as well.
+	_, err = store.DeleteMacaroonID(context.TODO(), oldID)
+	util.RequireNoErr(t, err)
+	infos, err = store.ListMacaroonInfo(context.TODO())
+	util.RequireNoErr(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, macaroons.DefaultRootKeyID, infos[0].ID)
+}
+
+// TestStoreUnlockBackoff tests that failed unlock attempts are delayed with an
+// exponential backoff, which is reset by a successful unlock.
+func TestStoreUnlockBackoff(t *testing.T) {
+	tempDir, cleanup, store := newTestStore(t)
+	defer cleanup()
+
+	pw := []byte("weks")
+	err := store.CreateUnlock(&pw)
+	util.RequireNoErr(t, err)
+	_ = store.Close()
+
+	_, store = openTestStore(t, tempDir)
+	defer store.Close()
+
+	const delay = 50 * time.Millisecond
+	store.UnlockAttemptDelay = delay
+
+	// failUnlock attempts to unlock the store with a wrong password, and
+	// returns how long the attempt took.
+	badPw := []byte("badweks")
+	failUnlock := func() time.Duration {
+		start := time.Now()
+		err := store.CreateUnlock(&badPw)
+		require.True(t, snacl.ErrInvalidPassword.Is(err))
+		return time.Since(start)
+	}
+
+	// Each consecutive failure should at least double the delay.
+	var elapsed time.Duration
+	for i := 0; i < 3; i++ {
+		elapsed = failUnlock()
+		require.GreaterOrEqual(t, int64(elapsed), int64(delay<<i))
+	}
+
+	// After resetting the backoff, the next failure is only delayed by
+	// the base delay plus its jitter, far less than the last one.
+	store.ResetUnlockBackoff()
+	resetElapsed := failUnlock()
+	require.GreaterOrEqual(t, int64(resetElapsed), int64(delay))
+	require.Less(t, int64(resetElapsed), int64(elapsed))
+
+	// The correct password still unlocks the store.
+	err = store.CreateUnlock(&pw)
+	util.RequireNoErr(t, err)
+}
+
+// TestStoreScryptParams tests that the scrypt parameters a store is created
+// with are validated and persisted with the encryption key.
+func TestStoreScryptParams(t *testing.T) {
+	tempDir, errr := ioutil.TempDir("", "macaroonstore-")
+	require.NoError(t, errr)
+	defer os.RemoveAll(tempDir)
+
+	db, err := kvdb.Create(
+		kvdb.BoltBackendName, path.Join(tempDir, "weks.db"), true,
+	)
+	util.RequireNoErr(t, err)
+
+	// Invalid parameters should be rejected.
+	invalidParams := []macaroons.ScryptParams{
+		{N: 0, R: 8, P: 1},
+		{N: 1000, R: 8, P: 1},
+		{N: 1 << 21, R: 8, P: 1},
+		{N: 1 << 10, R: 0, P: 1},
+		{N: 1 << 10, R: 8, P: -1},
+		{N: 1 << 10, R: 1 << 15, P: 1 << 15},
+	}
+	for _, params := range invalidParams {
+		_, err := macaroons.NewRootKeyStorageWithParams(db, params)
+		util.RequireErr(t, err, "params %v accepted", params)
+	}
+
+	// Create a store with non-default parameters and bake a root key.
+	params := macaroons.ScryptParams{N: 1 << 5, R: 4, P: 2}
+	store, err := macaroons.NewRootKeyStorageWithParams(db, params)
+	util.RequireNoErr(t, err)
+
+	pw := []byte("weks")
+	err = store.CreateUnlock(&pw)
+	util.RequireNoErr(t, err)
+	key, _, errr := store.RootKey(defaultRootKeyIDContext)
+	require.NoError(t, errr)
+	_ = store.Close()
+
+	// readParams returns the scrypt parameters stored with the encryption
+	// key of the store.
+	readParams := func(store *macaroons.RootKeyStorage) macaroons.ScryptParams {
+		var encKey snacl.SecretKey
+		err := kvdb.View(store, func(tx kvdb.RTx) er.R {
+			bucket := tx.ReadBucket([]byte("macrootkeys"))
+			return encKey.Unmarshal(bucket.Get([]byte("enckey")))
+		}, func() {})
+		util.RequireNoErr(t, err)
+
+		return macaroons.ScryptParams{
+			N: encKey.Parameters.N,
+			R: encKey.Parameters.R,
+			P: encKey.Parameters.P,
+		}
+	}
+
+	// Reopening the store with the default parameters should still unlock
+	// it using the parameters it was created with.
+	_, store = openTestStore(t, tempDir)
+	require.Equal(t, params, readParams(store))
+
+	err = store.CreateUnlock(&pw)
+	util.RequireNoErr(t, err)
+	key2, errr := store.Get(defaultRootKeyIDContext, macaroons.DefaultRootKeyID)
+	require.NoError(t, errr)
+	require.Equal(t, key, key2)
+
+	// Changing the password switches to the parameters of the store.
+	newPw := []byte("newweks")
+	err = store.ChangePassword(pw, newPw)
+	util.RequireNoErr(t, err)
+	require.NotEqual(t, params, readParams(store))
+	_ = store.Close()
+}
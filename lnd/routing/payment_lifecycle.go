@@ -754,7 +754,7 @@ func marshallError(sendError er.R, time time.Time) *channeldb.HTLCFailInfo {
 	// we failed locally.
 	fErr, ok := rtErr.(*htlcswitch.ForwardingError)
 	if ok {
-		response.FailureSourceIndex = uint32(fErr.FailureSourceIdx)
+		response.FailureSourceIndex = uint32(fErr.FailedHop())
 	}
 
 	return response
@@ -2058,7 +2058,7 @@ func (r *ChannelRouter) processSendError(paymentID uint64, rt *route.Route,
 	failureSourceIdx := 0
 	source, ok := rtErr.(*htlcswitch.ForwardingError)
 	if ok {
-		failureSourceIdx = source.FailureSourceIdx
+		failureSourceIdx = source.FailedHop()
 	}
 
 	// Extract the wire failure and apply channel update if it contains one.
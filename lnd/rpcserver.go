@@ -6339,11 +6339,7 @@ func (r *rpcServer) RestoreChannelBackups(ctx context.Context,
 	// chanbackup.ChannelRestorer interface which we'll use to properly
 	// restore either a set of chanbackup.Single or chanbackup.Multi
 	// backups.
-	chanRestorer := &chanDBRestorer{
-		db:         r.server.remoteChanDB,
-		secretKeys: r.server.cc.KeyRing,
-		chainArb:   r.server.chainArb,
-	}
+	chanRestorer := newChanDBRestorer(r.server)
 
 	// We'll accept either a list of Single backups, or a single Multi
 	// backup which contains several single backups.
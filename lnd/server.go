@@ -497,6 +497,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		FwdingLog:              remoteChanDB.ForwardingLog(),
 		SwitchPackager:         channeldb.NewSwitchPackager(),
 		ExtractErrorEncrypter:  s.sphinx.ExtractErrorEncrypter,
+		DecodeHopIterators:     s.sphinx.DecodeHopIterators,
 		FetchLastChannelUpdate: s.fetchLastChanUpdate(),
 		Notifier:               s.cc.ChainNotifier,
 		HtlcNotifier:           s.htlcNotifier,
@@ -505,6 +506,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		AckEventTicker:         ticker.New(htlcswitch.DefaultAckInterval),
 		AllowCircularRoute:     cfg.AllowCircularRoute,
 		RejectHTLC:             cfg.RejectHTLC,
+		MaxForwardedHTLC:       lnwire.MilliSatoshi(cfg.MaxForwardedHTLC),
 		Clock:                  clock.NewDefaultClock(),
 		HTLCExpiry:             htlcswitch.DefaultHTLCExpiry,
 	}, uint32(currentHeight))
@@ -1491,11 +1493,7 @@ func (s *server) Start() er.R {
 		// any backups to recover. We do this now as we want to ensure
 		// that have all the information we need to handle channel
 		// recovery _before_ we even accept connections from any peers.
-		chanRestorer := &chanDBRestorer{
-			db:         s.remoteChanDB,
-			secretKeys: s.cc.KeyRing,
-			chainArb:   s.chainArb,
-		}
+		chanRestorer := newChanDBRestorer(s)
 		if len(s.chansToRestore.PackedSingleChanBackups) != 0 {
 			err := chanbackup.UnpackAndRecoverSingles(
 				s.chansToRestore.PackedSingleChanBackups,
@@ -0,0 +1,156 @@
+package sweep
+
+import (
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/lnd/input"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet/chainfee"
+	"github.com/kaotisk-hund/cjdcoind/wire"
+)
+
+// ExclusionReason is the reason an input wouldn't be swept.
+type ExclusionReason uint8
+
+const (
+	// ExcludedNegativeYield means that adding the input to a sweep
+	// transaction would cost more in fees than the value of the input.
+	ExcludedNegativeYield ExclusionReason = iota
+
+	// ExcludedDustOutput means that the output the input requires in the
+	// sweep transaction is below the dust limit.
+	ExcludedDustOutput
+
+	// ExcludedLowerYield means that the input wasn't considered, because
+	// an input with a higher yield was already rejected.
+	ExcludedLowerYield
+
+	// ExcludedSetBelowDust means that the output value of the set the
+	// input would be swept in doesn't reach the dust limit, even with
+	// inputs added from the wallet.
+	ExcludedSetBelowDust
+)
+
+// String returns a human readable version of the exclusion reason.
+func (r ExclusionReason) String() string {
+	switch r {
+	case ExcludedNegativeYield:
+		return "negative yield"
+
+	case ExcludedDustOutput:
+		return "required output below dust limit"
+
+	case ExcludedLowerYield:
+		return "lower yield than a rejected input"
+
+	case ExcludedSetBelowDust:
+		return "set output below dust limit"
+
+	default:
+		return "unknown"
+	}
+}
+
+// PlannedInputSet is a set of inputs which would be swept in a single
+// transaction.
+type PlannedInputSet struct {
+	// Inputs are the inputs of the transaction, including those added
+	// from the wallet.
+	Inputs []input.Input
+
+	// NumWalletInputs is the number of inputs added from the wallet to
+	// bring the output value of the transaction above the dust limit.
+	NumWalletInputs int
+
+	// Yield is the value of the transaction outputs minus the value of
+	// the wallet inputs.
+	Yield btcutil.Amount
+
+	// Weight is the estimated weight of the transaction.
+	Weight int
+}
+
+// SweepPlan describes how a set of inputs would be swept at a particular fee
+// rate.
+type SweepPlan struct {
+	// FeeRate is the fee rate the plan is made for.
+	FeeRate chainfee.SatPerKWeight
+
+	// InputSets are the sets of inputs which would be swept, each in a
+	// transaction of its own.
+	InputSets []PlannedInputSet
+
+	// Yields are the yields of all inputs, i.e. their value minus the fee
+	// for their witness. These are used to order the inputs when
+	// partitioning them into sets.
+	Yields map[wire.OutPoint]btcutil.Amount
+
+	// Excluded holds the reason for each input which wouldn't be swept.
+	Excluded map[wire.OutPoint]ExclusionReason
+}
+
+// excludeRejected records the reasons the given inputs are excluded, after the
+// first of them was rejected as the first input of a set.
+func (p *SweepPlan) excludeRejected(inputs []txInput,
+	dustLimit btcutil.Amount) {
+
+	for i, inp := range inputs {
+		op := *inp.OutPoint()
+
+		reqOut := inp.RequiredTxOut()
+		switch {
+		case reqOut != nil && btcutil.Amount(reqOut.Value) < dustLimit:
+			p.Excluded[op] = ExcludedDustOutput
+
+		// The yield of the first input within the set is non-positive,
+		// while the remaining ones weren't considered anymore.
+		case i == 0 || p.Yields[op] <= 0:
+			p.Excluded[op] = ExcludedNegativeYield
+
+		default:
+			p.Excluded[op] = ExcludedLowerYield
+		}
+	}
+}
+
+// DryRunSweep returns how the given inputs would be swept at the given fee
+// rate, without sweeping them. The inputs are partitioned into sets exactly as
+// the sweeper does, treating them as regular, non-forced inputs. Wallet
+// inputs may be added to sets, but aren't locked.
+func (s *UtxoSweeper) DryRunSweep(inputs []input.Input,
+	feePerKW chainfee.SatPerKWeight) (SweepPlan, er.R) {
+
+	plan := SweepPlan{
+		FeeRate:  feePerKW,
+		Yields:   make(map[wire.OutPoint]btcutil.Amount, len(inputs)),
+		Excluded: make(map[wire.OutPoint]ExclusionReason),
+	}
+
+	if feePerKW < s.relayFeeRate {
+		return SweepPlan{}, er.Errorf("fee rate %v below minimum relay fee "+
+			"rate %v", feePerKW, s.relayFeeRate)
+	}
+
+	txInputs := make([]txInput, 0, len(inputs))
+	for _, inp := range inputs {
+		txInputs = append(txInputs, &pendingInput{Input: inp})
+	}
+
+	_, err := planInputPartitionings(
+		txInputs, s.relayFeeRate, feePerKW, s.cfg.MaxInputsPerTx,
+		s.cfg.Wallet, &plan,
+	)
+	if err != nil {
+		return SweepPlan{}, err
+	}
+
+	// Hand out the inputs that were passed in rather than their wrappers.
+	for _, set := range plan.InputSets {
+		for i, inp := range set.Inputs {
+			if p, ok := inp.(*pendingInput); ok {
+				set.Inputs[i] = p.Input
+			}
+		}
+	}
+
+	return plan, nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/kaotisk-hund/cjdcoind/blockchain"
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/chaincfg/chainhash"
@@ -84,6 +85,14 @@ type Params struct {
 	// ExclusiveGroup is an identifier that, if set, prevents other inputs
 	// with the same identifier from being batched together.
 	ExclusiveGroup *uint64
+
+	// MinChangeAmount is the minimum value of the change output of a sweep
+	// tx spending the input, in addition to the dust limit. Change below
+	// it is added to the fee instead, to avoid creating uneconomical
+	// outputs. It only applies if the tx has other outputs, i.e. inputs
+	// committing to an output. If inputs with different values are swept
+	// together, the highest one is used.
+	MinChangeAmount btcutil.Amount
 }
 
 // ParamsUpdate contains a new set of parameters to update a pending sweep with.
@@ -100,8 +109,9 @@ type ParamsUpdate struct {
 
 // String returns a human readable interpretation of the sweep parameters.
 func (p Params) String() string {
-	return fmt.Sprintf("fee=%v, force=%v, exclusive_group=%v",
-		p.Fee, p.Force, p.ExclusiveGroup)
+	return fmt.Sprintf("fee=%v, force=%v, exclusive_group=%v, "+
+		"min_change=%v", p.Fee, p.Force, p.ExclusiveGroup,
+		p.MinChangeAmount)
 }
 
 // pendingInput is created when an input reaches the main loop for the first
@@ -225,6 +235,10 @@ type UtxoSweeper struct {
 	// requested to sweep.
 	pendingInputs pendingInputs
 
+	// inFlightSweeps is the set of sweep txes we've published that haven't
+	// confirmed yet, together with the inputs they spend.
+	inFlightSweeps map[chainhash.Hash][]wire.OutPoint
+
 	// timer is the channel that signals expiry of the sweep batch timer.
 	timer <-chan time.Time
 
@@ -273,6 +287,11 @@ type UtxoSweeperConfig struct {
 	// created and published.
 	MaxInputsPerTx int
 
+	// SortInputsBIP69 indicates whether the inputs of sweep txes are sorted
+	// according to BIP 69, rather than kept in the order they're selected
+	// in.
+	SortInputsBIP69 bool
+
 	// MaxSweepAttempts specifies the maximum number of times an input is
 	// included in a publish attempt before giving up and returning an error
 	// to the caller.
@@ -297,6 +316,13 @@ type UtxoSweeperConfig struct {
 	//   #1: min = 1 sat/vbyte, max (exclusive) = 11 sat/vbyte
 	//   #2: min = 11 sat/vbyte, max (exclusive) = 21 sat/vbyte...
 	FeeRateBucketSize int
+
+	// MaxConcurrentSweeps is the maximum number of published sweep txes
+	// that may be unconfirmed at the same time. Inputs that would exceed
+	// this limit remain pending until an earlier sweep confirms. Inputs
+	// with the Force flag set are always swept. A value of zero means no
+	// limit.
+	MaxConcurrentSweeps int
 }
 
 // Result is the struct that is pushed through the result channel. Callers can
@@ -330,6 +356,7 @@ func New(cfg *UtxoSweeperConfig) *UtxoSweeper {
 		pendingSweepsReqs: make(chan *pendingSweepsReq),
 		quit:              make(chan struct{}),
 		pendingInputs:     make(pendingInputs),
+		inFlightSweeps:    make(map[chainhash.Hash][]wire.OutPoint),
 	}
 }
 
@@ -728,6 +755,20 @@ func (s *UtxoSweeper) sweepCluster(cluster inputCluster,
 
 		// Sweep selected inputs.
 		for _, inputs := range inputLists {
+			// Keep the inputs pending if we already have the
+			// maximum number of sweeps in flight, unless they
+			// need to be swept regardless. Re-sweeps of inputs
+			// that are already in flight replace their earlier
+			// sweep tx, so they don't count against the limit.
+			if s.sweepsThrottled() && !s.hasForcedInput(inputs) &&
+				!s.hasInFlightInput(inputs) {
+
+				log.Debugf("Deferring sweep of %v inputs, "+
+					"%v sweeps in flight", len(inputs),
+					len(s.inFlightSweeps))
+				continue
+			}
+
 			err := s.sweep(inputs, cluster.sweepFeeRate, currentHeight)
 			if err != nil {
 				return er.Errorf("unable to sweep inputs: %v", err)
@@ -738,6 +779,70 @@ func (s *UtxoSweeper) sweepCluster(cluster inputCluster,
 	})
 }
 
+// sweepsThrottled returns true if the number of unconfirmed sweep txes has
+// reached the configured maximum.
+func (s *UtxoSweeper) sweepsThrottled() bool {
+	return s.cfg.MaxConcurrentSweeps > 0 &&
+		len(s.inFlightSweeps) >= s.cfg.MaxConcurrentSweeps
+}
+
+// hasForcedInput returns true if any of the pending inputs in the set has the
+// Force flag set.
+func (s *UtxoSweeper) hasForcedInput(inputs inputSet) bool {
+	for _, input := range inputs {
+		pi, ok := s.pendingInputs[*input.OutPoint()]
+		if ok && pi.params.Force {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasInFlightInput returns true if any of the inputs in the set is spent by a
+// sweep tx that is still in flight.
+func (s *UtxoSweeper) hasInFlightInput(inputs inputSet) bool {
+	for _, input := range inputs {
+		for _, outpoints := range s.inFlightSweeps {
+			for _, op := range outpoints {
+				if op == *input.OutPoint() {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// minChangeAmount returns the highest minimum change amount of the sweep
+// parameters of the given inputs.
+func (s *UtxoSweeper) minChangeAmount(inputs inputSet) btcutil.Amount {
+	var minChange btcutil.Amount
+	for _, input := range inputs {
+		pi, ok := s.pendingInputs[*input.OutPoint()]
+		if ok && pi.params.MinChangeAmount > minChange {
+			minChange = pi.params.MinChangeAmount
+		}
+	}
+
+	return minChange
+}
+
+// releaseInFlightSweeps forgets about all in flight sweep txes that spend the
+// given outpoint. Those txes either confirmed, or can never confirm anymore
+// because the outpoint has been spent by another tx.
+func (s *UtxoSweeper) releaseInFlightSweeps(outpoint wire.OutPoint) {
+	for txHash, outpoints := range s.inFlightSweeps {
+		for _, op := range outpoints {
+			if op == outpoint {
+				delete(s.inFlightSweeps, txHash)
+				break
+			}
+		}
+	}
+}
+
 // bucketForFeeReate determines the proper bucket for a fee rate. This is done
 // in order to batch inputs with similar fee rates together.
 func (s *UtxoSweeper) bucketForFeeRate(
@@ -1083,6 +1188,10 @@ func (s *UtxoSweeper) signalAndRemove(outpoint *wire.OutPoint, result Result) {
 
 	// Inputs are no longer pending after result has been sent.
 	delete(s.pendingInputs, *outpoint)
+
+	// A sweep tx spending this input won't confirm anymore, or already
+	// did, so it no longer counts towards the in flight sweeps.
+	s.releaseInFlightSweeps(*outpoint)
 }
 
 // getInputLists goes through the given inputs and constructs multiple distinct
@@ -1168,7 +1277,8 @@ func (s *UtxoSweeper) sweep(inputs inputSet, feeRate chainfee.SatPerKWeight,
 	// Create sweep tx.
 	tx, err := createSweepTx(
 		inputs, s.currentOutputScript, uint32(currentHeight), feeRate,
-		dustLimit(s.relayFeeRate), s.cfg.Signer,
+		dustLimit(s.relayFeeRate), s.minChangeAmount(inputs),
+		s.cfg.SortInputsBIP69, s.cfg.Signer,
 	)
 	if err != nil {
 		return er.Errorf("create sweep tx: %v", err)
@@ -1205,6 +1315,14 @@ func (s *UtxoSweeper) sweep(inputs inputSet, feeRate chainfee.SatPerKWeight,
 	// for the next transaction and causes no address inflation.
 	if err == nil {
 		s.currentOutputScript = nil
+
+		// This tx replaces any earlier sweep of the same inputs.
+		outpoints := make([]wire.OutPoint, 0, len(tx.TxIn))
+		for _, input := range tx.TxIn {
+			s.releaseInFlightSweeps(input.PreviousOutPoint)
+			outpoints = append(outpoints, input.PreviousOutPoint)
+		}
+		s.inFlightSweeps[tx.TxHash()] = outpoints
 	}
 
 	// Reschedule sweep.
@@ -1459,7 +1577,72 @@ func (s *UtxoSweeper) CreateSweepTx(inputs []input.Input, feePref FeePreference,
 
 	return createSweepTx(
 		inputs, pkScript, currentBlockHeight, feePerKw,
-		dustLimit(s.relayFeeRate), s.cfg.Signer,
+		dustLimit(s.relayFeeRate), 0, s.cfg.SortInputsBIP69,
+		s.cfg.Signer,
+	)
+}
+
+// parentInput wraps an input to attach information about its unconfirmed
+// parent tx.
+type parentInput struct {
+	input.Input
+
+	parent *input.TxInfo
+}
+
+// UnconfParent returns information about the unconfirmed parent tx.
+//
+// NOTE: Part of the input.Input interface.
+func (p *parentInput) UnconfParent() *input.TxInfo {
+	return p.parent
+}
+
+// SweepWithParent signs and generates a tx sweeping an output of the given
+// unconfirmed parent tx, paying for the parent as well (CPFP). The fee of the
+// child is chosen such that the fee rate of the package of both transactions
+// reaches the target, taking into account the parentFee the parent already
+// pays. If the parent alone pays at least the target fee rate, the child just
+// pays the target fee rate for itself. The tx isn't published.
+//
+// The value of currentBlockHeight will be set as the tx locktime, see
+// CreateSweepTx.
+func (s *UtxoSweeper) SweepWithParent(inp input.Input, parent *wire.MsgTx,
+	parentFee btcutil.Amount, target chainfee.SatPerKWeight,
+	currentBlockHeight uint32) (*wire.MsgTx, er.R) {
+
+	op := inp.OutPoint()
+	if op.Hash != parent.TxHash() || op.Index >= uint32(len(parent.TxOut)) {
+		return nil, er.Errorf("input %v doesn't spend an output of "+
+			"parent tx %v", *op, parent.TxHash())
+	}
+
+	if target < s.relayFeeRate {
+		return nil, er.Errorf("fee rate %v below minimum relay fee "+
+			"rate %v", target, s.relayFeeRate)
+	}
+
+	parentWeight := blockchain.GetTransactionWeight(btcutil.NewTx(parent))
+	if parentWeight == 0 {
+		return nil, er.New("parent tx has zero weight")
+	}
+
+	pkScript, err := s.cfg.GenSweepScript()
+	if err != nil {
+		return nil, err
+	}
+
+	cpfpInput := &parentInput{
+		Input: inp,
+		parent: &input.TxInfo{
+			Fee:    parentFee,
+			Weight: parentWeight,
+		},
+	}
+
+	return createSweepTx(
+		[]input.Input{cpfpInput}, pkScript, currentBlockHeight, target,
+		dustLimit(s.relayFeeRate), 0, s.cfg.SortInputsBIP69,
+		s.cfg.Signer,
 	)
 }
 
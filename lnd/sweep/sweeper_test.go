@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/kaotisk-hund/cjdcoind/blockchain"
 	"github.com/kaotisk-hund/cjdcoind/btcec"
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
@@ -578,6 +579,67 @@ func TestNegativeInput(t *testing.T) {
 	ctx.finish(1)
 }
 
+// TestDryRunSweep asserts that a dry run reports the sets the sweeper would
+// publish, as well as why the remaining inputs would be skipped.
+func TestDryRunSweep(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+
+	const feeRate = chainfee.SatPerKWeight(10000)
+
+	largeInput := createTestInput(100000, input.CommitmentTimeLock)
+	negInput := createTestInput(1000, input.CommitmentTimeLock)
+	dustReqInput := createTestInput(50000, input.CommitmentTimeLock)
+	dustReq := &testInput{
+		BaseInput: &dustReqInput,
+		reqTxOut:  &wire.TxOut{Value: 100},
+	}
+
+	plan, err := ctx.sweeper.DryRunSweep(
+		[]input.Input{&largeInput, &negInput, dustReq}, feeRate,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The large input is swept on its own. The input with the next highest
+	// yield requires a dust output, so it can't be added to any set.
+	require.Len(t, plan.InputSets, 1)
+	require.Equal(t, []input.Input{&largeInput}, plan.InputSets[0].Inputs)
+	require.Zero(t, plan.InputSets[0].NumWalletInputs)
+	require.Equal(t, map[wire.OutPoint]ExclusionReason{
+		*dustReq.OutPoint():  ExcludedDustOutput,
+		*negInput.OutPoint(): ExcludedNegativeYield,
+	}, plan.Excluded)
+
+	// The yields must match those used to order the inputs.
+	for _, inp := range []input.Input{&largeInput, &negInput, dustReq} {
+		yield, err := inputYield(inp, feeRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.Equal(
+			t, btcutil.Amount(yield), plan.Yields[*inp.OutPoint()],
+		)
+	}
+
+	// As in TestDust, an input whose set stays below the dust limit
+	// isn't swept.
+	dustInput := createTestInput(5260, input.CommitmentTimeLock)
+	plan, err = ctx.sweeper.DryRunSweep(
+		[]input.Input{&dustInput}, feeRate,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Empty(t, plan.InputSets)
+	require.Equal(t, map[wire.OutPoint]ExclusionReason{
+		*dustInput.OutPoint(): ExcludedSetBelowDust,
+	}, plan.Excluded)
+
+	// No tx should appear. This is asserted in finish().
+	ctx.finish(1)
+}
+
 // TestChunks asserts that large sets of inputs are split into multiple txes.
 func TestChunks(t *testing.T) {
 	ctx := createSweeperTestContext(t)
@@ -1253,6 +1315,47 @@ func TestBumpFeeRBF(t *testing.T) {
 	ctx.finish(1)
 }
 
+// TestRetryAtRisingFeeRate asserts that an input swept with a confirmation
+// target, like the HTLCs swept from the remote commitment by the contract
+// court, is swept again at a higher fee rate if the fee estimate for its
+// target rises before the sweep confirms.
+func TestRetryAtRisingFeeRate(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+
+	feePref := FeePreference{ConfTarget: 6}
+	lowFeeRate := chainfee.FeePerKwFloor
+	ctx.estimator.blocksToFee[feePref.ConfTarget] = lowFeeRate
+
+	baseInput := createTestInput(
+		btcutil.UnitsPerCoinI64(), input.HtlcAcceptedRemoteSuccess,
+	)
+	input := input.MakeHtlcSucceedInput(
+		baseInput.OutPoint(), baseInput.SignDesc(), []byte{1}, 0, 0,
+	)
+	result, err := ctx.sweeper.SweepInput(&input, Params{Fee: feePref})
+	util.RequireNoErr(t, err)
+
+	ctx.tick()
+	lowFeeTx := ctx.receiveTx()
+	assertTxFeeRate(t, &lowFeeTx, lowFeeRate, &input)
+
+	// Fees rise while the sweep is unconfirmed.
+	highFeeRate := lowFeeRate * 4
+	ctx.estimator.blocksToFee[feePref.ConfTarget] = highFeeRate
+
+	// Once the next attempt is due, the input is swept again at the new
+	// fee estimate.
+	ctx.notifier.NotifyEpoch(1000)
+	ctx.tick()
+	highFeeTx := ctx.receiveTx()
+	assertTxFeeRate(t, &highFeeTx, highFeeRate, &input)
+
+	ctx.backend.mine()
+	ctx.expectResult(result, nil)
+
+	ctx.finish(1)
+}
+
 // TestExclusiveGroup tests the sweeper exclusive group functionality.
 func TestExclusiveGroup(t *testing.T) {
 	ctx := createSweeperTestContext(t)
@@ -1404,6 +1507,87 @@ var (
 	}
 )
 
+// TestSweepWithParent asserts that a tx sweeping an output of an unconfirmed
+// parent pays enough for the package of both txes to reach the target fee
+// rate.
+func TestSweepWithParent(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+
+	const target = chainfee.SatPerKWeight(5000)
+
+	parent := wire.NewMsgTx(2)
+	parent.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}},
+		Witness:          [][]byte{make([]byte, 72), make([]byte, 33)},
+	})
+	parent.AddTxOut(&wire.TxOut{
+		Value:    100000,
+		PkScript: make([]byte, 22),
+	})
+	parentWeight := blockchain.GetTransactionWeight(btcutil.NewTx(parent))
+
+	inp := input.MakeBaseInput(
+		&wire.OutPoint{Hash: parent.TxHash()},
+		input.CommitmentTimeLock,
+		&input.SignDescriptor{
+			Output: parent.TxOut[0],
+			KeyDesc: keychain.KeyDescriptor{
+				PubKey: testPubKey,
+			},
+		},
+		0, nil,
+	)
+
+	// childFee returns the fee and weight of a sweep tx.
+	childFee := func(tx *wire.MsgTx) (btcutil.Amount, int64) {
+		require.Len(t, tx.TxIn, 1)
+		require.Len(t, tx.TxOut, 1)
+
+		fee := btcutil.Amount(parent.TxOut[0].Value - tx.TxOut[0].Value)
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+
+		return fee, weight
+	}
+
+	// The parent pays 1 sat only, so the child has to pay for almost the
+	// whole package.
+	const lowParentFee = 1
+	tx, err := ctx.sweeper.SweepWithParent(
+		&inp, parent, lowParentFee, target, 100,
+	)
+	util.RequireNoErr(t, err)
+
+	fee, weight := childFee(tx)
+	packageFeeRate := chainfee.SatPerKWeight(
+		(fee + lowParentFee) * 1000 / btcutil.Amount(weight+parentWeight),
+	)
+	require.True(t, packageFeeRate >= target,
+		"package fee rate %v below target %v", packageFeeRate, target)
+	require.True(t, fee > target.FeeForWeight(weight),
+		"child doesn't pay for the parent")
+
+	// A parent paying more than the target on its own isn't paid for.
+	highParentFee := target.FeeForWeight(parentWeight) * 2
+	tx, err = ctx.sweeper.SweepWithParent(
+		&inp, parent, highParentFee, target, 100,
+	)
+	util.RequireNoErr(t, err)
+
+	fee, weight = childFee(tx)
+	require.True(t, fee >= target.FeeForWeight(weight))
+	require.True(t, fee < target.FeeForWeight(weight+parentWeight))
+
+	// The input must spend an output of the parent.
+	otherInput := createTestInput(100000, input.CommitmentTimeLock)
+	_, err = ctx.sweeper.SweepWithParent(
+		&otherInput, parent, lowParentFee, target, 100,
+	)
+	util.RequireErr(t, err)
+
+	// No tx should be published. This is asserted in finish().
+	ctx.finish(1)
+}
+
 // TestMergeClusters check that we properly can merge clusters together,
 // according to their required locktime.
 func TestMergeClusters(t *testing.T) {
@@ -2081,6 +2265,128 @@ func TestRequiredTxOuts(t *testing.T) {
 	}
 }
 
+// TestMaxConcurrentSweeps asserts that no more than the configured number of
+// sweep txes are in flight at once, that queued inputs are swept as soon as an
+// earlier sweep confirms and that forced inputs bypass the limit.
+func TestMaxConcurrentSweeps(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+	ctx.sweeper.cfg.MaxConcurrentSweeps = 1
+
+	resultChan0, err := ctx.sweeper.SweepInput(
+		spendableInputs[0], defaultFeePref,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.tick()
+
+	sweepTx0 := ctx.receiveTx()
+	assertTxSweepsInputs(t, &sweepTx0, spendableInputs[0])
+
+	// With one sweep in flight, the next input must remain queued.
+	resultChan1, err := ctx.sweeper.SweepInput(
+		spendableInputs[1], defaultFeePref,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.tick()
+
+	ctx.assertPendingInputs(spendableInputs[0], spendableInputs[1])
+	ctx.assertNoTx()
+
+	// Confirming the first sweep releases the queued input.
+	ctx.backend.mine()
+	ctx.expectResult(resultChan0, nil)
+
+	ctx.tick()
+
+	sweepTx1 := ctx.receiveTx()
+	assertTxSweepsInputs(t, &sweepTx1, spendableInputs[1])
+
+	// A forced input is swept even though the limit has been reached.
+	resultChan2, err := ctx.sweeper.SweepInput(
+		spendableInputs[2], Params{
+			Fee:   FeePreference{ConfTarget: 1},
+			Force: true,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.tick()
+
+	sweepTx2 := ctx.receiveTx()
+	assertTxSweepsInputs(t, &sweepTx2, spendableInputs[2])
+
+	ctx.backend.mine()
+	ctx.expectResult(resultChan1, nil)
+	ctx.expectResult(resultChan2, nil)
+
+	ctx.finish(1)
+}
+
+// TestMaxConcurrentSweepsResweep asserts that re-sweeps of an input that is
+// already in flight aren't throttled, so that a sweep tx that was dropped
+// from the mempool is republished and can still be fee bumped.
+func TestMaxConcurrentSweepsResweep(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+	ctx.sweeper.cfg.MaxConcurrentSweeps = 1
+	ctx.sweeper.cfg.MaxSweepAttempts = DefaultMaxSweepAttempts
+
+	lowFeePref := FeePreference{ConfTarget: 144}
+	lowFeeRate := chainfee.FeePerKwFloor
+	ctx.estimator.blocksToFee[lowFeePref.ConfTarget] = lowFeeRate
+
+	input := createTestInput(
+		btcutil.UnitsPerCoinI64(), input.CommitmentTimeLock,
+	)
+	sweepResult, err := ctx.sweeper.SweepInput(
+		&input, Params{Fee: lowFeePref},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.tick()
+	sweepTx := ctx.receiveTx()
+	assertTxFeeRate(t, &sweepTx, lowFeeRate, &input)
+
+	// Drop the sweep tx so that it can never confirm. The next block must
+	// trigger a republish even though the limit has been reached.
+	ctx.backend.deleteUnconfirmed(sweepTx.TxHash())
+	ctx.backend.notifier.NotifyEpoch(101)
+
+	ctx.tick()
+	sweepTx = ctx.receiveTx()
+	assertTxFeeRate(t, &sweepTx, lowFeeRate, &input)
+
+	// Bumping the fee must replace the republished tx right away.
+	highFeePref := FeePreference{ConfTarget: 6}
+	highFeeRate := DefaultMaxFeeRate
+	ctx.estimator.blocksToFee[highFeePref.ConfTarget] = highFeeRate
+
+	bumpResult, err := ctx.sweeper.UpdateParams(
+		*input.OutPoint(), ParamsUpdate{Fee: highFeePref},
+	)
+	if err != nil {
+		t.Fatalf("unable to bump input's fee: %v", err)
+	}
+
+	ctx.tick()
+	highFeeTx := ctx.receiveTx()
+	assertTxFeeRate(t, &highFeeTx, highFeeRate, &input)
+
+	ctx.backend.mine()
+	ctx.expectResult(sweepResult, nil)
+	ctx.expectResult(bumpResult, nil)
+
+	ctx.finish(1)
+}
+
 func TestMain(m *testing.M) {
 	globalcfg.SelectConfig(globalcfg.BitcoinDefaults())
 	os.Exit(m.Run())
@@ -8,6 +8,7 @@ import (
 	"github.com/kaotisk-hund/cjdcoind/blockchain"
 	"github.com/kaotisk-hund/cjdcoind/btcutil"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/chaincfg/chainhash"
 	"github.com/kaotisk-hund/cjdcoind/lnd/input"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet/chainfee"
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
@@ -33,6 +34,25 @@ type txInput interface {
 // on.
 type inputSet []input.Input
 
+// inputYield returns the yield of an input at the given fee rate, i.e. its
+// value minus the fee for its witness. The fee calculation excludes fee
+// components that are common to all inputs, as those wouldn't influence the
+// order of inputs sorted by yield.
+//
+// For witness size, the upper limit is taken. The actual size depends on the
+// signature length, which is not known yet at this point.
+func inputYield(inp input.Input, feePerKW chainfee.SatPerKWeight) (int64,
+	er.R) {
+
+	size, _, err := inp.WitnessType().SizeUpperBound()
+	if err != nil {
+		return 0, er.Errorf("failed adding input weight: %v", err)
+	}
+
+	return inp.SignDesc().Output.Value -
+		int64(feePerKW.FeeForWeight(int64(size))), nil
+}
+
 // generateInputPartitionings goes through all given inputs and constructs sets
 // of inputs that can be used to generate a sensible transaction. Each set
 // contains up to the configured maximum number of inputs. Negative yield
@@ -42,6 +62,20 @@ func generateInputPartitionings(sweepableInputs []txInput,
 	relayFeePerKW, feePerKW chainfee.SatPerKWeight,
 	maxInputsPerTx int, wallet Wallet) ([]inputSet, er.R) {
 
+	return planInputPartitionings(
+		sweepableInputs, relayFeePerKW, feePerKW, maxInputsPerTx,
+		wallet, nil,
+	)
+}
+
+// planInputPartitionings is generateInputPartitionings, additionally recording
+// the candidate sets, the yield of each input and the reason each input which
+// isn't part of a set was excluded in the given plan, if not nil.
+func planInputPartitionings(sweepableInputs []txInput,
+	relayFeePerKW, feePerKW chainfee.SatPerKWeight,
+	maxInputsPerTx int, wallet Wallet, plan *SweepPlan) ([]inputSet,
+	er.R) {
+
 	// Sort input by yield. We will start constructing input sets starting
 	// with the highest yield inputs. This is to prevent the construction
 	// of a set with an output below the dust limit, causing the sweep
@@ -50,22 +84,21 @@ func generateInputPartitionings(sweepableInputs []txInput,
 	// first input in this ordering is encountered with a negative yield.
 	//
 	// Yield is calculated as the difference between value and added fee
-	// for this input. The fee calculation excludes fee components that are
-	// common to all inputs, as those wouldn't influence the order. The
-	// single component that is differentiating is witness size.
-	//
-	// For witness size, the upper limit is taken. The actual size depends
-	// on the signature length, which is not known yet at this point.
+	// for this input, see inputYield.
 	yields := make(map[wire.OutPoint]int64)
 	for _, input := range sweepableInputs {
-		size, _, err := input.WitnessType().SizeUpperBound()
+		yield, err := inputYield(input, feePerKW)
 		if err != nil {
-			return nil, er.Errorf(
-				"failed adding input weight: %v", err)
+			return nil, err
 		}
 
-		yields[*input.OutPoint()] = input.SignDesc().Output.Value -
-			int64(feePerKW.FeeForWeight(int64(size)))
+		yields[*input.OutPoint()] = yield
+	}
+
+	if plan != nil {
+		for op, yield := range yields {
+			plan.Yields[op] = btcutil.Amount(yield)
+		}
 	}
 
 	sort.Slice(sweepableInputs, func(i, j int) bool {
@@ -99,6 +132,11 @@ func generateInputPartitionings(sweepableInputs []txInput,
 		// If there are no positive yield inputs, we can stop here.
 		inputCount := len(txInputs.inputs)
 		if inputCount == 0 {
+			if plan != nil {
+				plan.excludeRejected(
+					sweepableInputs, txInputs.dustLimit,
+				)
+			}
 			return sets, nil
 		}
 
@@ -117,6 +155,13 @@ func generateInputPartitionings(sweepableInputs []txInput,
 				"limit of %v", txInputs.totalOutput(),
 				txInputs.requiredOutput, txInputs.changeOutput,
 				txInputs.dustLimit)
+
+			if plan != nil {
+				for _, input := range sweepableInputs {
+					plan.Excluded[*input.OutPoint()] =
+						ExcludedSetBelowDust
+				}
+			}
 			return sets, nil
 		}
 
@@ -126,6 +171,16 @@ func generateInputPartitionings(sweepableInputs []txInput,
 			txInputs.totalOutput()-txInputs.walletInputTotal,
 			txInputs.weightEstimate(true).weight())
 
+		if plan != nil {
+			plan.InputSets = append(plan.InputSets, PlannedInputSet{
+				Inputs:          txInputs.inputs,
+				NumWalletInputs: len(txInputs.inputs) - inputCount,
+				Yield: txInputs.totalOutput() -
+					txInputs.walletInputTotal,
+				Weight: txInputs.weightEstimate(true).weight(),
+			})
+		}
+
 		sets = append(sets, txInputs.inputs)
 		sweepableInputs = sweepableInputs[inputCount:]
 	}
@@ -134,12 +189,26 @@ func generateInputPartitionings(sweepableInputs []txInput,
 }
 
 // createSweepTx builds a signed tx spending the inputs to a the output script.
+// If the tx has outputs committed to by the inputs, change below
+// minChangeAmount is added to the fee rather than creating a change output.
+//
+// Inputs committing to an output come first, each at the index of its output,
+// followed by the remaining inputs. If sortBIP69 is set, both groups of inputs
+// are sorted according to BIP 69 instead of keeping the order they were passed
+// in. As the change output is the only output which isn't committed to by an
+// input and comes last, the outputs are in BIP 69 order only if there are no
+// committed outputs.
 func createSweepTx(inputs []input.Input, outputPkScript []byte,
 	currentBlockHeight uint32, feePerKw chainfee.SatPerKWeight,
-	dustLimit btcutil.Amount, signer input.Signer) (*wire.MsgTx, er.R) {
+	dustLimit, minChangeAmount btcutil.Amount, sortBIP69 bool,
+	signer input.Signer) (*wire.MsgTx, er.R) {
 
 	inputs, estimator := getWeightEstimate(inputs, feePerKw)
 
+	if sortBIP69 {
+		sortInputsBIP69(inputs)
+	}
+
 	txFee := estimator.fee()
 
 	// Create the sweep transaction that we will be building. We use
@@ -155,12 +224,16 @@ func createSweepTx(inputs []input.Input, outputPkScript []byte,
 	var (
 		totalInput     btcutil.Amount
 		requiredOutput btcutil.Amount
+
+		// txInputs are the inputs in the order of the tx inputs.
+		txInputs = make([]input.Input, 0, len(inputs))
 	)
 	for _, o := range inputs {
 		if o.RequiredTxOut() == nil {
 			continue
 		}
 
+		txInputs = append(txInputs, o)
 		sweepTx.AddTxIn(&wire.TxIn{
 			PreviousOutPoint: *o.OutPoint(),
 			Sequence:         o.BlocksToMaturity(),
@@ -188,6 +261,7 @@ func createSweepTx(inputs []input.Input, outputPkScript []byte,
 			continue
 		}
 
+		txInputs = append(txInputs, o)
 		sweepTx.AddTxIn(&wire.TxIn{
 			PreviousOutPoint: *o.OutPoint(),
 			Sequence:         o.BlocksToMaturity(),
@@ -209,9 +283,14 @@ func createSweepTx(inputs []input.Input, outputPkScript []byte,
 	// sweep tx has a change output.
 	changeAmt := totalInput - requiredOutput - txFee
 
+	// Change below the minimum change amount is only added to the fee if
+	// there are required outputs, as the tx would have no outputs at all
+	// otherwise.
+	keepChange := changeAmt >= minChangeAmount || len(sweepTx.TxOut) == 0
+
 	// The txn will sweep the amount after fees to the pkscript generated
 	// above.
-	if changeAmt >= dustLimit {
+	if changeAmt >= dustLimit && keepChange {
 		sweepTx.AddTxOut(&wire.TxOut{
 			PkScript: outputPkScript,
 			Value:    int64(changeAmt),
@@ -225,6 +304,12 @@ func createSweepTx(inputs []input.Input, outputPkScript []byte,
 		sweepTx.LockTime = uint32(locktime)
 	}
 
+	// The inputs were added in two separate passes above, so make sure
+	// that none of them got lost along the way before we go ahead and sign.
+	if err := checkSweepTxInputs(sweepTx, inputs); err != nil {
+		return nil, err
+	}
+
 	// Before signing the transaction, check to ensure that it meets some
 	// basic validity requirements.
 	//
@@ -258,8 +343,10 @@ func createSweepTx(inputs []input.Input, outputPkScript []byte,
 	}
 
 	// Finally we'll attach a valid input script to each csv and cltv input
-	// within the sweeping transaction.
-	for i, input := range inputs {
+	// within the sweeping transaction. The inputs are signed in the order
+	// they were added to the tx, which differs from the order they were
+	// passed in if some commit to an output.
+	for i, input := range txInputs {
 		if err := addInputScript(i, input); err != nil {
 			return nil, err
 		}
@@ -278,6 +365,84 @@ func createSweepTx(inputs []input.Input, outputPkScript []byte,
 	return sweepTx, nil
 }
 
+// sortInputsBIP69 sorts the inputs by their outpoints according to BIP 69,
+// i.e. by the byte-reversed hash of the previous tx and then by output index.
+func sortInputsBIP69(inputs []input.Input) {
+	sort.SliceStable(inputs, func(i, j int) bool {
+		iOp, jOp := inputs[i].OutPoint(), inputs[j].OutPoint()
+		if iOp.Hash == jOp.Hash {
+			return iOp.Index < jOp.Index
+		}
+
+		// Compare the hashes in reversed byte order, as they are
+		// displayed.
+		for b := chainhash.HashSize - 1; b >= 0; b-- {
+			if iOp.Hash[b] != jOp.Hash[b] {
+				return iOp.Hash[b] < jOp.Hash[b]
+			}
+		}
+		return false
+	})
+}
+
+// checkSweepTxInputs asserts that the sweep tx spends exactly the given set of
+// inputs. Since inputs that commit to an output and the remaining inputs are
+// added to the tx separately, an input that is missed by both passes would
+// otherwise go unnoticed.
+func checkSweepTxInputs(sweepTx *wire.MsgTx, inputs []input.Input) er.R {
+	if len(sweepTx.TxIn) != len(inputs) {
+		return er.Errorf("sweep tx has %v inputs, expected %v",
+			len(sweepTx.TxIn), len(inputs))
+	}
+
+	spent := make(map[wire.OutPoint]struct{}, len(sweepTx.TxIn))
+	for _, txIn := range sweepTx.TxIn {
+		spent[txIn.PreviousOutPoint] = struct{}{}
+	}
+
+	for _, inp := range inputs {
+		if _, ok := spent[*inp.OutPoint()]; !ok {
+			return er.Errorf("sweep tx does not spend input %v",
+				*inp.OutPoint())
+		}
+	}
+
+	return nil
+}
+
+// TxFeeRate returns the fee rate of an already built transaction, given the
+// values of the outputs spent by each of its inputs. The fee rate is computed
+// over the weight of the tx, so that the witness discount is accounted for.
+func TxFeeRate(tx *wire.MsgTx, inputValues []btcutil.Amount) (
+	chainfee.SatPerKWeight, er.R) {
+
+	if len(inputValues) != len(tx.TxIn) {
+		return 0, er.Errorf("tx has %v inputs, but %v input values "+
+			"given", len(tx.TxIn), len(inputValues))
+	}
+
+	var totalIn, totalOut btcutil.Amount
+	for _, value := range inputValues {
+		totalIn += value
+	}
+	for _, txOut := range tx.TxOut {
+		totalOut += btcutil.Amount(txOut.Value)
+	}
+
+	fee := totalIn - totalOut
+	if fee < 0 {
+		return 0, er.Errorf("tx outputs (%v) exceed inputs (%v)",
+			totalOut, totalIn)
+	}
+
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	if weight == 0 {
+		return 0, er.New("tx has zero weight")
+	}
+
+	return chainfee.SatPerKWeight(int64(fee) * 1000 / weight), nil
+}
+
 // getWeightEstimate returns a weight estimate for the given inputs.
 // Additionally, it returns counts for the number of csv and cltv inputs.
 func getWeightEstimate(inputs []input.Input, feeRate chainfee.SatPerKWeight) (
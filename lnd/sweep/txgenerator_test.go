@@ -3,9 +3,16 @@ package sweep
 import (
 	"testing"
 
+	"github.com/kaotisk-hund/cjdcoind/btcutil"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/txsort"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
 	"github.com/kaotisk-hund/cjdcoind/chaincfg/chainhash"
-	"github.com/kaotisk-hund/cjdcoind/wire"
 	"github.com/kaotisk-hund/cjdcoind/lnd/input"
+	"github.com/kaotisk-hund/cjdcoind/lnd/keychain"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet/chainfee"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lntest/mock"
+	"github.com/kaotisk-hund/cjdcoind/wire"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -51,3 +58,238 @@ func TestWeightEstimate(t *testing.T) {
 			expectedSummary, summary)
 	}
 }
+
+// TestCreateSweepTxSpendsAllInputs tests that a sweep tx created from a mix of
+// inputs with and without a required output spends every one of them, and
+// that a tx missing one of the inputs is detected.
+func TestCreateSweepTxSpendsAllInputs(t *testing.T) {
+	t.Parallel()
+
+	inputs := []input.Input{
+		createP2WKHInput(10000),
+		&reqInput{
+			Input: createP2WKHInput(20000),
+			txOut: &wire.TxOut{
+				Value:    15000,
+				PkScript: make([]byte, 22),
+			},
+		},
+		createP2WKHInput(30000),
+		&reqInput{
+			Input: createP2WKHInput(40000),
+			txOut: &wire.TxOut{
+				Value:    35000,
+				PkScript: make([]byte, 22),
+			},
+		},
+	}
+
+	sweepTx, err := createSweepTx(
+		inputs, make([]byte, 22), 100, 1000, 500, 0, false,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	util.RequireNoErr(t, checkSweepTxInputs(sweepTx, inputs))
+
+	// The inputs committing to an output must come first, followed by
+	// the remaining ones, each group in the order it was passed in.
+	require.Equal(t, *inputs[1].OutPoint(), sweepTx.TxIn[0].PreviousOutPoint)
+	require.Equal(t, *inputs[3].OutPoint(), sweepTx.TxIn[1].PreviousOutPoint)
+	require.Equal(t, *inputs[0].OutPoint(), sweepTx.TxIn[2].PreviousOutPoint)
+	require.Equal(t, *inputs[2].OutPoint(), sweepTx.TxIn[3].PreviousOutPoint)
+
+	// Passing the inputs in reverse changes the order within each group.
+	reversed := []input.Input{inputs[3], inputs[2], inputs[1], inputs[0]}
+	reversedTx, err := createSweepTx(
+		reversed, make([]byte, 22), 100, 1000, 500, 0, false,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	require.Equal(t, *inputs[3].OutPoint(), reversedTx.TxIn[0].PreviousOutPoint)
+	require.Equal(t, *inputs[1].OutPoint(), reversedTx.TxIn[1].PreviousOutPoint)
+	require.Equal(t, *inputs[2].OutPoint(), reversedTx.TxIn[2].PreviousOutPoint)
+	require.Equal(t, *inputs[0].OutPoint(), reversedTx.TxIn[3].PreviousOutPoint)
+
+	// With BIP 69 ordering, the order the inputs are passed in no longer
+	// matters, while the committed pairs are still kept together.
+	sortedTx, err := createSweepTx(
+		inputs, make([]byte, 22), 100, 1000, 500, 0, true,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	util.RequireNoErr(t, checkSweepTxInputs(sortedTx, inputs))
+	reversedTx, err = createSweepTx(
+		reversed, make([]byte, 22), 100, 1000, 500, 0, true,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	require.Equal(t, sortedTx.TxHash(), reversedTx.TxHash())
+	for i := 0; i < 2; i++ {
+		require.Equal(t, int64(15000+20000*i), sortedTx.TxOut[i].Value)
+	}
+
+	// Dropping an input from the tx must be reported.
+	sweepTx.TxIn = sweepTx.TxIn[:len(sweepTx.TxIn)-1]
+	util.RequireErr(t, checkSweepTxInputs(sweepTx, inputs))
+
+	// As must replacing one with an input we didn't intend to spend.
+	sweepTx.TxIn = append(sweepTx.TxIn, &wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 99},
+	})
+	util.RequireErr(t, checkSweepTxInputs(sweepTx, inputs))
+}
+
+// TestCreateSweepTxBIP69 asserts that with BIP 69 ordering, the inputs of a
+// sweep tx are sorted by the byte-reversed hash and the index of their
+// outpoints.
+func TestCreateSweepTxBIP69(t *testing.T) {
+	t.Parallel()
+
+	// The hashes are chosen such that their natural byte order differs
+	// from their byte-reversed order.
+	hashA := chainhash.Hash{0xff, 0x00}
+	hashA[chainhash.HashSize-1] = 0x01
+	hashB := chainhash.Hash{0x00, 0xff}
+	hashB[chainhash.HashSize-1] = 0x02
+
+	newInput := func(hash chainhash.Hash, index uint32) input.Input {
+		return input.NewBaseInput(
+			&wire.OutPoint{Hash: hash, Index: index},
+			input.WitnessKeyHash,
+			&input.SignDescriptor{
+				Output: &wire.TxOut{Value: 10000},
+				KeyDesc: keychain.KeyDescriptor{
+					PubKey: testPubKey,
+				},
+			}, 0,
+		)
+	}
+	inputs := []input.Input{
+		newInput(hashB, 0),
+		newInput(hashA, 3),
+		newInput(hashB, 1),
+		newInput(hashA, 0),
+	}
+	expectedOrder := []wire.OutPoint{
+		{Hash: hashA, Index: 0},
+		{Hash: hashA, Index: 3},
+		{Hash: hashB, Index: 0},
+		{Hash: hashB, Index: 1},
+	}
+
+	sweepTx, err := createSweepTx(
+		inputs, make([]byte, 22), 100, 1000, 500, 0, true,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	util.RequireNoErr(t, checkSweepTxInputs(sweepTx, inputs))
+
+	require.Len(t, sweepTx.TxIn, len(expectedOrder))
+	for i, op := range expectedOrder {
+		require.Equal(t, op, sweepTx.TxIn[i].PreviousOutPoint)
+	}
+	require.True(t, txsort.IsSorted(sweepTx))
+
+	// The same inputs are left in the order they're passed in without
+	// BIP 69 ordering.
+	sweepTx, err = createSweepTx(
+		inputs, make([]byte, 22), 100, 1000, 500, 0, false,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	for i, inp := range inputs {
+		require.Equal(t, *inp.OutPoint(), sweepTx.TxIn[i].PreviousOutPoint)
+	}
+	require.False(t, txsort.IsSorted(sweepTx))
+}
+
+// TestCreateSweepTxMinChangeAmount asserts that change between the dust limit
+// and the minimum change amount is added to the fee if the tx has a required
+// output, but not if the change output is the only output.
+func TestCreateSweepTxMinChangeAmount(t *testing.T) {
+	t.Parallel()
+
+	const (
+		feeRate   = 1000
+		dustLimit = 500
+	)
+
+	inputs := []input.Input{
+		&reqInput{
+			Input: createP2WKHInput(20000),
+			txOut: &wire.TxOut{
+				Value:    15000,
+				PkScript: make([]byte, 22),
+			},
+		},
+		createP2WKHInput(3000),
+	}
+
+	// Without a minimum, the change is just above the dust limit and
+	// gets its own output.
+	sweepTx, err := createSweepTx(
+		inputs, make([]byte, 22), 100, feeRate, dustLimit, 0, false,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	require.Len(t, sweepTx.TxOut, 2)
+	change := btcutil.Amount(sweepTx.TxOut[1].Value)
+	require.True(t, change >= dustLimit)
+
+	// A minimum at the change amount still keeps the change output.
+	sweepTx, err = createSweepTx(
+		inputs, make([]byte, 22), 100, feeRate, dustLimit, change, false,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	require.Len(t, sweepTx.TxOut, 2)
+
+	// A minimum above the change amount drops the change output, while
+	// all inputs are still spent.
+	sweepTx, err = createSweepTx(
+		inputs, make([]byte, 22), 100, feeRate, dustLimit, change+1, false,
+		&mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	require.Len(t, sweepTx.TxOut, 1)
+	require.Equal(t, int64(15000), sweepTx.TxOut[0].Value)
+	util.RequireNoErr(t, checkSweepTxInputs(sweepTx, inputs))
+
+	// Without a required output, the sweep output is kept regardless of
+	// the minimum.
+	sweepTx, err = createSweepTx(
+		inputs[1:], make([]byte, 22), 100, feeRate, dustLimit,
+		btcutil.UnitsPerCoin(), false, &mock.DummySigner{},
+	)
+	util.RequireNoErr(t, err)
+	require.Len(t, sweepTx.TxOut, 1)
+}
+
+// TestTxFeeRate asserts that the fee rate of a segwit tx is computed over its
+// weight, taking the witness discount into account.
+func TestTxFeeRate(t *testing.T) {
+	t.Parallel()
+
+	// A p2wkh spend with a single p2wkh output. Its stripped size is 82
+	// bytes and its witness adds another 110 bytes, for a weight of
+	// 82*3 + 192 = 438.
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}},
+		Witness:          [][]byte{make([]byte, 72), make([]byte, 33)},
+	})
+	tx.AddTxOut(&wire.TxOut{Value: 100000, PkScript: make([]byte, 22)})
+
+	// A fee of 876 sat over 438 weight units is 2000 sat/kw.
+	feeRate, err := TxFeeRate(tx, []btcutil.Amount{100876})
+	util.RequireNoErr(t, err)
+	require.Equal(t, chainfee.SatPerKWeight(2000), feeRate)
+
+	// The number of input values must match the number of inputs.
+	_, err = TxFeeRate(tx, nil)
+	util.RequireErr(t, err)
+
+	// Outputs can't exceed the inputs.
+	_, err = TxFeeRate(tx, []btcutil.Amount{99999})
+	util.RequireErr(t, err)
+}
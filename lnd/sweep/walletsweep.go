@@ -277,7 +277,7 @@ func CraftSweepAllTx(feeRate chainfee.SatPerKWeight, dustLimit btcutil.Amount,
 	// respects our fee preference and targets all the UTXOs of the wallet.
 	sweepTx, err := createSweepTx(
 		inputsToSweep, deliveryPkScript, blockHeight, feeRate,
-		dustLimit, signer,
+		dustLimit, 0, false, signer,
 	)
 	if err != nil {
 		unlockOutputs()
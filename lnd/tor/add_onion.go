@@ -3,7 +3,12 @@ package tor
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 )
@@ -80,6 +85,76 @@ func (f *OnionFile) DeletePrivateKey(_ OnionType) er.R {
 	return er.E(os.Remove(f.privateKeyPath))
 }
 
+// onionPrivateKeyPerm is the permission of the files onion service private
+// keys are saved to, which must only be readable by their owner.
+const onionPrivateKeyPerm os.FileMode = 0600
+
+// SaveOnionPrivateKey saves an onion service private key to the file at the
+// given path, so that the service can be recreated with the same onion address
+// later on. The file is replaced atomically and is only accessible by its
+// owner, even if it already existed with looser permissions.
+func SaveOnionPrivateKey(path string, key []byte) er.R {
+	if len(key) == 0 {
+		return er.New("private key must not be empty")
+	}
+
+	// The temporary file is created in the same directory so that it can be
+	// renamed over the destination, and is only accessible by its owner.
+	tempFile, errr := ioutil.TempFile(
+		filepath.Dir(path), filepath.Base(path)+".tmp",
+	)
+	if errr != nil {
+		return er.E(errr)
+	}
+	tempPath := tempFile.Name()
+
+	if errr := writeOnionPrivateKey(tempFile, key); errr != nil {
+		os.Remove(tempPath)
+		return er.E(errr)
+	}
+
+	if errr := os.Rename(tempPath, path); errr != nil {
+		os.Remove(tempPath)
+		return er.E(errr)
+	}
+
+	return nil
+}
+
+// writeOnionPrivateKey writes the key to the file, syncs and closes it.
+func writeOnionPrivateKey(f *os.File, key []byte) error {
+	if err := f.Chmod(onionPrivateKeyPerm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadOnionPrivateKey loads an onion service private key saved by
+// SaveOnionPrivateKey. If the file does not exist, then ErrNoPrivateKey is
+// returned.
+func LoadOnionPrivateKey(path string) ([]byte, er.R) {
+	key, errr := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(errr):
+		return nil, ErrNoPrivateKey.Default()
+	case errr != nil:
+		return nil, er.E(errr)
+	case len(key) == 0:
+		return nil, er.Errorf("private key file %v is empty", path)
+	}
+
+	return key, nil
+}
+
 // AddOnionConfig houses all of the required parameters in order to successfully
 // create a new onion service or restore an existing one.
 type AddOnionConfig struct {
@@ -150,15 +225,12 @@ func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, er.R) {
 	// to provide a one-to-one mapping.
 	var portParam string
 
-	// Helper function which appends the correct Port param depending on
-	// whether the user chose to use a custom target IP address or not.
+	// Helper function which appends the correct Port params depending on
+	// whether the user chose to use custom target IP addresses or not.
 	pushPortParam := func(targetPort int) {
-		if c.targetIPAddress == "" {
-			portParam += fmt.Sprintf("Port=%d,%d ", cfg.VirtualPort,
-				targetPort)
-		} else {
-			portParam += fmt.Sprintf("Port=%d,%s:%d ", cfg.VirtualPort,
-				c.targetIPAddress, targetPort)
+		for _, target := range c.portTargets(strconv.Itoa(targetPort)) {
+			portParam += fmt.Sprintf("Port=%d,%s ", cfg.VirtualPort,
+				target)
 		}
 	}
 
@@ -217,3 +289,162 @@ func (c *Controller) AddOnion(cfg AddOnionConfig) (*OnionAddr, er.R) {
 		Port:         cfg.VirtualPort,
 	}, nil
 }
+
+// v3KeyType is the key type prefix Tor uses for v3 onion service private keys.
+const v3KeyType = "ED25519-V3"
+
+// targetParams returns the targets of the ADD_ONION Port parameters of a
+// virtual port. Targets which are a bare port are mapped onto each target IP
+// address the controller was configured with, so that a dual-stack host is
+// reachable over both IPv4 and IPv6. Targets that already contain a host are
+// used as is.
+func (c *Controller) targetParams(target string) ([]string, er.R) {
+	if strings.Contains(target, ":") {
+		return []string{target}, nil
+	}
+
+	if _, err := strconv.Atoi(target); err != nil {
+		return nil, er.Errorf("invalid target port %q", target)
+	}
+
+	return c.portTargets(target), nil
+}
+
+// portTargets maps a local port onto each target IP address of the controller,
+// or returns it as is if there are none.
+func (c *Controller) portTargets(port string) []string {
+	hosts := c.targetHosts()
+	if len(hosts) == 0 {
+		return []string{port}
+	}
+
+	// IPv6 addresses must be bracketed to be told apart from the port.
+	targets := make([]string, len(hosts))
+	for i, host := range hosts {
+		targets[i] = net.JoinHostPort(host, port)
+	}
+
+	return targets
+}
+
+// AddOnionV3 creates a v3 onion service which forwards traffic from each
+// virtual port in the ports map to its target. A target is either a local port
+// or a host:port pair. privKey is the key of the service in the format Tor
+// returns it, "ED25519-V3:<base64 blob>" with the type prefix being optional.
+// If it's empty, a new key is generated by the Tor server and returned, so the
+// service can be recreated later on. The service ID, which is the onion
+// address without the .onion suffix, is returned on success.
+//
+// Just like with AddOnion, the onion service remains active until the
+// connection between the controller and the Tor server is closed or DelOnion
+// is called.
+func (c *Controller) AddOnionV3(ports map[int]string,
+	privKey []byte) (string, []byte, er.R) {
+
+	if err := supportsV3(c.version); err != nil {
+		return "", nil, err
+	}
+
+	if len(ports) == 0 {
+		return "", nil, er.New("at least one port mapping is required")
+	}
+
+	keyParam := "NEW:" + v3KeyType
+	if len(privKey) > 0 {
+		keyParam = string(privKey)
+		if !strings.HasPrefix(keyParam, v3KeyType+":") {
+			keyParam = v3KeyType + ":" + keyParam
+		}
+	}
+
+	// Sort the virtual ports so that the command is deterministic.
+	virtualPorts := make([]int, 0, len(ports))
+	for virtualPort := range ports {
+		virtualPorts = append(virtualPorts, virtualPort)
+	}
+	sort.Ints(virtualPorts)
+
+	portParams := make([]string, 0, len(ports))
+	for _, virtualPort := range virtualPorts {
+		targets, err := c.targetParams(ports[virtualPort])
+		if err != nil {
+			return "", nil, err
+		}
+		for _, target := range targets {
+			portParams = append(portParams, fmt.Sprintf("Port=%d,%s",
+				virtualPort, target))
+		}
+	}
+
+	cmd := fmt.Sprintf("ADD_ONION %s %s", keyParam,
+		strings.Join(portParams, " "))
+	_, reply, err := c.sendCommand(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The reply has the same format as the one described in AddOnion.
+	replyParams := parseTorReply(reply)
+	serviceID, ok := replyParams["ServiceID"]
+	if !ok {
+		return "", nil, er.New("service id not found in reply")
+	}
+
+	// A private key is only returned if we asked for a new one.
+	if len(privKey) > 0 {
+		return serviceID, privKey, nil
+	}
+
+	newPrivKey, ok := replyParams["PrivateKey"]
+	if !ok {
+		return "", nil, er.New("private key not found in reply")
+	}
+
+	return serviceID, []byte(newPrivKey), nil
+}
+
+// AddOnionV3WithKeyFile creates a v3 onion service just like AddOnionV3, using
+// the private key saved at keyPath so that the service keeps its onion address
+// across restarts. If there's no key file yet, the Tor server generates a new
+// key which is saved to keyPath before returning. The service ID is returned on
+// success.
+func (c *Controller) AddOnionV3WithKeyFile(ports map[int]string,
+	keyPath string) (string, er.R) {
+
+	privKey, err := LoadOnionPrivateKey(keyPath)
+	if err != nil && !ErrNoPrivateKey.Is(err) {
+		return "", err
+	}
+
+	serviceID, newPrivKey, err := c.AddOnionV3(ports, privKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Only a newly generated key needs to be saved.
+	if len(privKey) > 0 {
+		return serviceID, nil
+	}
+
+	if err := SaveOnionPrivateKey(keyPath, newPrivKey); err != nil {
+		// Without its key the service couldn't be recreated, so we
+		// don't leave it running.
+		_ = c.DelOnion(serviceID)
+		return "", er.Errorf("unable to save private key: %v", err)
+	}
+
+	return serviceID, nil
+}
+
+// DelOnion removes an onion service which was created by this controller
+// given its service ID, with or without the .onion suffix.
+func (c *Controller) DelOnion(serviceID string) er.R {
+	serviceID = strings.TrimSuffix(serviceID, ".onion")
+	if serviceID == "" {
+		return er.New("service id must not be empty")
+	}
+
+	cmd := fmt.Sprintf("DEL_ONION %s", serviceID)
+	_, _, err := c.sendCommand(cmd)
+	return err
+}
@@ -2,8 +2,14 @@ package tor
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
 )
 
@@ -49,3 +55,484 @@ func TestOnionFile(t *testing.T) {
 		t.Fatal("found deleted private key")
 	}
 }
+
+// mockTorExchange is a single command the mock Tor server expects to receive,
+// along with the lines it replies with.
+type mockTorExchange struct {
+	command string
+	reply   []string
+}
+
+// newMockTorConn returns a connection to a mock Tor server which expects to
+// receive the given commands in order. Any error of the mock server is
+// delivered on the returned channel, which is closed once all the exchanges are
+// done.
+func newMockTorConn(t *testing.T,
+	exchanges []mockTorExchange) (*textproto.Conn, <-chan error) {
+
+	clientConn, serverConn := net.Pipe()
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+
+		if err := serveMockTor(serverConn, exchanges); err != nil {
+			errChan <- err
+		}
+	}()
+
+	conn := textproto.NewConn(clientConn)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return conn, errChan
+}
+
+// serveMockTor acts as a Tor server on the given connection, which expects to
+// receive the given commands in order. The connection is closed once all the
+// exchanges are done, or an error occurs.
+func serveMockTor(serverConn net.Conn, exchanges []mockTorExchange) error {
+	server := textproto.NewConn(serverConn)
+	defer server.Close()
+
+	for _, exchange := range exchanges {
+		command, err := server.ReadLine()
+		if err != nil {
+			return err
+		}
+		if command != exchange.command {
+			return fmt.Errorf("expected command %q, got %q",
+				exchange.command, command)
+		}
+		for _, line := range exchange.reply {
+			if err := server.PrintfLine(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// newMockTorController returns a controller connected to a mock Tor server, see
+// newMockTorConn.
+func newMockTorController(t *testing.T, targetIPAddress string,
+	exchanges []mockTorExchange) (*Controller, <-chan error) {
+
+	conn, errChan := newMockTorConn(t, exchanges)
+
+	c := NewController("", targetIPAddress, "")
+	c.conn = conn
+	c.version = MinTorVersion
+
+	return c, errChan
+}
+
+// TestAddOnionV3 tests that AddOnionV3 and DelOnion issue the expected
+// commands and parse the replies of the Tor server.
+func TestAddOnionV3(t *testing.T) {
+	t.Parallel()
+
+	const (
+		serviceID = "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd"
+		privKey   = "ED25519-V3:oOuIsDXfFbpk1Aq0gq8GN0FgH6Ut3LEjhyIhbbT3klk"
+	)
+
+	tests := []struct {
+		name            string
+		targetIPAddress string
+		ports           map[int]string
+		privKey         []byte
+		exchanges       []mockTorExchange
+	}{
+		{
+			name: "new key",
+			ports: map[int]string{
+				9735: "9736",
+				80:   "8080",
+			},
+			exchanges: []mockTorExchange{{
+				command: "ADD_ONION NEW:ED25519-V3 " +
+					"Port=80,8080 Port=9735,9736",
+				reply: []string{
+					"250-ServiceID=" + serviceID,
+					"250-PrivateKey=" + privKey,
+					"250 OK",
+				},
+			}},
+		},
+		{
+			name:            "existing key with target ip",
+			targetIPAddress: "10.0.0.2",
+			ports: map[int]string{
+				9735: "9735",
+				80:   "127.0.0.1:8080",
+			},
+			privKey: []byte(privKey),
+			exchanges: []mockTorExchange{{
+				command: "ADD_ONION " + privKey + " " +
+					"Port=80,127.0.0.1:8080 " +
+					"Port=9735,10.0.0.2:9735",
+				reply: []string{
+					"250-ServiceID=" + serviceID,
+					"250 OK",
+				},
+			}},
+		},
+		{
+			name: "existing key without prefix",
+			ports: map[int]string{
+				9735: "9735",
+			},
+			privKey: []byte(privKey[len(v3KeyType)+1:]),
+			exchanges: []mockTorExchange{{
+				command: "ADD_ONION " + privKey + " " +
+					"Port=9735,9735",
+				reply: []string{
+					"250-ServiceID=" + serviceID,
+					"250 OK",
+				},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			exchanges := append(test.exchanges, mockTorExchange{
+				command: "DEL_ONION " + serviceID,
+				reply:   []string{"250 OK"},
+			})
+			c, errChan := newMockTorController(
+				t, test.targetIPAddress, exchanges,
+			)
+
+			id, key, err := c.AddOnionV3(test.ports, test.privKey)
+			if err != nil {
+				t.Fatalf("unable to add onion: %v", err)
+			}
+			if id != serviceID {
+				t.Fatalf("expected service id %v, got %v",
+					serviceID, id)
+			}
+			expectedKey := test.privKey
+			if expectedKey == nil {
+				expectedKey = []byte(privKey)
+			}
+			if !bytes.Equal(key, expectedKey) {
+				t.Fatalf("expected private key %s, got %s",
+					expectedKey, key)
+			}
+
+			if err := c.DelOnion(id + ".onion"); err != nil {
+				t.Fatalf("unable to delete onion: %v", err)
+			}
+
+			if err := <-errChan; err != nil {
+				t.Fatalf("mock tor server failed: %v", err)
+			}
+		})
+	}
+}
+
+// TestAddOnionV3Errors tests that invalid arguments and error replies of the
+// Tor server are reported by AddOnionV3.
+func TestAddOnionV3Errors(t *testing.T) {
+	t.Parallel()
+
+	c, errChan := newMockTorController(t, "", []mockTorExchange{{
+		command: "ADD_ONION NEW:ED25519-V3 Port=9735,9735",
+		reply:   []string{"512 Bad arguments to ADD_ONION"},
+	}})
+
+	if _, _, err := c.AddOnionV3(nil, nil); err == nil {
+		t.Fatal("expected error without port mappings")
+	}
+	_, _, err := c.AddOnionV3(map[int]string{9735: "port"}, nil)
+	if err == nil {
+		t.Fatal("expected error for invalid target port")
+	}
+	_, _, err = c.AddOnionV3(map[int]string{9735: "9735"}, nil)
+	if err == nil {
+		t.Fatal("expected error reply from tor server")
+	}
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("mock tor server failed: %v", err)
+	}
+}
+
+// TestOnionPrivateKeyRoundTrip tests that a private key saved with
+// SaveOnionPrivateKey is loaded back by LoadOnionPrivateKey.
+func TestOnionPrivateKeyRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tempDir, errr := ioutil.TempDir("", "onion_key")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	keyPath := filepath.Join(tempDir, "v3_onion_private_key")
+
+	if _, err := LoadOnionPrivateKey(keyPath); !ErrNoPrivateKey.Is(err) {
+		t.Fatalf("expected ErrNoPrivateKey, got \"%v\"", err)
+	}
+
+	// Saving a key twice must replace the first one.
+	for _, key := range []string{"ED25519-V3:first", "ED25519-V3:second"} {
+		if err := SaveOnionPrivateKey(keyPath, []byte(key)); err != nil {
+			t.Fatalf("unable to save private key: %v", err)
+		}
+		loadedKey, err := LoadOnionPrivateKey(keyPath)
+		if err != nil {
+			t.Fatalf("unable to load private key: %v", err)
+		}
+		if string(loadedKey) != key {
+			t.Fatalf("expected private key %v, got %s", key,
+				loadedKey)
+		}
+	}
+
+	if err := SaveOnionPrivateKey(keyPath, nil); err == nil {
+		t.Fatal("expected error saving an empty private key")
+	}
+
+	// No temporary file must be left behind.
+	files, errr := ioutil.ReadDir(tempDir)
+	if errr != nil {
+		t.Fatalf("unable to read temp dir: %v", errr)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected a single file, got %d", len(files))
+	}
+}
+
+// TestOnionPrivateKeyPermissions tests that private keys are only accessible by
+// their owner, including when replacing a file with looser permissions.
+func TestOnionPrivateKeyPermissions(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("unix file permissions aren't supported on windows")
+	}
+
+	tempDir, errr := ioutil.TempDir("", "onion_key")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	assertPerm := func(path string) {
+		t.Helper()
+
+		info, errr := os.Stat(path)
+		if errr != nil {
+			t.Fatalf("unable to stat private key: %v", errr)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("expected permissions 0600, got %v",
+				info.Mode().Perm())
+		}
+	}
+
+	newKeyPath := filepath.Join(tempDir, "new")
+	if err := SaveOnionPrivateKey(newKeyPath, []byte("key")); err != nil {
+		t.Fatalf("unable to save private key: %v", err)
+	}
+	assertPerm(newKeyPath)
+
+	existingKeyPath := filepath.Join(tempDir, "existing")
+	errr = ioutil.WriteFile(existingKeyPath, []byte("old"), 0644)
+	if errr != nil {
+		t.Fatalf("unable to write file: %v", errr)
+	}
+	if err := SaveOnionPrivateKey(existingKeyPath, []byte("key")); err != nil {
+		t.Fatalf("unable to save private key: %v", err)
+	}
+	assertPerm(existingKeyPath)
+}
+
+// TestAddOnionV3WithKeyFile tests that AddOnionV3WithKeyFile saves a newly
+// generated private key, and reuses it once it exists.
+func TestAddOnionV3WithKeyFile(t *testing.T) {
+	t.Parallel()
+
+	const (
+		serviceID = "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd"
+		privKey   = "ED25519-V3:oOuIsDXfFbpk1Aq0gq8GN0FgH6Ut3LEjhyIhbbT3klk"
+	)
+
+	tempDir, errr := ioutil.TempDir("", "onion_key")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	keyPath := filepath.Join(tempDir, "v3_onion_private_key")
+	ports := map[int]string{9735: "9735"}
+
+	c, errChan := newMockTorController(t, "", []mockTorExchange{
+		{
+			command: "ADD_ONION NEW:ED25519-V3 Port=9735,9735",
+			reply: []string{
+				"250-ServiceID=" + serviceID,
+				"250-PrivateKey=" + privKey,
+				"250 OK",
+			},
+		},
+		{
+			command: "ADD_ONION " + privKey + " Port=9735,9735",
+			reply: []string{
+				"250-ServiceID=" + serviceID,
+				"250 OK",
+			},
+		},
+	})
+
+	// The first service is created with a new key, which must be saved and
+	// then reused for the second one.
+	for i := 0; i < 2; i++ {
+		id, err := c.AddOnionV3WithKeyFile(ports, keyPath)
+		if err != nil {
+			t.Fatalf("unable to add onion: %v", err)
+		}
+		if id != serviceID {
+			t.Fatalf("expected service id %v, got %v", serviceID,
+				id)
+		}
+
+		savedKey, err := LoadOnionPrivateKey(keyPath)
+		if err != nil {
+			t.Fatalf("unable to load private key: %v", err)
+		}
+		if string(savedKey) != privKey {
+			t.Fatalf("expected private key %v, got %s", privKey,
+				savedKey)
+		}
+	}
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("mock tor server failed: %v", err)
+	}
+}
+
+// TestSetTargets tests that the ADD_ONION port mappings target the IPv4 and
+// IPv6 addresses set through SetTargets.
+func TestSetTargets(t *testing.T) {
+	t.Parallel()
+
+	const serviceID = "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd"
+
+	tests := []struct {
+		name    string
+		v4      string
+		v6      string
+		targets []string
+		command string
+	}{
+		{
+			name:    "no targets",
+			targets: []string{"9735"},
+			command: "Port=80,127.0.0.1:8080 Port=9735,9735",
+		},
+		{
+			name:    "v4 only",
+			v4:      "10.0.0.2",
+			targets: []string{"10.0.0.2:9735"},
+			command: "Port=80,127.0.0.1:8080 Port=9735,10.0.0.2:9735",
+		},
+		{
+			name:    "v6 only",
+			v6:      "fd00::2",
+			targets: []string{"[fd00::2]:9735"},
+			command: "Port=80,127.0.0.1:8080 Port=9735,[fd00::2]:9735",
+		},
+		{
+			name:    "dual stack",
+			v4:      "10.0.0.2",
+			v6:      "fd00::2",
+			targets: []string{"10.0.0.2:9735", "[fd00::2]:9735"},
+			command: "Port=80,127.0.0.1:8080 " +
+				"Port=9735,10.0.0.2:9735 Port=9735,[fd00::2]:9735",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			c, errChan := newMockTorController(t, "192.168.1.1",
+				[]mockTorExchange{{
+					command: "ADD_ONION NEW:ED25519-V3 " +
+						test.command,
+					reply: []string{
+						"250-ServiceID=" + serviceID,
+						"250-PrivateKey=ED25519-V3:key",
+						"250 OK",
+					},
+				}},
+			)
+
+			if err := c.SetTargets(test.v4, test.v6); err != nil {
+				t.Fatalf("unable to set targets: %v", err)
+			}
+
+			targets, err := c.targetParams("9735")
+			if err != nil {
+				t.Fatalf("unable to get targets: %v", err)
+			}
+			if !reflect.DeepEqual(targets, test.targets) {
+				t.Fatalf("expected targets %v, got %v",
+					test.targets, targets)
+			}
+
+			_, _, err = c.AddOnionV3(map[int]string{
+				9735: "9735",
+				80:   "127.0.0.1:8080",
+			}, nil)
+			if err != nil {
+				t.Fatalf("unable to add onion: %v", err)
+			}
+
+			if err := <-errChan; err != nil {
+				t.Fatalf("mock tor server failed: %v", err)
+			}
+		})
+	}
+}
+
+// TestSetTargetsInvalid tests that SetTargets rejects addresses which aren't
+// IP addresses of the expected family, leaving the targets unchanged.
+func TestSetTargetsInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		v4 string
+		v6 string
+	}{
+		{v4: "localhost"},
+		{v4: "fd00::2"},
+		{v4: "10.0.0.256"},
+		{v6: "10.0.0.2"},
+		{v6: "[fd00::2]"},
+		{v4: "10.0.0.2", v6: "fd00::zz"},
+	}
+
+	for _, test := range tests {
+		c := NewController("", "192.168.1.1", "")
+		if err := c.SetTargets(test.v4, test.v6); err == nil {
+			t.Fatalf("expected error for targets %q and %q",
+				test.v4, test.v6)
+		}
+
+		targets, err := c.targetParams("9735")
+		if err != nil {
+			t.Fatalf("unable to get targets: %v", err)
+		}
+		if len(targets) != 1 || targets[0] != "192.168.1.1:9735" {
+			t.Fatalf("expected targets to be unchanged, got %v",
+				targets)
+		}
+	}
+}
@@ -7,13 +7,16 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
 )
 
 const (
@@ -68,9 +71,7 @@ var (
 // proceeding to send commands. Otherwise, the connection will be closed.
 //
 // TODO:
-//   * if adding support for more commands, extend this with a command queue?
 //   * place under sub-package?
-//   * support async replies from the server
 type Controller struct {
 	// started is used atomically in order to prevent multiple calls to
 	// Start.
@@ -94,6 +95,13 @@ type Controller struct {
 	// HASHEDPASSWORD authentication method with this value.
 	password string
 
+	// passwordFile, if non-empty, is the path of a file holding the
+	// password used for the HASHEDPASSWORD authentication method. It's
+	// read every time the controller authenticates, so the password can be
+	// rotated. If it can't be read, the other authentication methods are
+	// attempted instead.
+	passwordFile string
+
 	// version is the current version of the Tor server.
 	version string
 
@@ -101,6 +109,38 @@ type Controller struct {
 	// to connect to the LND node.  This is required when the Tor server
 	// runs on another host, otherwise the service will not be reachable.
 	targetIPAddress string
+
+	// targetIPv6Address is the IPv6 address which we tell the Tor server to
+	// use to connect to the LND node, in addition to targetIPAddress on
+	// dual-stack hosts.
+	targetIPv6Address string
+
+	// cmdMtx serializes commands, so that each reply is matched with the
+	// command it belongs to.
+	cmdMtx sync.Mutex
+
+	// readerStarted is set once the reader goroutine has been started by
+	// Subscribe. From then on, all lines sent by the Tor server are read by
+	// it, and replies to commands are delivered on the replies channel.
+	// It's guarded by cmdMtx.
+	readerStarted bool
+
+	// replies receives the replies to commands from the reader goroutine.
+	replies chan *torReply
+
+	// readerDone is closed once the reader goroutine exits.
+	readerDone chan struct{}
+
+	// subscribers are the channels events are delivered to, along with
+	// the events each of them is interested in. Once subscribersClosed is
+	// set, the reader goroutine has exited and no more subscribers are
+	// accepted. Both are guarded by subscribersMtx.
+	subscribers       []*eventSubscriber
+	subscribersClosed bool
+	subscribersMtx    sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
 // NewController returns a new Tor controller that will be able to interact with
@@ -112,9 +152,69 @@ func NewController(controlAddr string, targetIPAddress string,
 		controlAddr:     controlAddr,
 		targetIPAddress: targetIPAddress,
 		password:        password,
+		replies:         make(chan *torReply),
+		quit:            make(chan struct{}),
 	}
 }
 
+// NewControllerFromPasswordFile returns a new Tor controller which
+// authenticates itself through the HASHEDPASSWORD authentication method with
+// the password stored in the given file. The file is read when the controller
+// is started rather than here, so it always uses the current password.
+func NewControllerFromPasswordFile(controlAddr string, targetIPAddress string,
+	passwordFile string) (*Controller, er.R) {
+
+	if passwordFile == "" {
+		return nil, er.New("password file must be specified")
+	}
+
+	c := NewController(controlAddr, targetIPAddress, "")
+	c.passwordFile = passwordFile
+
+	return c, nil
+}
+
+// SetTargets sets the IPv4 and IPv6 addresses which the Tor server uses to
+// connect to the LND node, replacing the target IP address the controller was
+// created with. Either may be empty. If both are set, the Tor server is given a
+// target of each family for every port, and picks one of them for each
+// connection. If neither is set, the Tor server connects to the local host.
+//
+// NOTE: This must be called before any onion service is created.
+func (c *Controller) SetTargets(v4, v6 string) er.R {
+	if v4 != "" {
+		ip := net.ParseIP(v4)
+		if ip == nil || ip.To4() == nil {
+			return er.Errorf("invalid IPv4 target address %q", v4)
+		}
+	}
+	if v6 != "" {
+		ip := net.ParseIP(v6)
+		if ip == nil || ip.To4() != nil {
+			return er.Errorf("invalid IPv6 target address %q", v6)
+		}
+	}
+
+	c.targetIPAddress = v4
+	c.targetIPv6Address = v6
+
+	return nil
+}
+
+// targetHosts returns the hosts the Tor server should connect to, IPv4 first.
+// It's empty if the Tor server should connect to the local host.
+func (c *Controller) targetHosts() []string {
+	var hosts []string
+	if c.targetIPAddress != "" {
+		hosts = append(hosts, c.targetIPAddress)
+	}
+	if c.targetIPv6Address != "" {
+		hosts = append(hosts, c.targetIPv6Address)
+	}
+
+	return hosts
+}
+
 // Start establishes and authenticates the connection between the controller and
 // a Tor server. Once done, the controller will be able to send commands and
 // expect responses.
@@ -139,26 +239,95 @@ func (c *Controller) Stop() er.R {
 		return nil
 	}
 
-	return er.E(c.conn.Close())
+	close(c.quit)
+	err := er.E(c.conn.Close())
+	c.wg.Wait()
+
+	return err
 }
 
 // sendCommand sends a command to the Tor server and returns its response, as a
 // single space-delimited string, and code.
 func (c *Controller) sendCommand(command string) (int, string, er.R) {
+	c.cmdMtx.Lock()
+	defer c.cmdMtx.Unlock()
+
+	return c.sendCommandLocked(command)
+}
+
+// sendCommandLocked sends a command to the Tor server and returns its
+// response. The caller must hold cmdMtx.
+//
+// Failures to write the command or read the reply are returned as
+// errConnectionLost, as the connection is unusable from then on.
+func (c *Controller) sendCommandLocked(command string) (int, string, er.R) {
 	if err := c.conn.Writer.PrintfLine(command); err != nil {
-		return 0, "", er.E(err)
+		return 0, "", errConnectionLost.New("", er.E(err))
+	}
+
+	// Once the reader goroutine is running, it's the only one allowed to
+	// read from the connection, so we'll wait for it to hand us the reply.
+	if c.readerStarted {
+		return c.awaitReply()
 	}
 
 	// We'll use ReadResponse as it has built-in support for multi-line
 	// text protocol responses.
 	code, reply, err := c.conn.Reader.ReadResponse(success)
-	if err != nil {
+	if _, ok := err.(*textproto.Error); ok {
 		return code, reply, er.E(err)
 	}
+	if err != nil {
+		return code, reply, errConnectionLost.New("", er.E(err))
+	}
 
 	return code, reply, nil
 }
 
+// clone returns a new, unstarted controller with the same configuration as
+// this one, which can be used to reconnect to the Tor server.
+func (c *Controller) clone() *Controller {
+	clone := NewController(c.controlAddr, c.targetIPAddress, c.password)
+	clone.targetIPv6Address = c.targetIPv6Address
+	clone.passwordFile = c.passwordFile
+
+	return clone
+}
+
+// GetInfo queries the Tor server for the values of the given keys, such as
+// version or status/bootstrap-phase, and returns them by key. Only keys with
+// single line values are supported.
+func (c *Controller) GetInfo(keys ...string) (map[string]string, er.R) {
+	if len(keys) == 0 {
+		return nil, er.New("at least one key is required")
+	}
+
+	cmd := "GETINFO " + strings.Join(keys, " ")
+	_, reply, err := c.sendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each value is replied on its own line, e.g.:
+	//
+	//	S: 250-version=0.4.7.13
+	//	S: 250 OK
+	info := make(map[string]string, len(keys))
+	for _, line := range strings.Split(reply, "\n") {
+		keyValue := strings.SplitN(line, "=", 2)
+		if len(keyValue) == 2 {
+			info[keyValue[0]] = keyValue[1]
+		}
+	}
+	for _, key := range keys {
+		if _, ok := info[key]; !ok {
+			return nil, er.Errorf("%v not found in reply", key)
+		}
+	}
+
+	return info, nil
+}
+
 // parseTorReply parses the reply from the Tor server after receiving a command
 // from a controller. This will parse the relevant reply parameters into a map
 // of keys and values.
@@ -201,16 +370,21 @@ func (c *Controller) authenticate() er.R {
 	// used later on.
 	c.version = protocolInfo.version()
 
+	password := c.password
+	if c.passwordFile != "" {
+		password = c.readPasswordFile()
+	}
+
 	switch {
 	// If a password was provided, then we should attempt to use the
 	// HASHEDPASSWORD authentication method.
-	case c.password != "":
+	case password != "":
 		if !protocolInfo.supportsAuthMethod(authHashedPassword) {
 			return er.Errorf("%v authentication method not "+
 				"supported", authHashedPassword)
 		}
 
-		return c.authenticateViaHashedPassword()
+		return c.authenticateViaHashedPassword(password)
 
 	// Otherwise, attempt to authentication via the SAFECOOKIE method as it
 	// provides the most security.
@@ -235,10 +409,25 @@ func (c *Controller) authenticateViaNull() er.R {
 	return err
 }
 
+// readPasswordFile returns the contents of the password file with surrounding
+// whitespace trimmed. An empty string is returned if the file can't be read,
+// in which case the controller falls back to the other authentication methods.
+func (c *Controller) readPasswordFile() string {
+	password, err := ioutil.ReadFile(c.passwordFile)
+	if err != nil {
+		log.Warnf("Unable to read Tor password file %v, falling back "+
+			"to other authentication methods: %v", c.passwordFile,
+			err)
+		return ""
+	}
+
+	return strings.TrimSpace(string(password))
+}
+
 // authenticateViaHashedPassword authenticates the controller with the Tor
 // server using the HASHEDPASSWORD authentication method.
-func (c *Controller) authenticateViaHashedPassword() er.R {
-	cmd := fmt.Sprintf("AUTHENTICATE \"%s\"", c.password)
+func (c *Controller) authenticateViaHashedPassword(password string) er.R {
+	cmd := fmt.Sprintf("AUTHENTICATE \"%s\"", password)
 	_, _, err := c.sendCommand(cmd)
 	return err
 }
@@ -371,38 +560,78 @@ func computeHMAC256(key, message []byte) []byte {
 	return mac.Sum(nil)
 }
 
-// supportsV3 is a helper function that parses the current version of the Tor
-// server and determines whether it supports creationg v3 onion services through
-// Tor's control port. The version string should be of the format:
-//	major.minor.revision.build
-func supportsV3(version string) er.R {
-	// We'll split the minimum Tor version that's supported and the given
-	// version in order to individually compare each number.
-	parts := strings.Split(version, ".")
-	if len(parts) != 4 {
-		return er.New("version string is not of the format " +
-			"major.minor.revision.build")
+// torVersionParts is the number of numeric components in a Tor version
+// string of the format major.minor.revision.build.
+const torVersionParts = 4
+
+// parseTorVersion parses a Tor version string into its numeric components.
+// Besides the canonical major.minor.revision.build format, it accepts the
+// variations reported by real Tor builds:
+//
+//	0.4.7.13 (git-a0b1c2d3e4f5a6b7)  trailing parenthesized build metadata
+//	0.4.8.1-alpha, 0.3.3.6-rc         pre-release suffixes on the last part
+//	0.4.8                             fewer components, zero-padded
+func parseTorVersion(version string) ([torVersionParts]int, er.R) {
+	var parsed [torVersionParts]int
+
+	// Anything after the first whitespace, such as the git revision the
+	// binary was built from, is build metadata we don't care about.
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return parsed, er.New("empty version string")
 	}
 
-	// It's possible that the build number (the last part of the version
-	// string) includes a pre-release string, e.g. rc, beta, etc., so we'll
-	// parse that as well.
-	build := strings.Split(parts[len(parts)-1], "-")
-	parts[len(parts)-1] = build[0]
+	// It's possible that the last part of the version string includes a
+	// pre-release string, e.g. rc, alpha, etc., which doesn't affect
+	// whether the version is supported, so we'll strip it.
+	number := strings.SplitN(fields[0], "-", 2)[0]
+
+	parts := strings.Split(number, ".")
+	if len(parts) > torVersionParts {
+		return parsed, er.Errorf("version string %v is not of the "+
+			"format major.minor.revision.build", version)
+	}
 
 	// Ensure that each part of the version string corresponds to a number.
-	for _, part := range parts {
-		if _, err := strconv.Atoi(part); err != nil {
-			return er.E(err)
+	// Missing parts are left as zero.
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, er.E(err)
 		}
+		if n < 0 {
+			return parsed, er.Errorf("invalid version string %v",
+				version)
+		}
+		parsed[i] = n
+	}
+
+	return parsed, nil
+}
+
+// supportsV3 is a helper function that parses the current version of the Tor
+// server and determines whether it supports creationg v3 onion services through
+// Tor's control port. See parseTorVersion for the accepted formats.
+func supportsV3(version string) er.R {
+	parsed, err := parseTorVersion(version)
+	if err != nil {
+		return err
+	}
+	min, err := parseTorVersion(MinTorVersion)
+	if err != nil {
+		return err
 	}
 
-	// Once we've determined we have a proper version string of the format
-	// major.minor.revision.build, we can just do a string comparison to
-	// determine if it satisfies the minimum version supported.
-	if version < MinTorVersion {
-		return er.Errorf("version %v below minimum version supported "+
-			"%v", version, MinTorVersion)
+	// Compare each component numerically, as a string comparison would
+	// consider e.g. 0.10.0.0 to be older than 0.3.3.6.
+	for i := range parsed {
+		if parsed[i] > min[i] {
+			return nil
+		}
+		if parsed[i] < min[i] {
+			return er.Errorf("version %v below minimum version "+
+				"supported %v", version, MinTorVersion)
+		}
 	}
 
 	return nil
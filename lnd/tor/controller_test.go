@@ -1,6 +1,11 @@
 package tor
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 // TestParseTorVersion is a series of tests for different version strings that
 // check the correctness of determining whether they support creating v3 onion
@@ -64,6 +69,58 @@ func TestParseTorVersion(t *testing.T) {
 			version: "0.0.6.3",
 			valid:   false,
 		},
+		{
+			version: "0.4.7.13 (git-7c0a4c5b0c5d5e4f)",
+			valid:   true,
+		},
+		{
+			version: "0.4.8.1-alpha (git-2b1f3e0b7c4b9e57)",
+			valid:   true,
+		},
+		{
+			version: "0.3.3.5-rc (git-1d8a5c3e2ffb1a40)",
+			valid:   false,
+		},
+		{
+			version: "0.4.8.1-alpha-dev",
+			valid:   true,
+		},
+		{
+			version: "0.3.3.6-rc",
+			valid:   true,
+		},
+		{
+			version: "0.4.8",
+			valid:   true,
+		},
+		{
+			version: "0.3.3",
+			valid:   false,
+		},
+		{
+			version: "0.10",
+			valid:   true,
+		},
+		{
+			version: "0.3.10.1",
+			valid:   true,
+		},
+		{
+			version: "0.3.3.10",
+			valid:   true,
+		},
+		{
+			version: "",
+			valid:   false,
+		},
+		{
+			version: "0.3.3.6.1",
+			valid:   false,
+		},
+		{
+			version: "0.3.x.6",
+			valid:   false,
+		},
 	}
 
 	for i, test := range tests {
@@ -74,3 +131,139 @@ func TestParseTorVersion(t *testing.T) {
 		}
 	}
 }
+
+// TestParseTorVersionParts checks that version strings are split into their
+// numeric components, with build metadata and pre-release suffixes stripped
+// and missing components zero-padded.
+func TestParseTorVersionParts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version  string
+		expected [torVersionParts]int
+	}{
+		{
+			version:  "0.3.3.6",
+			expected: [torVersionParts]int{0, 3, 3, 6},
+		},
+		{
+			version:  "0.4.7.13 (git-7c0a4c5b0c5d5e4f)",
+			expected: [torVersionParts]int{0, 4, 7, 13},
+		},
+		{
+			version:  "0.4.8.1-alpha",
+			expected: [torVersionParts]int{0, 4, 8, 1},
+		},
+		{
+			version:  "0.4.5.0-alpha-dev (git-0123456789abcdef)",
+			expected: [torVersionParts]int{0, 4, 5, 0},
+		},
+		{
+			version:  "0.4.8",
+			expected: [torVersionParts]int{0, 4, 8, 0},
+		},
+		{
+			version:  "1",
+			expected: [torVersionParts]int{1, 0, 0, 0},
+		},
+	}
+
+	for _, test := range tests {
+		parsed, err := parseTorVersion(test.version)
+		if err != nil {
+			t.Fatalf("unable to parse version %v: %v",
+				test.version, err)
+		}
+		if parsed != test.expected {
+			t.Fatalf("version %v parsed as %v, expected %v",
+				test.version, parsed, test.expected)
+		}
+	}
+}
+
+// TestPasswordFileReread tests that a controller created from a password file
+// reads the current password every time it authenticates, and falls back to
+// the other authentication methods if the file can't be read.
+func TestPasswordFileReread(t *testing.T) {
+	t.Parallel()
+
+	tempDir, errr := ioutil.TempDir("", "tor_password")
+	if errr != nil {
+		t.Fatalf("unable to create temp dir: %v", errr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	passwordFile := filepath.Join(tempDir, "password")
+	c, err := NewControllerFromPasswordFile("", "", passwordFile)
+	if err != nil {
+		t.Fatalf("unable to create controller: %v", err)
+	}
+
+	protocolInfo := mockTorExchange{
+		command: "PROTOCOLINFO 1",
+		reply: []string{
+			"250-PROTOCOLINFO 1",
+			"250-AUTH METHODS=NULL,HASHEDPASSWORD",
+			"250-VERSION Tor=\"0.4.7.13\"",
+			"250 OK",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		authCmd  string
+	}{
+		{
+			name:     "initial password",
+			password: "hunter2\n",
+			authCmd:  "AUTHENTICATE \"hunter2\"",
+		},
+		{
+			name:     "rotated password",
+			password: "  correct horse battery staple \n",
+			authCmd:  "AUTHENTICATE \"correct horse battery staple\"",
+		},
+		{
+			name:    "unreadable password file",
+			authCmd: "AUTHENTICATE",
+		},
+	}
+
+	for _, test := range tests {
+		if test.password != "" {
+			errr := ioutil.WriteFile(
+				passwordFile, []byte(test.password), 0600,
+			)
+			if errr != nil {
+				t.Fatalf("unable to write password file: %v",
+					errr)
+			}
+		} else {
+			os.Remove(passwordFile)
+		}
+
+		// Simulate a reconnection by authenticating over a new
+		// connection.
+		conn, errChan := newMockTorConn(t, []mockTorExchange{
+			protocolInfo, {
+				command: test.authCmd,
+				reply:   []string{"250 OK"},
+			},
+		})
+		c.conn = conn
+
+		if err := c.authenticate(); err != nil {
+			t.Fatalf("%v: unable to authenticate: %v", test.name,
+				err)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("%v: mock tor server failed: %v", test.name,
+				err)
+		}
+	}
+
+	if _, err := NewControllerFromPasswordFile("", "", ""); err == nil {
+		t.Fatal("expected error without password file")
+	}
+}
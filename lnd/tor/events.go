@@ -0,0 +1,229 @@
+package tor
+
+import (
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+const (
+	// asyncEvent is the Tor Control response code of asynchronous event
+	// notifications.
+	asyncEvent = 650
+
+	// eventBufferSize is the number of events buffered for each
+	// subscriber.
+	eventBufferSize = 20
+)
+
+// errConnectionLost is returned when the connection to the Tor server is lost
+// while the reader goroutine is running.
+var errConnectionLost = er.GenericErrorType.CodeWithDetail("errConnectionLost",
+	"connection to the tor server lost")
+
+// TorEvent is an asynchronous event notification sent by the Tor server, e.g.
+//
+//	650 CIRC 1000 EXTENDED moria1,moria2
+type TorEvent struct {
+	// Type is the keyword of the event, e.g. CIRC or STATUS_CLIENT.
+	Type string
+
+	// Data is the remainder of the event. For events which span multiple
+	// lines, the lines are separated by newlines.
+	Data string
+}
+
+// torReply is a reply of the Tor server to a command, as read by the reader
+// goroutine.
+type torReply struct {
+	code int
+	msg  string
+	err  er.R
+}
+
+// eventSubscriber is a channel events are delivered to, along with the types of
+// events it's interested in.
+type eventSubscriber struct {
+	events map[string]struct{}
+	c      chan TorEvent
+}
+
+// Subscribe registers the given events, such as CIRC or STATUS_CLIENT, with the
+// Tor server and returns a channel on which they are delivered. The channel is
+// closed once the connection to the Tor server is lost or the controller is
+// stopped.
+//
+// As SETEVENTS replaces the events the Tor server reports, the union of the
+// events of all subscriptions is registered each time.
+//
+// NOTE: The events must be received promptly, as replies to commands are read
+// from the same connection and can't be processed while an event is waiting to
+// be delivered.
+func (c *Controller) Subscribe(events []string) (<-chan TorEvent, er.R) {
+	if len(events) == 0 {
+		return nil, er.New("at least one event is required")
+	}
+
+	sub := &eventSubscriber{
+		events: make(map[string]struct{}, len(events)),
+		c:      make(chan TorEvent, eventBufferSize),
+	}
+	for _, event := range events {
+		sub.events[strings.ToUpper(event)] = struct{}{}
+	}
+
+	c.cmdMtx.Lock()
+	defer c.cmdMtx.Unlock()
+
+	// From now on, lines from the Tor server may arrive at any time, so
+	// a dedicated goroutine reads all of them.
+	if !c.readerStarted {
+		c.readerStarted = true
+		c.readerDone = make(chan struct{})
+		c.wg.Add(1)
+		go c.readReplies()
+	}
+
+	// The subscriber is added before sending the command, as the Tor
+	// server may send events right after its reply.
+	c.subscribersMtx.Lock()
+	if c.subscribersClosed {
+		c.subscribersMtx.Unlock()
+		return nil, errConnectionLost.Default()
+	}
+	c.subscribers = append(c.subscribers, sub)
+	allEvents := make(map[string]struct{})
+	for _, s := range c.subscribers {
+		for event := range s.events {
+			allEvents[event] = struct{}{}
+		}
+	}
+	c.subscribersMtx.Unlock()
+
+	sortedEvents := make([]string, 0, len(allEvents))
+	for event := range allEvents {
+		sortedEvents = append(sortedEvents, event)
+	}
+	sort.Strings(sortedEvents)
+
+	cmd := "SETEVENTS " + strings.Join(sortedEvents, " ")
+	if _, _, err := c.sendCommandLocked(cmd); err != nil {
+		c.removeSubscriber(sub)
+		return nil, err
+	}
+
+	return sub.c, nil
+}
+
+// awaitReply waits for the reply to the last command from the reader
+// goroutine.
+func (c *Controller) awaitReply() (int, string, er.R) {
+	select {
+	case reply := <-c.replies:
+		return reply.code, reply.msg, reply.err
+	case <-c.readerDone:
+		return 0, "", errConnectionLost.Default()
+	case <-c.quit:
+		return 0, "", er.New("tor controller shutting down")
+	}
+}
+
+// readReplies reads all lines sent by the Tor server. Asynchronous events are
+// delivered to the subscribers interested in them, while everything else is a
+// reply to a command which is handed to sendCommand.
+//
+// NOTE: This MUST be run as a goroutine.
+func (c *Controller) readReplies() {
+	defer c.wg.Done()
+	defer close(c.readerDone)
+	defer c.closeSubscribers()
+
+	for {
+		// Any code is accepted here, as only sendCommand knows which
+		// one it expects. A pending command is failed through
+		// readerDone if the connection is lost.
+		code, msg, err := c.conn.Reader.ReadResponse(0)
+		if err != nil {
+			return
+		}
+
+		if code == asyncEvent {
+			if !c.deliverEvent(msg) {
+				return
+			}
+			continue
+		}
+
+		reply := &torReply{code: code, msg: msg}
+		if code != success {
+			reply.err = er.E(&textproto.Error{Code: code, Msg: msg})
+		}
+
+		select {
+		case c.replies <- reply:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// deliverEvent parses an event and sends it to all subscribers interested in
+// it. It returns false if the controller is shutting down.
+func (c *Controller) deliverEvent(msg string) bool {
+	event := TorEvent{Type: msg}
+	if i := strings.IndexAny(msg, " \n"); i >= 0 {
+		event.Type = msg[:i]
+		event.Data = msg[i+1:]
+	}
+
+	c.subscribersMtx.Lock()
+	var subscribers []*eventSubscriber
+	for _, sub := range c.subscribers {
+		if _, ok := sub.events[event.Type]; ok {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	c.subscribersMtx.Unlock()
+
+	for _, sub := range subscribers {
+		select {
+		case sub.c <- event:
+		case <-c.quit:
+			return false
+		}
+	}
+
+	return true
+}
+
+// closeSubscribers closes the channels of all subscribers, signalling that no
+// more events will be delivered.
+func (c *Controller) closeSubscribers() {
+	c.subscribersMtx.Lock()
+	defer c.subscribersMtx.Unlock()
+
+	for _, sub := range c.subscribers {
+		close(sub.c)
+	}
+	c.subscribers = nil
+	c.subscribersClosed = true
+}
+
+// removeSubscriber removes a subscriber whose subscription failed. Its channel
+// is left open, as the reader goroutine may still be delivering an event to it,
+// and it was never handed out anyway.
+func (c *Controller) removeSubscriber(sub *eventSubscriber) {
+	c.subscribersMtx.Lock()
+	defer c.subscribersMtx.Unlock()
+
+	for i, s := range c.subscribers {
+		if s == sub {
+			c.subscribers = append(
+				c.subscribers[:i], c.subscribers[i+1:]...,
+			)
+			return
+		}
+	}
+}
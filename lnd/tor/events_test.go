@@ -0,0 +1,106 @@
+package tor
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSubscribe tests that events are delivered to the subscribers interested
+// in them, while replies to commands are still routed to sendCommand.
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	c, errChan := newMockTorController(t, "", []mockTorExchange{
+		{
+			command: "SETEVENTS CIRC",
+			reply:   []string{"250 OK"},
+		},
+		{
+			command: "SETEVENTS CIRC STATUS_CLIENT",
+			reply: []string{
+				"250 OK",
+				"650 CIRC 1000 EXTENDED moria1,moria2",
+			},
+		},
+		{
+			// Events may arrive before the reply to a command.
+			command: "GETINFO version",
+			reply: []string{
+				"650 STATUS_CLIENT NOTICE CIRCUIT_ESTABLISHED",
+				"650 BW 1536 2048",
+				"650-CIRC 1001 BUILT",
+				"650 PURPOSE=GENERAL",
+				"250-version=0.4.7.13",
+				"250 OK",
+			},
+		},
+	})
+
+	circEvents, err := c.Subscribe([]string{"CIRC"})
+	if err != nil {
+		t.Fatalf("unable to subscribe: %v", err)
+	}
+	allEvents, err := c.Subscribe([]string{"circ", "status_client"})
+	if err != nil {
+		t.Fatalf("unable to subscribe: %v", err)
+	}
+
+	_, reply, err := c.sendCommand("GETINFO version")
+	if err != nil {
+		t.Fatalf("unable to send command: %v", err)
+	}
+	if version := parseTorReply(reply)["version"]; version != "0.4.7.13" {
+		t.Fatalf("expected version 0.4.7.13, got %v", version)
+	}
+
+	circ := []TorEvent{
+		{Type: "CIRC", Data: "1000 EXTENDED moria1,moria2"},
+		{Type: "CIRC", Data: "1001 BUILT\nPURPOSE=GENERAL"},
+	}
+	all := []TorEvent{
+		circ[0],
+		{Type: "STATUS_CLIENT", Data: "NOTICE CIRCUIT_ESTABLISHED"},
+		circ[1],
+	}
+
+	assertEvents := func(events <-chan TorEvent, expected []TorEvent) {
+		t.Helper()
+
+		for _, expectedEvent := range expected {
+			select {
+			case event := <-events:
+				if event != expectedEvent {
+					t.Fatalf("expected event %v, got %v",
+						expectedEvent, event)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("event %v not received", expectedEvent)
+			}
+		}
+
+		// Once the mock server hangs up, the channel should be closed.
+		select {
+		case event, ok := <-events:
+			if ok {
+				t.Fatalf("unexpected event %v", event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("event channel not closed")
+		}
+	}
+	assertEvents(circEvents, circ)
+	assertEvents(allEvents, all)
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("mock tor server failed: %v", err)
+	}
+
+	// Commands sent after the connection is lost should fail rather than
+	// block.
+	if _, _, err := c.sendCommand("GETINFO version"); err == nil {
+		t.Fatal("expected error after connection loss")
+	}
+	if _, err := c.Subscribe([]string{"CIRC"}); err == nil {
+		t.Fatal("expected error after connection loss")
+	}
+}
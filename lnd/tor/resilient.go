@@ -0,0 +1,250 @@
+package tor
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/cjdcoinlog/log"
+)
+
+const (
+	// DefaultMinReconnectBackoff is the default time the ResilientController
+	// waits before its second attempt to reconnect to the Tor server.
+	DefaultMinReconnectBackoff = time.Second
+
+	// DefaultMaxReconnectBackoff is the default maximum time the
+	// ResilientController waits between attempts to reconnect to the Tor
+	// server.
+	DefaultMaxReconnectBackoff = time.Minute
+
+	// DefaultMaxReconnectAttempts is the default number of attempts the
+	// ResilientController makes to reconnect to the Tor server before
+	// giving up.
+	DefaultMaxReconnectAttempts = 5
+)
+
+// ErrConnectionReset is returned by the ResilientController when a command
+// which isn't safe to retry failed because the connection to the Tor server was
+// lost. The controller has reconnected, but all state tied to the old
+// connection, such as onion services, is gone and must be recreated by the
+// caller.
+var ErrConnectionReset = er.GenericErrorType.CodeWithDetail(
+	"ErrConnectionReset",
+	"connection to the tor server was reset")
+
+// ResilientConfig holds the settings of a ResilientController. Zero values are
+// replaced by their defaults.
+type ResilientConfig struct {
+	// MinBackoff is the time waited after the first failed attempt to
+	// reconnect. It is doubled after each further failed attempt.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum time waited between two attempts to
+	// reconnect.
+	MaxBackoff time.Duration
+
+	// MaxAttempts is the number of attempts made to reconnect before the
+	// command which detected the connection loss fails.
+	MaxAttempts int
+}
+
+// ResilientController wraps a Controller and transparently reconnects to the
+// Tor server if the connection is lost, e.g. because the Tor daemon restarted.
+// The connection loss is detected when a command fails, upon which the
+// controller reconnects and re-authenticates with exponential backoff. Commands
+// which are safe to repeat are then retried, while the others fail with
+// ErrConnectionReset so that the caller can recreate its state.
+//
+// NOTE: Event subscriptions don't survive a reconnection, so they aren't
+// offered by the ResilientController.
+type ResilientController struct {
+	// stopped is used atomically in order to prevent multiple calls to
+	// Stop.
+	stopped int32
+
+	cfg ResilientConfig
+
+	// ctrlMtx guards ctrl, and serializes commands with reconnections.
+	ctrlMtx sync.Mutex
+	ctrl    *Controller
+
+	quit chan struct{}
+}
+
+// NewResilientController returns a ResilientController wrapping the given
+// controller, which must not be started yet.
+func NewResilientController(c *Controller,
+	cfg ResilientConfig) *ResilientController {
+
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinReconnectBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxReconnectBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxReconnectAttempts
+	}
+
+	return &ResilientController{
+		cfg:  cfg,
+		ctrl: c,
+		quit: make(chan struct{}),
+	}
+}
+
+// Start establishes and authenticates the connection to the Tor server.
+func (r *ResilientController) Start() er.R {
+	r.ctrlMtx.Lock()
+	defer r.ctrlMtx.Unlock()
+
+	return r.ctrl.Start()
+}
+
+// Stop aborts any pending reconnection and closes the connection to the Tor
+// server.
+func (r *ResilientController) Stop() er.R {
+	if !atomic.CompareAndSwapInt32(&r.stopped, 0, 1) {
+		return nil
+	}
+
+	close(r.quit)
+
+	r.ctrlMtx.Lock()
+	defer r.ctrlMtx.Unlock()
+
+	return r.ctrl.Stop()
+}
+
+// GetInfo queries the Tor server for the values of the given keys, see
+// Controller.GetInfo. It's retried if the connection is lost.
+func (r *ResilientController) GetInfo(keys ...string) (map[string]string,
+	er.R) {
+
+	var info map[string]string
+	err := r.run(true, func(c *Controller) er.R {
+		var err er.R
+		info, err = c.GetInfo(keys...)
+		return err
+	})
+
+	return info, err
+}
+
+// AddOnion creates an onion service, see Controller.AddOnion. It isn't retried
+// if the connection is lost.
+func (r *ResilientController) AddOnion(cfg AddOnionConfig) (*OnionAddr, er.R) {
+	var addr *OnionAddr
+	err := r.run(false, func(c *Controller) er.R {
+		var err er.R
+		addr, err = c.AddOnion(cfg)
+		return err
+	})
+
+	return addr, err
+}
+
+// AddOnionV3 creates a v3 onion service, see Controller.AddOnionV3. It isn't
+// retried if the connection is lost.
+func (r *ResilientController) AddOnionV3(ports map[int]string,
+	privKey []byte) (string, []byte, er.R) {
+
+	var (
+		serviceID  string
+		newPrivKey []byte
+	)
+	err := r.run(false, func(c *Controller) er.R {
+		var err er.R
+		serviceID, newPrivKey, err = c.AddOnionV3(ports, privKey)
+		return err
+	})
+
+	return serviceID, newPrivKey, err
+}
+
+// DelOnion removes an onion service, see Controller.DelOnion. It isn't retried
+// if the connection is lost, as the onion service is gone along with the
+// connection it was created on.
+func (r *ResilientController) DelOnion(serviceID string) er.R {
+	return r.run(false, func(c *Controller) er.R {
+		return c.DelOnion(serviceID)
+	})
+}
+
+// run executes the command on the current controller. If the connection to
+// the Tor server was lost, it reconnects and then retries the command if it's
+// idempotent, or fails with ErrConnectionReset otherwise.
+func (r *ResilientController) run(idempotent bool,
+	command func(*Controller) er.R) er.R {
+
+	r.ctrlMtx.Lock()
+	defer r.ctrlMtx.Unlock()
+
+	err := command(r.ctrl)
+	if !errConnectionLost.Is(err) {
+		return err
+	}
+
+	log.Infof("Connection to Tor server lost, reconnecting: %v", err)
+
+	if err := r.reconnect(); err != nil {
+		return err
+	}
+
+	if !idempotent {
+		return ErrConnectionReset.New("", err)
+	}
+
+	return command(r.ctrl)
+}
+
+// reconnect replaces the current controller with a new one connected to the
+// Tor server, making up to MaxAttempts attempts with exponential backoff. The
+// caller must hold ctrlMtx.
+func (r *ResilientController) reconnect() er.R {
+	// The old connection is unusable, so it's closed right away. Any error
+	// is irrelevant as we're replacing it anyway.
+	_ = r.ctrl.Stop()
+
+	var (
+		backoff = r.cfg.MinBackoff
+		err     er.R
+	)
+	for attempt := 0; attempt < r.cfg.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-r.quit:
+				return er.New("tor controller shutting down")
+			}
+
+			backoff *= 2
+			if backoff > r.cfg.MaxBackoff {
+				backoff = r.cfg.MaxBackoff
+			}
+		}
+
+		c := r.ctrl.clone()
+		err = c.Start()
+		if err == nil {
+			r.ctrl = c
+			return nil
+		}
+
+		// A failed start may still have left an open connection.
+		if c.conn != nil {
+			_ = c.Stop()
+		}
+
+		log.Debugf("Unable to reconnect to Tor server (attempt %d): %v",
+			attempt+1, err)
+	}
+
+	return er.Errorf("unable to reconnect to Tor server after %d "+
+		"attempts: %v", r.cfg.MaxAttempts, err)
+}
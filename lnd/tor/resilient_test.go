@@ -0,0 +1,175 @@
+package tor
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestResilientControllerReconnect tests that the ResilientController
+// reconnects to a Tor server which dropped the connection mid-session,
+// retrying idempotent commands and failing the others.
+func TestResilientControllerReconnect(t *testing.T) {
+	t.Parallel()
+
+	listener, errr := net.Listen("tcp", "127.0.0.1:0")
+	if errr != nil {
+		t.Fatalf("unable to listen: %v", errr)
+	}
+	defer listener.Close()
+
+	authenticate := []mockTorExchange{
+		{
+			command: "PROTOCOLINFO 1",
+			reply: []string{
+				"250-PROTOCOLINFO 1",
+				"250-AUTH METHODS=NULL",
+				"250-VERSION Tor=\"0.4.7.13\"",
+				"250 OK",
+			},
+		},
+		{
+			command: "AUTHENTICATE",
+			reply:   []string{"250 OK"},
+		},
+	}
+	getVersion := mockTorExchange{
+		command: "GETINFO version",
+		reply:   []string{"250-version=0.4.7.13", "250 OK"},
+	}
+
+	// The mock server hangs up once it received the last command of each
+	// session, without replying to it.
+	sessions := [][]mockTorExchange{
+		append(authenticate, getVersion, mockTorExchange{
+			command: "GETINFO version",
+		}),
+		append(authenticate, getVersion, mockTorExchange{
+			command: "ADD_ONION NEW:ED25519-V3 Port=9735,9735",
+		}),
+		append(authenticate, getVersion),
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+
+		for _, session := range sessions {
+			conn, err := listener.Accept()
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if err := serveMockTor(conn, session); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	c := NewResilientController(
+		NewController(listener.Addr().String(), "", ""),
+		ResilientConfig{
+			MinBackoff: 10 * time.Millisecond,
+			MaxBackoff: 100 * time.Millisecond,
+		},
+	)
+	if err := c.Start(); err != nil {
+		t.Fatalf("unable to start controller: %v", err)
+	}
+	defer c.Stop()
+
+	assertVersion := func() {
+		t.Helper()
+
+		info, err := c.GetInfo("version")
+		if err != nil {
+			t.Fatalf("unable to get version: %v", err)
+		}
+		if info["version"] != "0.4.7.13" {
+			t.Fatalf("expected version 0.4.7.13, got %v",
+				info["version"])
+		}
+	}
+
+	// The second query is dropped by the server, so it's transparently
+	// retried over the second session.
+	assertVersion()
+	assertVersion()
+
+	// Creating an onion service isn't retried when the connection is
+	// dropped, while later commands use the third session.
+	_, _, err := c.AddOnionV3(map[int]string{9735: "9735"}, nil)
+	if !ErrConnectionReset.Is(err) {
+		t.Fatalf("expected ErrConnectionReset, got %v", err)
+	}
+	assertVersion()
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("mock tor server failed: %v", err)
+	}
+}
+
+// TestResilientControllerGiveUp tests that the ResilientController gives up
+// reconnecting after the configured number of attempts.
+func TestResilientControllerGiveUp(t *testing.T) {
+	t.Parallel()
+
+	listener, errr := net.Listen("tcp", "127.0.0.1:0")
+	if errr != nil {
+		t.Fatalf("unable to listen: %v", errr)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		// Hang up after authenticating, and stop listening so that
+		// reconnecting fails.
+		listener.Close()
+		err = serveMockTor(conn, []mockTorExchange{
+			{
+				command: "PROTOCOLINFO 1",
+				reply: []string{
+					"250-PROTOCOLINFO 1",
+					"250-AUTH METHODS=NULL",
+					"250 OK",
+				},
+			},
+			{
+				command: "AUTHENTICATE",
+				reply:   []string{"250 OK"},
+			},
+		})
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	c := NewResilientController(
+		NewController(listener.Addr().String(), "", ""),
+		ResilientConfig{
+			MinBackoff:  time.Millisecond,
+			MaxAttempts: 3,
+		},
+	)
+	if err := c.Start(); err != nil {
+		t.Fatalf("unable to start controller: %v", err)
+	}
+	defer c.Stop()
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("mock tor server failed: %v", err)
+	}
+
+	_, err := c.GetInfo("version")
+	if err == nil || ErrConnectionReset.Is(err) {
+		t.Fatalf("expected reconnection failure, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package walletunlocker
+
+import (
+	"fmt"
+	"math"
+	"unicode"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+)
+
+var (
+	// ErrPasswordTooShort signals that a password has fewer characters
+	// than the policy requires.
+	ErrPasswordTooShort = er.GenericErrorType.Code("ErrPasswordTooShort")
+
+	// ErrPasswordNoUpper signals that a password lacks an upper case
+	// letter while the policy requires one.
+	ErrPasswordNoUpper = er.GenericErrorType.CodeWithDetail(
+		"ErrPasswordNoUpper",
+		"password must contain an upper case letter")
+
+	// ErrPasswordNoLower signals that a password lacks a lower case
+	// letter while the policy requires one.
+	ErrPasswordNoLower = er.GenericErrorType.CodeWithDetail(
+		"ErrPasswordNoLower",
+		"password must contain a lower case letter")
+
+	// ErrPasswordNoDigit signals that a password lacks a digit while the
+	// policy requires one.
+	ErrPasswordNoDigit = er.GenericErrorType.CodeWithDetail(
+		"ErrPasswordNoDigit", "password must contain a digit")
+
+	// ErrPasswordNoSymbol signals that a password lacks a character which
+	// is neither a letter nor a digit while the policy requires one.
+	ErrPasswordNoSymbol = er.GenericErrorType.CodeWithDetail(
+		"ErrPasswordNoSymbol", "password must contain a symbol")
+
+	// ErrPasswordLowEntropy signals that the estimated entropy of a
+	// password is below the minimum of the policy.
+	ErrPasswordLowEntropy = er.GenericErrorType.Code("ErrPasswordLowEntropy")
+)
+
+// DefaultPasswordPolicy is the policy used unless another one is configured.
+// It only requires a password to have at least 8 characters.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+}
+
+// PasswordPolicy describes the constraints a new wallet password must meet.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters of the password.
+	MinLength int
+
+	// RequireUpper requires the password to contain an upper case
+	// letter.
+	RequireUpper bool
+
+	// RequireLower requires the password to contain a lower case letter.
+	RequireLower bool
+
+	// RequireDigit requires the password to contain a digit.
+	RequireDigit bool
+
+	// RequireSymbol requires the password to contain a character which is
+	// neither a letter nor a digit.
+	RequireSymbol bool
+
+	// MinEntropyBits is the minimum estimated entropy of the password in
+	// bits, see EstimateEntropy. Zero disables the check.
+	MinEntropyBits float64
+}
+
+// Validate returns an error describing the first rule of the policy the
+// password violates, or nil if it meets all of them.
+func (p *PasswordPolicy) Validate(password []byte) er.R {
+	// As before policies were configurable, the length is counted in
+	// bytes.
+	if len(password) < p.MinLength {
+		return ErrPasswordTooShort.New(fmt.Sprintf("password must "+
+			"have at least %d characters", p.MinLength), nil)
+	}
+
+	classes := charClasses(password)
+	switch {
+	case p.RequireUpper && !classes.upper:
+		return ErrPasswordNoUpper.Default()
+
+	case p.RequireLower && !classes.lower:
+		return ErrPasswordNoLower.Default()
+
+	case p.RequireDigit && !classes.digit:
+		return ErrPasswordNoDigit.Default()
+
+	case p.RequireSymbol && !classes.symbol:
+		return ErrPasswordNoSymbol.Default()
+	}
+
+	if p.MinEntropyBits > 0 {
+		entropy := EstimateEntropy(password)
+		if entropy < p.MinEntropyBits {
+			return ErrPasswordLowEntropy.New(fmt.Sprintf(
+				"estimated password entropy of %.1f bits is "+
+					"below the minimum of %.1f bits", entropy,
+				p.MinEntropyBits), nil)
+		}
+	}
+
+	return nil
+}
+
+// passwordClasses records which classes of characters a password contains.
+type passwordClasses struct {
+	upper, lower, digit, symbol bool
+}
+
+// charClasses returns the classes of characters the password contains.
+func charClasses(password []byte) passwordClasses {
+	var classes passwordClasses
+	for _, r := range string(password) {
+		switch {
+		case unicode.IsUpper(r):
+			classes.upper = true
+		case unicode.IsLower(r):
+			classes.lower = true
+		case unicode.IsDigit(r):
+			classes.digit = true
+		default:
+			classes.symbol = true
+		}
+	}
+
+	return classes
+}
+
+// EstimateEntropy returns a rough estimate of the entropy of a password in
+// bits, assuming each of its characters was picked at random from the classes
+// of characters it contains. Repeated characters only count once, so that a
+// password like "aaaaaaaaaaaa" isn't considered strong.
+//
+// NOTE: This is an upper bound which doesn't detect dictionary words or
+// common patterns, so it should be combined with a reasonable minimum.
+func EstimateEntropy(password []byte) float64 {
+	classes := charClasses(password)
+
+	var poolSize int
+	if classes.upper {
+		poolSize += 26
+	}
+	if classes.lower {
+		poolSize += 26
+	}
+	if classes.digit {
+		poolSize += 10
+	}
+	if classes.symbol {
+		poolSize += 33
+	}
+	if poolSize == 0 {
+		return 0
+	}
+
+	unique := make(map[rune]struct{})
+	for _, r := range string(password) {
+		unique[r] = struct{}{}
+	}
+
+	return float64(len(unique)) * math.Log2(float64(poolSize))
+}
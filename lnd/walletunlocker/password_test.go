@@ -0,0 +1,157 @@
+package walletunlocker_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/kaotisk-hund/cjdcoind/lnd/lnrpc"
+	"github.com/kaotisk-hund/cjdcoind/lnd/walletunlocker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDefaultPasswordPolicy asserts that the default policy only requires a
+// password to have at least 8 bytes, as ValidatePassword always did.
+func TestDefaultPasswordPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := walletunlocker.DefaultPasswordPolicy
+	for _, password := range []string{"", "a", "1234567"} {
+		err := policy.Validate([]byte(password))
+		require.True(t, walletunlocker.ErrPasswordTooShort.Is(err))
+		require.Contains(
+			t, err.Message(), "password must have at least 8 "+
+				"characters",
+		)
+
+		err = walletunlocker.ValidatePassword([]byte(password))
+		require.True(t, walletunlocker.ErrPasswordTooShort.Is(err))
+	}
+
+	// Neither character classes nor entropy are checked, and the length
+	// is counted in bytes.
+	for _, password := range []string{
+		"aaaaaaaa", "12345678", "password", "ääää",
+	} {
+		util.RequireNoErr(t, policy.Validate([]byte(password)))
+		util.RequireNoErr(
+			t, walletunlocker.ValidatePassword([]byte(password)),
+		)
+	}
+}
+
+// TestPasswordPolicy asserts that each rule of a password policy rejects
+// passwords violating it with its own error.
+func TestPasswordPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policy   walletunlocker.PasswordPolicy
+		password string
+		err      *er.ErrorCode
+	}{
+		{
+			name:     "min length",
+			policy:   walletunlocker.PasswordPolicy{MinLength: 12},
+			password: "Short-pw1",
+			err:      walletunlocker.ErrPasswordTooShort,
+		},
+		{
+			name:     "upper",
+			policy:   walletunlocker.PasswordPolicy{RequireUpper: true},
+			password: "lower-case-1",
+			err:      walletunlocker.ErrPasswordNoUpper,
+		},
+		{
+			name:     "lower",
+			policy:   walletunlocker.PasswordPolicy{RequireLower: true},
+			password: "UPPER-CASE-1",
+			err:      walletunlocker.ErrPasswordNoLower,
+		},
+		{
+			name:     "digit",
+			policy:   walletunlocker.PasswordPolicy{RequireDigit: true},
+			password: "No-Digits-Here",
+			err:      walletunlocker.ErrPasswordNoDigit,
+		},
+		{
+			name:     "symbol",
+			policy:   walletunlocker.PasswordPolicy{RequireSymbol: true},
+			password: "NoSymbols123",
+			err:      walletunlocker.ErrPasswordNoSymbol,
+		},
+		{
+			name: "entropy",
+			policy: walletunlocker.PasswordPolicy{
+				MinEntropyBits: 40,
+			},
+			password: "aaaaaaaaaaaaaaaaaaaa",
+			err:      walletunlocker.ErrPasswordLowEntropy,
+		},
+	}
+
+	strong := []byte("Correct-Horse-Battery-Staple-42")
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Validate([]byte(test.password))
+			require.True(
+				t, test.err.Is(err), "unexpected error: %v",
+				err,
+			)
+
+			// A password meeting every rule is accepted.
+			util.RequireNoErr(t, test.policy.Validate(strong))
+		})
+	}
+}
+
+// TestEstimateEntropy asserts that the entropy estimate grows with the classes
+// of characters used and ignores repeated characters.
+func TestEstimateEntropy(t *testing.T) {
+	t.Parallel()
+
+	require.Zero(t, walletunlocker.EstimateEntropy(nil))
+
+	// A single lower case letter picked from 26 possible ones.
+	require.InDelta(
+		t, 4.70, walletunlocker.EstimateEntropy([]byte("a")), 0.01,
+	)
+	require.Equal(
+		t, walletunlocker.EstimateEntropy([]byte("a")),
+		walletunlocker.EstimateEntropy([]byte("aaaa")),
+	)
+	require.Less(
+		t, walletunlocker.EstimateEntropy([]byte("abcd")),
+		walletunlocker.EstimateEntropy([]byte("aB1!")),
+	)
+}
+
+// TestInitWalletPasswordPolicy asserts that InitWallet enforces the policy
+// configured on the service.
+func TestInitWalletPasswordPolicy(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testpasswordpolicy")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	service := walletunlocker.New(testDir, testNetParams, true, nil)
+	service.PasswordPolicy = walletunlocker.PasswordPolicy{
+		MinLength:    8,
+		RequireDigit: true,
+	}
+
+	// The test password is long enough, but doesn't contain a digit.
+	req := &lnrpc.InitWalletRequest{
+		WalletPassword: testPassword,
+	}
+	_, err := service.InitWallet0(context.Background(), req)
+	require.True(t, walletunlocker.ErrPasswordNoDigit.Is(err))
+}
@@ -15,9 +15,17 @@ import (
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet"
 	"github.com/kaotisk-hund/cjdcoind/lnd/lnwallet/btcwallet"
 	"github.com/kaotisk-hund/cjdcoind/lnd/macaroons"
+	"github.com/kaotisk-hund/cjdcoind/cjdcoinwallet/waddrmgr"
 	"github.com/kaotisk-hund/cjdcoind/cjdcoinwallet/wallet"
 )
 
+const (
+	// maxSeedBirthdaySkew is how far a seed birthday given to GenSeed may
+	// lie in the future, to allow for clock skew between the client and
+	// the daemon.
+	maxSeedBirthdaySkew = 2 * time.Hour
+)
+
 var (
 	// ErrUnlockTimeout signals that we did not get the expected unlock
 	// message before the timeout occurred.
@@ -102,6 +110,26 @@ type WalletUnlockMsg struct {
 	// initialized stateless, which means no unencrypted macaroons should be
 	// written to disk.
 	StatelessInit bool
+
+	// Progress is used to report the progress of the remaining unlock
+	// stages, such as the recovery rescan, back to the caller. It may be
+	// nil if the caller isn't interested in progress updates.
+	Progress UnlockProgressFunc
+}
+
+// UnlockProgressFunc is a function that is called to report the progress of a
+// wallet unlock. The progress value ranges from 0 to 1 and is only meaningful
+// for the RESCAN_PROGRESS stage.
+type UnlockProgressFunc func(stage lnrpc.UnlockWalletProgress_Stage,
+	progress float64)
+
+// Report calls the progress function if it is set.
+func (f UnlockProgressFunc) Report(stage lnrpc.UnlockWalletProgress_Stage,
+	progress float64) {
+
+	if f != nil {
+		f(stage, progress)
+	}
 }
 
 // UnlockerService implements the WalletUnlocker service used to provide lnd
@@ -125,6 +153,11 @@ type UnlockerService struct {
 	noFreelistSync bool
 	netParams      *chaincfg.Params
 
+	// PasswordPolicy is the policy new wallet passwords must meet when
+	// initializing the wallet or changing its password. It defaults to
+	// DefaultPasswordPolicy.
+	PasswordPolicy PasswordPolicy
+
 	// macaroonFiles is the path to the three generated macaroons with
 	// different access permissions. These might not exist in a stateless
 	// initialization of lnd.
@@ -147,6 +180,7 @@ func New(chainDir string, params *chaincfg.Params, noFreelistSync bool,
 		chainDir:        chainDir,
 		netParams:       params,
 		macaroonFiles:   macaroonFiles,
+		PasswordPolicy:  DefaultPasswordPolicy,
 	}
 }
 
@@ -201,11 +235,30 @@ func (u *UnlockerService) GenSeed0(_ context.Context,
 		}
 	}
 
+	// If the user provided a birthday, we'll make sure it's sane. As the
+	// birthday is stored as the number of days since the genesis date, it
+	// can't lie before it.
+	birthday := time.Now()
+	if in.SeedBirthdayUnix != 0 {
+		birthday = time.Unix(in.SeedBirthdayUnix, 0)
+
+		switch {
+		case birthday.Before(aezeed.BitcoinGenesisDate):
+			return nil, er.Errorf("seed birthday %v is before the "+
+				"genesis date %v", birthday,
+				aezeed.BitcoinGenesisDate)
+
+		case birthday.After(time.Now().Add(maxSeedBirthdaySkew)):
+			return nil, er.Errorf("seed birthday %v is in the "+
+				"future", birthday)
+		}
+	}
+
 	// Now that we have our set of entropy, we'll create a new cipher seed
 	// instance.
 	//
 	cipherSeed, err := aezeed.New(
-		keychain.KeyDerivationVersion, &entropy, time.Now(),
+		keychain.KeyDerivationVersion, &entropy, birthday,
 	)
 	if err != nil {
 		return nil, err
@@ -231,6 +284,34 @@ func (u *UnlockerService) GenSeed0(_ context.Context,
 	}, nil
 }
 
+func (u *UnlockerService) WalletExists(ctx context.Context,
+	in *lnrpc.WalletExistsRequest) (*lnrpc.WalletExistsResponse, error) {
+	res, err := u.WalletExists0(ctx, in)
+	return res, er.Native(err)
+}
+
+// WalletExists reports whether a wallet exists in the chain's wallet database
+// directory, along with the path of the wallet database. The wallet isn't
+// opened, so no password is required.
+func (u *UnlockerService) WalletExists0(_ context.Context,
+	_ *lnrpc.WalletExistsRequest) (*lnrpc.WalletExistsResponse, er.R) {
+
+	netDir := btcwallet.NetworkDir(u.chainDir, u.netParams)
+	loader := wallet.NewLoader(
+		u.netParams, netDir, "wallet.db", u.noFreelistSync, 0,
+	)
+
+	walletExists, err := loader.WalletExists()
+	if err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.WalletExistsResponse{
+		Exists:     walletExists,
+		WalletPath: wallet.WalletDbPath(netDir, "wallet.db"),
+	}, nil
+}
+
 // extractChanBackups is a helper function that extracts the set of channel
 // backups from the proto into a format that we'll pass to higher level
 // sub-systems.
@@ -291,7 +372,7 @@ func (u *UnlockerService) InitWallet0(ctx context.Context,
 
 	// Make sure the password meets our constraints.
 	password := in.WalletPassword
-	if err := ValidatePassword(password); err != nil {
+	if err := u.PasswordPolicy.Validate(password); err != nil {
 		return nil, err
 	}
 
@@ -383,6 +464,77 @@ func (u *UnlockerService) UnlockWallet(ctx context.Context,
 func (u *UnlockerService) UnlockWallet0(ctx context.Context,
 	in *lnrpc.UnlockWalletRequest) (*lnrpc.UnlockWalletResponse, er.R) {
 
+	return u.unlockWallet(ctx, in, nil)
+}
+
+func (u *UnlockerService) UnlockWalletStream(in *lnrpc.UnlockWalletRequest,
+	stream lnrpc.WalletUnlocker_UnlockWalletStreamServer) error {
+	return er.Native(u.UnlockWalletStream0(in, stream))
+}
+
+// UnlockWalletStream unlocks the wallet in the same way as UnlockWallet, but
+// additionally sends progress updates over the stream until the unlock, and
+// the recovery rescan if one was requested, is complete.
+func (u *UnlockerService) UnlockWalletStream0(in *lnrpc.UnlockWalletRequest,
+	stream lnrpc.WalletUnlocker_UnlockWalletStreamServer) er.R {
+
+	ctx := stream.Context()
+
+	// Progress is reported both from within unlockWallet and, once the
+	// wallet has been handed over, from the main daemon. In both cases we
+	// must not block the reporter if the client has gone away.
+	updates := make(chan *lnrpc.UnlockWalletProgress)
+	progress := func(stage lnrpc.UnlockWalletProgress_Stage,
+		progress float64) {
+
+		select {
+		case updates <- &lnrpc.UnlockWalletProgress{
+			Stage:    stage,
+			Progress: progress,
+		}:
+		case <-ctx.Done():
+		}
+	}
+
+	errChan := make(chan er.R, 1)
+	go func() {
+		_, err := u.unlockWallet(ctx, in, progress)
+		errChan <- err
+	}()
+
+	for {
+		select {
+		case update := <-updates:
+			if err := stream.Send(update); err != nil {
+				return er.E(err)
+			}
+			if update.Stage == lnrpc.UnlockWalletProgress_COMPLETE {
+				return nil
+			}
+
+		// If the unlock itself failed, there won't be any more
+		// updates. Otherwise we keep waiting for the daemon to report
+		// completion.
+		case err := <-errChan:
+			if err != nil {
+				return err
+			}
+			errChan = nil
+
+		case <-ctx.Done():
+			return er.E(ctx.Err())
+		}
+	}
+}
+
+// unlockWallet attempts to open the existing wallet with the password from the
+// request and hands it over to the main daemon. The given progress function,
+// which may be nil, is passed along so that the progress of the unlock can be
+// reported to the caller.
+func (u *UnlockerService) unlockWallet(ctx context.Context,
+	in *lnrpc.UnlockWalletRequest,
+	progress UnlockProgressFunc) (*lnrpc.UnlockWalletResponse, er.R) {
+
 	password := in.WalletPassword
 	recoveryWindow := uint32(in.RecoveryWindow)
 
@@ -409,6 +561,7 @@ func (u *UnlockerService) UnlockWallet0(ctx context.Context,
 		// password was incorrect.
 		return nil, err
 	}
+	progress.Report(lnrpc.UnlockWalletProgress_SCRYPT_DONE, 0)
 
 	// We successfully opened the wallet and pass the instance back to
 	// avoid it needing to be unlocked again.
@@ -418,6 +571,7 @@ func (u *UnlockerService) UnlockWallet0(ctx context.Context,
 		Wallet:         unlockedWallet,
 		UnloadWallet:   loader.UnloadWallet,
 		StatelessInit:  in.StatelessInit,
+		Progress:       progress,
 	}
 
 	// Before we return the unlock payload, we'll check if we can extract
@@ -448,12 +602,126 @@ func (u *UnlockerService) UnlockWallet0(ctx context.Context,
 	}
 }
 
+func (u *UnlockerService) CheckPassword(ctx context.Context,
+	in *lnrpc.UnlockWalletRequest) (*lnrpc.CheckPasswordResponse, error) {
+	res, err := u.CheckPassword0(ctx, in)
+	return res, er.Native(err)
+}
+
+// CheckPassword checks whether the password of the incoming
+// UnlockWalletRequest decrypts the existing wallet. The wallet is unloaded
+// again right away and nothing is sent over the UnlockMsgs channel, so the
+// daemon isn't started.
+//
+// NOTE: Opening the wallet incurs the full scrypt cost of an unlock, so this
+// must not be called in a tight loop.
+func (u *UnlockerService) CheckPassword0(_ context.Context,
+	in *lnrpc.UnlockWalletRequest) (*lnrpc.CheckPasswordResponse, er.R) {
+
+	netDir := btcwallet.NetworkDir(u.chainDir, u.netParams)
+	loader := wallet.NewLoader(
+		u.netParams, netDir, "wallet.db", u.noFreelistSync, 0,
+	)
+
+	walletExists, err := loader.WalletExists()
+	if err != nil {
+		return nil, err
+	}
+
+	if !walletExists {
+		return nil, er.Errorf("wallet not found at path [%s/wallet.db]", netDir)
+	}
+
+	// Opening the wallet fails if the password doesn't decrypt it. If it
+	// succeeds, the wallet is unloaded again right away, without handing
+	// it over to the daemon.
+	_, err = loader.OpenExistingWallet(in.WalletPassword, false)
+	if waddrmgr.ErrWrongPassphrase.Is(err) {
+		return &lnrpc.CheckPasswordResponse{Valid: false}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := loader.UnloadWallet(); err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.CheckPasswordResponse{Valid: true}, nil
+}
+
+func (u *UnlockerService) ChangeSeedPassphrase(ctx context.Context,
+	in *lnrpc.ChangeSeedPassphraseRequest) (*lnrpc.ChangeSeedPassphraseResponse, error) {
+	res, err := u.ChangeSeedPassphrase0(ctx, in)
+	return res, er.Native(err)
+}
+
+// ChangeSeedPassphrase deciphers the aezeed cipher seed of the given mnemonic
+// with its current passphrase and re-enciphers it under the new one. Only the
+// seed material is involved, the wallet itself isn't touched.
+func (u *UnlockerService) ChangeSeedPassphrase0(_ context.Context,
+	in *lnrpc.ChangeSeedPassphraseRequest) (*lnrpc.ChangeSeedPassphraseResponse, er.R) {
+
+	if len(in.CipherSeedMnemonic) != aezeed.NummnemonicWords {
+		return nil, er.Errorf("mnemonic must have %d words, instead "+
+			"got %d words", aezeed.NummnemonicWords,
+			len(in.CipherSeedMnemonic))
+	}
+
+	var mnemonic aezeed.Mnemonic
+	copy(mnemonic[:], in.CipherSeedMnemonic)
+
+	// If the current passphrase doesn't decipher the seed, we can't go
+	// any further.
+	cipherSeed, err := mnemonic.ToCipherSeed(in.CurrentAezeedPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	newMnemonic, err := cipherSeed.ToMnemonic(in.NewAezeedPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	encipheredSeed, err := cipherSeed.Encipher(in.NewAezeedPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.ChangeSeedPassphraseResponse{
+		CipherSeedMnemonic: []string(newMnemonic[:]),
+		EncipheredSeed:     encipheredSeed[:],
+	}, nil
+}
+
 func (u *UnlockerService) ChangePassword(ctx context.Context,
 	in *lnrpc.ChangePasswordRequest) (*lnrpc.ChangePasswordResponse, error) {
 	res, err := u.ChangePassword0(ctx, in)
 	return res, er.Native(err)
 }
 
+// invalidateMacaroonFiles removes the macaroon files generated for the current
+// macaroon root key. A missing file is only tolerated if the wallet was
+// initialized stateless, in which case no macaroon files are written to disk.
+func (u *UnlockerService) invalidateMacaroonFiles(statelessInit bool) er.R {
+	for _, file := range u.macaroonFiles {
+		err := os.Remove(file)
+		switch {
+		case err == nil, os.IsNotExist(err) && statelessInit:
+			continue
+
+		case os.IsNotExist(err):
+			return er.Errorf("could not remove macaroon file: %v. "+
+				"if the wallet was initialized stateless "+
+				"please add the --stateless_init flag", err)
+
+		default:
+			return er.Errorf("could not remove macaroon file: %v",
+				err)
+		}
+	}
+
+	return nil
+}
+
 // ChangePassword changes the password of the wallet and sends the new password
 // across the UnlockPasswords channel to automatically unlock the wallet if
 // successful.
@@ -485,7 +753,7 @@ func (u *UnlockerService) ChangePassword0(ctx context.Context,
 	}
 
 	// Make sure the new password meets our constraints.
-	if err := ValidatePassword(in.NewPassword); err != nil {
+	if err := u.PasswordPolicy.Validate(in.NewPassword); err != nil {
 		return nil, err
 	}
 
@@ -508,18 +776,10 @@ func (u *UnlockerService) ChangePassword0(ctx context.Context,
 	// were set correctly. The content of the previously generated macaroon
 	// files will become invalid after we generate a new root key. So we try
 	// to delete them here and they will be recreated during normal startup
-	// later. If they are missing, this is only an error if the
-	// stateless_init flag was not set.
+	// later.
 	if in.NewMacaroonRootKey || in.StatelessInit {
-		for _, file := range u.macaroonFiles {
-			err := os.Remove(file)
-			if err != nil && !in.StatelessInit {
-				return nil, er.Errorf("could not remove "+
-					"macaroon file: %v. if the wallet "+
-					"was initialized stateless please "+
-					"add the --stateless_init "+
-					"flag", err)
-			}
+		if err := u.invalidateMacaroonFiles(in.StatelessInit); err != nil {
+			return nil, err
 		}
 	}
 
@@ -616,12 +876,8 @@ func (u *UnlockerService) ChangePassword0(ctx context.Context,
 	}
 }
 
-// ValidatePassword assures the password meets all of our constraints.
+// ValidatePassword assures the password meets the constraints of the
+// DefaultPasswordPolicy.
 func ValidatePassword(password []byte) er.R {
-	// Passwords should have a length of at least 8 characters.
-	if len(password) < 8 {
-		return er.New("password must have at least 8 characters")
-	}
-
-	return nil
+	return DefaultPasswordPolicy.Validate(password)
 }
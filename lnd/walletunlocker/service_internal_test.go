@@ -0,0 +1,60 @@
+package walletunlocker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaotisk-hund/cjdcoind/btcutil/util"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInvalidateMacaroonFiles tests that the macaroon files are removed and
+// that missing files are only tolerated for a stateless wallet.
+func TestInvalidateMacaroonFiles(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testmacaroonfiles")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	macaroonFiles := []string{
+		filepath.Join(testDir, "admin.macaroon"),
+		filepath.Join(testDir, "readonly.macaroon"),
+		filepath.Join(testDir, "invoice.macaroon"),
+	}
+	writeFiles := func() {
+		for _, file := range macaroonFiles {
+			errr := ioutil.WriteFile(file, []byte("mac"), 0600)
+			require.NoError(t, errr)
+		}
+	}
+	service := &UnlockerService{macaroonFiles: macaroonFiles}
+
+	// Files that are present are removed in both modes.
+	for _, stateless := range []bool{false, true} {
+		writeFiles()
+		util.RequireNoErr(t, service.invalidateMacaroonFiles(stateless))
+		for _, file := range macaroonFiles {
+			_, errr := os.Stat(file)
+			require.True(t, os.IsNotExist(errr))
+		}
+	}
+
+	// Missing files are an error unless the wallet is stateless.
+	util.RequireErr(t, service.invalidateMacaroonFiles(false))
+	util.RequireNoErr(t, service.invalidateMacaroonFiles(true))
+
+	// A partially missing set of files is still removed in stateless
+	// mode.
+	writeFiles()
+	require.NoError(t, os.Remove(macaroonFiles[0]))
+	util.RequireNoErr(t, service.invalidateMacaroonFiles(true))
+	for _, file := range macaroonFiles {
+		_, errr := os.Stat(file)
+		require.True(t, os.IsNotExist(errr))
+	}
+}
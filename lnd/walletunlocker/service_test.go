@@ -168,6 +168,52 @@ func TestGenSeed(t *testing.T) {
 	util.RequireNoErr(t, err)
 }
 
+// TestGenSeedBirthday tests that a seed generated with an explicit birthday is
+// stamped with it, and that birthdays before the genesis date or in the future
+// are rejected.
+func TestGenSeedBirthday(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testgenseedbirthday")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	service := walletunlocker.New(testDir, testNetParams, true, nil)
+	ctx := context.Background()
+
+	birthday := time.Date(2015, time.March, 1, 0, 0, 0, 0, time.UTC)
+	seedResp, err := service.GenSeed0(ctx, &lnrpc.GenSeedRequest{
+		SeedEntropy:      testEntropy[:],
+		SeedBirthdayUnix: birthday.Unix(),
+	})
+	util.RequireNoErr(t, err)
+
+	var mnemonic aezeed.Mnemonic
+	copy(mnemonic[:], seedResp.CipherSeedMnemonic)
+	cipherSeed, err := mnemonic.ToCipherSeed(nil)
+	util.RequireNoErr(t, err)
+
+	// The birthday is stored with a granularity of days.
+	require.WithinDuration(
+		t, birthday, cipherSeed.BirthdayTime(), 24*time.Hour,
+	)
+
+	// Birthdays which can't be represented or lie in the future are
+	// rejected.
+	invalidBirthdays := []time.Time{
+		aezeed.BitcoinGenesisDate.Add(-time.Second),
+		time.Now().Add(24 * time.Hour),
+	}
+	for _, invalid := range invalidBirthdays {
+		_, err := service.GenSeed0(ctx, &lnrpc.GenSeedRequest{
+			SeedBirthdayUnix: invalid.Unix(),
+		})
+		util.RequireErr(t, err)
+	}
+}
+
 // TestGenSeedInvalidEntropy tests that the gen seed method generates a valid
 // cipher seed mnemonic pass phrase even when the user doesn't provide its own
 // source of entropy.
@@ -347,6 +393,119 @@ func TestCreateWalletInvalidEntropy(t *testing.T) {
 	require.Error(t, errr)
 }
 
+// TestChangeSeedPassphrase tests that a cipher seed can be re-enciphered under
+// a new passphrase, and that the current passphrase is required to do so.
+func TestChangeSeedPassphrase(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testchangeseedpass")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	service := walletunlocker.New(testDir, testNetParams, true, nil)
+	ctx := context.Background()
+
+	oldPass := []byte("kek")
+	newPass := []byte("new-kek")
+	cipherSeed, mnemonic := createSeedAndMnemonic(t, oldPass)
+
+	// A mnemonic with the wrong number of words is rejected.
+	_, err := service.ChangeSeedPassphrase0(
+		ctx, &lnrpc.ChangeSeedPassphraseRequest{
+			CipherSeedMnemonic:      mnemonic[:23],
+			CurrentAezeedPassphrase: oldPass,
+			NewAezeedPassphrase:     newPass,
+		},
+	)
+	util.RequireErr(t, err)
+
+	// A wrong current passphrase must not decipher the seed.
+	_, err = service.ChangeSeedPassphrase0(
+		ctx, &lnrpc.ChangeSeedPassphraseRequest{
+			CipherSeedMnemonic:      mnemonic[:],
+			CurrentAezeedPassphrase: []byte("wrong"),
+			NewAezeedPassphrase:     newPass,
+		},
+	)
+	require.True(t, aezeed.ErrInvalidPass.Is(err))
+
+	resp, err := service.ChangeSeedPassphrase0(
+		ctx, &lnrpc.ChangeSeedPassphraseRequest{
+			CipherSeedMnemonic:      mnemonic[:],
+			CurrentAezeedPassphrase: oldPass,
+			NewAezeedPassphrase:     newPass,
+		},
+	)
+	util.RequireNoErr(t, err)
+
+	// The new mnemonic must decipher to the same seed under the new
+	// passphrase only.
+	var newMnemonic aezeed.Mnemonic
+	copy(newMnemonic[:], resp.CipherSeedMnemonic)
+	_, err = newMnemonic.ToCipherSeed(oldPass)
+	require.True(t, aezeed.ErrInvalidPass.Is(err))
+
+	newCipherSeed, err := newMnemonic.ToCipherSeed(newPass)
+	util.RequireNoErr(t, err)
+	require.Equal(t, cipherSeed.Entropy, newCipherSeed.Entropy)
+	require.Equal(t, cipherSeed.Birthday, newCipherSeed.Birthday)
+
+	encipheredSeed, err := newCipherSeed.Encipher(newPass)
+	util.RequireNoErr(t, err)
+	require.Equal(t, encipheredSeed[:], resp.EncipheredSeed)
+
+	// Changing the passphrase back yields the original mnemonic.
+	resp, err = service.ChangeSeedPassphrase0(
+		ctx, &lnrpc.ChangeSeedPassphraseRequest{
+			CipherSeedMnemonic:      newMnemonic[:],
+			CurrentAezeedPassphrase: newPass,
+			NewAezeedPassphrase:     oldPass,
+		},
+	)
+	util.RequireNoErr(t, err)
+	require.Equal(t, mnemonic[:], resp.CipherSeedMnemonic)
+}
+
+// TestWalletExists tests that the existence of a wallet can be probed without a
+// password and without opening the wallet.
+func TestWalletExists(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testwalletexists")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	service := walletunlocker.New(testDir, testNetParams, true, nil)
+	ctx := context.Background()
+
+	netDir := btcwallet.NetworkDir(testDir, testNetParams)
+	walletPath := path.Join(netDir, "wallet.db")
+
+	resp, err := service.WalletExists0(ctx, &lnrpc.WalletExistsRequest{})
+	util.RequireNoErr(t, err)
+	require.False(t, resp.Exists)
+	require.Equal(t, walletPath, resp.WalletPath)
+
+	createTestWallet(t, testDir, testNetParams)
+
+	resp, err = service.WalletExists0(ctx, &lnrpc.WalletExistsRequest{})
+	util.RequireNoErr(t, err)
+	require.True(t, resp.Exists)
+	require.Equal(t, walletPath, resp.WalletPath)
+
+	// As the wallet wasn't opened by the probe, it can still be
+	// unlocked.
+	resp2, err := service.CheckPassword0(ctx, &lnrpc.UnlockWalletRequest{
+		WalletPassword: testPassword,
+	})
+	util.RequireNoErr(t, err)
+	require.True(t, resp2.Valid)
+}
+
 // TestUnlockWallet checks that trying to unlock non-existing wallet fail, that
 // unlocking existing wallet with wrong passphrase fails, and that unlocking
 // existing wallet with correct passphrase succeeds.
@@ -414,6 +573,178 @@ func TestUnlockWallet(t *testing.T) {
 	}
 }
 
+// TestCheckPassword tests that a password can be checked without unlocking
+// the wallet, and that the wallet can still be unlocked afterwards.
+func TestCheckPassword(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testcheckpassword")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	service := walletunlocker.New(testDir, testNetParams, true, nil)
+	ctx := context.Background()
+	req := &lnrpc.UnlockWalletRequest{
+		WalletPassword: testPassword,
+	}
+
+	// Checking the password of a non-existing wallet should fail.
+	_, err := service.CheckPassword0(ctx, req)
+	util.RequireErr(t, err)
+
+	createTestWallet(t, testDir, testNetParams)
+
+	// A wrong password is reported as invalid, rather than as an error.
+	resp, err := service.CheckPassword0(ctx, &lnrpc.UnlockWalletRequest{
+		WalletPassword: []byte("wrong-ofc"),
+	})
+	util.RequireNoErr(t, err)
+	require.False(t, resp.Valid)
+
+	// The correct password is reported as valid, without anything being
+	// sent to the daemon.
+	resp, err = service.CheckPassword0(ctx, req)
+	util.RequireNoErr(t, err)
+	require.True(t, resp.Valid)
+	select {
+	case <-service.UnlockMsgs:
+		t.Fatalf("unexpected unlock message")
+	default:
+	}
+
+	// As the wallet was unloaded again, it can be checked once more and
+	// then be unlocked.
+	resp, err = service.CheckPassword0(ctx, req)
+	util.RequireNoErr(t, err)
+	require.True(t, resp.Valid)
+
+	errChan := make(chan er.R, 1)
+	go func() {
+		_, err := service.UnlockWallet0(ctx, req)
+		errChan <- err
+	}()
+
+	select {
+	case err := <-errChan:
+		t.Fatalf("UnlockWallet call failed: %v", err)
+
+	case unlockMsg := <-service.UnlockMsgs:
+		require.Equal(t, testPassword, unlockMsg.Passphrase)
+		service.MacResponseChan <- testMac
+		util.RequireNoErr(t, unlockMsg.UnloadWallet())
+
+	case <-time.After(defaultTestTimeout):
+		t.Fatalf("password not received")
+	}
+	util.RequireNoErr(t, <-errChan)
+}
+
+// mockUnlockStream is a mock implementation of the server side of the
+// UnlockWalletStream RPC that records all progress updates sent to it.
+type mockUnlockStream struct {
+	lnrpc.WalletUnlocker_UnlockWalletStreamServer
+
+	ctx     context.Context
+	updates chan *lnrpc.UnlockWalletProgress
+}
+
+func (m *mockUnlockStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockUnlockStream) Send(update *lnrpc.UnlockWalletProgress) error {
+	m.updates <- update
+	return nil
+}
+
+// TestUnlockWalletStream tests that unlocking the wallet through the streaming
+// RPC reports the progress of the unlock, including the progress reported by
+// the daemon after the wallet has been handed over.
+func TestUnlockWalletStream(t *testing.T) {
+	t.Parallel()
+
+	testDir, errr := ioutil.TempDir("", "testunlockstream")
+	require.NoError(t, errr)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
+
+	service := walletunlocker.New(testDir, testNetParams, true, nil)
+	createTestWallet(t, testDir, testNetParams)
+
+	stream := &mockUnlockStream{
+		ctx:     context.Background(),
+		updates: make(chan *lnrpc.UnlockWalletProgress, 10),
+	}
+	req := &lnrpc.UnlockWalletRequest{
+		WalletPassword: testPassword,
+		RecoveryWindow: int32(testRecoveryWindow),
+	}
+
+	errChan := make(chan er.R, 1)
+	go func() {
+		errChan <- service.UnlockWalletStream0(req, stream)
+	}()
+
+	// We should be told that the wallet was decrypted before it is
+	// handed over to the daemon.
+	select {
+	case update := <-stream.updates:
+		require.Equal(
+			t, lnrpc.UnlockWalletProgress_SCRYPT_DONE, update.Stage,
+		)
+
+	case <-time.After(defaultTestTimeout):
+		t.Fatalf("no progress received")
+	}
+
+	var unlockMsg *walletunlocker.WalletUnlockMsg
+	select {
+	case unlockMsg = <-service.UnlockMsgs:
+		require.NotNil(t, unlockMsg.Progress)
+		service.MacResponseChan <- testMac
+
+	case <-time.After(defaultTestTimeout):
+		t.Fatalf("password not received")
+	}
+
+	// Act as the daemon and report the progress of the recovery.
+	unlockMsg.Progress(lnrpc.UnlockWalletProgress_RESCAN_STARTED, 0)
+	unlockMsg.Progress(lnrpc.UnlockWalletProgress_RESCAN_PROGRESS, 0.5)
+	unlockMsg.Progress(lnrpc.UnlockWalletProgress_COMPLETE, 1)
+
+	expected := []*lnrpc.UnlockWalletProgress{
+		{Stage: lnrpc.UnlockWalletProgress_RESCAN_STARTED},
+		{
+			Stage:    lnrpc.UnlockWalletProgress_RESCAN_PROGRESS,
+			Progress: 0.5,
+		},
+		{Stage: lnrpc.UnlockWalletProgress_COMPLETE, Progress: 1},
+	}
+	for _, exp := range expected {
+		select {
+		case update := <-stream.updates:
+			require.Equal(t, exp.Stage, update.Stage)
+			require.Equal(t, exp.Progress, update.Progress)
+
+		case <-time.After(defaultTestTimeout):
+			t.Fatalf("no progress received")
+		}
+	}
+
+	// Once complete, the stream should be closed without an error.
+	select {
+	case err := <-errChan:
+		util.RequireNoErr(t, err)
+
+	case <-time.After(defaultTestTimeout):
+		t.Fatalf("stream not closed")
+	}
+	util.RequireNoErr(t, unlockMsg.UnloadWallet())
+}
+
 // TestChangeWalletPasswordNewRootkey tests that we can successfully change the
 // wallet's password needed to unlock it and rotate the root key for the
 // macaroons in the same process.
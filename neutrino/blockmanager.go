@@ -2908,13 +2908,12 @@ func (b *blockManager) calcNextRequiredDifficulty(newBlockTime time.Time,
 		if b.server.chainParams.ReduceMinDifficulty {
 			// Return minimum difficulty when more than the desired
 			// amount of time has elapsed without mining a block.
-			reductionTime := int64(
-				b.server.chainParams.MinDiffReductionTime /
-					time.Second)
-			allowMinTime := lastNode.Header.Timestamp.Unix() +
-				reductionTime
-			if newBlockTime.Unix() > allowMinTime {
-				return b.server.chainParams.PowLimitBits, nil
+			bits, allowed := b.server.chainParams.MinDifficultyAllowed(
+				lastNode.Header.Timestamp, newBlockTime,
+				lastNode.Header.Bits,
+			)
+			if allowed {
+				return bits, nil
 			}
 
 			// The block was mined within the desired timeframe, so
@@ -103,6 +103,32 @@ const (
 	ScriptVerifyWitnessPubKeyType
 )
 
+// enableableOpcodes is the set of disabled opcodes which can be re-enabled
+// through the EnabledOpcodes field of the engine config.
+var enableableOpcodes = map[byte]struct{}{
+	opcode.OP_CAT: {},
+}
+
+// TraceFunc is called by the script engine after each executed opcode with the
+// value of the opcode, the depth of the data stack after executing it, and the
+// error it failed with, if any.
+type TraceFunc func(op byte, stackDepth int32, err er.R)
+
+// Config houses optional settings which alter the consensus rules enforced by
+// the script engine, or how it executes scripts.  The zero value results in the
+// default Bitcoin rules.
+type Config struct {
+	// EnabledOpcodes re-enables opcodes which are disabled by default.  An
+	// empty map leaves the default set of disabled opcodes in place.
+	// Currently only OP_CAT may be enabled.
+	EnabledOpcodes map[byte]bool
+
+	// TraceFunc, if set, is called after each opcode executed by Step,
+	// which allows for stepping through or inspecting the execution of a
+	// script.
+	TraceFunc TraceFunc
+}
+
 // halforder is used to tame ECDSA malleability (see BIP0062).
 var halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
 
@@ -126,6 +152,7 @@ type Engine struct {
 	witnessVersion  int
 	witnessProgram  []byte
 	inputAmount     int64
+	cfg             Config
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -133,6 +160,12 @@ func (vm *Engine) hasFlag(flag ScriptFlags) bool {
 	return vm.flags&flag == flag
 }
 
+// isOpcodeEnabled returns whether the passed opcode, which is disabled by
+// default, has been enabled through the engine config.
+func (vm *Engine) isOpcodeEnabled(op byte) bool {
+	return vm.cfg.EnabledOpcodes[op]
+}
+
 // isBranchExecuting returns whether or not the current conditional branch is
 // actively executing.  For example, when the data stack has an OP_FALSE on it
 // and an OP_IF is encountered, the branch is inactive until an OP_ELSE or
@@ -149,7 +182,7 @@ func (vm *Engine) isBranchExecuting() bool {
 // tested in this case.
 func (vm *Engine) executeOpcode(pop *parsescript.ParsedOpcode) er.R {
 	// Disabled opcodes are fail on program counter.
-	if popIsDisabled(pop) {
+	if popIsDisabled(pop) && !vm.isOpcodeEnabled(pop.Opcode.Value) {
 		str := fmt.Sprintf("attempt to execute disabled opcode %s",
 			opcode.OpcodeName(pop.Opcode.Value))
 		return txscripterr.ScriptError(txscripterr.ErrDisabledOpcode, str)
@@ -443,6 +476,7 @@ func (vm *Engine) Step() (done bool, err er.R) {
 	// script, maximum script element sizes, and conditionals.
 	err = vm.executeOpcode(opcode)
 	if err != nil {
+		vm.trace(opcode, err)
 		return true, err
 	}
 
@@ -452,8 +486,11 @@ func (vm *Engine) Step() (done bool, err er.R) {
 	if combinedStackSize > params.MaxStackSize {
 		str := fmt.Sprintf("combined stack size %d > max allowed %d",
 			combinedStackSize, params.MaxStackSize)
-		return false, txscripterr.ScriptError(txscripterr.ErrStackOverflow, str)
+		err = txscripterr.ScriptError(txscripterr.ErrStackOverflow, str)
+		vm.trace(opcode, err)
+		return false, err
 	}
+	vm.trace(opcode, nil)
 
 	// Prepare for next instruction.
 	if vm.scriptOff >= len(vm.scripts[vm.scriptIdx]) {
@@ -515,6 +552,15 @@ func (vm *Engine) Step() (done bool, err er.R) {
 	return false, nil
 }
 
+// trace passes the result of executing the given opcode to the trace function
+// of the engine config, if any.
+func (vm *Engine) trace(pop *parsescript.ParsedOpcode, err er.R) {
+	if vm.cfg.TraceFunc == nil {
+		return
+	}
+	vm.cfg.TraceFunc(pop.Opcode.Value, vm.dstack.Depth(), err)
+}
+
 // Execute will execute all scripts in the script engine and return either nil
 // for successful validation or an error if one occurred.
 func (vm *Engine) Execute() (err er.R) {
@@ -798,8 +844,10 @@ func (vm *Engine) checkSignatureEncoding(sig []byte) er.R {
 func getStack(stack *stack) [][]byte {
 	array := make([][]byte, stack.Depth())
 	for i := range array {
-		// PeekByteArry can't fail due to overflow, already checked
-		array[len(array)-i-1], _ = stack.PeekByteArray(int32(i))
+		// PeekByteArrayCopy can't fail due to overflow, already
+		// checked.  A copy is returned so callers can't modify the
+		// stack through it.
+		array[len(array)-i-1], _ = stack.PeekByteArrayCopy(int32(i))
 	}
 	return array
 }
@@ -833,6 +881,29 @@ func (vm *Engine) SetStack(data [][]byte) {
 func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags,
 	sigCache *SigCache, hashCache *TxSigHashes, inputAmount int64) (*Engine, er.R) {
 
+	return NewEngineWithConfig(scriptPubKey, tx, txIdx, flags, sigCache,
+		hashCache, inputAmount, nil)
+}
+
+// NewEngineWithConfig returns a new script engine like NewEngine, but applies
+// the given config, which allows for deviating from the default consensus
+// rules.  A nil config is the same as calling NewEngine.
+func NewEngineWithConfig(scriptPubKey []byte, tx *wire.MsgTx, txIdx int,
+	flags ScriptFlags, sigCache *SigCache, hashCache *TxSigHashes,
+	inputAmount int64, cfg *Config) (*Engine, er.R) {
+
+	// Only opcodes which have an implementation may be enabled.
+	if cfg != nil {
+		for op, enabled := range cfg.EnabledOpcodes {
+			if _, ok := enableableOpcodes[op]; enabled && !ok {
+				str := fmt.Sprintf("opcode %s cannot be enabled",
+					opcode.OpcodeName(op))
+				return nil, txscripterr.ScriptError(
+					txscripterr.ErrInvalidFlags, str)
+			}
+		}
+	}
+
 	// The provided transaction input index must refer to a valid input.
 	if txIdx < 0 || txIdx >= len(tx.TxIn) {
 		str := fmt.Sprintf("transaction input index %d is negative or "+
@@ -862,6 +933,9 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 	// additional scripts for execution from the witness stack.
 	vm := Engine{flags: flags, sigCache: sigCache, hashCache: hashCache,
 		inputAmount: inputAmount}
+	if cfg != nil {
+		vm.cfg = *cfg
+	}
 	if vm.hasFlag(ScriptVerifyCleanStack) && (!vm.hasFlag(ScriptBip16) &&
 		!vm.hasFlag(ScriptVerifyWitness)) {
 		return nil, txscripterr.ScriptError(txscripterr.ErrInvalidFlags,
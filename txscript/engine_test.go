@@ -5,10 +5,15 @@
 package txscript
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
 
+	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/chaincfg/chainhash"
 	"github.com/kaotisk-hund/cjdcoind/txscript/opcode"
+	"github.com/kaotisk-hund/cjdcoind/txscript/params"
+	"github.com/kaotisk-hund/cjdcoind/txscript/scriptbuilder"
 	"github.com/kaotisk-hund/cjdcoind/txscript/txscripterr"
 	"github.com/kaotisk-hund/cjdcoind/wire"
 )
@@ -194,6 +199,142 @@ func TestInvalidFlagCombinations(t *testing.T) {
 	}
 }
 
+// TestEnabledOpcodes ensures that disabled opcodes can be re-enabled through
+// the engine config and that OP_CAT enforces the max script element size.
+func TestEnabledOpcodes(t *testing.T) {
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{},
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{
+			Value:    1000000000,
+			PkScript: nil,
+		}},
+	}
+
+	catScript := func(a, b []byte) []byte {
+		script, err := scriptbuilder.NewScriptBuilder().AddData(a).
+			AddData(b).AddOp(opcode.OP_CAT).
+			AddData(append(append([]byte{}, a...), b...)).
+			AddOp(opcode.OP_EQUAL).Script()
+		if err != nil {
+			t.Fatalf("unable to build script: %v", err)
+		}
+		return script
+	}
+	catEnabled := &Config{
+		EnabledOpcodes: map[byte]bool{opcode.OP_CAT: true},
+	}
+
+	// OP_CAT remains disabled when no config is given.
+	pkScript := catScript([]byte("ab"), []byte("c"))
+	vm, err := NewEngine(pkScript, tx, 0, 0, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	if err := vm.Execute(); !txscripterr.ErrDisabledOpcode.Is(err) {
+		t.Fatalf("expected disabled opcode error, got %v", err)
+	}
+
+	// Once enabled, the two items are concatenated.
+	vm, err = NewEngineWithConfig(pkScript, tx, 0, 0, nil, nil, -1,
+		catEnabled)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("unexpected error executing OP_CAT: %v", err)
+	}
+
+	// A result of exactly the max element size is allowed, but one byte
+	// more is rejected.
+	half := bytes.Repeat([]byte{0x01}, params.MaxScriptElementSize/2)
+	pkScript = catScript(half, half)
+	vm, err = NewEngineWithConfig(pkScript, tx, 0, 0, nil, nil, -1,
+		catEnabled)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("unexpected error executing OP_CAT: %v", err)
+	}
+
+	pkScript, err = scriptbuilder.NewScriptBuilder().AddData(half).
+		AddData(append(half, 0x01)).AddOp(opcode.OP_CAT).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	vm, err = NewEngineWithConfig(pkScript, tx, 0, 0, nil, nil, -1,
+		catEnabled)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	if err := vm.Execute(); !txscripterr.ErrElementTooBig.Is(err) {
+		t.Fatalf("expected element too big error, got %v", err)
+	}
+
+	// Opcodes without an implementation can't be enabled.
+	_, err = NewEngineWithConfig(pkScript, tx, 0, 0, nil, nil, -1,
+		&Config{EnabledOpcodes: map[byte]bool{opcode.OP_MUL: true}})
+	if !txscripterr.ErrInvalidFlags.Is(err) {
+		t.Fatalf("expected invalid flags error, got %v", err)
+	}
+}
+
+// TestTraceFunc ensures the trace function of the engine config is called
+// after each executed opcode with the resulting stack depth and error.
+func TestTraceFunc(t *testing.T) {
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{},
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{
+			Value:    1000000000,
+			PkScript: nil,
+		}},
+	}
+
+	type step struct {
+		op    byte
+		depth int32
+		err   bool
+	}
+	var steps []step
+	cfg := &Config{
+		TraceFunc: func(op byte, stackDepth int32, err er.R) {
+			steps = append(steps, step{op, stackDepth, err != nil})
+		},
+	}
+
+	pkScript := []byte{
+		opcode.OP_1, opcode.OP_2, opcode.OP_ADD, opcode.OP_3,
+		opcode.OP_EQUALVERIFY, opcode.OP_RETURN, opcode.OP_1,
+	}
+	vm, err := NewEngineWithConfig(pkScript, tx, 0, 0, nil, nil, -1, cfg)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	if err := vm.Execute(); !txscripterr.ErrEarlyReturn.Is(err) {
+		t.Fatalf("expected early return error, got %v", err)
+	}
+
+	expected := []step{
+		{opcode.OP_1, 1, false},
+		{opcode.OP_2, 2, false},
+		{opcode.OP_ADD, 1, false},
+		{opcode.OP_3, 2, false},
+		{opcode.OP_EQUALVERIFY, 0, false},
+		{opcode.OP_RETURN, 0, true},
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("unexpected trace: want %v, got %v", expected, steps)
+	}
+}
+
 // TestCheckPubKeyEncoding ensures the internal checkPubKeyEncoding function
 // works as expected.
 func TestCheckPubKeyEncoding(t *testing.T) {
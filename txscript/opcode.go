@@ -298,7 +298,7 @@ func executeOp(po *parsescript.ParsedOpcode, e *Engine) er.R {
 
 	// Splice opcodes.
 	case opcode.OP_CAT:
-		return opcodeDisabled(po, e)
+		return opcodeCat(po, e)
 	case opcode.OP_SUBSTR:
 		return opcodeDisabled(po, e)
 	case opcode.OP_LEFT:
@@ -1430,6 +1430,39 @@ func opcodeTuck(op *parsescript.ParsedOpcode, vm *Engine) er.R {
 	return vm.dstack.Tuck()
 }
 
+// opcodeCat removes the top 2 items of the data stack and pushes their
+// concatenation.  The opcode is disabled unless it has been explicitly enabled
+// through the engine config.  The size of the result is limited to the maximum
+// allowed size of a script element.
+//
+// Stack transformation: [... x1 x2] -> [... x1||x2]
+func opcodeCat(op *parsescript.ParsedOpcode, vm *Engine) er.R {
+	if !vm.isOpcodeEnabled(op.Opcode.Value) {
+		return opcodeDisabled(op, vm)
+	}
+
+	b, err := vm.dstack.PopByteArray()
+	if err != nil {
+		return err
+	}
+	a, err := vm.dstack.PopByteArray()
+	if err != nil {
+		return err
+	}
+
+	if len(a)+len(b) > params.MaxScriptElementSize {
+		str := fmt.Sprintf("concatenated size %d exceeds max allowed "+
+			"size %d", len(a)+len(b), params.MaxScriptElementSize)
+		return txscripterr.ScriptError(txscripterr.ErrElementTooBig, str)
+	}
+
+	cat := make([]byte, 0, len(a)+len(b))
+	cat = append(cat, a...)
+	cat = append(cat, b...)
+	vm.dstack.PushByteArray(cat)
+	return nil
+}
+
 // opcodeSize pushes the size of the top item of the data stack onto the data
 // stack.
 //
@@ -7,6 +7,8 @@ package txscript
 import (
 	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
 	"github.com/kaotisk-hund/cjdcoind/txscript/scriptnum"
@@ -42,6 +44,48 @@ func fromBool(v bool) []byte {
 type stack struct {
 	stk               [][]byte
 	verifyMinimalData bool
+
+	// numLen is the maximum number of bytes of data interpreted as a
+	// script num by PopInt and PeekInt.  Zero means
+	// scriptnum.DefaultScriptNumLen.
+	numLen int
+}
+
+// SetNumLen sets the maximum number of bytes of data interpreted as a script
+// num by PopInt and PeekInt, such as the 5 bytes used for locktimes.  A length
+// of zero or less restores the default of scriptnum.DefaultScriptNumLen.
+//
+// WARNING: As with scriptnum.MakeScriptNum, great care should be taken when
+// using a length larger than the default, as arithmetic on the resulting
+// numbers could overflow.
+func (s *stack) SetNumLen(numLen int) {
+	if numLen < 0 {
+		numLen = 0
+	}
+	s.numLen = numLen
+}
+
+// scriptNumLen returns the maximum number of bytes of data interpreted as a
+// script num.
+func (s *stack) scriptNumLen() int {
+	if s.numLen == 0 {
+		return scriptnum.DefaultScriptNumLen
+	}
+	return s.numLen
+}
+
+// errInvalidIndex returns an ErrInvalidStackOperation error for an operation
+// on the item at the given index, including the depth of the stack.
+func (s *stack) errInvalidIndex(op string, idx int32) er.R {
+	str := fmt.Sprintf("%s index %d with depth %d", op, idx, s.Depth())
+	return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+}
+
+// errInvalidCount returns an ErrInvalidStackOperation error for an operation
+// on the given number of items, including the depth of the stack.
+func (s *stack) errInvalidCount(op string, n int64) er.R {
+	str := fmt.Sprintf("%s %d items with depth %d", op, n, s.Depth())
+	return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
 }
 
 // Depth returns the number of items on the stack.
@@ -76,6 +120,10 @@ func (s *stack) PushBool(val bool) {
 //
 // Stack transformation: [... x1 x2 x3] -> [... x1 x2]
 func (s *stack) PopByteArray() ([]byte, er.R) {
+	if len(s.stk) == 0 {
+		return nil, s.errInvalidIndex("pop", 0)
+	}
+
 	return s.nipN(0)
 }
 
@@ -90,7 +138,7 @@ func (s *stack) PopInt() (scriptnum.ScriptNum, er.R) {
 		return 0, err
 	}
 
-	return scriptnum.MakeScriptNum(so, s.verifyMinimalData, scriptnum.DefaultScriptNumLen)
+	return scriptnum.MakeScriptNum(so, s.verifyMinimalData, s.scriptNumLen())
 }
 
 // PopBool pops the value off the top of the stack, converts it into a bool, and
@@ -110,14 +158,24 @@ func (s *stack) PopBool() (bool, er.R) {
 func (s *stack) PeekByteArray(idx int32) ([]byte, er.R) {
 	sz := int32(len(s.stk))
 	if idx < 0 || idx >= sz {
-		str := fmt.Sprintf("index %d is invalid for stack size %d", idx,
-			sz)
-		return nil, txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+		return nil, s.errInvalidIndex("peek", idx)
 	}
 
 	return s.stk[sz-idx-1], nil
 }
 
+// PeekByteArrayCopy returns a copy of the Nth item on the stack without
+// removing it.  Unlike PeekByteArray, the returned slice may be modified or
+// pushed back onto the stack without affecting the stack entry.
+func (s *stack) PeekByteArrayCopy(idx int32) ([]byte, er.R) {
+	so, err := s.PeekByteArray(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), so...), nil
+}
+
 // PeekInt returns the Nth item on the stack as a script num without removing
 // it.  The act of converting to a script num enforces the consensus rules
 // imposed on data interpreted as numbers.
@@ -127,7 +185,7 @@ func (s *stack) PeekInt(idx int32) (scriptnum.ScriptNum, er.R) {
 		return 0, err
 	}
 
-	return scriptnum.MakeScriptNum(so, s.verifyMinimalData, scriptnum.DefaultScriptNumLen)
+	return scriptnum.MakeScriptNum(so, s.verifyMinimalData, s.scriptNumLen())
 }
 
 // PeekBool returns the Nth item on the stack as a bool without removing it.
@@ -150,9 +208,7 @@ func (s *stack) PeekBool(idx int32) (bool, er.R) {
 func (s *stack) nipN(idx int32) ([]byte, er.R) {
 	sz := int32(len(s.stk))
 	if idx < 0 || idx > sz-1 {
-		str := fmt.Sprintf("index %d is invalid for stack size %d", idx,
-			sz)
-		return nil, txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+		return nil, s.errInvalidIndex("nip", idx)
 	}
 
 	so := s.stk[sz-idx-1]
@@ -207,9 +263,8 @@ func (s *stack) Tuck() er.R {
 // DropN(1): [... x1 x2] -> [... x1]
 // DropN(2): [... x1 x2] -> [...]
 func (s *stack) DropN(n int32) er.R {
-	if n < 1 {
-		str := fmt.Sprintf("attempt to drop %d items from stack", n)
-		return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+	if n < 1 || n > s.Depth() {
+		return s.errInvalidCount("drop", int64(n))
 	}
 
 	for ; n > 0; n-- {
@@ -227,9 +282,8 @@ func (s *stack) DropN(n int32) er.R {
 // DupN(1): [... x1 x2] -> [... x1 x2 x2]
 // DupN(2): [... x1 x2] -> [... x1 x2 x1 x2]
 func (s *stack) DupN(n int32) er.R {
-	if n < 1 {
-		str := fmt.Sprintf("attempt to dup %d stack items", n)
-		return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+	if n < 1 || n > s.Depth() {
+		return s.errInvalidCount("dup", int64(n))
 	}
 
 	// Iteratively duplicate the value n-1 down the stack n times.
@@ -250,9 +304,8 @@ func (s *stack) DupN(n int32) er.R {
 // RotN(1): [... x1 x2 x3] -> [... x2 x3 x1]
 // RotN(2): [... x1 x2 x3 x4 x5 x6] -> [... x3 x4 x5 x6 x1 x2]
 func (s *stack) RotN(n int32) er.R {
-	if n < 1 {
-		str := fmt.Sprintf("attempt to rotate %d stack items", n)
-		return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+	if n < 1 || n > s.Depth()/3 {
+		return s.errInvalidCount("rotate", 3*int64(n))
 	}
 
 	// Nip the 3n-1th item from the stack to the top n times to rotate
@@ -275,9 +328,8 @@ func (s *stack) RotN(n int32) er.R {
 // SwapN(1): [... x1 x2] -> [... x2 x1]
 // SwapN(2): [... x1 x2 x3 x4] -> [... x3 x4 x1 x2]
 func (s *stack) SwapN(n int32) er.R {
-	if n < 1 {
-		str := fmt.Sprintf("attempt to swap %d stack items", n)
-		return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+	if n < 1 || n > s.Depth()/2 {
+		return s.errInvalidCount("swap", 2*int64(n))
 	}
 
 	entry := 2*n - 1
@@ -299,10 +351,8 @@ func (s *stack) SwapN(n int32) er.R {
 // OverN(1): [... x1 x2 x3] -> [... x1 x2 x3 x2]
 // OverN(2): [... x1 x2 x3 x4] -> [... x1 x2 x3 x4 x1 x2]
 func (s *stack) OverN(n int32) er.R {
-	if n < 1 {
-		str := fmt.Sprintf("attempt to perform over on %d stack items",
-			n)
-		return txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, str)
+	if n < 1 || n > s.Depth()/2 {
+		return s.errInvalidCount("over", 2*int64(n))
 	}
 
 	// Copy 2n-1th entry to top of the stack.
@@ -351,15 +401,100 @@ func (s *stack) RollN(n int32) er.R {
 	return nil
 }
 
-// String returns the stack in a readable format.
+// StackSnapshot is a copy of the contents of a stack, which can be restored
+// later on.
+type StackSnapshot struct {
+	stk [][]byte
+}
+
+// copyStackItems returns a deep copy of the given stack items.
+func copyStackItems(items [][]byte) [][]byte {
+	if items == nil {
+		return nil
+	}
+
+	c := make([][]byte, len(items))
+	for i, item := range items {
+		c[i] = append([]byte(nil), item...)
+	}
+	return c
+}
+
+// Snapshot returns a copy of the contents of the stack.  The items are deep
+// copied, so later modifications of the stack don't affect the snapshot.
+func (s *stack) Snapshot() StackSnapshot {
+	return StackSnapshot{stk: copyStackItems(s.stk)}
+}
+
+// Restore replaces the contents of the stack with those of the snapshot.  The
+// items are deep copied again, so the same snapshot can be restored any number
+// of times.
+func (s *stack) Restore(snapshot StackSnapshot) {
+	// Reuse the backing array of the stack to avoid reallocating it.
+	s.stk = s.stk[:0]
+	for _, item := range snapshot.stk {
+		s.stk = append(s.stk, append([]byte(nil), item...))
+	}
+}
+
+// String returns the stack in a readable format, with one line per item from
+// the top of the stack down.  Each item is hex encoded and prefixed with its
+// index as used by PeekByteArray, and the top item is marked.  For example:
+//
+//	0: 0102 (top)
+//	1: <empty>
+//	2: ff
+//
+// An empty stack results in an empty string.
 func (s *stack) String() string {
-	var result string
-	for _, stack := range s.stk {
-		if len(stack) == 0 {
-			result += "00000000  <empty>\n"
+	const (
+		topMarker   = " (top)"
+		emptyMarker = "<empty>"
+	)
+
+	if len(s.stk) == 0 {
+		return ""
+	}
+
+	// Compute the size of the result up front, so that it is built in a
+	// single allocation even for large stacks.
+	var numBuf [20]byte
+	size := len(topMarker)
+	for i, item := range s.stk {
+		idx := strconv.AppendInt(numBuf[:0], int64(len(s.stk)-1-i), 10)
+		size += len(idx) + len(": \n")
+		if len(item) == 0 {
+			size += len(emptyMarker)
+		} else {
+			size += hex.EncodedLen(len(item))
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(size)
+	var hexBuf [64]byte
+	for i := len(s.stk) - 1; i >= 0; i-- {
+		item := s.stk[i]
+
+		b.Write(strconv.AppendInt(numBuf[:0], int64(len(s.stk)-1-i), 10))
+		b.WriteString(": ")
+		if len(item) == 0 {
+			b.WriteString(emptyMarker)
+		}
+		for len(item) > 0 {
+			n := len(item)
+			if n > len(hexBuf)/2 {
+				n = len(hexBuf) / 2
+			}
+			hex.Encode(hexBuf[:], item[:n])
+			b.Write(hexBuf[:hex.EncodedLen(n)])
+			item = item[n:]
+		}
+		if i == len(s.stk)-1 {
+			b.WriteString(topMarker)
 		}
-		result += hex.Dump(stack)
+		b.WriteByte('\n')
 	}
 
-	return result
+	return b.String()
 }
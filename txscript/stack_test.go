@@ -7,6 +7,8 @@ package txscript
 import (
 	"bytes"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/kaotisk-hund/cjdcoind/btcutil/er"
@@ -16,7 +18,8 @@ import (
 
 // tstCheckScriptError ensures the type of the two passed errors are of the
 // same type (either both nil or both of type Error) and their error codes
-// match when not nil.
+// match when not nil.  If the wanted error has a description, the description
+// of the error must match as well, otherwise any description is accepted.
 func tstCheckScriptError(gotErr, wantErr er.R) er.R {
 
 	// Ensure the want error type is a script error.
@@ -30,6 +33,15 @@ func tstCheckScriptError(gotErr, wantErr er.R) er.R {
 		return er.Errorf("wrong error - got %T (%[1]v), want %T",
 			gotErr, wantErr)
 	}
+
+	// The message of an error with a description is the error code
+	// followed by ": " and the description.
+	if wantErr != nil && strings.Contains(wantErr.Message(), ": ") &&
+		gotErr.Message() != wantErr.Message() {
+
+		return er.Errorf("wrong error description - got %q, want %q",
+			gotErr.Message(), wantErr.Message())
+	}
 	return nil
 }
 
@@ -59,7 +71,8 @@ func TestStack(t *testing.T) {
 				_, err := s.PeekByteArray(5)
 				return err
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"peek index 5 with depth 5"),
 			nil,
 		},
 		{
@@ -69,7 +82,8 @@ func TestStack(t *testing.T) {
 				_, err := s.PeekInt(5)
 				return err
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"peek index 5 with depth 5"),
 			nil,
 		},
 		{
@@ -79,7 +93,8 @@ func TestStack(t *testing.T) {
 				_, err := s.PeekBool(5)
 				return err
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"peek index 5 with depth 5"),
 			nil,
 		},
 		{
@@ -125,7 +140,8 @@ func TestStack(t *testing.T) {
 				}
 				return nil
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"pop index 0 with depth 0"),
 			nil,
 		},
 		{
@@ -169,7 +185,8 @@ func TestStack(t *testing.T) {
 				_, err := s.PopBool()
 				return err
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"pop index 0 with depth 0"),
 			nil,
 		},
 		{
@@ -391,7 +408,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.DupN(0)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"dup 0 items with depth 1"),
 			nil,
 		},
 		{
@@ -400,7 +418,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.DupN(-1)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"dup -1 items with depth 1"),
 			nil,
 		},
 		{
@@ -409,7 +428,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.DupN(2)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"dup 2 items with depth 1"),
 			nil,
 		},
 		{
@@ -540,7 +560,8 @@ func TestStack(t *testing.T) {
 				// bite off more than we can chew
 				return s.NipN(3)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"nip index 3 with depth 3"),
 			[][]byte{{2}, {3}},
 		},
 		{
@@ -558,7 +579,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.Tuck()
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"pop index 0 with depth 0"),
 			nil,
 		},
 		{
@@ -567,7 +589,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.Tuck()
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"pop index 0 with depth 0"),
 			nil,
 		},
 		{
@@ -612,7 +635,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.DropN(5)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"drop 5 items with depth 4"),
 			nil,
 		},
 		{
@@ -621,7 +645,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.DropN(0)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"drop 0 items with depth 4"),
 			nil,
 		},
 		{
@@ -648,7 +673,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.RotN(1)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"rotate 3 items with depth 2"),
 			nil,
 		},
 		{
@@ -657,7 +683,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.RotN(0)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"rotate 0 items with depth 3"),
 			nil,
 		},
 		{
@@ -684,7 +711,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.SwapN(1)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"swap 2 items with depth 1"),
 			nil,
 		},
 		{
@@ -693,7 +721,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.SwapN(0)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"swap 0 items with depth 3"),
 			nil,
 		},
 		{
@@ -720,7 +749,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.OverN(1)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"over 2 items with depth 1"),
 			nil,
 		},
 		{
@@ -729,7 +759,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.OverN(0)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"over 0 items with depth 3"),
 			nil,
 		},
 		{
@@ -756,7 +787,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.PickN(1)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"peek index 1 with depth 1"),
 			nil,
 		},
 		{
@@ -783,7 +815,8 @@ func TestStack(t *testing.T) {
 			func(s *stack) er.R {
 				return s.RollN(1)
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"nip index 1 with depth 1"),
 			nil,
 		},
 		{
@@ -877,6 +910,105 @@ func TestStack(t *testing.T) {
 			nil,
 			nil,
 		},
+		{
+			"peek copy underflow",
+			[][]byte{{1}, {2}, {3}, {4}, {5}},
+			func(s *stack) er.R {
+				_, err := s.PeekByteArrayCopy(5)
+				return err
+			},
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"peek index 5 with depth 5"),
+			nil,
+		},
+		// Confirm that modifying a peeked copy doesn't modify the stack.
+		{
+			"peek copy nomodify",
+			[][]byte{{1}, {2, 3}},
+			func(s *stack) er.R {
+				so, err := s.PeekByteArrayCopy(0)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(so, []byte{2, 3}) {
+					return er.Errorf("unexpected peeked "+
+						"value %v", so)
+				}
+				so[0] = 0xff
+				return nil
+			},
+			nil,
+			[][]byte{{1}, {2, 3}},
+		},
+		// A 5-byte number exceeds the default limit.
+		{
+			"popInt 5 bytes default",
+			[][]byte{{0xff, 0xff, 0xff, 0xff, 0x00}},
+			func(s *stack) er.R {
+				_, err := s.PopInt()
+				return err
+			},
+			txscripterr.ScriptError(txscripterr.ErrNumberTooBig, ""),
+			nil,
+		},
+		{
+			"popInt 5 bytes extended",
+			[][]byte{{0xff, 0xff, 0xff, 0xff, 0x00}},
+			func(s *stack) er.R {
+				s.SetNumLen(5)
+				v, err := s.PopInt()
+				if err != nil {
+					return err
+				}
+				if v != 0xffffffff {
+					return er.Errorf("%v != %v on popInt",
+						v, 0xffffffff)
+				}
+				return nil
+			},
+			nil,
+			nil,
+		},
+		{
+			"peekInt 5 bytes extended",
+			[][]byte{{0x00, 0x00, 0x00, 0x00, 0x80}},
+			func(s *stack) er.R {
+				s.SetNumLen(5)
+				v, err := s.PeekInt(0)
+				if err != nil {
+					return err
+				}
+				if v != 0 {
+					return er.Errorf("%v != 0 on peekInt", v)
+				}
+				return nil
+			},
+			nil,
+			[][]byte{{0x00, 0x00, 0x00, 0x00, 0x80}},
+		},
+		{
+			"peekInt 5 bytes reset",
+			[][]byte{{0xff, 0xff, 0xff, 0xff, 0x00}},
+			func(s *stack) er.R {
+				s.SetNumLen(5)
+				s.SetNumLen(0)
+				_, err := s.PeekInt(0)
+				return err
+			},
+			txscripterr.ScriptError(txscripterr.ErrNumberTooBig, ""),
+			nil,
+		},
+		{
+			"peekInt 6 bytes extended",
+			[][]byte{{0xff, 0xff, 0xff, 0xff, 0xff, 0x00}},
+			func(s *stack) er.R {
+				s.SetNumLen(5)
+				_, err := s.PeekInt(0)
+				return err
+			},
+			txscripterr.ScriptError(txscripterr.ErrNumberTooBig, ""),
+			nil,
+		},
 		{
 			"pop empty",
 			nil,
@@ -886,7 +1018,8 @@ func TestStack(t *testing.T) {
 				_, err := s.PopInt()
 				return err
 			},
-			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation, ""),
+			txscripterr.ScriptError(txscripterr.ErrInvalidStackOperation,
+				"pop index 0 with depth 0"),
 			nil,
 		},
 	}
@@ -936,3 +1069,78 @@ func TestStack(t *testing.T) {
 		}
 	}
 }
+
+// TestStackSnapshot tests that a snapshot isn't affected by modifications of
+// the stack it was taken from, and that it can be restored repeatedly.
+func TestStackSnapshot(t *testing.T) {
+	s := stack{}
+	s.PushByteArray([]byte{1})
+	s.PushByteArray([]byte{2, 3})
+	expected := [][]byte{{1}, {2, 3}}
+
+	snapshot := s.Snapshot()
+
+	// Modify an item in place, and change the stack itself.
+	so, err := s.PeekByteArray(0)
+	if err != nil {
+		t.Fatalf("unable to peek: %v", err)
+	}
+	so[0] = 0xff
+	if _, err := s.PopByteArray(); err != nil {
+		t.Fatalf("unable to pop: %v", err)
+	}
+	s.PushByteArray([]byte{4})
+	s.PushByteArray([]byte{5})
+
+	for i := 0; i < 2; i++ {
+		s.Restore(snapshot)
+
+		stk := getStack(&s)
+		if !reflect.DeepEqual(stk, expected) {
+			t.Fatalf("restore %d: expected stack %v, got %v", i,
+				expected, stk)
+		}
+
+		// Modifying the restored stack must not affect the
+		// snapshot.
+		so, err := s.PeekByteArray(1)
+		if err != nil {
+			t.Fatalf("unable to peek: %v", err)
+		}
+		so[0] = 0xff
+	}
+}
+
+// TestStackString tests the readable format of a stack.
+func TestStackString(t *testing.T) {
+	s := stack{}
+	if str := s.String(); str != "" {
+		t.Fatalf("expected empty string for empty stack, got %q", str)
+	}
+
+	s.PushByteArray([]byte{0xff})
+	s.PushByteArray(nil)
+	s.PushByteArray(bytes.Repeat([]byte{0xab}, 40))
+	s.PushByteArray([]byte{1, 2})
+
+	expected := "0: 0102 (top)\n" +
+		"1: " + strings.Repeat("ab", 40) + "\n" +
+		"2: <empty>\n" +
+		"3: ff\n"
+	if str := s.String(); str != expected {
+		t.Fatalf("unexpected stack string:\nwant: %q\ngot:  %q",
+			expected, str)
+	}
+
+	// The string should be built in a single allocation, regardless of
+	// the size of the stack.
+	for i := 0; i < 200; i++ {
+		s.PushByteArray([]byte{byte(i)})
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		_ = s.String()
+	})
+	if allocs > 1 {
+		t.Fatalf("expected a single allocation, got %v", allocs)
+	}
+}